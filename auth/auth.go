@@ -0,0 +1,294 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const ApiKeyHeader = "x-api-key"
+
+// ApiKeyQuery is also accepted on the stream routes, browsers can't set headers on EventSource and WebSocket
+const ApiKeyQuery = "apiKey"
+
+// the context key of the id of the api key of a request
+const keyIDContext = "apiKeyId"
+
+// keys read from the db are cached for this long, a revoke done by another instance takes up to it to apply
+const keyCacheTime = time.Minute
+
+// above this many cached keys the expired ones are swept, and unknown keys are no longer cached
+const maxCachedKeys = 10000
+
+// paths with their own authentication, the api docs and the probes and metrics of the instance
+var exemptPrefixes = []string{"/admin", "/openapi.json", "/docs", "/healthz", "/readyz", "/metrics"}
+
+type cachedKey struct {
+	key     *types.ApiKeyDoc
+	expires time.Time
+}
+
+// Authenticator checks the x-api-key header of requests against the keys of the config and the db,
+// and rate limits every key, or every client ip for requests without a key.
+type Authenticator struct {
+	readDB        database.ReadDB
+	requireKey    bool
+	defaultLimits Limits
+	anonymous     Limits
+	static        map[string]*types.ApiKeyDoc
+	limiter       *limiter
+	networks      []string
+
+	mu   sync.Mutex
+	keys map[string]*cachedKey
+}
+
+func NewAuthenticator(authConfig *config.AuthConfig, readDB database.ReadDB) *Authenticator {
+	a := &Authenticator{
+		readDB:        readDB,
+		requireKey:    authConfig.RequireKey,
+		defaultLimits: limits(authConfig.Default),
+		anonymous:     limits(authConfig.Anonymous),
+		static:        make(map[string]*types.ApiKeyDoc),
+		limiter:       newLimiter(),
+		keys:          make(map[string]*cachedKey),
+	}
+	for _, key := range authConfig.Keys {
+		id := HashKey(key.Key)
+		a.static[id] = &types.ApiKeyDoc{
+			ID:         id,
+			Name:       key.Name,
+			Rate:       key.Rate,
+			Burst:      key.Burst,
+			DailyQuota: key.DailyQuota,
+		}
+	}
+	slog.Info("Created authenticator", "configKeys", len(a.static))
+	return a
+}
+
+// Networks sets the names of the networks, their routes are also served under /{network}.
+func (a *Authenticator) Networks(names []string) {
+	a.networks = names
+}
+
+// path returns the path of a request without its network prefix.
+func (a *Authenticator) path(path string) string {
+	for _, name := range a.networks {
+		if rest, ok := strings.CutPrefix(path, "/"+name); ok && strings.HasPrefix(rest, "/") {
+			return rest
+		}
+	}
+	return path
+}
+
+func limits(rateLimit *config.RateLimitConfig) Limits {
+	if rateLimit == nil {
+		return Limits{}
+	}
+	return Limits{
+		Rate:       rateLimit.Rate,
+		Burst:      rateLimit.Burst,
+		DailyQuota: rateLimit.DailyQuota,
+	}
+}
+
+// HashKey returns the id of a key, the sha256 stored instead of the key.
+func HashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// NewKey returns a new random key.
+func NewKey() (string, error) {
+	key := make([]byte, 24)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "smk_" + hex.EncodeToString(key), nil
+}
+
+// IssueKey creates a key and saves its hash, the key is only returned here.
+func IssueKey(writeDB database.WriteDB, request *types.ApiKeyRequest) (*types.ApiKey, error) {
+	key, err := NewKey()
+	if err != nil {
+		return nil, err
+	}
+	doc := &types.ApiKeyDoc{
+		ID:         HashKey(key),
+		Name:       request.Name,
+		Rate:       request.Rate,
+		Burst:      request.Burst,
+		DailyQuota: request.DailyQuota,
+		CreatedAt:  time.Now().UnixMilli(),
+	}
+	if err := writeDB.SaveApiKey(doc); err != nil {
+		return nil, err
+	}
+	apiKey := ApiKeyResponse(doc)
+	apiKey.Key = key
+	return apiKey, nil
+}
+
+func ApiKeyResponse(doc *types.ApiKeyDoc) *types.ApiKey {
+	return &types.ApiKey{
+		ID:         doc.ID,
+		Name:       doc.Name,
+		Rate:       doc.Rate,
+		Burst:      doc.Burst,
+		DailyQuota: doc.DailyQuota,
+		CreatedAt:  doc.CreatedAt,
+		RevokedAt:  doc.RevokedAt,
+	}
+}
+
+// KeyID returns the id of the api key the request was authenticated with, empty without one.
+func KeyID(c *gin.Context) string {
+	return c.GetString(keyIDContext)
+}
+
+// Forget drops a key from the cache and its limits, used after it is revoked.
+func (a *Authenticator) Forget(id string) {
+	a.mu.Lock()
+	delete(a.keys, id)
+	a.mu.Unlock()
+	a.limiter.forget("key:" + id)
+}
+
+func (a *Authenticator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := a.path(c.Request.URL.Path)
+		for _, prefix := range exemptPrefixes {
+			if strings.HasPrefix(path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		client := "ip:" + c.ClientIP()
+		clientLimits := a.anonymous
+		apiKey := c.GetHeader(ApiKeyHeader)
+		if apiKey == "" && strings.HasPrefix(path, "/stream") {
+			apiKey = c.Query(ApiKeyQuery)
+		}
+		if apiKey != "" {
+			id := HashKey(apiKey)
+			key, ok := a.cached(id)
+			if !ok || key == nil {
+				// unknown keys are charged to the client ip before the db lookup, so guessing keys is rate limited
+				if !a.allow(c, client, clientLimits) {
+					return
+				}
+			}
+			if !ok {
+				var err error
+				key, err = a.key(id)
+				if err != nil {
+					slog.Error("Failed to get api key", "error", err)
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+						"status": "Internal Error",
+						"error":  "Failed to check api key",
+					})
+					return
+				}
+			}
+			if key == nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": "invalid api key",
+				})
+				return
+			}
+			client = "key:" + key.ID
+			c.Set(keyIDContext, key.ID)
+			clientLimits = a.keyLimits(key)
+		} else if a.requireKey {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "missing api key, send it in the " + ApiKeyHeader + " header",
+			})
+			return
+		}
+
+		if !a.allow(c, client, clientLimits) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// allow takes a request from the client limits, it aborts the request when it is limited.
+func (a *Authenticator) allow(c *gin.Context, client string, clientLimits Limits) bool {
+	allowed, retryAfter := a.limiter.allow(client, clientLimits, time.Now())
+	if !allowed {
+		c.Header("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+			"error": "rate limit exceeded",
+		})
+	}
+	return allowed
+}
+
+// cached returns the key with id when it is a config key or a cached one, ok is false when it needs a db lookup.
+func (a *Authenticator) cached(id string) (*types.ApiKeyDoc, bool) {
+	if key, ok := a.static[id]; ok {
+		return key, true
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cached, ok := a.keys[id]
+	if !ok || !time.Now().Before(cached.expires) {
+		return nil, false
+	}
+	return cached.key, true
+}
+
+// key reads the key with id from the db and caches it, nil if there is no active one.
+func (a *Authenticator) key(id string) (*types.ApiKeyDoc, error) {
+	now := time.Now()
+	key, err := a.readDB.GetApiKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.ID == "" || key.RevokedAt != 0 {
+		key = nil
+	}
+	a.mu.Lock()
+	if len(a.keys) >= maxCachedKeys {
+		for cachedID, cached := range a.keys {
+			if !now.Before(cached.expires) {
+				delete(a.keys, cachedID)
+			}
+		}
+	}
+	if key != nil || len(a.keys) < maxCachedKeys {
+		a.keys[id] = &cachedKey{key: key, expires: now.Add(keyCacheTime)}
+	}
+	a.mu.Unlock()
+	return key, nil
+}
+
+// keyLimits returns the limits of a key, the default ones for the limits it doesn't set.
+func (a *Authenticator) keyLimits(key *types.ApiKeyDoc) Limits {
+	keyLimits := a.defaultLimits
+	if key.Rate > 0 {
+		keyLimits.Rate = key.Rate
+	}
+	if key.Burst > 0 {
+		keyLimits.Burst = key.Burst
+	}
+	if key.DailyQuota > 0 {
+		keyLimits.DailyQuota = key.DailyQuota
+	}
+	return keyLimits
+}
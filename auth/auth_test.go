@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// keysDB serves the api keys of the test, the other reads aren't used by the authenticator
+type keysDB struct {
+	database.ReadDB
+	keys    map[string]*types.ApiKeyDoc
+	lookups int
+}
+
+func (db *keysDB) GetApiKey(id string) (*types.ApiKeyDoc, error) {
+	db.lookups++
+	if key, ok := db.keys[id]; ok {
+		return key, nil
+	}
+	return &types.ApiKeyDoc{}, nil
+}
+
+func testRouter(a *Authenticator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(a.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	for _, prefix := range []string{"", "/mainnet"} {
+		router.GET(prefix+"/admin/reconcile", ok)
+		router.GET(prefix+"/stream", ok)
+		router.GET(prefix+"/layers", ok)
+	}
+	return router
+}
+
+func TestMiddlewareNetworkPrefix(t *testing.T) {
+	a := NewAuthenticator(&config.AuthConfig{
+		Enabled:    true,
+		RequireKey: true,
+		Keys:       []*config.ApiKeyConfig{{Key: "k1", Name: "test"}},
+	}, nil)
+	a.Networks([]string{"mainnet"})
+	router := testRouter(a)
+
+	tests := []struct {
+		path   string
+		header string
+		status int
+	}{
+		{"/admin/reconcile", "", http.StatusOK},
+		{"/mainnet/admin/reconcile", "", http.StatusOK},
+		{"/stream?apiKey=k1", "", http.StatusOK},
+		{"/mainnet/stream?apiKey=k1", "", http.StatusOK},
+		{"/mainnet/stream", "", http.StatusUnauthorized},
+		{"/mainnet/layers?apiKey=k1", "", http.StatusUnauthorized},
+		{"/mainnet/layers", "k1", http.StatusOK},
+		{"/mainnetadmin/reconcile", "", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.header != "" {
+			req.Header.Set(ApiKeyHeader, test.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("GET %s: status %d, want %d", test.path, w.Code, test.status)
+		}
+	}
+}
+
+func TestMiddlewareLimitsInvalidKeys(t *testing.T) {
+	db := &keysDB{keys: map[string]*types.ApiKeyDoc{HashKey("k1"): {ID: HashKey("k1"), Name: "test"}}}
+	a := NewAuthenticator(&config.AuthConfig{
+		Enabled:   true,
+		Anonymous: &config.RateLimitConfig{Rate: 0.001, Burst: 2},
+	}, db)
+	router := testRouter(a)
+
+	get := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/layers", nil)
+		req.Header.Set(ApiKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	if status := get("bad1"); status != http.StatusUnauthorized {
+		t.Fatalf("first invalid key: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := get("bad2"); status != http.StatusUnauthorized {
+		t.Fatalf("second invalid key: status %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status := get("bad3"); status != http.StatusTooManyRequests {
+		t.Fatalf("third invalid key: status %d, want %d", status, http.StatusTooManyRequests)
+	}
+	if db.lookups != 2 {
+		t.Errorf("db lookups %d, want 2", db.lookups)
+	}
+	// a valid key isn't limited by the client ip once it is cached
+	a.keys[HashKey("k1")] = &cachedKey{key: db.keys[HashKey("k1")], expires: time.Now().Add(keyCacheTime)}
+	if status := get("k1"); status != http.StatusOK {
+		t.Errorf("valid key: status %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestKeyCacheBound(t *testing.T) {
+	db := &keysDB{keys: map[string]*types.ApiKeyDoc{HashKey("k1"): {ID: HashKey("k1"), Name: "test"}}}
+	a := NewAuthenticator(&config.AuthConfig{Enabled: true}, db)
+
+	for i := 0; i < maxCachedKeys+100; i++ {
+		if _, err := a.key(HashKey("bad" + strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.keys) != maxCachedKeys {
+		t.Fatalf("cached keys %d, want %d", len(a.keys), maxCachedKeys)
+	}
+	// valid keys are still cached when the cache is full
+	if key, err := a.key(HashKey("k1")); err != nil || key == nil {
+		t.Fatalf("valid key: %v, %v", key, err)
+	}
+	if _, ok := a.cached(HashKey("k1")); !ok {
+		t.Error("valid key not cached")
+	}
+
+	// expired entries are swept on the next lookup
+	for _, cached := range a.keys {
+		cached.expires = time.Now().Add(-time.Second)
+	}
+	if _, err := a.key(HashKey("bad")); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.keys) != 1 {
+		t.Errorf("cached keys after sweep %d, want 1", len(a.keys))
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// clients idle for longer than this are dropped, their bucket is full again by then
+const idleClient = time.Hour
+
+type Limits struct {
+	// Rate is the number of requests per second refilled in the bucket, 0 for no rate limit
+	Rate float64
+	// Burst is the size of the bucket, at least 1
+	Burst int64
+	// DailyQuota is the number of requests per UTC day, 0 for no quota
+	DailyQuota int64
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	day     int64
+	used    int64
+}
+
+// limiter applies a token bucket and a daily quota per client. The counts are only kept in memory, every
+// instance limits the clients on its own and the quota used today is lost on a restart.
+type limiter struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+}
+
+func newLimiter() *limiter {
+	return &limiter{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a request from the client limits, when it is limited it returns how long to wait.
+func (l *limiter) allow(client string, limits Limits, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.cleanup(now)
+
+	burst := float64(max(limits.Burst, 1))
+	b, ok := l.buckets[client]
+	if !ok {
+		b = &bucket{tokens: burst, updated: now}
+		l.buckets[client] = b
+	}
+
+	if limits.Rate > 0 {
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*limits.Rate)
+	}
+	b.updated = now
+
+	day := now.Unix() / 86400
+	if b.day != day {
+		b.day = day
+		b.used = 0
+	}
+	if limits.DailyQuota > 0 && b.used >= limits.DailyQuota {
+		return false, time.Unix((day+1)*86400, 0).Sub(now)
+	}
+	if limits.Rate > 0 {
+		if b.tokens < 1 {
+			return false, time.Duration((1 - b.tokens) / limits.Rate * float64(time.Second))
+		}
+		b.tokens--
+	}
+	b.used++
+	return true, 0
+}
+
+func (l *limiter) forget(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, client)
+}
+
+func (l *limiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < idleClient {
+		return
+	}
+	l.lastCleanup = now
+	day := now.Unix() / 86400
+	for client, b := range l.buckets {
+		// keep the clients that used some quota today
+		if now.Sub(b.updated) > idleClient && (b.day != day || b.used == 0) {
+			delete(l.buckets, client)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailyQuotaRollover(t *testing.T) {
+	l := newLimiter()
+	limits := Limits{DailyQuota: 2}
+	beforeMidnight := time.Date(2024, 3, 10, 23, 59, 59, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := l.allow("key:k1", limits, beforeMidnight); !allowed {
+			t.Fatalf("request %d limited before the quota is used", i)
+		}
+	}
+	allowed, retryAfter := l.allow("key:k1", limits, beforeMidnight)
+	if allowed || retryAfter != time.Second {
+		t.Fatalf("over quota allowed %t, retry after %s, want limited for 1s until midnight", allowed, retryAfter)
+	}
+	// another client has its own quota
+	if allowed, _ := l.allow("key:k2", limits, beforeMidnight); !allowed {
+		t.Error("other client limited by the quota of k1")
+	}
+
+	// the quota starts over at midnight utc, whatever the local time zone of the request time
+	midnight := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC).In(time.FixedZone("UTC-5", -5*3600))
+	for i := 0; i < 2; i++ {
+		if allowed, _ := l.allow("key:k1", limits, midnight); !allowed {
+			t.Fatalf("request %d of the next day limited", i)
+		}
+	}
+	allowed, retryAfter = l.allow("key:k1", limits, midnight.Add(time.Hour))
+	if allowed || retryAfter != 23*time.Hour {
+		t.Errorf("over the quota of the next day allowed %t, retry after %s, want limited for 23h", allowed, retryAfter)
+	}
+}
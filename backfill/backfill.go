@@ -0,0 +1,55 @@
+package backfill
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+)
+
+// Record kinds, they match the nats streams consumed by the sink.
+const (
+	KindLayer              = "layer"
+	KindAtx                = "atx"
+	KindReward             = "reward"
+	KindTransactionCreated = "transaction-created"
+	KindTransactionResult  = "transaction-result"
+	KindMalfeasance        = "malfeasance"
+)
+
+var Kinds = []string{KindLayer, KindAtx, KindReward, KindTransactionCreated, KindTransactionResult, KindMalfeasance}
+
+// Backfill replays historical records through the same WriteDB.Save* paths used by the nats sink,
+// so a database can be rebuilt without consuming the streams from the start.
+type Backfill struct {
+	WriteDB database.WriteDB
+	// network of the records, the rewards of a state are matched to the atxs of their epoch
+	network         *config.NetworkConfig
+	checkpoint      *Checkpoint
+	continueOnError bool
+}
+
+func NewBackfill(network *config.NetworkConfig, writeDB database.WriteDB, checkpoint *Checkpoint, continueOnError bool) *Backfill {
+	return &Backfill{
+		WriteDB:         writeDB,
+		network:         network,
+		checkpoint:      checkpoint,
+		continueOnError: continueOnError,
+	}
+}
+
+// save replays a single record. A failed record stops the backfill, leaving the checkpoint
+// before it, unless continueOnError is set in which case it is logged and skipped.
+func (b *Backfill) save(p *progress, id string, save func() error) error {
+	err := save()
+	p.add(err == nil)
+	if err == nil {
+		return nil
+	}
+	if b.continueOnError {
+		slog.Warn("Backfill skipped record", "kind", p.kind, "id", id, "error", err)
+		return nil
+	}
+	return fmt.Errorf("failed to save %s %s: %w", p.kind, id, err)
+}
@@ -0,0 +1,203 @@
+package backfill
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/sql"
+	"github.com/spacemeshos/go-spacemesh/sql/atxs"
+	"github.com/spacemeshos/go-spacemesh/sql/layers"
+	"github.com/spacemeshos/go-spacemesh/sql/rewards"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+)
+
+const coinbase = "sm1qqqqqq8y5rupfpqzvw4n5mcfcld5aa8utsp3agc2hryuj"
+
+func reward(id string, amount uint64) string {
+	return fmt.Sprintf(`{"id":"%s","layer":10,"totalReward":%d,"layerReward":%d,"coinbase":"%s","atxID":"a1","nodeID":"n1"}`,
+		id, amount, amount, coinbase)
+}
+
+// testBackfill writes the lines of a dump and replays it into a new sqlite db, with a checkpoint next to the dump.
+func testBackfill(t *testing.T, dump string, lines []string, continueOnError bool) (*Backfill, *database.SQLiteDB) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(dump, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.NewSQLiteDB("file:"+filepath.Join(dir, "backfill.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	checkpoint, err := LoadCheckpoint(dump+".checkpoint", dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBackfill(config.Mainnet(), db, checkpoint, continueOnError), db
+}
+
+func balance(t *testing.T, db *database.SQLiteDB) uint64 {
+	t.Helper()
+	account, err := db.GetAccount(coinbase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account.Balance
+}
+
+func TestReplayDump(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "rewards.ndjson")
+	b, db := testBackfill(t, dump, []string{reward("r1", 100), "", reward("r2", 200), ""}, false)
+	if err := b.ReplayDump(dump, KindReward); err != nil {
+		t.Fatal(err)
+	}
+	if balance := balance(t, db); balance != 300 {
+		t.Errorf("balance %d, want 300", balance)
+	}
+	checkpoint, err := LoadCheckpoint(dump+".checkpoint", dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position := checkpoint.Position(KindReward); position != 2 {
+		t.Errorf("checkpoint at line %d, want 2", position)
+	}
+
+	// the dump of another kind starts from its own position, an unknown kind is rejected
+	if err := b.ReplayDump(dump, "rewards"); err == nil {
+		t.Error("replayed an unknown kind")
+	}
+	if _, err := LoadCheckpoint(dump+".checkpoint", "other.ndjson"); err == nil {
+		t.Error("loaded the checkpoint of another dump")
+	}
+}
+
+func TestReplayDumpResumes(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "rewards.ndjson")
+	b, db := testBackfill(t, dump, []string{reward("r1", 100), "{", reward("r3", 300)}, false)
+
+	// a malformed line stops the backfill with the checkpoint before it
+	if err := b.ReplayDump(dump, KindReward); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("error %v, want one of line 2", err)
+	}
+	if position := b.checkpoint.Position(KindReward); position != 0 {
+		t.Errorf("checkpoint at line %d after the failure, want 0", position)
+	}
+	if balance := balance(t, db); balance != 100 {
+		t.Errorf("balance %d before the failure, want 100", balance)
+	}
+
+	// resumed from line 1, the fixed line 2 is replayed and line 1 isn't again
+	if err := os.WriteFile(dump, []byte(reward("r1", 100)+"\n"+reward("r2", 200)+"\n"+reward("r3", 300)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.checkpoint.Set(KindReward, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ReplayDump(dump, KindReward); err != nil {
+		t.Fatal(err)
+	}
+	if balance := balance(t, db); balance != 600 {
+		t.Errorf("balance %d after the resume, want 600", balance)
+	}
+}
+
+func TestReplayDumpContinueOnError(t *testing.T) {
+	dump := filepath.Join(t.TempDir(), "rewards.ndjson")
+	b, db := testBackfill(t, dump, []string{reward("r1", 100), "{", reward("r3", 300)}, true)
+	if err := b.ReplayDump(dump, KindReward); err != nil {
+		t.Fatal(err)
+	}
+	if balance := balance(t, db); balance != 400 {
+		t.Errorf("balance %d, want 400 without the malformed line", balance)
+	}
+	if position := b.checkpoint.Position(KindReward); position != 3 {
+		t.Errorf("checkpoint at line %d, want 3", position)
+	}
+}
+
+// testState writes a go-spacemesh state.sql with the atx of a smesher in epoch 1, its rewards in layers 8 and 9
+// and a reward in layer 10 of a smesher without atx.
+func testState(t *testing.T) (string, sTypes.ATXID, sTypes.Address) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "state.sql")
+	migrations, err := sql.StateMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("file:"+path, sql.WithMigrations(migrations))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	atxID := sTypes.ATXID{1}
+	nodeID := sTypes.NodeID{2}
+	address := sTypes.Address{3}
+	err = atxs.AddCheckpointed(db, &atxs.CheckpointAtx{ID: atxID, Epoch: 1, NumUnits: 4, SmesherID: nodeID, Coinbase: address})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range []sTypes.LayerID{8, 9} {
+		if err := rewards.Add(db, &sTypes.Reward{Layer: layer, SmesherID: nodeID, Coinbase: address, TotalReward: 100, LayerReward: 90}); err != nil {
+			t.Fatal(err)
+		}
+		if err := layers.SetApplied(db, layer, sTypes.BlockID{byte(layer)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orphan := &sTypes.Reward{Layer: 10, SmesherID: sTypes.NodeID{4}, Coinbase: address, TotalReward: 100, LayerReward: 90}
+	if err := rewards.Add(db, orphan); err != nil {
+		t.Fatal(err)
+	}
+	return path, atxID, address
+}
+
+func TestReplayState(t *testing.T) {
+	statePath, atxID, address := testState(t)
+	db, err := database.NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "backfill.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	network := &config.NetworkConfig{Name: "test", HRP: "sm", LayersPerEpoch: 4}
+	checkpoint, err := LoadCheckpoint(filepath.Join(t.TempDir(), "checkpoint.json"), statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the reward of the smesher without atx stops the backfill, the ones before it are replayed
+	b := NewBackfill(network, db, checkpoint, false)
+	if err := b.ReplayState(statePath); err == nil || !strings.Contains(err.Error(), "failed to find atx") {
+		t.Fatalf("error %v, want the one of the reward without atx", err)
+	}
+	// with continueOnError it is skipped, the replay goes on from the checkpoint
+	b = NewBackfill(network, db, checkpoint, true)
+	if err := b.ReplayState(statePath); err != nil {
+		t.Fatal(err)
+	}
+
+	account, err := db.GetAccount(address.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 200 || account.TotalRewards != 200 {
+		t.Errorf("balance %d and rewards %d, want 200 from the rewards of layers 8 and 9", account.Balance, account.TotalRewards)
+	}
+	// the id the node published the reward with
+	id := hex.EncodeToString([]byte(atxID.String() + ":" + sTypes.LayerID(9).String() + ":0:100"))
+	if reward, err := db.GetReward(id); err != nil || reward.Layer != 9 || reward.AtxID != hex.EncodeToString(atxID.Bytes()) {
+		t.Errorf("reward %s of layer 9 %+v: %v", id, reward, err)
+	}
+	if epochAtxs, err := db.GetAtxForEpoch(1); err != nil || len(epochAtxs) != 1 || epochAtxs[0].Coinbase != address.String() {
+		t.Errorf("atxs of epoch 1 %v: %v", epochAtxs, err)
+	}
+	if position := checkpoint.Position(KindLayer); position != 10 {
+		t.Errorf("layers checkpointed at %d, want 10", position)
+	}
+}
@@ -0,0 +1,80 @@
+package backfill
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint keeps, per record kind, the next position to replay so an interrupted
+// backfill can resume where it stopped. For state.sql sources the position is a layer
+// (or atx rowid), for NDJSON dumps it is the number of lines already processed.
+type Checkpoint struct {
+	path string
+
+	Source    string           `json:"source"`
+	Positions map[string]int64 `json:"positions"`
+	UpdatedAt int64            `json:"updatedAt"`
+}
+
+// LoadCheckpoint reads the checkpoint at path. A missing file starts a new checkpoint.
+// An empty path disables persistence. A checkpoint written for another source is rejected,
+// it must be removed before backfilling from a different file.
+func LoadCheckpoint(path string, source string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{
+		path:      path,
+		Source:    source,
+		Positions: map[string]int64{},
+	}
+	if path == "" {
+		return checkpoint, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if checkpoint.Source != source {
+		return nil, fmt.Errorf("checkpoint %s belongs to %s, not %s", path, checkpoint.Source, source)
+	}
+	if checkpoint.Positions == nil {
+		checkpoint.Positions = map[string]int64{}
+	}
+	return checkpoint, nil
+}
+
+// Position returns the next position to replay for kind.
+func (c *Checkpoint) Position(kind string) int64 {
+	return c.Positions[kind]
+}
+
+// Set records the next position to replay for kind and persists the checkpoint.
+func (c *Checkpoint) Set(kind string, position int64) error {
+	c.Positions[kind] = position
+	return c.save()
+}
+
+func (c *Checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+	c.UpdatedAt = time.Now().UnixMilli()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first so a crash never leaves a truncated checkpoint
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
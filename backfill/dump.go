@@ -0,0 +1,143 @@
+package backfill
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/database"
+)
+
+// number of dump lines replayed between checkpoint writes
+const dumpCheckpointLines = 1000
+
+// ReplayDump replays an NDJSON dump where every line is one JSON encoded nats record of kind,
+// exactly as published on the matching stream.
+func (b *Backfill) ReplayDump(path string, kind string) error {
+	if !validKind(kind) {
+		return fmt.Errorf("unknown record kind %q", kind)
+	}
+	lines, err := countLines(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	skip := b.checkpoint.Position(kind)
+	p := newProgress(kind, lines-skip)
+	reader := bufio.NewReader(file)
+	var line int64
+	for {
+		data, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return readErr
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) > 0 {
+			line++
+			if line > skip {
+				if err := b.save(p, "line "+strconv.FormatInt(line, 10), func() error {
+					return b.saveRecord(kind, data)
+				}); err != nil {
+					return err
+				}
+				if line%dumpCheckpointLines == 0 {
+					if err := b.checkpoint.Set(kind, line); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if err := b.checkpoint.Set(kind, line); err != nil {
+		return err
+	}
+	p.finish()
+	return nil
+}
+
+func validKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Backfill) saveRecord(kind string, data []byte) error {
+	switch kind {
+	case KindLayer:
+		var layer nats.LayerUpdate
+		if err := json.Unmarshal(data, &layer); err != nil {
+			return err
+		}
+		return b.WriteDB.SaveLayer(&layer, database.Origin{})
+	case KindAtx:
+		var atx nats.Atx
+		if err := json.Unmarshal(data, &atx); err != nil {
+			return err
+		}
+		return b.WriteDB.SaveAtx(&atx, database.Origin{})
+	case KindReward:
+		var reward nats.Reward
+		if err := json.Unmarshal(data, &reward); err != nil {
+			return err
+		}
+		return b.WriteDB.SaveReward(&reward, database.Origin{})
+	case KindTransactionCreated, KindTransactionResult:
+		var transaction nats.Transaction
+		if err := json.Unmarshal(data, &transaction); err != nil {
+			return err
+		}
+		if transaction.Header == nil {
+			return fmt.Errorf("transaction %s has no header", transaction.ID)
+		}
+		return b.WriteDB.SaveTransactions(&transaction, kind == KindTransactionResult, database.Origin{})
+	case KindMalfeasance:
+		var malfeasance nats.Malfeasance
+		if err := json.Unmarshal(data, &malfeasance); err != nil {
+			return err
+		}
+		return b.WriteDB.SaveMalfeasance(&malfeasance, database.Origin{})
+	}
+	return fmt.Errorf("unknown record kind %q", kind)
+}
+
+// countLines counts the non empty lines of the dump, used as total for progress reporting.
+func countLines(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var lines int64
+	reader := bufio.NewReader(file)
+	for {
+		data, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(data)) > 0 {
+			lines++
+		}
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
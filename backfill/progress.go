@@ -0,0 +1,60 @@
+package backfill
+
+import (
+	"log/slog"
+	"math"
+	"time"
+)
+
+const progressInterval = 10 * time.Second
+
+// progress logs how many records of a kind were replayed, at most once per progressInterval.
+type progress struct {
+	kind    string
+	total   int64
+	done    int64
+	failed  int64
+	start   time.Time
+	lastLog time.Time
+}
+
+func newProgress(kind string, total int64) *progress {
+	now := time.Now()
+	slog.Info("Backfill started", "kind", kind, "records", total)
+	return &progress{
+		kind:    kind,
+		total:   total,
+		start:   now,
+		lastLog: now,
+	}
+}
+
+func (p *progress) add(ok bool) {
+	p.done++
+	if !ok {
+		p.failed++
+	}
+	if time.Since(p.lastLog) >= progressInterval {
+		p.report()
+	}
+}
+
+func (p *progress) report() {
+	p.lastLog = time.Now()
+	elapsed := time.Since(p.start).Seconds()
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(p.done) / elapsed
+	}
+	if p.total > 0 {
+		slog.Info("Backfill progress", "kind", p.kind, "done", p.done, "total", p.total,
+			"percent", math.Round(float64(p.done)*1000/float64(p.total))/10, "perSecond", math.Round(rate), "failed", p.failed)
+	} else {
+		slog.Info("Backfill progress", "kind", p.kind, "done", p.done, "perSecond", math.Round(rate), "failed", p.failed)
+	}
+}
+
+func (p *progress) finish() {
+	slog.Info("Backfill finished", "kind", p.kind, "replayed", p.done, "failed", p.failed,
+		"elapsed", time.Since(p.start).Round(time.Second).String())
+}
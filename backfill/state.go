@@ -0,0 +1,419 @@
+package backfill
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/codec"
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/spacemeshos/go-spacemesh/sql"
+	"github.com/spacemeshos/go-spacemesh/sql/atxs"
+	"github.com/spacemeshos/go-spacemesh/sql/transactions"
+	"github.com/swarmbit/spacemesh-state-api/database"
+)
+
+const (
+	// number of atx rows read from state.sql at once
+	atxBatch = 10000
+	// number of layers of rewards and transactions read from state.sql at once
+	layerBatch = 100
+	// layerStatusApplied, the status published for layers applied to state
+	layerStatusApplied = 3
+)
+
+// ReplayState replays the atxs, malfeasance proofs, applied layers, rewards and transactions of a
+// go-spacemesh state.sql. The database is opened read only, it should be a copy or belong to a stopped node.
+func (b *Backfill) ReplayState(statePath string) error {
+	db, err := sql.Open("file:"+statePath+"?mode=ro", sql.WithMigrations(nil), sql.WithConnections(1))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	steps := []struct {
+		kind   string
+		replay func(*sql.Database) error
+	}{
+		{KindAtx, b.replayStateAtxs},
+		{KindMalfeasance, b.replayStateMalfeasance},
+		{KindLayer, b.replayStateLayers},
+		{KindReward, b.replayStateRewards},
+		{KindTransactionResult, b.replayStateTransactionResults},
+		{KindTransactionCreated, b.replayStateTransactionsCreated},
+	}
+	for _, step := range steps {
+		if err := step.replay(db); err != nil {
+			return fmt.Errorf("backfill %s: %w", step.kind, err)
+		}
+	}
+	return nil
+}
+
+func bindInt64(args ...int64) sql.Encoder {
+	return func(stmt *sql.Statement) {
+		for i, arg := range args {
+			stmt.BindInt64(i+1, arg)
+		}
+	}
+}
+
+func countState(db *sql.Database, query string, args ...int64) (int64, error) {
+	var count int64
+	_, err := db.Exec(query, bindInt64(args...), func(stmt *sql.Statement) bool {
+		count = stmt.ColumnInt64(0)
+		return false
+	})
+	return count, err
+}
+
+// receivedMillis converts the received nanoseconds stored by go-spacemesh to the milliseconds
+// published on nats. Checkpointed atxs are stored with 0 and keep it.
+func receivedMillis(received int64) int64 {
+	if received == 0 {
+		return 0
+	}
+	return time.Unix(0, received).UnixMilli()
+}
+
+func (b *Backfill) replayStateAtxs(db *sql.Database) error {
+	next := b.checkpoint.Position(KindAtx)
+	total, err := countState(db, `select count(*) from atxs where rowid >= ?1`, next)
+	if err != nil {
+		return err
+	}
+	p := newProgress(KindAtx, total)
+
+	for {
+		var batch []*nats.Atx
+		var last int64
+		_, err := db.Exec(`select rowid, id, pubkey, epoch, effective_num_units, base_tick_height, tick_count,
+			sequence, coinbase, received from atxs where rowid >= ?1 order by rowid limit ?2`,
+			bindInt64(next, atxBatch), func(stmt *sql.Statement) bool {
+				var id sTypes.ATXID
+				var nodeID sTypes.NodeID
+				var coinbase sTypes.Address
+				last = stmt.ColumnInt64(0)
+				stmt.ColumnBytes(1, id[:])
+				stmt.ColumnBytes(2, nodeID[:])
+				stmt.ColumnBytes(8, coinbase[:])
+				batch = append(batch, &nats.Atx{
+					AtxID:             hex.EncodeToString(id.Bytes()),
+					NodeID:            nodeID.String(),
+					PublishEpoch:      uint32(stmt.ColumnInt64(3)),
+					EffectiveNumUnits: uint32(stmt.ColumnInt64(4)),
+					BaseTick:          uint64(stmt.ColumnInt64(5)),
+					TickCount:         uint64(stmt.ColumnInt64(6)),
+					Sequence:          uint64(stmt.ColumnInt64(7)),
+					Coinbase:          coinbase.String(),
+					Received:          receivedMillis(stmt.ColumnInt64(9)),
+				})
+				return true
+			})
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, atx := range batch {
+			if err := b.save(p, atx.AtxID, func() error { return b.WriteDB.SaveAtx(atx, database.Origin{}) }); err != nil {
+				return err
+			}
+		}
+		next = last + 1
+		if err := b.checkpoint.Set(KindAtx, next); err != nil {
+			return err
+		}
+	}
+	p.finish()
+	return nil
+}
+
+// replayStateMalfeasance replays every malicious identity, there are few of them and saving is an
+// upsert so they are not checkpointed.
+func (b *Backfill) replayStateMalfeasance(db *sql.Database) error {
+	var malfeasances []*nats.Malfeasance
+	_, err := db.Exec(`select pubkey, received from identities where proof is not null`, nil,
+		func(stmt *sql.Statement) bool {
+			var nodeID sTypes.NodeID
+			stmt.ColumnBytes(0, nodeID[:])
+			malfeasances = append(malfeasances, &nats.Malfeasance{
+				NodeID:   nodeID.String(),
+				Received: receivedMillis(stmt.ColumnInt64(1)),
+			})
+			return true
+		})
+	if err != nil {
+		return err
+	}
+
+	p := newProgress(KindMalfeasance, int64(len(malfeasances)))
+	for _, malfeasance := range malfeasances {
+		if err := b.save(p, malfeasance.NodeID, func() error { return b.WriteDB.SaveMalfeasance(malfeasance, database.Origin{}) }); err != nil {
+			return err
+		}
+	}
+	p.finish()
+	return nil
+}
+
+func (b *Backfill) replayStateLayers(db *sql.Database) error {
+	next := b.checkpoint.Position(KindLayer)
+	total, err := countState(db, `select count(*) from layers where applied_block is not null and id >= ?1`, next)
+	if err != nil {
+		return err
+	}
+	p := newProgress(KindLayer, total)
+
+	for {
+		var batch []*nats.LayerUpdate
+		_, err := db.Exec(`select id from layers where applied_block is not null and id >= ?1 order by id limit ?2`,
+			bindInt64(next, atxBatch), func(stmt *sql.Statement) bool {
+				batch = append(batch, &nats.LayerUpdate{
+					LayerID: uint32(stmt.ColumnInt64(0)),
+					Status:  layerStatusApplied,
+				})
+				return true
+			})
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, layer := range batch {
+			id := strconv.FormatUint(uint64(layer.LayerID), 10)
+			if err := b.save(p, id, func() error { return b.WriteDB.SaveLayer(layer, database.Origin{}) }); err != nil {
+				return err
+			}
+		}
+		next = int64(batch[len(batch)-1].LayerID) + 1
+		if err := b.checkpoint.Set(KindLayer, next); err != nil {
+			return err
+		}
+	}
+	p.finish()
+	return nil
+}
+
+type stateReward struct {
+	nodeID      sTypes.NodeID
+	coinbase    sTypes.Address
+	layer       sTypes.LayerID
+	total       uint64
+	layerReward uint64
+}
+
+// replayStateRewards rebuilds the nats reward records. state.sql does not keep the atx of a reward
+// nor its position in the layer, so the atx is resolved by the smesher and the epoch and the position
+// comes from the rowid order, which is the order go-spacemesh applied and published the rewards in.
+func (b *Backfill) replayStateRewards(db *sql.Database) error {
+	next := b.checkpoint.Position(KindReward)
+	total, err := countState(db, `select count(*) from rewards where layer >= ?1`, next)
+	if err != nil {
+		return err
+	}
+	first, err := countState(db, `select coalesce(min(layer), 0) from rewards`)
+	if err != nil {
+		return err
+	}
+	last, err := countState(db, `select coalesce(max(layer), -1) from rewards`)
+	if err != nil {
+		return err
+	}
+	next = max(next, first)
+	p := newProgress(KindReward, total)
+
+	for ; next <= last; next += layerBatch {
+		var batch []*stateReward
+		_, err := db.Exec(`select pubkey, coinbase, layer, total_reward, layer_reward from rewards
+			where layer >= ?1 and layer < ?2 order by layer, rowid`,
+			bindInt64(next, next+layerBatch), func(stmt *sql.Statement) bool {
+				reward := &stateReward{
+					layer:       sTypes.LayerID(stmt.ColumnInt64(2)),
+					total:       uint64(stmt.ColumnInt64(3)),
+					layerReward: uint64(stmt.ColumnInt64(4)),
+				}
+				stmt.ColumnBytes(0, reward.nodeID[:])
+				stmt.ColumnBytes(1, reward.coinbase[:])
+				batch = append(batch, reward)
+				return true
+			})
+		if err != nil {
+			return err
+		}
+
+		index := 0
+		for i, reward := range batch {
+			if i > 0 && batch[i-1].layer != reward.layer {
+				index = 0
+			}
+			position := index
+			index++
+			id := reward.nodeID.String() + ":" + reward.layer.String()
+			if err := b.save(p, id, func() error {
+				natsReward, err := newStateReward(db, reward, position, b.network.LayersPerEpoch)
+				if err != nil {
+					return err
+				}
+				return b.WriteDB.SaveReward(natsReward, database.Origin{})
+			}); err != nil {
+				return err
+			}
+		}
+		if err := b.checkpoint.Set(KindReward, next+layerBatch); err != nil {
+			return err
+		}
+	}
+	p.finish()
+	return nil
+}
+
+func newStateReward(db *sql.Database, reward *stateReward, index int, layersPerEpoch uint32) (*nats.Reward, error) {
+	epoch := sTypes.EpochID(uint32(reward.layer) / layersPerEpoch)
+	if epoch == 0 {
+		return nil, fmt.Errorf("reward in layer %d before first epoch", reward.layer)
+	}
+	atxID, err := atxs.GetIDByEpochAndNodeID(db, epoch-1, reward.nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find atx of reward in layer %d: %w", reward.layer, err)
+	}
+
+	// same id as the one published by the node, see events.ReportRewardReceived
+	id := atxID.String() + ":" + reward.layer.String() + ":" + strconv.Itoa(index) + ":" + strconv.FormatUint(reward.total, 10)
+	return &nats.Reward{
+		ID:          hex.EncodeToString([]byte(id)),
+		Layer:       reward.layer.Uint32(),
+		Total:       reward.total,
+		LayerReward: reward.layerReward,
+		Coinbase:    reward.coinbase.String(),
+		AtxID:       hex.EncodeToString(atxID.Bytes()),
+		NodeID:      reward.nodeID.String(),
+	}, nil
+}
+
+func (b *Backfill) replayStateTransactionResults(db *sql.Database) error {
+	next := b.checkpoint.Position(KindTransactionResult)
+	total, err := countState(db, `select count(*) from transactions where result is not null and layer >= ?1`, next)
+	if err != nil {
+		return err
+	}
+	first, err := countState(db, `select coalesce(min(layer), 0) from transactions where result is not null`)
+	if err != nil {
+		return err
+	}
+	last, err := countState(db, `select coalesce(max(layer), -1) from transactions where result is not null`)
+	if err != nil {
+		return err
+	}
+	next = max(next, first)
+	p := newProgress(KindTransactionResult, total)
+
+	for ; next <= last; next += layerBatch {
+		start := sTypes.LayerID(next)
+		end := sTypes.LayerID(next + layerBatch - 1)
+		var batch []*nats.Transaction
+		err := transactions.IterateResults(db, transactions.ResultsFilter{Start: &start, End: &end},
+			func(tx *sTypes.TransactionWithResult) bool {
+				batch = append(batch, newStateTransaction(&tx.Transaction, &tx.TransactionResult))
+				return true
+			})
+		if err != nil {
+			return err
+		}
+		for _, transaction := range batch {
+			if err := b.save(p, transaction.ID, func() error {
+				if transaction.Header == nil {
+					return fmt.Errorf("transaction header not decoded")
+				}
+				return b.WriteDB.SaveTransactions(transaction, true, database.Origin{})
+			}); err != nil {
+				return err
+			}
+		}
+		if err := b.checkpoint.Set(KindTransactionResult, next+layerBatch); err != nil {
+			return err
+		}
+	}
+	p.finish()
+	return nil
+}
+
+// replayStateTransactionsCreated replays transactions that have no result yet. Saving a created
+// transaction never overwrites a stored one so they are not checkpointed.
+func (b *Backfill) replayStateTransactionsCreated(db *sql.Database) error {
+	var batch []*nats.Transaction
+	var decodeErr error
+	_, err := db.Exec(`select id, tx, header, layer from transactions where result is null`, nil,
+		func(stmt *sql.Statement) bool {
+			var tx sTypes.Transaction
+			stmt.ColumnBytes(0, tx.ID[:])
+			tx.Raw = make([]byte, stmt.ColumnLen(1))
+			stmt.ColumnBytes(1, tx.Raw)
+			if stmt.ColumnLen(2) > 0 {
+				tx.TxHeader = &sTypes.TxHeader{}
+				if _, decodeErr = codec.DecodeFrom(stmt.ColumnReader(2), tx.TxHeader); decodeErr != nil {
+					return false
+				}
+			}
+			batch = append(batch, newStateTransaction(&tx, &sTypes.TransactionResult{
+				Layer: sTypes.LayerID(stmt.ColumnInt64(3)),
+			}))
+			return true
+		})
+	if err == nil {
+		err = decodeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	p := newProgress(KindTransactionCreated, int64(len(batch)))
+	for _, transaction := range batch {
+		// transactions that were never validated have no header to replay
+		if transaction.Header == nil {
+			continue
+		}
+		if err := b.save(p, transaction.ID, func() error { return b.WriteDB.SaveTransactions(transaction, false, database.Origin{}) }); err != nil {
+			return err
+		}
+	}
+	p.finish()
+	return nil
+}
+
+// newStateTransaction builds the same record as events.ReportResult. The header is left nil
+// when state.sql has no decoded header for the transaction.
+func newStateTransaction(tx *sTypes.Transaction, result *sTypes.TransactionResult) *nats.Transaction {
+	transaction := &nats.Transaction{
+		ID:  hex.EncodeToString(tx.ID.Bytes()),
+		Raw: tx.Raw,
+	}
+	if tx.TxHeader == nil {
+		slog.Warn("Backfill transaction has no header", "transaction", transaction.ID)
+		return transaction
+	}
+
+	addresses := make([]string, 0, len(result.Addresses))
+	for _, address := range result.Addresses {
+		addresses = append(addresses, address.String())
+	}
+	transaction.Header = &nats.TransactionHeader{
+		Message:         result.Message,
+		Principal:       tx.Principal.String(),
+		TemplateAddress: tx.TemplateAddress.String(),
+		Nonce:           tx.Nonce,
+		Method:          tx.Method,
+		LayerID:         result.Layer.Uint32(),
+		Status:          uint8(result.Status),
+		Gas:             result.Gas,
+		Fee:             result.Fee,
+		BlockID:         result.Block.String(),
+		Addresses:       addresses,
+	}
+	return transaction
+}
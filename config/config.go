@@ -1,29 +1,177 @@
 package config
 
 type Config struct {
-    Server *ServerConfig `json:"server"`
-    Price  *PriceConfig  `json:"price"`
-    DB     *DBConfig     `json:"db"`
-    Nats   *NatsConfig   `json:"nats"`
-    Poets  []*PoetConfig `json:"poets"`
+    Server    *ServerConfig    `json:"server"`
+    Price     *PriceConfig     `json:"price"`
+    DB        *DBConfig        `json:"db"`
+    Nats      *NatsConfig      `json:"nats"`
+    Poets     []*PoetConfig    `json:"poets"`
+    Admin     *AdminConfig     `json:"admin"`
+    Reconcile *ReconcileConfig `json:"reconcile"`
+    Stats     *StatsConfig     `json:"stats"`
+    Pending   *PendingConfig   `json:"pending"`
+    Auth      *AuthConfig      `json:"auth"`
+    Stream    *StreamConfig    `json:"stream"`
+    Webhooks  *WebhooksConfig  `json:"webhooks"`
+    GraphQL   *GraphQLConfig   `json:"graphql"`
+    Log       *LogConfig       `json:"log"`
+    Health    *HealthConfig    `json:"health"`
+    // Networks to serve, mainnet from the top level db and nats when empty
+    Networks []*NetworkConfig `json:"networks"`
+}
+
+type AdminConfig struct {
+    // Token enables the /admin routes, requests send it as "Authorization: Bearer <token>".
+    Token string `json:"token"`
+}
+
+type ReconcileConfig struct {
+    Enabled bool `json:"enabled"`
+    // Interval between runs in minutes
+    Interval int  `json:"interval"`
+    Repair   bool `json:"repair"`
+}
+
+type StatsConfig struct {
+    // Enabled collects the statistics of every epoch and day of each network to the stats collection
+    Enabled bool `json:"enabled"`
+    // Interval between collections in minutes, 10 by default
+    Interval int `json:"interval"`
+}
+
+type PendingConfig struct {
+    // Enabled marks dropped the transactions of each network without a result for DropLayers processed layers
+    // after they were created
+    Enabled bool `json:"enabled"`
+    // DropLayers is 20 by default
+    DropLayers int64 `json:"dropLayers"`
+    // Interval between runs in minutes, 1 by default
+    Interval int `json:"interval"`
+}
+
+type AuthConfig struct {
+    Enabled bool `json:"enabled"`
+    // RequireKey rejects requests without x-api-key, otherwise they get the anonymous limits per client ip
+    RequireKey bool             `json:"requireKey"`
+    Keys       []*ApiKeyConfig  `json:"keys"`
+    Default    *RateLimitConfig `json:"default"`
+    Anonymous  *RateLimitConfig `json:"anonymous"`
+}
+
+// RateLimitConfig is a token bucket of Rate requests per second up to Burst, plus a number of requests per
+// UTC day. 0 disables the limit. Both are counted in the memory of each instance, the daily quota starts
+// over when the instance restarts and behind N instances a client gets up to N times it.
+type RateLimitConfig struct {
+    Rate       float64 `json:"rate"`
+    Burst      int64   `json:"burst"`
+    DailyQuota int64   `json:"dailyQuota"`
+}
+
+type ApiKeyConfig struct {
+    Key        string  `json:"key"`
+    Name       string  `json:"name"`
+    Rate       float64 `json:"rate"`
+    Burst      int64   `json:"burst"`
+    DailyQuota int64   `json:"dailyQuota"`
+}
+
+type StreamConfig struct {
+    // Enabled serves /stream, events are only published by an instance that runs the sink
+    Enabled bool `json:"enabled"`
+    // Buffer is the number of recent events kept to resume subscriptions
+    Buffer int `json:"buffer"`
+}
+
+type WebhooksConfig struct {
+    // Enabled serves /webhooks to api keys, deliveries are sent by the instance that runs the sink
+    Enabled bool `json:"enabled"`
+    // MaxAttempts before a delivery is marked failed
+    MaxAttempts int `json:"maxAttempts"`
+    // Timeout of a delivery request in seconds
+    Timeout int `json:"timeout"`
+    // MaxPerKey is the number of webhooks an api key can register
+    MaxPerKey int `json:"maxPerKey"`
+    // AtxCheckLayers is how many layers before the end of an epoch nodes without an atx for the next one are reported
+    AtxCheckLayers int `json:"atxCheckLayers"`
+    // AllowPrivate lets webhooks target loopback and private network addresses
+    AllowPrivate bool `json:"allowPrivate"`
+}
+
+type GraphQLConfig struct {
+    // Enabled serves /graphql
+    Enabled bool `json:"enabled"`
+    // MaxDepth is the deepest field nesting a query can select
+    MaxDepth int `json:"maxDepth"`
+    // MaxComplexity is the highest cost a query can have, each field costs 1 times the limit of the lists it is in
+    MaxComplexity int `json:"maxComplexity"`
+}
+
+type LogConfig struct {
+    // Level is debug, info, warn or error, info by default
+    Level string `json:"level"`
+    // Format is json or text, json by default
+    Format string `json:"format"`
+}
+
+type HealthConfig struct {
+    // MaxLayerLag is how many layers the last processed layer can be behind the current one before the
+    // instance running the sink is not ready
+    MaxLayerLag int64 `json:"maxLayerLag"`
 }
 
 type PriceConfig struct {
-    Provider    string `json:"provider"`
-    RefreshTime int    `json:"refreshTime"`
+    RefreshTime int `json:"refreshTime"`
+    // DisableHistory stops saving the fetched prices and backfilling the daily closes to the prices collection
+    // of the first network
+    DisableHistory bool `json:"disableHistory"`
+    // Providers are the sources of the price, xt and coinpaprika by default. The price in a currency is the
+    // median of their quotes
+    Providers []*PriceProviderConfig `json:"providers"`
+    // Currencies are quoted by the providers, USD, EUR and BTC by default. USD is always quoted
+    Currencies []string `json:"currencies"`
+    // MaxDeviation is how far from the median of all the quotes, as a fraction of it, a quote can be before it is
+    // left out, 0.1 by default. Outliers are only told apart with three quotes or more
+    MaxDeviation float64 `json:"maxDeviation"`
+    // MaxAge is how many seconds a quote is used after it was fetched, three refresh times by default. A price
+    // without a quote that recent is stale and its values are unknown
+    MaxAge int64 `json:"maxAge"`
+}
+
+type PriceProviderConfig struct {
+    // Type is xt, coinpaprika, static or file
+    Type string `json:"type"`
+    // Name tells apart the providers of the same type, the type by default
+    Name string `json:"name"`
+    // Prices are the prices by currency of a static provider
+    Prices map[string]float64 `json:"prices"`
+    // File is the json file of a file provider, an object of prices by currency read at every fetch
+    File string `json:"file"`
 }
 
 type ServerConfig struct {
     Port string `json:"port"`
+    // ShutdownTimeout is how many seconds in flight requests and sink messages get to finish on shutdown, 30 by default
+    ShutdownTimeout int `json:"shutdownTimeout"`
 }
 
 type NatsConfig struct {
     Enabled bool   `json:"enabled"`
     Uri     string `json:"uri"`
+    // Workers is the number of messages of the rewards and atx streams saved at the same time, 16 by default.
+    // The layers, transactions and malfeasance streams are saved one at a time to keep their order.
+    Workers int `json:"workers"`
+    // MaxDeliver is how many times a message that fails to be saved is delivered before it is dead lettered,
+    // 5 by default, each delivery after a longer backoff. Malformed messages are dead lettered on their first
+    // delivery.
+    MaxDeliver int `json:"maxDeliver"`
 }
 
 type DBConfig struct {
-    Uri string `json:"uri"`
+    // Type selects the storage backend, either "mongo" (default) or "sqlite".
+    Type string `json:"type"`
+    Uri  string `json:"uri"`
+    // Name of the mongo database, spacemesh by default
+    Name string `json:"name"`
 }
 
 type PoetConfig struct {
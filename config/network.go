@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NetworkConfig is the profile of a Spacemesh network. The first network is served at the root paths and
+// every network under /{name}/..., each one from its own db and nats streams.
+type NetworkConfig struct {
+	// Name is the path prefix of the routes of the network, lowercase letters, digits and dashes
+	Name string `json:"name"`
+	// HRP is the prefix of the addresses of the network, sm on mainnet
+	HRP string `json:"hrp"`
+	// Genesis is the unix time in seconds of layer 0
+	Genesis int64 `json:"genesis"`
+	// GenesisID is the hex of the 20 bytes that prefix what transactions sign, signatures are not verified
+	// when it is not set
+	GenesisID string `json:"genesisId"`
+	// LayerDuration in seconds
+	LayerDuration  int64  `json:"layerDuration"`
+	LayersPerEpoch uint32 `json:"layersPerEpoch"`
+	// LayerSize is the expected number of ballots of a layer, the tortoise default when 0
+	LayerSize uint32 `json:"layerSize"`
+	// MinimalWeights are the minimal weights of the eligibilities, each applies from its epoch on
+	MinimalWeights []*MinimalWeightConfig `json:"minimalWeights"`
+	// Vesting of the vaults, nothing vests when it is not set
+	Vesting *VestingConfig `json:"vesting"`
+	// Vaults are the vault accounts with the balances they started with at genesis
+	Vaults map[string]uint64 `json:"vaults"`
+	// DB and Nats replace the top level ones for this network. The first network uses the top level ones
+	// when they are not set, the others use the database named after them on the top level mongo and
+	// don't run a sink.
+	DB   *DBConfig   `json:"db"`
+	Nats *NatsConfig `json:"nats"`
+}
+
+type MinimalWeightConfig struct {
+	Epoch  uint32 `json:"epoch"`
+	Weight uint64 `json:"weight"`
+}
+
+// VestingConfig vests Total smidge linearly from layer Start to layer End.
+type VestingConfig struct {
+	Start uint32 `json:"start"`
+	End   uint32 `json:"end"`
+	Total uint64 `json:"total"`
+}
+
+// GenesisIDLength is the length in bytes of a genesis id.
+const GenesisIDLength = 20
+
+var networkName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// GenesisIDBytes returns the genesis id, nil when it is not set.
+func (n *NetworkConfig) GenesisIDBytes() []byte {
+	id, err := hex.DecodeString(n.GenesisID)
+	if err != nil || len(id) != GenesisIDLength {
+		return nil
+	}
+	return id
+}
+
+// LayerTime returns the unix time in seconds a layer starts at.
+func (n *NetworkConfig) LayerTime(layer int64) int64 {
+	return n.Genesis + layer*n.LayerDuration
+}
+
+// CurrentLayer returns the layer of the current time.
+func (n *NetworkConfig) CurrentLayer() int64 {
+	return n.LayerAt(time.Now().Unix())
+}
+
+// LayerAt returns the layer of a unix time in seconds, -1 before genesis.
+func (n *NetworkConfig) LayerAt(timestamp int64) int64 {
+	if timestamp < n.Genesis {
+		return -1
+	}
+	return (timestamp - n.Genesis) / n.LayerDuration
+}
+
+// MinimalWeight returns the minimal weight of the eligibilities of an epoch.
+func (n *NetworkConfig) MinimalWeight(epoch uint32) uint64 {
+	var weight uint64
+	for _, minimal := range n.MinimalWeights {
+		if minimal.Epoch <= epoch {
+			weight = minimal.Weight
+		}
+	}
+	return weight
+}
+
+// GenesisBalances returns the balances the vault accounts started with.
+func (n *NetworkConfig) GenesisBalances() map[string]uint64 {
+	balances := make(map[string]uint64, len(n.Vaults))
+	for account, balance := range n.Vaults {
+		balances[account] = balance
+	}
+	return balances
+}
+
+// VaultAccounts returns the vault accounts, sorted.
+func (n *NetworkConfig) VaultAccounts() []string {
+	accounts := make([]string, 0, len(n.Vaults))
+	for account := range n.Vaults {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// NetworkProfiles returns the networks to serve with their db and nats resolved, mainnet with the top
+// level db and nats when none is configured. A network named mainnet gets the mainnet parameters it
+// doesn't set.
+func (c *Config) NetworkProfiles() ([]*NetworkConfig, error) {
+	if len(c.Networks) == 0 {
+		mainnet := Mainnet()
+		mainnet.DB = c.DB
+		mainnet.Nats = c.Nats
+		return []*NetworkConfig{mainnet}, nil
+	}
+
+	names := make(map[string]bool, len(c.Networks))
+	profiles := make([]*NetworkConfig, 0, len(c.Networks))
+	for i, configured := range c.Networks {
+		profile := *configured
+		if !networkName.MatchString(profile.Name) {
+			return nil, fmt.Errorf("network name %q must be lowercase letters, digits and dashes", profile.Name)
+		}
+		if names[profile.Name] {
+			return nil, fmt.Errorf("network %s is configured twice", profile.Name)
+		}
+		names[profile.Name] = true
+
+		if profile.Name == "mainnet" {
+			profile.withDefaults(Mainnet())
+		}
+		if profile.HRP == "" || profile.Genesis <= 0 || profile.LayerDuration <= 0 || profile.LayersPerEpoch == 0 {
+			return nil, fmt.Errorf("network %s needs hrp, genesis, layerDuration and layersPerEpoch", profile.Name)
+		}
+		if profile.GenesisID != "" {
+			if id, err := hex.DecodeString(profile.GenesisID); err != nil || len(id) != GenesisIDLength {
+				return nil, fmt.Errorf("network %s genesisId must be the hex of %d bytes", profile.Name, GenesisIDLength)
+			}
+		}
+		minimalWeights := append([]*MinimalWeightConfig(nil), profile.MinimalWeights...)
+		sort.Slice(minimalWeights, func(a, b int) bool {
+			return minimalWeights[a].Epoch < minimalWeights[b].Epoch
+		})
+		profile.MinimalWeights = minimalWeights
+
+		if profile.DB == nil {
+			if i == 0 {
+				profile.DB = c.DB
+			} else if c.DB != nil && (c.DB.Type == "" || strings.ToLower(c.DB.Type) == "mongo") {
+				db := *c.DB
+				db.Name = profile.Name
+				profile.DB = &db
+			} else {
+				return nil, fmt.Errorf("network %s needs its own db", profile.Name)
+			}
+		}
+		if profile.Nats == nil {
+			if i == 0 {
+				profile.Nats = c.Nats
+			} else {
+				// sharing the durable consumers of another network would split its messages
+				profile.Nats = &NatsConfig{}
+			}
+		}
+		profiles = append(profiles, &profile)
+	}
+	return profiles, nil
+}
+
+// Network returns the profile of the network with name, the first one when name is empty.
+func (c *Config) Network(name string) (*NetworkConfig, error) {
+	profiles, err := c.NetworkProfiles()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return profiles[0], nil
+	}
+	for _, profile := range profiles {
+		if profile.Name == name {
+			return profile, nil
+		}
+	}
+	return nil, fmt.Errorf("network %s is not configured", name)
+}
+
+// withDefaults sets the parameters that are not configured to the ones of defaults.
+func (n *NetworkConfig) withDefaults(defaults *NetworkConfig) {
+	if n.HRP == "" {
+		n.HRP = defaults.HRP
+	}
+	if n.Genesis == 0 {
+		n.Genesis = defaults.Genesis
+	}
+	if n.GenesisID == "" {
+		n.GenesisID = defaults.GenesisID
+	}
+	if n.LayerDuration == 0 {
+		n.LayerDuration = defaults.LayerDuration
+	}
+	if n.LayersPerEpoch == 0 {
+		n.LayersPerEpoch = defaults.LayersPerEpoch
+	}
+	if n.LayerSize == 0 {
+		n.LayerSize = defaults.LayerSize
+	}
+	if n.MinimalWeights == nil {
+		n.MinimalWeights = defaults.MinimalWeights
+	}
+	if n.Vesting == nil {
+		n.Vesting = defaults.Vesting
+	}
+	if n.Vaults == nil {
+		n.Vaults = defaults.Vaults
+	}
+}
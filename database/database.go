@@ -0,0 +1,249 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	MongoType  = "mongo"
+	SQLiteType = "sqlite"
+)
+
+// ErrMalformed is returned for the records that can't be decoded, saving them again fails the same way.
+var ErrMalformed = errors.New("malformed record")
+
+// ReadDB is the read side of the state store used by the routes and the network state.
+// The list methods taking a cursor order by their sort field then id, with a non nil cursor they return
+// the items after it and skip counts from there.
+type ReadDB interface {
+	GetAccounts(skip int64, limit int64, sort int8, after *types.Cursor) ([]*types.AccountDoc, error)
+	GetAccount(account string) (*types.AccountDoc, error)
+	// GetAccountsByAddress returns the stored accounts among accounts, in no particular order.
+	GetAccountsByAddress(accounts []string) ([]*types.AccountDoc, error)
+	GetNode(nodeId string) (*types.NodeDoc, error)
+	// GetNodesById returns the stored nodes among nodeIds, in no particular order.
+	GetNodesById(nodeIds []string) ([]*types.NodeDoc, error)
+	GetTransaction(transactionId string) (*types.TransactionDoc, error)
+	GetReward(rewardId string) (*types.RewardsDoc, error)
+	CountTransactions(account string) (int64, error)
+	CountAllTransactions(complete bool, method int, minAmount int) (int64, error)
+	CountLayerTransactions(layer int) (int64, error)
+	CountLayerRewards(layer int) (int64, error)
+	CountRewards(account string, firstLayer int, lastLayer int) (int64, error)
+	CountNodeRewards(node string) (int64, error)
+	CountNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error)
+	CountAccountsPostEpoch(epoch int) (int64, error)
+	GetAccountsGroup(accounts []string) (*types.AccountGroup, error)
+	GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort int8) ([]*types.AccountAtxDoc, error)
+	SumNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error)
+	SumRewardsLayers(account string, minLayer uint32, maxLayer uint32) (int64, error)
+	GetRewards(account string, skip int64, limit int64, sort int8, firstLayer int, lastLayer int, after *types.Cursor) ([]*types.RewardsDoc, error)
+	GetLayerRewards(layer int, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error)
+	GetNodeRewards(node string, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error)
+	GetAtxWeightAccount(account string, epoch uint64) (*types.AggregationAtxTotals, error)
+	GetAccountAtxList(account string, epoch uint64) ([]*types.AtxDoc, error)
+	GetAtxWeightNode(node string, epoch uint64) (*types.AggregationAtxTotals, error)
+	GetTransactions(account string, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error)
+	GetLayerTransactions(layer int, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error)
+	// GetAppliedTransactions returns up to limit transactions that changed the balance of account, as principal,
+	// receiver or drained vault, between firstLayer and lastLayer (-1 for no bound), ordered by layer and id.
+	GetAppliedTransactions(account string, firstLayer int, lastLayer int, limit int64, after *types.Cursor) ([]*types.TransactionDoc, error)
+	GetNodes(skip int64, limit int64, after *types.Cursor) ([]*types.NodeDoc, error)
+	GetAllTransactions(skip int64, limit int64, sort int8, complete bool, method int, minAmount int, after *types.Cursor) ([]*types.TransactionDoc, error)
+	CountNodes() (int64, error)
+	CountAccounts() (int64, error)
+	CountAtxEpoch(epoch uint64) (int64, error)
+	FilterAccountAtxNodesForEpoch(account string, epoch uint64, nodes []string) ([]string, error)
+	CountAccountAtxEpoch(account string, epoch uint64) (int64, error)
+	GetAtxForEpochPaginated(epoch uint64, skip int64, limit int64, sort int8, after *types.Cursor) ([]*types.AtxDoc, error)
+	GetAccountAtxEpoch(account string, epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error)
+	GetAtxForEpoch(epoch uint64) ([]*types.AtxDoc, error)
+	GetMalfeasanceNodes() ([]*types.NodeDoc, error)
+	GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error)
+	GetNetworkInfo() (*types.NetworkInfoDoc, error)
+	GetProcessedsLayers(skip int64, limit int64, sort int8) ([]*types.LayerDoc, error)
+	GetLastProcessedLayer() (*types.LayerDoc, error)
+	GetLayer(layer int) (*types.LayerDoc, error)
+	GetLayerReverts(layer int) ([]*types.LayerRevertDoc, error)
+	// GetAccountsAfter returns up to limit accounts with an address greater than account, ordered by address.
+	GetAccountsAfter(account string, limit int64) ([]*types.AccountDoc, error)
+	// GetAccountsTotals recomputes totalRewards, sent, fees and received of the accounts from the
+	// rewards and transactions, in the order of accounts. Balance is left to the caller.
+	GetAccountsTotals(accounts []string) ([]*types.AccountDoc, error)
+	GetApiKey(id string) (*types.ApiKeyDoc, error)
+	GetApiKeys() ([]*types.ApiKeyDoc, error)
+	GetWebhook(id string) (*types.WebhookDoc, error)
+	// GetWebhooks returns the webhooks of an owner ordered by creation, all of them when owner is empty.
+	GetWebhooks(owner string) ([]*types.WebhookDoc, error)
+	// GetWebhookDeliveries returns the deliveries of a webhook, the most recent first.
+	GetWebhookDeliveries(webhookID string, skip int64, limit int64) ([]*types.WebhookDeliveryDoc, error)
+	CountWebhookDeliveries(webhookID string) (int64, error)
+	// GetDueWebhookDeliveries returns up to limit pending deliveries with a next attempt before now.
+	GetDueWebhookDeliveries(now int64, limit int64) ([]*types.WebhookDeliveryDoc, error)
+	GetDeadLetter(id string) (*types.DeadLetterDoc, error)
+	// GetDeadLetters returns the dead letters of a sink consumer, of all of them when stream is empty, the most
+	// recent first.
+	GetDeadLetters(stream string, skip int64, limit int64) ([]*types.DeadLetterDoc, error)
+	CountDeadLetters(stream string) (int64, error)
+	// GetPrices returns the quotes between from and to, unix seconds included, the oldest first.
+	GetPrices(from int64, to int64) ([]*types.PriceDoc, error)
+	// GetPriceAt returns the latest quote at or before timestamp, an empty one when there is none.
+	GetPriceAt(timestamp int64) (*types.PriceDoc, error)
+	// GetLastPrice returns the latest quote of a source, an empty one when there is none.
+	GetLastPrice(source string) (*types.PriceDoc, error)
+	// GetTransactionsTotals counts the transactions that changed the balances between minLayer included and
+	// maxLayer excluded, and sums their amounts.
+	GetTransactionsTotals(minLayer uint32, maxLayer uint32) (*types.AggregationTransactionsTotals, error)
+	// CountNewAccounts counts the accounts whose balance changed between minLayer included and maxLayer
+	// excluded, with a reward or a transaction, and never before.
+	CountNewAccounts(minLayer uint32, maxLayer uint32) (int64, error)
+	// CountMalfeasance counts the nodes whose malfeasance proof was received between from included and to
+	// excluded, unix milliseconds.
+	CountMalfeasance(from int64, to int64) (int64, error)
+	// GetStats returns the statistics of a period starting between from and to, unix seconds included, the
+	// oldest first.
+	GetStats(period string, from int64, to int64) ([]*types.StatsDoc, error)
+	// GetLastCompleteStats returns the latest complete statistics of a period, an empty one when there is none.
+	GetLastCompleteStats(period string) (*types.StatsDoc, error)
+	// GetTransactionDetails returns the decoded payload of a transaction, an empty one when it has none.
+	GetTransactionDetails(id string) (*types.TransactionDetailsDoc, error)
+	// GetNodesRewardsTotals sums and counts the rewards of every node rewarded between minLayer included and
+	// maxLayer excluded.
+	GetNodesRewardsTotals(minLayer uint32, maxLayer uint32) ([]*types.AggregationNodeRewards, error)
+	// GetLastPerformanceEpoch returns the latest epoch with the performance of nodes, -1 when there is none.
+	GetLastPerformanceEpoch() (int64, error)
+	GetNodePerformance(node string, skip int64, limit int64, sort int8) ([]*types.NodePerformanceDoc, error)
+	CountNodePerformance(node string) (int64, error)
+	// GetAccountPerformance returns the performance in an epoch of the nodes with the account as coinbase, the
+	// least efficient first.
+	GetAccountPerformance(account string, epoch int64) ([]*types.NodePerformanceDoc, error)
+	// GetAccountPerformanceEpochs sums the performance of the nodes of an account by epoch.
+	GetAccountPerformanceEpochs(account string, skip int64, limit int64, sort int8) ([]*types.AggregationPerformance, error)
+	CountAccountPerformanceEpochs(account string) (int64, error)
+	// GetAccountTemplate returns the template of an account, an empty one when none of its transactions was seen.
+	GetAccountTemplate(address string) (*types.AccountTemplateDoc, error)
+	// GetVaultsOfOwner returns the templates of the vaults spawned with owner as their owner.
+	GetVaultsOfOwner(owner string) ([]*types.AccountTemplateDoc, error)
+	// GetDrainedAmount sums what the drains of a vault moved out of it.
+	GetDrainedAmount(vault string) (uint64, error)
+	// GetLastCounter returns the highest counter of the complete transactions of a principal, -1 when there is none.
+	GetLastCounter(account string) (int64, error)
+	// GetPendingTransactions returns the transactions of an account, sent or received, that have no result and
+	// were not dropped, by counter.
+	GetPendingTransactions(account string) ([]*types.TransactionDoc, error)
+	// Ping checks that the db answers.
+	Ping(ctx context.Context) error
+	CloseRead()
+}
+
+// Origin describes the stream message a record was read from.
+type Origin struct {
+	// Published is the time in milliseconds the record was published on the stream, 0 when unknown
+	// (e.g. backfill). Records without it skip the layer revert detection.
+	Published int64
+	// Stream and Sequence identify the message on its JetStream stream. The writes of a message are recorded
+	// with them in the same transaction, so a message delivered again after a crash is not applied twice.
+	// Sequence is 0 for records not read from a stream (e.g. backfill), they rely on the checks of each record.
+	Stream   string
+	Sequence uint64
+	// MsgID is the Nats-Msg-Id header of the message, when the publisher sets it.
+	MsgID string
+}
+
+// ProcessedRetention is how long the processed messages are remembered, a message delivered again later
+// is applied again.
+const ProcessedRetention = 30 * 24 * time.Hour
+
+func (o Origin) key() string {
+	return fmt.Sprintf("%s-%d", o.Stream, o.Sequence)
+}
+
+func newProcessedMessageDoc(origin Origin) *types.ProcessedMessageDoc {
+	return &types.ProcessedMessageDoc{
+		ID:          origin.key(),
+		Stream:      origin.Stream,
+		Sequence:    origin.Sequence,
+		MsgID:       origin.MsgID,
+		ProcessedAt: time.Now().UTC(),
+	}
+}
+
+// WriteDB is the write side of the state store used by the sink.
+type WriteDB interface {
+	SaveLayer(layer *nats.LayerUpdate, origin Origin) error
+	SaveAtx(atx *nats.Atx, origin Origin) error
+	SaveMalfeasance(malfeasance *nats.Malfeasance, origin Origin) error
+	SaveTransactions(transaction *nats.Transaction, result bool, origin Origin) error
+	SaveReward(reward *nats.Reward, origin Origin) error
+	// RepairAccount overwrites the balances of an account with computed, only if it still matches stored.
+	// It returns false when the account changed in between.
+	RepairAccount(stored *types.AccountDoc, computed *types.AccountDoc) (bool, error)
+	SaveApiKey(key *types.ApiKeyDoc) error
+	// RevokeApiKey marks a key revoked at revokedAt, it returns false if there is no such active key.
+	RevokeApiKey(id string, revokedAt int64) (bool, error)
+	SaveWebhook(webhook *types.WebhookDoc) error
+	// DeleteWebhook deletes a webhook of owner with its deliveries, it returns false if there is no such webhook.
+	DeleteWebhook(id string, owner string) (bool, error)
+	// SaveWebhookDelivery inserts a delivery, it returns false if one with the same id already exists.
+	SaveWebhookDelivery(delivery *types.WebhookDeliveryDoc) (bool, error)
+	// UpdateWebhookDelivery saves the outcome of a delivery attempt.
+	UpdateWebhookDelivery(delivery *types.WebhookDeliveryDoc) error
+	// DeleteWebhookDeliveries deletes the deliveries created before createdAt that are no longer pending.
+	DeleteWebhookDeliveries(createdAt int64) error
+	// SaveDeadLetter stores a message the sink gave up on, replacing the one with the same id.
+	SaveDeadLetter(letter *types.DeadLetterDoc) error
+	// DeleteDeadLetter returns false if there is no such dead letter.
+	DeleteDeadLetter(id string) (bool, error)
+	// SavePrices saves quotes, replacing the ones with the same timestamp.
+	SavePrices(prices []*types.PriceDoc) error
+	// SaveStats saves the statistics of periods, replacing the ones already saved.
+	SaveStats(stats []*types.StatsDoc) error
+	// SaveNodePerformance saves the performance of nodes, replacing the ones already saved.
+	SaveNodePerformance(performance []*types.NodePerformanceDoc) error
+	// DropTransactions marks dropped the transactions without a result published before publishedBefore, in
+	// milliseconds, or, for those without a publish time, of a layer before layerBefore. It returns how many.
+	DropTransactions(publishedBefore int64, layerBefore uint32) (int64, error)
+	CloseWrite()
+}
+
+// Open creates the read and write stores of a network on its configured backend.
+func Open(network *config.NetworkConfig) (ReadDB, WriteDB, error) {
+	dbConfig := network.DB
+	if dbConfig == nil {
+		return nil, nil, fmt.Errorf("network %s has no db", network.Name)
+	}
+	dbType := strings.ToLower(dbConfig.Type)
+	switch dbType {
+	case "", MongoType:
+		name := dbConfig.Name
+		if name == "" {
+			name = defaultDatabase
+		}
+		writeDB, err := NewMongoWriteDB(dbConfig.Uri, name, network.HRP)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open mongo write db: %w", err)
+		}
+		readDB, err := NewMongoReadDB(dbConfig.Uri, name)
+		if err != nil {
+			writeDB.CloseWrite()
+			return nil, nil, fmt.Errorf("open mongo read db: %w", err)
+		}
+		return readDB, writeDB, nil
+	case SQLiteType:
+		db, err := NewSQLiteDB(dbConfig.Uri, network.HRP)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open sqlite db: %w", err)
+		}
+		return db, db, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown db type %s", dbConfig.Type)
+	}
+}
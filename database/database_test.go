@@ -0,0 +1,15 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+func TestOpenBadMongoUri(t *testing.T) {
+	network := &config.NetworkConfig{Name: "test", HRP: "sm", DB: &config.DBConfig{Type: MongoType, Uri: "bad://localhost"}}
+	readDB, writeDB, err := Open(network)
+	if err == nil || readDB != nil || writeDB != nil {
+		t.Fatalf("opened a bad uri: %v", err)
+	}
+}
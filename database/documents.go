@@ -0,0 +1,268 @@
+package database
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+
+	"github.com/cosmos/btcutil/bech32"
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+func newAtxDoc(atx *nats.Atx) *types.AtxDoc {
+	return &types.AtxDoc{
+		AtxID:             atx.AtxID,
+		NodeID:            atx.NodeID,
+		EffectiveNumUnits: atx.EffectiveNumUnits,
+		BaseTick:          atx.BaseTick,
+		TickCount:         atx.TickCount,
+		Sequence:          atx.Sequence,
+		PublishEpoch:      atx.PublishEpoch,
+		Coinbase:          atx.Coinbase,
+		Received:          atx.Received,
+		Weight:            getATXWeight(atx.TickCount, uint64(atx.EffectiveNumUnits)),
+	}
+}
+
+func newRewardDoc(reward *nats.Reward) *types.RewardsDoc {
+	return &types.RewardsDoc{
+		Id:          reward.ID,
+		Coinbase:    reward.Coinbase,
+		LayerReward: int64(reward.LayerReward),
+		TotalReward: int64(reward.Total),
+		AtxID:       reward.AtxID,
+		NodeId:      reward.NodeID,
+		Layer:       int64(reward.Layer),
+	}
+}
+
+// newCreatedTransactionDoc builds the document of a transaction before its result. The fields decoded from its
+// raw bytes are kept for the pending view, a transaction the parser can't decode only has those of its header.
+func newCreatedTransactionDoc(transaction *nats.Transaction, hrp string) *types.TransactionDoc {
+	if len(transaction.Raw) > 0 {
+		transactionDoc, transactionData, err := newResultTransactionDoc(transaction, hrp)
+		if err != nil {
+			slog.Warn("Failed to parse created transaction", "transaction", transaction.ID, "error", err)
+		} else if transactionData.Opaque == nil {
+			transactionDoc.Complete = false
+			return transactionDoc
+		}
+	}
+	return &types.TransactionDoc{
+		ID:              transaction.ID,
+		PrincipaAccount: transaction.Header.Principal,
+		Fee:             transaction.Header.Fee,
+		Gas:             transaction.Header.Gas,
+		Layer:           transaction.Header.LayerID,
+		Status:          transaction.Header.Status,
+		Method:          transaction.Header.Method,
+		Counter:         transaction.Header.Nonce,
+		Complete:        false,
+	}
+}
+
+// newResultTransactionDoc parses the raw transaction of a result and builds the complete document, the
+// addresses it contains are encoded with the prefix hrp of its network.
+func newResultTransactionDoc(transaction *nats.Transaction, hrp string) (*types.TransactionDoc, *transactionparsertypes.TransactionData, error) {
+	transactionData, err := transactionparser.Parse(transaction.Raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to parse transaction: %w", ErrMalformed, err)
+	}
+	gasPrice := transactionData.Tx.GetGasPrice()
+	if transactionData.Opaque != nil {
+		// saved with the fields of its result, its amount, receiver and counter are unknown. Its fee is the one
+		// of the result, the gas price derived from it is rounded down
+		slog.Warn("Unsupported transaction", "transaction", transaction.ID, "reason", transactionData.Opaque.Reason)
+		if transaction.Header.Gas > 0 {
+			gasPrice = transaction.Header.Fee / transaction.Header.Gas
+		}
+	}
+	receiver := transactionData.Tx.GetReceiver()
+	receiverString := ""
+	if len(receiver.Bytes()) > 0 {
+		receiverString, err = EncodeAddress(receiver, hrp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: failed to encode receiver: %w", ErrMalformed, err)
+		}
+	}
+
+	vaultString := ""
+	if transactionData.Type == transactionparsertypes.TypeDrainVault {
+		vaultString, err = EncodeAddress(transactionData.Vault.GetVault(), hrp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: failed to encode vault: %w", ErrMalformed, err)
+		}
+	}
+
+	return &types.TransactionDoc{
+		ID:              transaction.ID,
+		PrincipaAccount: transaction.Header.Principal,
+		ReceiverAccount: receiverString,
+		VaultAccount:    vaultString,
+		Fee:             transaction.Header.Fee,
+		Gas:             transaction.Header.Gas,
+		Layer:           transaction.Header.LayerID,
+		Status:          transaction.Header.Status,
+		Method:          transaction.Header.Method,
+		Type:            transactionData.Tx.GetType(),
+		Amount:          transactionData.Tx.GetAmount(),
+		Counter:         transactionData.Tx.GetCounter(),
+		GasPrice:        gasPrice,
+		Complete:        true,
+	}, transactionData, nil
+}
+
+// newTransactionDetailsDoc builds the details of a transaction from what the parser decoded of its raw bytes,
+// the addresses it contains are encoded with the prefix hrp of its network.
+func newTransactionDetailsDoc(transaction *nats.Transaction, transactionData *transactionparsertypes.TransactionData, hrp string) (*types.TransactionDetailsDoc, error) {
+	doc := &types.TransactionDetailsDoc{
+		ID:        transaction.ID,
+		Raw:       hex.EncodeToString(transaction.Raw),
+		Template:  transactionparsertypes.SpawnedTemplate(transactionData.Type),
+		Addresses: append([]string{}, transaction.Header.Addresses...),
+		Message:   transaction.Header.Message,
+	}
+	if doc.Template == "" {
+		doc.Template = principalTemplate(transaction.Header.TemplateAddress, transactionData.Type)
+	}
+
+	for _, publicKey := range transactionData.Tx.GetPublicKeys() {
+		doc.PublicKeys = append(doc.PublicKeys, hex.EncodeToString(publicKey))
+	}
+	if transactionData.Multisig != nil {
+		doc.RequiredSigners = transactionData.Multisig.GetRequired()
+	}
+	if transactionData.Type == transactionparsertypes.TypeVaultSpawn {
+		owner, err := EncodeAddress(transactionData.Vault.GetOwner(), hrp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to encode vault owner: %w", ErrMalformed, err)
+		}
+		doc.Owner = owner
+		doc.TotalAmount = transactionData.Vault.GetTotalAmount()
+		doc.InitialUnlockAmount = transactionData.Vault.GetInitialUnlockAmount()
+		doc.VestingStart = transactionData.Vault.GetVestingStart().Uint32()
+		doc.VestingEnd = transactionData.Vault.GetVestingEnd().Uint32()
+	}
+
+	if transactionData.Sig != nil {
+		doc.Signature = hex.EncodeToString(transactionData.Sig[:])
+	}
+	if transactionData.Signatures != nil {
+		for _, part := range *transactionData.Signatures {
+			doc.Signatures = append(doc.Signatures, &types.SignaturePartDoc{
+				Ref:       part.Ref,
+				Signature: hex.EncodeToString(part.Sig[:]),
+			})
+		}
+	}
+	return doc, nil
+}
+
+// newAccountTemplateDocs builds the templates a transaction result tells of: the account a spawn spawned,
+// with its arguments, or the principal of any other transaction and the vault drained by a drain.
+func newAccountTemplateDocs(transaction *nats.Transaction, transactionData *transactionparsertypes.TransactionData, details *types.TransactionDetailsDoc, hrp string) ([]*types.AccountTemplateDoc, error) {
+	success := transaction.Header.Status == uint8(sTypes.TransactionSuccess)
+	if transactionData.Spawn != nil {
+		address, err := EncodeAddress(transactionData.Spawn.GetSpawned(), hrp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to encode spawned account: %w", ErrMalformed, err)
+		}
+		docs := []*types.AccountTemplateDoc{{
+			Address:             address,
+			Template:            details.Template,
+			Spawned:             success,
+			SpawnLayer:          transaction.Header.LayerID,
+			SpawnTransaction:    transaction.ID,
+			PublicKeys:          details.PublicKeys,
+			RequiredSigners:     details.RequiredSigners,
+			Owner:               details.Owner,
+			TotalAmount:         details.TotalAmount,
+			InitialUnlockAmount: details.InitialUnlockAmount,
+			VestingStart:        details.VestingStart,
+			VestingEnd:          details.VestingEnd,
+		}}
+		// a vesting account spawns its vaults, the principal is then another account
+		if address != transaction.Header.Principal {
+			docs = append(docs, &types.AccountTemplateDoc{
+				Address:  transaction.Header.Principal,
+				Template: principalTemplate(transaction.Header.TemplateAddress, transactionData.Type),
+				Spawned:  success,
+			})
+		}
+		return docs, nil
+	}
+
+	docs := []*types.AccountTemplateDoc{{
+		Address:  transaction.Header.Principal,
+		Template: details.Template,
+		Spawned:  success,
+	}}
+	if transactionData.Type == transactionparsertypes.TypeDrainVault {
+		vault, err := EncodeAddress(transactionData.Vault.GetVault(), hrp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to encode vault: %w", ErrMalformed, err)
+		}
+		vaultDoc := &types.AccountTemplateDoc{
+			Address:  vault,
+			Template: transactionparsertypes.TemplateVault,
+			Spawned:  success,
+		}
+		// only the owner drains a vault, this is how the owner of a genesis vault is known
+		if success {
+			vaultDoc.Owner = transaction.Header.Principal
+		}
+		docs = append(docs, vaultDoc)
+	}
+	return docs, nil
+}
+
+// principalTemplate returns the name of the template of the principal from the address of the header, or
+// from the type of the transaction when the header has none.
+func principalTemplate(templateAddress string, txType int) string {
+	if address, err := DecodeAddress(templateAddress); err == nil {
+		if name := transactionparsertypes.TemplateName(address); name != "" {
+			return name
+		}
+	}
+	switch txType {
+	case transactionparsertypes.TypeSpend:
+		return transactionparsertypes.TemplateWallet
+	case transactionparsertypes.TypeMultisigSpend:
+		return transactionparsertypes.TemplateMultisig
+	case transactionparsertypes.TypeDrainVault:
+		return transactionparsertypes.TemplateVesting
+	default:
+		return ""
+	}
+}
+
+// DecodeAddress decodes a bech32 address whatever its prefix.
+func DecodeAddress(address string) (sTypes.Address, error) {
+	_, data, err := bech32.Decode(address, bech32.MaxLengthBIP173)
+	if err != nil {
+		return sTypes.Address{}, err
+	}
+	decoded, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return sTypes.Address{}, err
+	}
+	if len(decoded) != sTypes.AddressLength {
+		return sTypes.Address{}, fmt.Errorf("address of %d bytes", len(decoded))
+	}
+	var result sTypes.Address
+	copy(result[:], decoded)
+	return result, nil
+}
+
+// EncodeAddress is Address.String with the prefix of a network instead of the process wide one.
+func EncodeAddress(address sTypes.Address, hrp string) (string, error) {
+	data, err := bech32.ConvertBits(address.Bytes(), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode(hrp, data)
+}
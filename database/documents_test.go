@@ -0,0 +1,66 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk/wallet"
+	"github.com/spacemeshos/go-spacemesh/nats"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+)
+
+func TestOpaqueTransactionFee(t *testing.T) {
+	db, err := NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "fee.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.CloseRead()
+	seed := make([]byte, ed25519.SeedSize)
+	principal := wallet.Address(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+	seed[0] = 1
+	receiver := wallet.Address(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+
+	reward := &nats.Reward{ID: "r1", Layer: 1, Coinbase: principal.String(), Total: 10000, LayerReward: 10000, NodeID: "n1", AtxID: "a1"}
+	if err := db.SaveReward(reward, Origin{}); err != nil {
+		t.Fatal(err)
+	}
+	// a version the parser has no decoder for, its fee isn't a multiple of its gas
+	transaction := &nats.Transaction{
+		ID:  "t1",
+		Raw: []byte{1 << 2, 1, 2, 3},
+		Header: &nats.TransactionHeader{
+			LayerID:   5,
+			Principal: principal.String(),
+			Method:    16,
+			Gas:       10,
+			Fee:       1001,
+			Addresses: []string{principal.String(), receiver.String()},
+		},
+	}
+	doc, _, err := newResultTransactionDoc(transaction, "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Type != transactionparsertypes.TypeOpaque || doc.TotalFee() != 1001 {
+		t.Fatalf("type %d with fee %d, want an opaque transaction with fee 1001", doc.Type, doc.TotalFee())
+	}
+
+	if err := db.SaveTransactions(transaction, true, Origin{}); err != nil {
+		t.Fatal(err)
+	}
+	account, err := db.GetAccount(principal.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 10000-1001 || account.Fees != 1001 {
+		t.Errorf("balance %d and fees %d, want 8999 and 1001", account.Balance, account.Fees)
+	}
+	totals, err := db.GetAccountsTotals([]string{principal.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(totals) != 1 || totals[0].Fees != 1001 {
+		t.Errorf("reconciled totals %+v, want fees 1001", totals)
+	}
+}
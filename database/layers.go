@@ -0,0 +1,78 @@
+package database
+
+import (
+	"github.com/spacemeshos/go-spacemesh/nats"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// LayerStatusApplied is the status of a layer applied to state (events.LayerStatusTypeApplied).
+const LayerStatusApplied = 3
+
+// Layer reverts are detected from publish times. go-spacemesh publishes the rewards and transaction
+// results of a layer when it executes it and the applied update after it, nothing is published when it
+// reverts state. So a reward or result published after the applied update of its layer can only come
+// from a new execution, the layer content published up to that update is then reverted.
+// A result of another block than the one applied to its layer comes from a new execution as well, even
+// before the applied update is consumed, the content published before it is then reverted.
+// A re-execution that leaves the layer empty publishes nothing and can't be detected here.
+
+// layerContent checks a reward or transaction result published at published against its layer, blockID is
+// the block of a result, empty for a reward. revert is set when the record shows the layer was executed again,
+// with the boundary the content published up to is reverted, stale when the record belongs to content that was
+// already reverted.
+func layerContent(layer *types.LayerDoc, published int64, blockID string) (revert bool, boundary int64, stale bool) {
+	if layer == nil || published == 0 {
+		return false, 0, false
+	}
+	if layer.RevertBoundary > 0 && published <= layer.RevertBoundary {
+		return false, 0, true
+	}
+	if layer.Published > 0 && published > layer.Published {
+		return true, layer.Published, false
+	}
+	if blockID != "" && layer.AppliedBlock != "" && blockID != layer.AppliedBlock {
+		return true, published - 1, false
+	}
+	return false, 0, false
+}
+
+// revertedLayer returns the layer once the content published up to boundary is reverted.
+func revertedLayer(layer *types.LayerDoc, boundary int64, published int64) *types.LayerDoc {
+	reverted := *layer
+	reverted.RevertBoundary = boundary
+	reverted.Reverts++
+	// the applied update for the new content may have been consumed before its records
+	reverted.Published = 0
+	if layer.LastApplied > published {
+		reverted.Published = layer.LastApplied
+	}
+	return &reverted
+}
+
+// updatedLayer returns the layer after a status update published at published.
+func updatedLayer(layer *types.LayerDoc, update *nats.LayerUpdate, published int64) *types.LayerDoc {
+	updated := &types.LayerDoc{Layer: int64(update.LayerID)}
+	if layer != nil {
+		*updated = *layer
+	}
+	// streams can be consumed out of order, an applied layer only changes with new content
+	if updated.Status != LayerStatusApplied {
+		updated.Status = update.Status
+	}
+	if update.Status == LayerStatusApplied && published > updated.RevertBoundary {
+		updated.LastApplied = max(updated.LastApplied, published)
+		if updated.Published == 0 {
+			updated.Published = published
+		}
+	}
+	return updated
+}
+
+// transactionSender returns the account debited by a transaction, drain vault debits the vault.
+func transactionSender(transaction *types.TransactionDoc) string {
+	if transaction.Type == transactionparsertypes.TypeDrainVault {
+		return transaction.VaultAccount
+	}
+	return transaction.PrincipaAccount
+}
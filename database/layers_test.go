@@ -0,0 +1,141 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk/wallet"
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+func TestLayerContent(t *testing.T) {
+	applied := &types.LayerDoc{Layer: 5, Published: 100, LastApplied: 100, AppliedBlock: "a"}
+	reverted := &types.LayerDoc{Layer: 5, RevertBoundary: 100, LastApplied: 100, AppliedBlock: "b"}
+	tests := []struct {
+		name      string
+		layer     *types.LayerDoc
+		published int64
+		blockID   string
+		revert    bool
+		boundary  int64
+		stale     bool
+	}{
+		{name: "unknown layer", published: 50, blockID: "a"},
+		{name: "no publish time", layer: applied, blockID: "b"},
+		{name: "reward before the applied update", layer: applied, published: 90},
+		{name: "result of the applied block", layer: applied, published: 90, blockID: "a"},
+		{name: "reward after the applied update", layer: applied, published: 110, revert: true, boundary: 100},
+		{name: "result after the applied update", layer: applied, published: 110, blockID: "a", revert: true, boundary: 100},
+		{name: "result of another block", layer: applied, published: 90, blockID: "b", revert: true, boundary: 89},
+		{
+			name:      "result of another block before the applied update is consumed",
+			layer:     &types.LayerDoc{Layer: 5, AppliedBlock: "a"},
+			published: 90,
+			blockID:   "b",
+			revert:    true,
+			boundary:  89,
+		},
+		{name: "result of a reverted block", layer: reverted, published: 90, blockID: "a", stale: true},
+		{name: "result of the new block", layer: reverted, published: 120, blockID: "b"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			revert, boundary, stale := layerContent(test.layer, test.published, test.blockID)
+			if revert != test.revert || boundary != test.boundary || stale != test.stale {
+				t.Errorf("revert %t, boundary %d, stale %t, want %t, %d, %t",
+					revert, boundary, stale, test.revert, test.boundary, test.stale)
+			}
+		})
+	}
+}
+
+func TestResultOfAnotherBlockRevertsLayer(t *testing.T) {
+	db, err := NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "layers.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.CloseRead()
+
+	seed := make([]byte, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+	principal := wallet.Address(key.Public().(ed25519.PublicKey))
+	seed[0] = 1
+	receiver := wallet.Address(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+
+	reward := &nats.Reward{ID: "r1", Layer: 1, Coinbase: principal.String(), Total: 10000, LayerReward: 10000, NodeID: "n1", AtxID: "a1"}
+	if err := db.SaveReward(reward, Origin{Stream: "rewards", Sequence: 1, Published: 10}); err != nil {
+		t.Fatal(err)
+	}
+	result := func(id string, nonce uint64, blockID string, sequence uint64, published int64) {
+		t.Helper()
+		transaction := &nats.Transaction{
+			ID:  id,
+			Raw: wallet.Spend(key, receiver, 100, nonce),
+			Header: &nats.TransactionHeader{
+				BlockID:   blockID,
+				LayerID:   5,
+				Principal: principal.String(),
+				Method:    16,
+				Nonce:     nonce,
+				Gas:       10,
+				Fee:       10,
+				Addresses: []string{principal.String(), receiver.String()},
+			},
+		}
+		if err := db.SaveTransactions(transaction, true, Origin{Stream: "transactions.result", Sequence: sequence, Published: published}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result("t1", 1, "a", 1, 100)
+	account, err := db.GetAccount(receiver.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 100 {
+		t.Fatalf("receiver balance %d after the first result, want 100", account.Balance)
+	}
+
+	// the layer is executed again with another block, before any applied update
+	result("t2", 2, "b", 2, 200)
+
+	first, err := db.GetTransaction("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Complete || first.BalanceApplied {
+		t.Errorf("result of the reverted block still complete %t, applied %t", first.Complete, first.BalanceApplied)
+	}
+	account, err = db.GetAccount(receiver.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 100 {
+		t.Errorf("receiver balance %d, want only the result of the new block", account.Balance)
+	}
+	reverts, err := db.GetLayerReverts(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverts) != 1 || reverts[0].TransactionsReverted != 1 || reverts[0].Boundary != 199 {
+		t.Fatalf("reverts %+v, want one of t1 up to 199", reverts)
+	}
+	layer, err := db.GetLayer(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer.AppliedBlock != "b" {
+		t.Errorf("applied block %q, want b", layer.AppliedBlock)
+	}
+
+	// the first result delivered again belongs to the reverted content
+	result("t1", 1, "a", 3, 100)
+	first, err = db.GetTransaction("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Complete {
+		t.Error("stale result of the reverted block saved as complete")
+	}
+}
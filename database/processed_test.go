@@ -0,0 +1,98 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/spacemeshos/go-spacemesh/sql"
+)
+
+func processedCount(t *testing.T, db *SQLiteDB, id string) int {
+	t.Helper()
+	count := 0
+	_, err := db.db.Exec(`select count(*) from processed_messages where id = ?1`, bindArgs(id), func(stmt *sql.Statement) bool {
+		count = stmt.ColumnInt(0)
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestWriteOnce(t *testing.T) {
+	db, err := NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "processed.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	coinbase := "sm1qqqqqq8y5rupfpqzvw4n5mcfcld5aa8utsp3agc2hryuj"
+	reward := &nats.Reward{ID: "r1", Layer: 10, Coinbase: coinbase, Total: 1000, LayerReward: 1000, NodeID: "n1", AtxID: "a1"}
+	origin := Origin{Stream: "rewards", Sequence: 7}
+
+	// the message is delivered again after a crash before its ack
+	for delivery := 0; delivery < 2; delivery++ {
+		if err := db.SaveReward(reward, origin); err != nil {
+			t.Fatal(err)
+		}
+	}
+	account, err := db.GetAccount(coinbase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 1000 || account.TotalRewards != 1000 {
+		t.Errorf("balance %d, rewards %d after two deliveries, want 1000", account.Balance, account.TotalRewards)
+	}
+	if count := processedCount(t, db, origin.key()); count != 1 {
+		t.Errorf("message recorded %d times, want 1", count)
+	}
+
+	// a failed write is rolled back with the record of its message, the next delivery applies it
+	failed := Origin{Stream: "rewards", Sequence: 8}
+	err = db.writeOnce(failed, func(tx *sql.Tx) error {
+		return errors.New("db is down")
+	})
+	if err == nil {
+		t.Fatal("failed write returned no error")
+	}
+	if count := processedCount(t, db, failed.key()); count != 0 {
+		t.Errorf("failed message recorded %d times, want 0", count)
+	}
+}
+
+func TestPruneProcessedMessages(t *testing.T) {
+	db, err := NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "processed.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	now := time.Now()
+	processed := map[string]time.Time{
+		"rewards-1": now.Add(-ProcessedRetention - time.Hour),
+		"rewards-2": now.Add(-ProcessedRetention + time.Hour),
+	}
+	for id, processedAt := range processed {
+		_, err := db.db.Exec(`insert into processed_messages (id, stream, sequence, msg_id, processed_at)
+			values (?1, 'rewards', 0, '', ?2)`, bindArgs(id, processedAt.UnixMilli()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the processed messages are pruned with each layer
+	if err := db.SaveLayer(&nats.LayerUpdate{LayerID: 1, Status: LayerStatusApplied}, Origin{Stream: "layers", Sequence: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if count := processedCount(t, db, "rewards-1"); count != 0 {
+		t.Error("message older than the retention not pruned")
+	}
+	if count := processedCount(t, db, "rewards-2"); count != 1 {
+		t.Error("message within the retention pruned")
+	}
+	if count := processedCount(t, db, "layers-1"); count != 1 {
+		t.Error("layer message not recorded")
+	}
+}
@@ -3,38 +3,45 @@ package database
 import (
     "context"
     "errors"
-    "log"
+    "log/slog"
     "time"
 
+    sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
     "github.com/swarmbit/spacemesh-state-api/types"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type ReadDB struct {
-    client *mongo.Client
+// number of accounts checked per query when counting the new ones
+const newAccountsBatch = 1000
+
+type MongoReadDB struct {
+    client   *mongo.Client
+    database string
 }
 
-func NewReadDB(dbConnection string) (*ReadDB, error) {
+func NewMongoReadDB(dbConnection string, database string) (*MongoReadDB, error) {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
     client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbConnection).SetMaxPoolSize(10))
-    log.Println("Created read db")
-    return &ReadDB{
-        client: client,
+    slog.Info("Created read db", "database", database)
+    return &MongoReadDB{
+        client:   client,
+        database: database,
     }, err
 }
 
-func (m *ReadDB) GetAccounts(skip int64, limit int64, sort int8) ([]*types.AccountDoc, error) {
-    accountsColl := m.client.Database(database).Collection(accountsCollection)
+func (m *MongoReadDB) GetAccounts(skip int64, limit int64, sort int8, after *types.Cursor) ([]*types.AccountDoc, error) {
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
     findOptions.SetLimit(limit)
-    findOptions.SetSort(bson.M{"balance": sort})
+    findOptions.SetSort(keysetSort("balance", sort))
 
-    filter := bson.D{}
+    filter := afterCursor(bson.D{}, "balance", sort, after)
 
     ctx := context.TODO()
     cursor, err := accountsColl.Find(
@@ -54,8 +61,8 @@ func (m *ReadDB) GetAccounts(skip int64, limit int64, sort int8) ([]*types.Accou
     return accounts, nil
 }
 
-func (m *ReadDB) GetAccount(account string) (*types.AccountDoc, error) {
-    accountsColl := m.client.Database(database).Collection(accountsCollection)
+func (m *MongoReadDB) GetAccount(account string) (*types.AccountDoc, error) {
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
     accountResult := accountsColl.FindOne(
         context.TODO(),
         bson.D{{Key: "_id", Value: account}},
@@ -73,8 +80,31 @@ func (m *ReadDB) GetAccount(account string) (*types.AccountDoc, error) {
     return accountDoc, nil
 }
 
-func (m *ReadDB) GetNode(nodeId string) (*types.NodeDoc, error) {
-    nodesColl := m.client.Database(database).Collection(nodesCollection)
+func (m *MongoReadDB) GetAccountsByAddress(accounts []string) ([]*types.AccountDoc, error) {
+    if len(accounts) == 0 {
+        return nil, nil
+    }
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
+
+    ctx := context.TODO()
+    cursor, err := accountsColl.Find(
+        ctx,
+        bson.M{"_id": bson.M{"$in": accounts}},
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var accountDocs []*types.AccountDoc
+    if err = cursor.All(ctx, &accountDocs); err != nil {
+        return nil, err
+    }
+    return accountDocs, nil
+}
+
+func (m *MongoReadDB) GetNode(nodeId string) (*types.NodeDoc, error) {
+    nodesColl := m.client.Database(m.database).Collection(nodesCollection)
     nodeResult := nodesColl.FindOne(
         context.TODO(),
         bson.D{{Key: "_id", Value: nodeId}},
@@ -90,8 +120,31 @@ func (m *ReadDB) GetNode(nodeId string) (*types.NodeDoc, error) {
     return nodeDoc, nil
 }
 
-func (m *ReadDB) GetTransaction(transactionId string) (*types.TransactionDoc, error) {
-    txColl := m.client.Database(database).Collection(transactionsCollection)
+func (m *MongoReadDB) GetNodesById(nodeIds []string) ([]*types.NodeDoc, error) {
+    if len(nodeIds) == 0 {
+        return nil, nil
+    }
+    nodesColl := m.client.Database(m.database).Collection(nodesCollection)
+
+    ctx := context.TODO()
+    cursor, err := nodesColl.Find(
+        ctx,
+        bson.M{"_id": bson.M{"$in": nodeIds}},
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var nodes []*types.NodeDoc
+    if err = cursor.All(ctx, &nodes); err != nil {
+        return nil, err
+    }
+    return nodes, nil
+}
+
+func (m *MongoReadDB) GetTransaction(transactionId string) (*types.TransactionDoc, error) {
+    txColl := m.client.Database(m.database).Collection(transactionsCollection)
     txResult := txColl.FindOne(
         context.TODO(),
         bson.D{{Key: "_id", Value: transactionId}},
@@ -107,8 +160,25 @@ func (m *ReadDB) GetTransaction(transactionId string) (*types.TransactionDoc, er
     return txDoc, nil
 }
 
-func (m *ReadDB) CountTransactions(account string) (int64, error) {
-    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+func (m *MongoReadDB) GetReward(rewardId string) (*types.RewardsDoc, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
+    rewardResult := rewardsColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: rewardId}},
+    )
+    rewardDoc := &types.RewardsDoc{}
+    err := rewardResult.Decode(rewardDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.RewardsDoc{}, nil
+        }
+        return &types.RewardsDoc{}, err
+    }
+    return rewardDoc, nil
+}
+
+func (m *MongoReadDB) CountTransactions(account string) (int64, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
 
     filter := bson.M{
         "$or": []bson.M{
@@ -126,8 +196,8 @@ func (m *ReadDB) CountTransactions(account string) (int64, error) {
     return accountResult, nil
 }
 
-func (m *ReadDB) CountAllTransactions(complete bool, method int, minAmount int) (int64, error) {
-    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+func (m *MongoReadDB) CountAllTransactions(complete bool, method int, minAmount int) (int64, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
 
     filter := bson.D{
         {Key: "complete", Value: complete},
@@ -153,8 +223,8 @@ func (m *ReadDB) CountAllTransactions(complete bool, method int, minAmount int)
     return accountResult, nil
 }
 
-func (m *ReadDB) CountLayerTransactions(layer int) (int64, error) {
-    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+func (m *MongoReadDB) CountLayerTransactions(layer int) (int64, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
 
     filter := bson.D{
         {Key: "layer", Value: layer},
@@ -169,8 +239,8 @@ func (m *ReadDB) CountLayerTransactions(layer int) (int64, error) {
     return accountResult, nil
 }
 
-func (m *ReadDB) CountLayerRewards(layer int) (int64, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) CountLayerRewards(layer int) (int64, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
 
     filter := bson.D{
         {Key: "layer", Value: layer},
@@ -185,8 +255,8 @@ func (m *ReadDB) CountLayerRewards(layer int) (int64, error) {
     return rewardsResult, nil
 }
 
-func (m *ReadDB) CountRewards(account string, firstLayer int, lastLayer int) (int64, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) CountRewards(account string, firstLayer int, lastLayer int) (int64, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
 
     filter := bson.D{}
     if account != "" {
@@ -237,8 +307,8 @@ func (m *ReadDB) CountRewards(account string, firstLayer int, lastLayer int) (in
     return rewardsResult, nil
 }
 
-func (m *ReadDB) CountNodeRewards(node string) (int64, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) CountNodeRewards(node string) (int64, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
     rewardsResult, err := rewardsColl.CountDocuments(
         context.TODO(),
         bson.D{
@@ -251,8 +321,8 @@ func (m *ReadDB) CountNodeRewards(node string) (int64, error) {
     return rewardsResult, nil
 }
 
-func (m *ReadDB) CountNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) CountNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
     filter := bson.M{
         "node_id": node,
         "layer": bson.M{
@@ -270,8 +340,8 @@ func (m *ReadDB) CountNodeRewardsLayers(node string, minLayer uint32, maxLayer u
     return rewardsResult, nil
 }
 
-func (m *ReadDB) CountAccountsPostEpoch(epoch int) (int64, error) {
-    accountAtxEpochsColl := m.client.Database(database).Collection(accountAtxsEpochsCollection)
+func (m *MongoReadDB) CountAccountsPostEpoch(epoch int) (int64, error) {
+    accountAtxEpochsColl := m.client.Database(m.database).Collection(accountAtxsEpochsCollection)
     filter := bson.M{
         "_id.publish_epoch": epoch,
     }
@@ -286,8 +356,8 @@ func (m *ReadDB) CountAccountsPostEpoch(epoch int) (int64, error) {
     return int64(len(result)), nil
 }
 
-func (m *ReadDB) GetAccountsGroup(accounts []string) (*types.AccountGroup, error) {
-    accountsColl := m.client.Database(database).Collection(accountsCollection)
+func (m *MongoReadDB) GetAccountsGroup(accounts []string) (*types.AccountGroup, error) {
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
 
     pipeline := mongo.Pipeline{
         bson.D{
@@ -327,8 +397,8 @@ func (m *ReadDB) GetAccountsGroup(accounts []string) (*types.AccountGroup, error
     }
 }
 
-func (m *ReadDB) GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort int8) ([]*types.AccountAtxDoc, error) {
-    accountAtxEpochsColl := m.client.Database(database).Collection(accountAtxsEpochsCollection)
+func (m *MongoReadDB) GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort int8) ([]*types.AccountAtxDoc, error) {
+    accountAtxEpochsColl := m.client.Database(m.database).Collection(accountAtxsEpochsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -355,8 +425,8 @@ func (m *ReadDB) GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort i
     return results, nil
 }
 
-func (m *ReadDB) SumNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) SumNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
 
     match := bson.D{
         {Key: "$match", Value: bson.D{
@@ -397,8 +467,8 @@ func (m *ReadDB) SumNodeRewardsLayers(node string, minLayer uint32, maxLayer uin
     return totalSum, nil
 }
 
-func (m *ReadDB) SumRewardsLayers(account string, minLayer uint32, maxLayer uint32) (int64, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) SumRewardsLayers(account string, minLayer uint32, maxLayer uint32) (int64, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
     match := bson.D{}
     if account != "" {
         match = bson.D{
@@ -449,13 +519,13 @@ func (m *ReadDB) SumRewardsLayers(account string, minLayer uint32, maxLayer uint
     return totalSum, nil
 }
 
-func (m *ReadDB) GetRewards(account string, skip int64, limit int64, sort int8, firstLayer int, lastLayer int) ([]*types.RewardsDoc, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) GetRewards(account string, skip int64, limit int64, sort int8, firstLayer int, lastLayer int, after *types.Cursor) ([]*types.RewardsDoc, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
     findOptions.SetLimit(limit)
-    findOptions.SetSort(bson.M{"layer": sort})
+    findOptions.SetSort(keysetSort("layer", sort))
 
     filter := bson.D{
         {Key: "coinbase", Value: account},
@@ -478,6 +548,8 @@ func (m *ReadDB) GetRewards(account string, skip int64, limit int64, sort int8,
         }
     }
 
+    filter = afterCursor(filter, "layer", sort, after)
+
     ctx := context.TODO()
     cursor, err := rewardsColl.Find(
         ctx,
@@ -496,8 +568,8 @@ func (m *ReadDB) GetRewards(account string, skip int64, limit int64, sort int8,
     return rewards, nil
 }
 
-func (m *ReadDB) GetLayerRewards(layer int, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) GetLayerRewards(layer int, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -525,8 +597,8 @@ func (m *ReadDB) GetLayerRewards(layer int, skip int64, limit int64, sort int8)
     }
     return rewards, nil
 }
-func (m *ReadDB) GetNodeRewards(node string, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
-    rewardsColl := m.client.Database(database).Collection(rewardsCollection)
+func (m *MongoReadDB) GetNodeRewards(node string, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -553,8 +625,8 @@ func (m *ReadDB) GetNodeRewards(node string, skip int64, limit int64, sort int8)
     return rewards, nil
 }
 
-func (m *ReadDB) GetAtxWeightAccount(account string, epoch uint64) (*types.AggregationAtxTotals, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+func (m *MongoReadDB) GetAtxWeightAccount(account string, epoch uint64) (*types.AggregationAtxTotals, error) {
+    atxColl := m.client.Database(m.database).Collection(atxsCollection)
 
     match := bson.D{
         {Key: "$match", Value: bson.D{
@@ -592,8 +664,8 @@ func (m *ReadDB) GetAtxWeightAccount(account string, epoch uint64) (*types.Aggre
     return &types.AggregationAtxTotals{}, nil
 }
 
-func (m *ReadDB) GetAccountAtxList(account string, epoch uint64) ([]*types.AtxDoc, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+func (m *MongoReadDB) GetAccountAtxList(account string, epoch uint64) ([]*types.AtxDoc, error) {
+    atxColl := m.client.Database(m.database).Collection(atxsCollection)
 
     findOptions := options.Find()
 
@@ -619,8 +691,8 @@ func (m *ReadDB) GetAccountAtxList(account string, epoch uint64) ([]*types.AtxDo
     return atx, nil
 }
 
-func (m *ReadDB) GetAtxWeightNode(node string, epoch uint64) (*types.AggregationAtxTotals, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+func (m *MongoReadDB) GetAtxWeightNode(node string, epoch uint64) (*types.AggregationAtxTotals, error) {
+    atxColl := m.client.Database(m.database).Collection(atxsCollection)
 
     match := bson.D{
         {Key: "$match", Value: bson.D{
@@ -658,8 +730,8 @@ func (m *ReadDB) GetAtxWeightNode(node string, epoch uint64) (*types.Aggregation
     return &types.AggregationAtxTotals{}, nil
 }
 
-func (m *ReadDB) GetTransactions(account string, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
-    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+func (m *MongoReadDB) GetTransactions(account string, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -690,8 +762,8 @@ func (m *ReadDB) GetTransactions(account string, skip int64, limit int64, sort i
     return transactions, nil
 }
 
-func (m *ReadDB) GetLayerTransactions(layer int, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
-    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+func (m *MongoReadDB) GetLayerTransactions(layer int, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -720,15 +792,64 @@ func (m *ReadDB) GetLayerTransactions(layer int, skip int64, limit int64, sort i
     return transactions, nil
 }
 
-func (m *ReadDB) GetNodes(skip int64, limit int64) ([]*types.NodeDoc, error) {
-    nodesColl := m.client.Database(database).Collection(nodesCollection)
+func (m *MongoReadDB) GetAppliedTransactions(account string, firstLayer int, lastLayer int, limit int64, after *types.Cursor) ([]*types.TransactionDoc, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(keysetSort("layer", 1))
+
+    filter := bson.D{
+        {Key: "$or", Value: bson.A{
+            bson.D{{Key: "principal_account", Value: account}},
+            bson.D{{Key: "receiver_account", Value: account}},
+            bson.D{{Key: "vault_account", Value: account}},
+        }},
+        {Key: "balance_applied", Value: true},
+    }
+    layers := bson.D{}
+    if firstLayer > -1 {
+        layers = append(layers, bson.E{Key: "$gte", Value: firstLayer})
+    }
+    if lastLayer > -1 {
+        layers = append(layers, bson.E{Key: "$lte", Value: lastLayer})
+    }
+    if len(layers) > 0 {
+        filter = append(filter, bson.E{Key: "layer", Value: layers})
+    }
+    filter = afterCursor(filter, "layer", 1, after)
+
+    ctx := context.TODO()
+    cursor, err := transactionsColl.Find(
+        ctx,
+        filter,
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var transactions []*types.TransactionDoc
+    if err = cursor.All(ctx, &transactions); err != nil {
+        return nil, err
+    }
+    return transactions, nil
+}
+
+func (m *MongoReadDB) GetNodes(skip int64, limit int64, after *types.Cursor) ([]*types.NodeDoc, error) {
+    nodesColl := m.client.Database(m.database).Collection(nodesCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
     findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
 
     ctx := context.TODO()
     filter := bson.D{}
+    if after != nil {
+        filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$gt", Value: after.ID}}})
+    }
     cursor, err := nodesColl.Find(
         ctx,
         filter,
@@ -745,12 +866,12 @@ func (m *ReadDB) GetNodes(skip int64, limit int64) ([]*types.NodeDoc, error) {
     }
     return nodes, nil
 }
-func (m *ReadDB) GetAllTransactions(skip int64, limit int64, sort int8, complete bool, method int, minAmount int) ([]*types.TransactionDoc, error) {
-    transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+func (m *MongoReadDB) GetAllTransactions(skip int64, limit int64, sort int8, complete bool, method int, minAmount int, after *types.Cursor) ([]*types.TransactionDoc, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
     findOptions := options.Find()
     findOptions.SetSkip(skip)
     findOptions.SetLimit(limit)
-    findOptions.SetSort(bson.M{"layer": sort})
+    findOptions.SetSort(keysetSort("layer", sort))
     ctx := context.TODO()
 
     // Start with the base filter
@@ -767,6 +888,7 @@ func (m *ReadDB) GetAllTransactions(skip int64, limit int64, sort int8, complete
     if minAmount > -1 {
         filter = append(filter, bson.E{Key: "amount", Value: bson.M{"$gte": minAmount}})
     }
+    filter = afterCursor(filter, "layer", sort, after)
 
     cursor, err := transactionsColl.Find(
         ctx,
@@ -786,8 +908,8 @@ func (m *ReadDB) GetAllTransactions(skip int64, limit int64, sort int8, complete
     return transactions, nil
 }
 
-func (m *ReadDB) CountNodes() (int64, error) {
-    nodesCountColl := m.client.Database(database).Collection(nodesCountCollection)
+func (m *MongoReadDB) CountNodes() (int64, error) {
+    nodesCountColl := m.client.Database(m.database).Collection(nodesCountCollection)
 
     nodesCountResult := nodesCountColl.FindOne(
         context.TODO(),
@@ -800,8 +922,8 @@ func (m *ReadDB) CountNodes() (int64, error) {
     return int64(doc.Count), nil
 }
 
-func (m *ReadDB) CountAccounts() (int64, error) {
-    accountsColl := m.client.Database(database).Collection(accountsCollection)
+func (m *MongoReadDB) CountAccounts() (int64, error) {
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
 
     ctx := context.TODO()
     filter := bson.M{}
@@ -815,8 +937,8 @@ func (m *ReadDB) CountAccounts() (int64, error) {
     return count, nil
 }
 
-func (m *ReadDB) CountAtxEpoch(epoch uint64) (int64, error) {
-    atxEpochsColl := m.client.Database(database).Collection(atxsEpochsCollection)
+func (m *MongoReadDB) CountAtxEpoch(epoch uint64) (int64, error) {
+    atxEpochsColl := m.client.Database(m.database).Collection(atxsEpochsCollection)
     atxResult := atxEpochsColl.FindOne(
         context.TODO(),
         bson.D{
@@ -828,8 +950,8 @@ func (m *ReadDB) CountAtxEpoch(epoch uint64) (int64, error) {
     return int64(doc.TotalAtx), nil
 }
 
-func (m *ReadDB) FilterAccountAtxNodesForEpoch(account string, epoch uint64, nodes []string) ([]string, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+func (m *MongoReadDB) FilterAccountAtxNodesForEpoch(account string, epoch uint64, nodes []string) ([]string, error) {
+    atxColl := m.client.Database(m.database).Collection(atxsCollection)
 
     findOptions := options.Find()
     findOptions.SetProjection(bson.D{{"node_id", 1}})
@@ -867,8 +989,8 @@ func (m *ReadDB) FilterAccountAtxNodesForEpoch(account string, epoch uint64, nod
     return results, nil
 }
 
-func (m *ReadDB) CountAccountAtxEpoch(account string, epoch uint64) (int64, error) {
-    accountAtxsEpochsColl := m.client.Database(database).Collection(accountAtxsEpochsCollection)
+func (m *MongoReadDB) CountAccountAtxEpoch(account string, epoch uint64) (int64, error) {
+    accountAtxsEpochsColl := m.client.Database(m.database).Collection(accountAtxsEpochsCollection)
 
     filter := bson.M{
         "_id.coinbase":     account,
@@ -884,18 +1006,16 @@ func (m *ReadDB) CountAccountAtxEpoch(account string, epoch uint64) (int64, erro
     return int64(doc.TotalAtx), nil
 }
 
-func (m *ReadDB) GetAtxForEpochPaginated(epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+func (m *MongoReadDB) GetAtxForEpochPaginated(epoch uint64, skip int64, limit int64, sort int8, after *types.Cursor) ([]*types.AtxDoc, error) {
+    atxColl := m.client.Database(m.database).Collection(atxsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
     findOptions.SetLimit(limit)
-    findOptions.SetSort(bson.M{"effective_num_units": sort})
+    findOptions.SetSort(keysetSort("effective_num_units", sort))
 
     ctx := context.TODO()
-    filter := bson.M{
-        "publishepoch": epoch,
-    }
+    filter := afterCursor(bson.D{{Key: "publishepoch", Value: epoch}}, "effective_num_units", sort, after)
 
     cursor, err := atxColl.Find(
         ctx,
@@ -914,8 +1034,8 @@ func (m *ReadDB) GetAtxForEpochPaginated(epoch uint64, skip int64, limit int64,
     return atx, nil
 }
 
-func (m *ReadDB) GetAccountAtxEpoch(account string, epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+func (m *MongoReadDB) GetAccountAtxEpoch(account string, epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
+    atxColl := m.client.Database(m.database).Collection(atxsCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -945,8 +1065,8 @@ func (m *ReadDB) GetAccountAtxEpoch(account string, epoch uint64, skip int64, li
     return atx, nil
 }
 
-func (m *ReadDB) GetAtxForEpoch(epoch uint64) ([]*types.AtxDoc, error) {
-    atxColl := m.client.Database(database).Collection(atxsCollection)
+func (m *MongoReadDB) GetAtxForEpoch(epoch uint64) ([]*types.AtxDoc, error) {
+    atxColl := m.client.Database(m.database).Collection(atxsCollection)
 
     sortDoc := bson.D{
         {Key: "_id", Value: 1},
@@ -977,8 +1097,8 @@ func (m *ReadDB) GetAtxForEpoch(epoch uint64) ([]*types.AtxDoc, error) {
     return atx, nil
 }
 
-func (m *ReadDB) GetMalfeasanceNodes() ([]*types.NodeDoc, error) {
-    nodesColl := m.client.Database(database).Collection(nodesCollection)
+func (m *MongoReadDB) GetMalfeasanceNodes() ([]*types.NodeDoc, error) {
+    nodesColl := m.client.Database(m.database).Collection(nodesCollection)
 
     findOptions := options.Find()
     findOptions.SetSort(bson.M{"publishepoch": -1})
@@ -1003,8 +1123,8 @@ func (m *ReadDB) GetMalfeasanceNodes() ([]*types.NodeDoc, error) {
     return node, nil
 }
 
-func (m *ReadDB) GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error) {
-    atxEpochsColl := m.client.Database(database).Collection(atxsEpochsCollection)
+func (m *MongoReadDB) GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error) {
+    atxEpochsColl := m.client.Database(m.database).Collection(atxsEpochsCollection)
     atxResult := atxEpochsColl.FindOne(
         context.TODO(),
         bson.D{
@@ -1016,8 +1136,8 @@ func (m *ReadDB) GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error) {
     return doc, nil
 }
 
-func (m *ReadDB) GetNetworkInfo() (*types.NetworkInfoDoc, error) {
-    networkColl := m.client.Database(database).Collection(networkInfoCollection)
+func (m *MongoReadDB) GetNetworkInfo() (*types.NetworkInfoDoc, error) {
+    networkColl := m.client.Database(m.database).Collection(networkInfoCollection)
     infoResult := networkColl.FindOne(
         context.TODO(),
         bson.D{
@@ -1032,8 +1152,8 @@ func (m *ReadDB) GetNetworkInfo() (*types.NetworkInfoDoc, error) {
     return doc, nil
 }
 
-func (m *ReadDB) GetProcessedsLayers(skip int64, limit int64, sort int8) ([]*types.LayerDoc, error) {
-    layersColl := m.client.Database(database).Collection(layersCollection)
+func (m *MongoReadDB) GetProcessedsLayers(skip int64, limit int64, sort int8) ([]*types.LayerDoc, error) {
+    layersColl := m.client.Database(m.database).Collection(layersCollection)
 
     findOptions := options.Find()
     findOptions.SetSkip(skip)
@@ -1060,8 +1180,8 @@ func (m *ReadDB) GetProcessedsLayers(skip int64, limit int64, sort int8) ([]*typ
     }
     return layers, nil
 }
-func (m *ReadDB) GetLastProcessedLayer() (*types.LayerDoc, error) {
-    layersColl := m.client.Database(database).Collection(layersCollection)
+func (m *MongoReadDB) GetLastProcessedLayer() (*types.LayerDoc, error) {
+    layersColl := m.client.Database(m.database).Collection(layersCollection)
 
     findOptions := options.Find()
     findOptions.SetLimit(1)
@@ -1092,6 +1212,853 @@ func (m *ReadDB) GetLastProcessedLayer() (*types.LayerDoc, error) {
     }
 }
 
-func (m *ReadDB) CloseRead() {
+func (m *MongoReadDB) Ping(ctx context.Context) error {
+    return m.client.Ping(ctx, nil)
+}
+
+func (m *MongoReadDB) CloseRead() {
     m.client.Disconnect(context.TODO())
 }
+
+func (m *MongoReadDB) GetLayer(layer int) (*types.LayerDoc, error) {
+    layersColl := m.client.Database(m.database).Collection(layersCollection)
+    layerResult := layersColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: layer}},
+    )
+    layerDoc := &types.LayerDoc{}
+    err := layerResult.Decode(layerDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.LayerDoc{}, nil
+        }
+        return &types.LayerDoc{}, err
+    }
+    return layerDoc, nil
+}
+
+func (m *MongoReadDB) GetLayerReverts(layer int) ([]*types.LayerRevertDoc, error) {
+    layerRevertsColl := m.client.Database(m.database).Collection(layerRevertsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSort(bson.M{"revertedAt": 1})
+
+    ctx := context.TODO()
+    cursor, err := layerRevertsColl.Find(
+        ctx,
+        bson.M{"layer": layer},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var reverts []*types.LayerRevertDoc
+    if err = cursor.All(ctx, &reverts); err != nil {
+        return nil, err
+    }
+    return reverts, nil
+}
+
+func (m *MongoReadDB) GetAccountsAfter(account string, limit int64) ([]*types.AccountDoc, error) {
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.M{"_id": 1})
+
+    ctx := context.TODO()
+    cursor, err := accountsColl.Find(
+        ctx,
+        bson.M{"_id": bson.M{"$gt": account}},
+        findOptions,
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var accounts []*types.AccountDoc
+    if err = cursor.All(ctx, &accounts); err != nil {
+        return nil, err
+    }
+    return accounts, nil
+}
+
+func (m *MongoReadDB) GetAccountsTotals(accounts []string) ([]*types.AccountDoc, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    ctx := context.TODO()
+
+    totals := make(map[string]*types.AccountDoc, len(accounts))
+    for _, account := range accounts {
+        totals[account] = &types.AccountDoc{Address: account}
+    }
+
+    // transactions saved before balance_applied existed changed the balances when complete and successful
+    balanceApplied := bson.D{{Key: "$or", Value: bson.A{
+        bson.D{{Key: "balance_applied", Value: true}},
+        bson.D{
+            {Key: "balance_applied", Value: bson.D{{Key: "$exists", Value: false}}},
+            {Key: "complete", Value: true},
+            {Key: "status", Value: sTypes.TransactionSuccess},
+        },
+    }}}
+    sender := bson.D{{Key: "$cond", Value: bson.A{
+        bson.D{{Key: "$eq", Value: bson.A{"$type", transactionparsertypes.TypeDrainVault}}},
+        "$vault_account",
+        "$principal_account",
+    }}}
+
+    aggregations := []struct {
+        coll     *mongo.Collection
+        pipeline mongo.Pipeline
+    }{
+        {
+            coll: rewardsColl,
+            pipeline: mongo.Pipeline{
+                bson.D{{Key: "$match", Value: bson.D{{Key: "coinbase", Value: bson.D{{Key: "$in", Value: accounts}}}}}},
+                bson.D{{Key: "$group", Value: bson.D{
+                    {Key: "_id", Value: "$coinbase"},
+                    {Key: "totalRewards", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+                }}},
+            },
+        },
+        {
+            coll: transactionsColl,
+            pipeline: mongo.Pipeline{
+                bson.D{{Key: "$match", Value: bson.D{{Key: "$and", Value: bson.A{
+                    bson.D{{Key: "receiver_account", Value: bson.D{{Key: "$in", Value: accounts}}}},
+                    balanceApplied,
+                }}}}},
+                bson.D{{Key: "$group", Value: bson.D{
+                    {Key: "_id", Value: "$receiver_account"},
+                    {Key: "received", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+                }}},
+            },
+        },
+        {
+            coll: transactionsColl,
+            pipeline: mongo.Pipeline{
+                bson.D{{Key: "$match", Value: bson.D{{Key: "$and", Value: bson.A{
+                    bson.D{{Key: "$or", Value: bson.A{
+                        bson.D{{Key: "principal_account", Value: bson.D{{Key: "$in", Value: accounts}}}},
+                        bson.D{{Key: "vault_account", Value: bson.D{{Key: "$in", Value: accounts}}}},
+                    }}},
+                    balanceApplied,
+                }}}}},
+                bson.D{{Key: "$group", Value: bson.D{
+                    {Key: "_id", Value: sender},
+                    {Key: "sent", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+                    {Key: "fees", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+                        bson.D{{Key: "$eq", Value: bson.A{"$type", transactionparsertypes.TypeOpaque}}},
+                        "$fee",
+                        bson.D{{Key: "$multiply", Value: bson.A{"$gas", "$gas_price"}}},
+                    }}}}}},
+                }}},
+            },
+        },
+    }
+
+    for _, aggregation := range aggregations {
+        cursor, err := aggregation.coll.Aggregate(ctx, aggregation.pipeline)
+        if err != nil {
+            return nil, err
+        }
+        var results []*types.AccountDoc
+        if err = cursor.All(ctx, &results); err != nil {
+            return nil, err
+        }
+        for _, result := range results {
+            total, ok := totals[result.Address]
+            if !ok {
+                // principal of a drain vault transaction
+                continue
+            }
+            total.TotalRewards += result.TotalRewards
+            total.Received += result.Received
+            total.Sent += result.Sent
+            total.Fees += result.Fees
+        }
+    }
+
+    result := make([]*types.AccountDoc, len(accounts))
+    for i, account := range accounts {
+        result[i] = totals[account]
+    }
+    return result, nil
+}
+
+func (m *MongoReadDB) GetApiKey(id string) (*types.ApiKeyDoc, error) {
+    apiKeysColl := m.client.Database(m.database).Collection(apiKeysCollection)
+    apiKeyResult := apiKeysColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: id}},
+    )
+    apiKeyDoc := &types.ApiKeyDoc{}
+    err := apiKeyResult.Decode(apiKeyDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.ApiKeyDoc{}, nil
+        }
+        return &types.ApiKeyDoc{}, err
+    }
+    return apiKeyDoc, nil
+}
+
+func (m *MongoReadDB) GetApiKeys() ([]*types.ApiKeyDoc, error) {
+    apiKeysColl := m.client.Database(m.database).Collection(apiKeysCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSort(bson.M{"createdAt": 1})
+
+    ctx := context.TODO()
+    cursor, err := apiKeysColl.Find(ctx, bson.D{}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var apiKeys []*types.ApiKeyDoc
+    if err = cursor.All(ctx, &apiKeys); err != nil {
+        return nil, err
+    }
+    return apiKeys, nil
+}
+
+func (m *MongoReadDB) GetWebhook(id string) (*types.WebhookDoc, error) {
+    webhooksColl := m.client.Database(m.database).Collection(webhooksCollection)
+    webhookResult := webhooksColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: id}},
+    )
+    webhookDoc := &types.WebhookDoc{}
+    err := webhookResult.Decode(webhookDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.WebhookDoc{}, nil
+        }
+        return &types.WebhookDoc{}, err
+    }
+    return webhookDoc, nil
+}
+
+func (m *MongoReadDB) GetWebhooks(owner string) ([]*types.WebhookDoc, error) {
+    webhooksColl := m.client.Database(m.database).Collection(webhooksCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSort(bson.M{"createdAt": 1})
+
+    filter := bson.D{}
+    if owner != "" {
+        filter = bson.D{{Key: "owner", Value: owner}}
+    }
+
+    ctx := context.TODO()
+    cursor, err := webhooksColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var webhooks []*types.WebhookDoc
+    if err = cursor.All(ctx, &webhooks); err != nil {
+        return nil, err
+    }
+    return webhooks, nil
+}
+
+func (m *MongoReadDB) GetWebhookDeliveries(webhookID string, skip int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+    webhookDeliveriesColl := m.client.Database(m.database).Collection(webhookDeliveriesCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+
+    ctx := context.TODO()
+    cursor, err := webhookDeliveriesColl.Find(ctx, bson.D{{Key: "webhookId", Value: webhookID}}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var deliveries []*types.WebhookDeliveryDoc
+    if err = cursor.All(ctx, &deliveries); err != nil {
+        return nil, err
+    }
+    return deliveries, nil
+}
+
+func (m *MongoReadDB) CountWebhookDeliveries(webhookID string) (int64, error) {
+    webhookDeliveriesColl := m.client.Database(m.database).Collection(webhookDeliveriesCollection)
+    return webhookDeliveriesColl.CountDocuments(context.TODO(), bson.D{{Key: "webhookId", Value: webhookID}})
+}
+
+func (m *MongoReadDB) GetDueWebhookDeliveries(now int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+    webhookDeliveriesColl := m.client.Database(m.database).Collection(webhookDeliveriesCollection)
+
+    findOptions := options.Find()
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.M{"nextAttempt": 1})
+
+    filter := bson.D{
+        {Key: "status", Value: "pending"},
+        {Key: "nextAttempt", Value: bson.D{{Key: "$lte", Value: now}}},
+    }
+
+    ctx := context.TODO()
+    cursor, err := webhookDeliveriesColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var deliveries []*types.WebhookDeliveryDoc
+    if err = cursor.All(ctx, &deliveries); err != nil {
+        return nil, err
+    }
+    return deliveries, nil
+}
+
+func (m *MongoReadDB) GetDeadLetter(id string) (*types.DeadLetterDoc, error) {
+    deadLettersColl := m.client.Database(m.database).Collection(deadLettersCollection)
+    deadLetterResult := deadLettersColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: id}},
+    )
+    deadLetterDoc := &types.DeadLetterDoc{}
+    err := deadLetterResult.Decode(deadLetterDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.DeadLetterDoc{}, nil
+        }
+        return &types.DeadLetterDoc{}, err
+    }
+    return deadLetterDoc, nil
+}
+
+func deadLettersFilter(stream string) bson.D {
+    if stream == "" {
+        return bson.D{}
+    }
+    return bson.D{{Key: "stream", Value: stream}}
+}
+
+func (m *MongoReadDB) GetDeadLetters(stream string, skip int64, limit int64) ([]*types.DeadLetterDoc, error) {
+    deadLettersColl := m.client.Database(m.database).Collection(deadLettersCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+
+    ctx := context.TODO()
+    cursor, err := deadLettersColl.Find(ctx, deadLettersFilter(stream), findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var deadLetters []*types.DeadLetterDoc
+    if err = cursor.All(ctx, &deadLetters); err != nil {
+        return nil, err
+    }
+    return deadLetters, nil
+}
+
+func (m *MongoReadDB) CountDeadLetters(stream string) (int64, error) {
+    deadLettersColl := m.client.Database(m.database).Collection(deadLettersCollection)
+    return deadLettersColl.CountDocuments(context.TODO(), deadLettersFilter(stream))
+}
+
+func (m *MongoReadDB) GetPrices(from int64, to int64) ([]*types.PriceDoc, error) {
+    pricesColl := m.client.Database(m.database).Collection(pricesCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSort(bson.D{{Key: "_id", Value: 1}})
+
+    ctx := context.TODO()
+    filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}}}
+    cursor, err := pricesColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var prices []*types.PriceDoc
+    if err = cursor.All(ctx, &prices); err != nil {
+        return nil, err
+    }
+    return prices, nil
+}
+
+func (m *MongoReadDB) GetPriceAt(timestamp int64) (*types.PriceDoc, error) {
+    return m.lastPrice(bson.D{{Key: "_id", Value: bson.D{{Key: "$lte", Value: timestamp}}}})
+}
+
+func (m *MongoReadDB) GetLastPrice(source string) (*types.PriceDoc, error) {
+    return m.lastPrice(bson.D{{Key: "source", Value: source}})
+}
+
+func (m *MongoReadDB) lastPrice(filter bson.D) (*types.PriceDoc, error) {
+    pricesColl := m.client.Database(m.database).Collection(pricesCollection)
+    priceResult := pricesColl.FindOne(
+        context.TODO(),
+        filter,
+        options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}),
+    )
+    priceDoc := &types.PriceDoc{}
+    err := priceResult.Decode(priceDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.PriceDoc{}, nil
+        }
+        return &types.PriceDoc{}, err
+    }
+    return priceDoc, nil
+}
+
+func (m *MongoReadDB) GetTransactionsTotals(minLayer uint32, maxLayer uint32) (*types.AggregationTransactionsTotals, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    match := bson.D{
+        {Key: "$match", Value: bson.D{
+            {Key: "balance_applied", Value: true},
+            {Key: "layer", Value: bson.D{
+                {Key: "$gte", Value: minLayer},
+                {Key: "$lt", Value: maxLayer},
+            }},
+        }},
+    }
+    group := bson.D{
+        {Key: "$group", Value: bson.D{
+            {Key: "_id", Value: nil},
+            {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+            {Key: "volume", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+        }},
+    }
+
+    ctx := context.TODO()
+    cursor, err := transactionsColl.Aggregate(ctx, mongo.Pipeline{match, group})
+    if err != nil {
+        return nil, err
+    }
+    var results []*types.AggregationTransactionsTotals
+    if err = cursor.All(ctx, &results); err != nil {
+        return nil, err
+    }
+    if len(results) == 0 {
+        return &types.AggregationTransactionsTotals{}, nil
+    }
+    return results[0], nil
+}
+
+func (m *MongoReadDB) CountNewAccounts(minLayer uint32, maxLayer uint32) (int64, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    ctx := context.TODO()
+
+    sources := []struct {
+        coll   *mongo.Collection
+        field  string
+        filter bson.D
+    }{
+        {coll: rewardsColl, field: "coinbase", filter: bson.D{}},
+        {coll: transactionsColl, field: "principal_account", filter: bson.D{{Key: "balance_applied", Value: true}}},
+        {coll: transactionsColl, field: "receiver_account", filter: bson.D{{Key: "balance_applied", Value: true}}},
+    }
+
+    active := make(map[string]bool)
+    for _, source := range sources {
+        filter := append(bson.D{{Key: "layer", Value: bson.D{
+            {Key: "$gte", Value: minLayer},
+            {Key: "$lt", Value: maxLayer},
+        }}}, source.filter...)
+        addresses, err := source.coll.Distinct(ctx, source.field, filter)
+        if err != nil {
+            return 0, err
+        }
+        for _, address := range addresses {
+            if address, ok := address.(string); ok && address != "" {
+                active[address] = true
+            }
+        }
+    }
+
+    // the active accounts seen before minLayer are not new
+    candidates := make([]string, 0, len(active))
+    for address := range active {
+        candidates = append(candidates, address)
+    }
+    for start := 0; start < len(candidates); start += newAccountsBatch {
+        batch := candidates[start:min(start+newAccountsBatch, len(candidates))]
+        for _, source := range sources {
+            filter := append(bson.D{
+                {Key: source.field, Value: bson.D{{Key: "$in", Value: batch}}},
+                {Key: "layer", Value: bson.D{{Key: "$lt", Value: minLayer}}},
+            }, source.filter...)
+            seen, err := source.coll.Distinct(ctx, source.field, filter)
+            if err != nil {
+                return 0, err
+            }
+            for _, address := range seen {
+                if address, ok := address.(string); ok {
+                    delete(active, address)
+                }
+            }
+        }
+    }
+    return int64(len(active)), nil
+}
+
+func (m *MongoReadDB) CountMalfeasance(from int64, to int64) (int64, error) {
+    nodesColl := m.client.Database(m.database).Collection(nodesCollection)
+    return nodesColl.CountDocuments(context.TODO(), bson.D{
+        {Key: "malfeasance.received", Value: bson.D{
+            {Key: "$gte", Value: from},
+            {Key: "$lt", Value: to},
+        }},
+    })
+}
+
+func (m *MongoReadDB) GetStats(period string, from int64, to int64) ([]*types.StatsDoc, error) {
+    statsColl := m.client.Database(m.database).Collection(statsCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSort(bson.D{{Key: "timestamp", Value: 1}})
+
+    ctx := context.TODO()
+    filter := bson.D{
+        {Key: "period", Value: period},
+        {Key: "timestamp", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}},
+    }
+    cursor, err := statsColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var stats []*types.StatsDoc
+    if err = cursor.All(ctx, &stats); err != nil {
+        return nil, err
+    }
+    return stats, nil
+}
+
+func (m *MongoReadDB) GetLastCompleteStats(period string) (*types.StatsDoc, error) {
+    statsColl := m.client.Database(m.database).Collection(statsCollection)
+    statsResult := statsColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "period", Value: period}, {Key: "complete", Value: true}},
+        options.FindOne().SetSort(bson.D{{Key: "timestamp", Value: -1}}),
+    )
+    statsDoc := &types.StatsDoc{}
+    err := statsResult.Decode(statsDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.StatsDoc{}, nil
+        }
+        return &types.StatsDoc{}, err
+    }
+    return statsDoc, nil
+}
+
+func (m *MongoReadDB) GetTransactionDetails(id string) (*types.TransactionDetailsDoc, error) {
+    transactionDetailsColl := m.client.Database(m.database).Collection(transactionDetailsCollection)
+    detailsDoc := &types.TransactionDetailsDoc{}
+    err := transactionDetailsColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: id}}).Decode(detailsDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.TransactionDetailsDoc{}, nil
+        }
+        return &types.TransactionDetailsDoc{}, err
+    }
+    return detailsDoc, nil
+}
+
+func (m *MongoReadDB) GetNodesRewardsTotals(minLayer uint32, maxLayer uint32) ([]*types.AggregationNodeRewards, error) {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
+
+    match := bson.D{
+        {Key: "$match", Value: bson.D{
+            {Key: "layer", Value: bson.D{
+                {Key: "$gte", Value: minLayer},
+                {Key: "$lt", Value: maxLayer},
+            }},
+        }},
+    }
+
+    group := bson.D{
+        {Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$node_id"},
+            {Key: "sum", Value: bson.D{{Key: "$sum", Value: "$totalReward"}}},
+            {Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+        }},
+    }
+
+    ctx := context.TODO()
+    cursor, err := rewardsColl.Aggregate(ctx, mongo.Pipeline{match, group})
+    if err != nil {
+        return nil, err
+    }
+
+    var totals []*types.AggregationNodeRewards
+    if err = cursor.All(ctx, &totals); err != nil {
+        return nil, err
+    }
+    return totals, nil
+}
+
+func (m *MongoReadDB) GetLastPerformanceEpoch() (int64, error) {
+    nodePerformanceColl := m.client.Database(m.database).Collection(nodePerformanceCollection)
+    performanceResult := nodePerformanceColl.FindOne(
+        context.TODO(),
+        bson.D{},
+        options.FindOne().SetSort(bson.D{{Key: "epoch", Value: -1}}),
+    )
+    performanceDoc := &types.NodePerformanceDoc{}
+    err := performanceResult.Decode(performanceDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return -1, nil
+        }
+        return -1, err
+    }
+    return performanceDoc.Epoch, nil
+}
+
+func (m *MongoReadDB) GetNodePerformance(node string, skip int64, limit int64, sort int8) ([]*types.NodePerformanceDoc, error) {
+    nodePerformanceColl := m.client.Database(m.database).Collection(nodePerformanceCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSkip(skip)
+    findOptions.SetLimit(limit)
+    findOptions.SetSort(bson.M{"epoch": sort})
+
+    ctx := context.TODO()
+    cursor, err := nodePerformanceColl.Find(ctx, bson.D{{Key: "nodeId", Value: node}}, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var performance []*types.NodePerformanceDoc
+    if err = cursor.All(ctx, &performance); err != nil {
+        return nil, err
+    }
+    return performance, nil
+}
+
+func (m *MongoReadDB) CountNodePerformance(node string) (int64, error) {
+    nodePerformanceColl := m.client.Database(m.database).Collection(nodePerformanceCollection)
+    return nodePerformanceColl.CountDocuments(context.TODO(), bson.D{{Key: "nodeId", Value: node}})
+}
+
+func (m *MongoReadDB) GetAccountPerformance(account string, epoch int64) ([]*types.NodePerformanceDoc, error) {
+    nodePerformanceColl := m.client.Database(m.database).Collection(nodePerformanceCollection)
+
+    findOptions := options.Find()
+    findOptions.SetSort(bson.D{{Key: "efficiency", Value: 1}, {Key: "nodeId", Value: 1}})
+
+    ctx := context.TODO()
+    filter := bson.D{
+        {Key: "coinbase", Value: account},
+        {Key: "epoch", Value: epoch},
+    }
+    cursor, err := nodePerformanceColl.Find(ctx, filter, findOptions)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var performance []*types.NodePerformanceDoc
+    if err = cursor.All(ctx, &performance); err != nil {
+        return nil, err
+    }
+    return performance, nil
+}
+
+func (m *MongoReadDB) GetAccountPerformanceEpochs(account string, skip int64, limit int64, sort int8) ([]*types.AggregationPerformance, error) {
+    nodePerformanceColl := m.client.Database(m.database).Collection(nodePerformanceCollection)
+
+    match := bson.D{
+        {Key: "$match", Value: bson.D{
+            {Key: "coinbase", Value: account},
+        }},
+    }
+
+    group := bson.D{
+        {Key: "$group", Value: bson.D{
+            {Key: "_id", Value: "$epoch"},
+            {Key: "nodes", Value: bson.D{{Key: "$sum", Value: 1}}},
+            {Key: "noRewardsNodes", Value: bson.D{{Key: "$sum", Value: bson.D{
+                {Key: "$cond", Value: bson.A{"$noRewards", 1, 0}},
+            }}}},
+            {Key: "eligibleSlots", Value: bson.D{{Key: "$sum", Value: "$eligibleSlots"}}},
+            {Key: "rewardedSlots", Value: bson.D{{Key: "$sum", Value: "$rewardedSlots"}}},
+            {Key: "missedSlots", Value: bson.D{{Key: "$sum", Value: "$missedSlots"}}},
+            {Key: "expectedRewards", Value: bson.D{{Key: "$sum", Value: "$expectedRewards"}}},
+            {Key: "rewards", Value: bson.D{{Key: "$sum", Value: "$rewards"}}},
+        }},
+    }
+
+    pipeline := mongo.Pipeline{match, group, {{Key: "$sort", Value: bson.D{{Key: "_id", Value: sort}}}}}
+    if skip > 0 {
+        pipeline = append(pipeline, bson.D{{Key: "$skip", Value: skip}})
+    }
+    if limit > 0 {
+        pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+    }
+
+    ctx := context.TODO()
+    cursor, err := nodePerformanceColl.Aggregate(ctx, pipeline)
+    if err != nil {
+        return nil, err
+    }
+
+    var epochs []*types.AggregationPerformance
+    if err = cursor.All(ctx, &epochs); err != nil {
+        return nil, err
+    }
+    return epochs, nil
+}
+
+func (m *MongoReadDB) CountAccountPerformanceEpochs(account string) (int64, error) {
+    nodePerformanceColl := m.client.Database(m.database).Collection(nodePerformanceCollection)
+    epochs, err := nodePerformanceColl.Distinct(context.TODO(), "epoch", bson.D{{Key: "coinbase", Value: account}})
+    if err != nil {
+        return 0, err
+    }
+    return int64(len(epochs)), nil
+}
+
+func (m *MongoReadDB) GetAccountTemplate(address string) (*types.AccountTemplateDoc, error) {
+    accountTemplatesColl := m.client.Database(m.database).Collection(accountTemplatesCollection)
+    templateDoc := &types.AccountTemplateDoc{}
+    err := accountTemplatesColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: address}}).Decode(templateDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return &types.AccountTemplateDoc{}, nil
+        }
+        return &types.AccountTemplateDoc{}, err
+    }
+    return templateDoc, nil
+}
+
+func (m *MongoReadDB) GetVaultsOfOwner(owner string) ([]*types.AccountTemplateDoc, error) {
+    accountTemplatesColl := m.client.Database(m.database).Collection(accountTemplatesCollection)
+    cursor, err := accountTemplatesColl.Find(
+        context.TODO(),
+        bson.D{{Key: "owner", Value: owner}, {Key: "template", Value: transactionparsertypes.TemplateVault}},
+        options.Find().SetSort(bson.D{{Key: "spawnLayer", Value: 1}, {Key: "_id", Value: 1}}),
+    )
+    if err != nil {
+        return nil, err
+    }
+
+    var vaults []*types.AccountTemplateDoc
+    if err = cursor.All(context.TODO(), &vaults); err != nil {
+        return nil, err
+    }
+    return vaults, nil
+}
+
+func (m *MongoReadDB) GetDrainedAmount(vault string) (uint64, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    cursor, err := transactionsColl.Aggregate(context.TODO(), mongo.Pipeline{
+        {{Key: "$match", Value: bson.D{
+            {Key: "vault_account", Value: vault},
+            {Key: "type", Value: transactionparsertypes.TypeDrainVault},
+            {Key: "balance_applied", Value: true},
+        }}},
+        {{Key: "$group", Value: bson.D{
+            {Key: "_id", Value: nil},
+            {Key: "sum", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+        }}},
+    })
+    if err != nil {
+        return 0, err
+    }
+
+    var totals []struct {
+        Sum int64 `bson:"sum"`
+    }
+    if err = cursor.All(context.TODO(), &totals); err != nil {
+        return 0, err
+    }
+    if len(totals) == 0 {
+        return 0, nil
+    }
+    return uint64(totals[0].Sum), nil
+}
+
+func (m *MongoReadDB) GetLastCounter(account string) (int64, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    transactionResult := transactionsColl.FindOne(
+        context.TODO(),
+        bson.D{{Key: "principal_account", Value: account}, {Key: "complete", Value: true}},
+        options.FindOne().SetSort(bson.D{{Key: "counter", Value: -1}}),
+    )
+    transactionDoc := &types.TransactionDoc{}
+    err := transactionResult.Decode(transactionDoc)
+    if err != nil {
+        if err == mongo.ErrNoDocuments {
+            return -1, nil
+        }
+        return -1, err
+    }
+    return int64(transactionDoc.Counter), nil
+}
+
+func (m *MongoReadDB) GetPendingTransactions(account string) ([]*types.TransactionDoc, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    ctx := context.TODO()
+    // the transactions saved before they could be dropped have no dropped field
+    cursor, err := transactionsColl.Find(
+        ctx,
+        bson.M{
+            "$or": []bson.M{
+                {"principal_account": account},
+                {"receiver_account": account},
+            },
+            "complete": false,
+            "dropped":  bson.M{"$ne": true},
+        },
+        options.Find().SetSort(bson.D{{Key: "counter", Value: 1}, {Key: "published", Value: 1}, {Key: "_id", Value: 1}}),
+    )
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var transactions []*types.TransactionDoc
+    if err = cursor.All(ctx, &transactions); err != nil {
+        return nil, err
+    }
+    return transactions, nil
+}
+
+// keysetSort orders by key then _id, so that a page can start after the last document of the previous one.
+func keysetSort(key string, sort int8) bson.D {
+    return bson.D{{Key: key, Value: sort}, {Key: "_id", Value: sort}}
+}
+
+// afterCursor restricts filter to the documents after cursor in the keysetSort order.
+func afterCursor(filter bson.D, key string, sort int8, after *types.Cursor) bson.D {
+    if after == nil {
+        return filter
+    }
+    bound, next := "$gte", "$gt"
+    if sort < 0 {
+        bound, next = "$lte", "$lt"
+    }
+    // the bound on key alone lets the query use the index range
+    return append(filter, bson.E{Key: "$and", Value: bson.A{
+        bson.D{{Key: key, Value: bson.D{{Key: bound, Value: after.Key}}}},
+        bson.D{{Key: "$or", Value: bson.A{
+            bson.D{{Key: key, Value: bson.D{{Key: next, Value: after.Key}}}},
+            bson.D{{Key: key, Value: after.Key}, {Key: "_id", Value: bson.D{{Key: next, Value: after.ID}}}},
+        }}},
+    }})
+}
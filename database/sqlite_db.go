@@ -0,0 +1,572 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/spacemeshos/go-spacemesh/sql"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// SQLiteDB is an embedded implementation of both ReadDB and WriteDB. It keeps the same
+// documents as the mongo collections in tables of a single sqlite file.
+type SQLiteDB struct {
+	db *sql.Database
+	// hrp encodes the addresses parsed from raw transactions
+	hrp string
+}
+
+var sqliteSchema = []string{
+	`create table if not exists layers (
+		id              integer primary key,
+		status          integer not null default 0,
+		published       integer not null default 0,
+		last_applied    integer not null default 0,
+		revert_boundary integer not null default 0,
+		reverts         integer not null default 0,
+		applied_block   text not null default ''
+	);`,
+	`create table if not exists layer_reverts (
+		layer                 integer not null,
+		reverted_at           integer not null,
+		boundary              integer not null,
+		trigger               text not null,
+		rewards_reverted      integer not null,
+		rewards_amount        integer not null,
+		transactions_reverted integer not null,
+		blocks                text not null
+	);`,
+	`create index if not exists layer_reverts_by_layer on layer_reverts (layer, reverted_at);`,
+	`create table if not exists rewards (
+		id           text primary key,
+		node_id      text not null,
+		coinbase     text not null,
+		atx_id       text not null,
+		layer_reward integer not null,
+		total_reward integer not null,
+		layer        integer not null,
+		published    integer not null default 0
+	);`,
+	`create index if not exists rewards_by_coinbase on rewards (coinbase, layer, id);`,
+	`create index if not exists rewards_by_node on rewards (node_id, layer);`,
+	`create index if not exists rewards_by_layer on rewards (layer);`,
+	`create table if not exists atxs (
+		id                  text primary key,
+		node_id             text not null,
+		coinbase            text not null,
+		publish_epoch       integer not null,
+		effective_num_units integer not null,
+		base_tick           integer not null,
+		weight              integer not null,
+		tick_count          integer not null,
+		sequence            integer not null,
+		received            integer not null
+	);`,
+	`create index if not exists atxs_by_epoch on atxs (publish_epoch, effective_num_units, id);`,
+	`create index if not exists atxs_by_node on atxs (node_id, publish_epoch);`,
+	`create index if not exists atxs_by_coinbase on atxs (coinbase, publish_epoch);`,
+	`create table if not exists atxs_epochs (
+		epoch                     integer primary key,
+		total_effective_num_units integer not null default 0,
+		total_weight              integer not null default 0,
+		total_atx                 integer not null default 0
+	);`,
+	`create table if not exists account_atxs_epochs (
+		coinbase                  text not null,
+		publish_epoch             integer not null,
+		total_effective_num_units integer not null default 0,
+		total_weight              integer not null default 0,
+		total_atx                 integer not null default 0,
+		primary key (coinbase, publish_epoch)
+	);`,
+	`create index if not exists account_atxs_epochs_by_weight on account_atxs_epochs (publish_epoch, total_weight);`,
+	`create table if not exists nodes (
+		id                   text primary key,
+		malfeasance_received integer
+	);`,
+	`create table if not exists node_atxs (
+		node_id             text not null,
+		coinbase            text not null,
+		publish_epoch       integer not null,
+		effective_num_units integer not null,
+		weight              integer not null,
+		sequence            integer not null,
+		received            integer not null,
+		primary key (node_id, coinbase, publish_epoch, effective_num_units, weight, sequence, received)
+	);`,
+	`create table if not exists network_info (
+		id                 text primary key,
+		circulating_supply integer not null default 0
+	);`,
+	`create table if not exists accounts (
+		address       text primary key,
+		balance       integer not null default 0,
+		total_rewards integer not null default 0,
+		fees          integer not null default 0,
+		sent          integer not null default 0,
+		received      integer not null default 0
+	);`,
+	`create index if not exists accounts_by_balance on accounts (balance, address);`,
+	`create table if not exists transactions (
+		id                text primary key,
+		status            integer not null default 0,
+		principal_account text not null default '',
+		receiver_account  text not null default '',
+		vault_account     text not null default '',
+		fee               integer not null default 0,
+		gas               integer not null default 0,
+		gas_price         integer not null default 0,
+		amount            integer not null default 0,
+		layer             integer not null default 0,
+		counter           integer not null default 0,
+		method            integer not null default 0,
+		type              integer not null default 0,
+		complete          integer not null default 0,
+		block_id          text not null default '',
+		published         integer not null default 0,
+		balance_applied   integer not null default 0,
+		dropped           integer not null default 0
+	);`,
+	`create index if not exists transactions_by_principal on transactions (principal_account, layer);`,
+	`create index if not exists transactions_by_receiver on transactions (receiver_account, layer);`,
+	`create index if not exists transactions_by_vault on transactions (vault_account, layer);`,
+	`create index if not exists transactions_by_layer on transactions (layer);`,
+	`create index if not exists transactions_pending on transactions (complete, dropped, published);`,
+	`create table if not exists transaction_details (
+		id                    text primary key,
+		raw                   text not null,
+		template              text not null,
+		public_keys           text not null,
+		required_signers      integer not null,
+		owner                 text not null,
+		total_amount          integer not null,
+		initial_unlock_amount integer not null,
+		vesting_start         integer not null,
+		vesting_end           integer not null,
+		signature             text not null,
+		signatures            text not null,
+		addresses             text not null,
+		message               text not null
+	);`,
+	`create table if not exists account_templates (
+		address               text primary key,
+		template              text not null,
+		spawned               integer not null default 0,
+		spawn_layer           integer not null default 0,
+		spawn_transaction     text not null default '',
+		public_keys           text not null default '[]',
+		required_signers      integer not null default 0,
+		owner                 text not null default '',
+		total_amount          integer not null default 0,
+		initial_unlock_amount integer not null default 0,
+		vesting_start         integer not null default 0,
+		vesting_end           integer not null default 0
+	);`,
+	`create index if not exists account_templates_by_owner on account_templates (owner);`,
+	`create index if not exists account_templates_by_spawn on account_templates (spawn_transaction);`,
+	`create index if not exists transactions_by_complete on transactions (complete, layer, id);`,
+	`create table if not exists api_keys (
+		id          text primary key,
+		name        text not null,
+		rate        real not null default 0,
+		burst       integer not null default 0,
+		daily_quota integer not null default 0,
+		created_at  integer not null,
+		revoked_at  integer not null default 0
+	);`,
+	`create table if not exists webhooks (
+		id         text primary key,
+		owner      text not null,
+		url        text not null,
+		secret     text not null,
+		event      text not null,
+		account    text not null default '',
+		node       text not null default '',
+		created_at integer not null
+	);`,
+	`create index if not exists webhooks_by_owner on webhooks (owner, created_at);`,
+	`create table if not exists webhook_deliveries (
+		id           text primary key,
+		webhook_id   text not null,
+		event        text not null,
+		payload      text not null,
+		status       text not null,
+		attempts     integer not null default 0,
+		next_attempt integer not null default 0,
+		status_code  integer not null default 0,
+		last_error   text not null default '',
+		created_at   integer not null,
+		delivered_at integer not null default 0
+	);`,
+	`create index if not exists webhook_deliveries_by_webhook on webhook_deliveries (webhook_id, created_at);`,
+	`create index if not exists webhook_deliveries_by_status on webhook_deliveries (status, next_attempt);`,
+	`create table if not exists dead_letters (
+		id         text primary key,
+		stream     text not null,
+		subject    text not null,
+		sequence   integer not null default 0,
+		published  integer not null default 0,
+		payload    text not null,
+		error      text not null,
+		deliveries integer not null default 0,
+		created_at integer not null
+	);`,
+	`create index if not exists dead_letters_by_stream on dead_letters (stream, created_at);`,
+	`create index if not exists dead_letters_by_created on dead_letters (created_at);`,
+	`create table if not exists prices (
+		timestamp integer primary key,
+		price     real not null,
+		source    text not null
+	);`,
+	`create index if not exists prices_by_source on prices (source, timestamp);`,
+	`create table if not exists stats (
+		id                        text primary key,
+		period                    text not null,
+		period_index              integer not null,
+		timestamp                 integer not null,
+		first_layer               integer not null,
+		last_layer                integer not null,
+		complete                  integer not null,
+		active_smeshers           integer not null,
+		total_weight              integer not null,
+		effective_units_committed integer not null,
+		total_rewards             integer not null,
+		vested                    integer not null,
+		circulating_supply        integer not null,
+		rewards                   integer not null,
+		transactions              integer not null,
+		transactions_volume       integer not null,
+		new_accounts              integer not null,
+		malfeasance               integer not null
+	);`,
+	`create index if not exists stats_by_period on stats (period, timestamp);`,
+	`create index if not exists nodes_by_malfeasance on nodes (malfeasance_received);`,
+	`create table if not exists node_performance (
+		id                  text primary key,
+		node_id             text not null,
+		coinbase            text not null,
+		epoch               integer not null,
+		weight              integer not null,
+		effective_num_units integer not null,
+		eligible_slots      integer not null,
+		rewarded_slots      integer not null,
+		missed_slots        integer not null,
+		expected_rewards    integer not null,
+		rewards             integer not null,
+		efficiency          real not null,
+		no_rewards          integer not null
+	);`,
+	`create index if not exists node_performance_by_node on node_performance (node_id, epoch);`,
+	`create index if not exists node_performance_by_coinbase on node_performance (coinbase, epoch);`,
+	`create index if not exists node_performance_by_epoch on node_performance (epoch);`,
+	`create table if not exists processed_messages (
+		id           text primary key,
+		stream       text not null,
+		sequence     integer not null,
+		msg_id       text not null default '',
+		processed_at integer not null
+	);`,
+	`create index if not exists processed_messages_by_time on processed_messages (processed_at);`,
+}
+
+// NewSQLiteDB opens (or creates) the sqlite database at uri, for example "file:state-api.sql", of the
+// network with the address prefix hrp.
+func NewSQLiteDB(uri string, hrp string) (*SQLiteDB, error) {
+	db, err := sql.Open(uri, sql.WithMigrations([]sql.Migration{}))
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range sqliteSchema {
+		if _, err := db.Exec(stmt, nil, nil); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("create schema: %w", err)
+		}
+	}
+	slog.Info("Created sqlite db")
+	return &SQLiteDB{
+		db:  db,
+		hrp: hrp,
+	}, nil
+}
+
+func (s *SQLiteDB) Ping(ctx context.Context) error {
+	_, err := s.db.Exec("select 1", nil, nil)
+	return err
+}
+
+func (s *SQLiteDB) CloseRead() {
+	s.db.Close()
+}
+
+func (s *SQLiteDB) CloseWrite() {
+	s.db.Close()
+}
+
+func sortOrder(sort int8) string {
+	if sort < 0 {
+		return "desc"
+	}
+	return "asc"
+}
+
+func boolToInt(value bool) int64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+const accountColumns = `address, balance, total_rewards, fees, sent, received`
+
+func decodeAccount(stmt *sql.Statement) *types.AccountDoc {
+	return &types.AccountDoc{
+		Address:      stmt.ColumnText(0),
+		Balance:      uint64(stmt.ColumnInt64(1)),
+		TotalRewards: uint64(stmt.ColumnInt64(2)),
+		Fees:         uint64(stmt.ColumnInt64(3)),
+		Sent:         uint64(stmt.ColumnInt64(4)),
+		Received:     uint64(stmt.ColumnInt64(5)),
+	}
+}
+
+const rewardColumns = `id, node_id, coinbase, atx_id, layer_reward, total_reward, layer, published`
+
+func decodeReward(stmt *sql.Statement) *types.RewardsDoc {
+	return &types.RewardsDoc{
+		Id:          stmt.ColumnText(0),
+		NodeId:      stmt.ColumnText(1),
+		Coinbase:    stmt.ColumnText(2),
+		AtxID:       stmt.ColumnText(3),
+		LayerReward: stmt.ColumnInt64(4),
+		TotalReward: stmt.ColumnInt64(5),
+		Layer:       stmt.ColumnInt64(6),
+		Published:   stmt.ColumnInt64(7),
+	}
+}
+
+const atxColumns = `id, node_id, coinbase, publish_epoch, effective_num_units, base_tick, weight, tick_count, sequence, received`
+
+func decodeAtx(stmt *sql.Statement) *types.AtxDoc {
+	return &types.AtxDoc{
+		AtxID:             stmt.ColumnText(0),
+		NodeID:            stmt.ColumnText(1),
+		Coinbase:          stmt.ColumnText(2),
+		PublishEpoch:      uint32(stmt.ColumnInt64(3)),
+		EffectiveNumUnits: uint32(stmt.ColumnInt64(4)),
+		BaseTick:          uint64(stmt.ColumnInt64(5)),
+		Weight:            uint64(stmt.ColumnInt64(6)),
+		TickCount:         uint64(stmt.ColumnInt64(7)),
+		Sequence:          uint64(stmt.ColumnInt64(8)),
+		Received:          stmt.ColumnInt64(9),
+	}
+}
+
+const transactionColumns = `id, status, principal_account, receiver_account, vault_account, fee, gas, gas_price,
+	amount, layer, counter, method, type, complete, block_id, published, balance_applied, dropped`
+
+func decodeTransaction(stmt *sql.Statement) *types.TransactionDoc {
+	return &types.TransactionDoc{
+		ID:              stmt.ColumnText(0),
+		Status:          uint8(stmt.ColumnInt64(1)),
+		PrincipaAccount: stmt.ColumnText(2),
+		ReceiverAccount: stmt.ColumnText(3),
+		VaultAccount:    stmt.ColumnText(4),
+		Fee:             uint64(stmt.ColumnInt64(5)),
+		Gas:             uint64(stmt.ColumnInt64(6)),
+		GasPrice:        uint64(stmt.ColumnInt64(7)),
+		Amount:          uint64(stmt.ColumnInt64(8)),
+		Layer:           uint32(stmt.ColumnInt64(9)),
+		Counter:         uint64(stmt.ColumnInt64(10)),
+		Method:          uint8(stmt.ColumnInt64(11)),
+		Type:            uint8(stmt.ColumnInt64(12)),
+		Complete:        stmt.ColumnInt64(13) == 1,
+		BlockID:         stmt.ColumnText(14),
+		Published:       stmt.ColumnInt64(15),
+		BalanceApplied:  stmt.ColumnInt64(16) == 1,
+		Dropped:         stmt.ColumnInt64(17) == 1,
+	}
+}
+
+// the lists of the transaction details are kept as json
+const transactionDetailsColumns = `id, raw, template, public_keys, required_signers, owner, total_amount,
+	initial_unlock_amount, vesting_start, vesting_end, signature, signatures, addresses, message`
+
+func decodeTransactionDetails(stmt *sql.Statement) *types.TransactionDetailsDoc {
+	details := &types.TransactionDetailsDoc{
+		ID:                  stmt.ColumnText(0),
+		Raw:                 stmt.ColumnText(1),
+		Template:            stmt.ColumnText(2),
+		RequiredSigners:     uint8(stmt.ColumnInt64(4)),
+		Owner:               stmt.ColumnText(5),
+		TotalAmount:         uint64(stmt.ColumnInt64(6)),
+		InitialUnlockAmount: uint64(stmt.ColumnInt64(7)),
+		VestingStart:        uint32(stmt.ColumnInt64(8)),
+		VestingEnd:          uint32(stmt.ColumnInt64(9)),
+		Signature:           stmt.ColumnText(10),
+		Message:             stmt.ColumnText(13),
+	}
+	json.Unmarshal([]byte(stmt.ColumnText(3)), &details.PublicKeys)
+	json.Unmarshal([]byte(stmt.ColumnText(11)), &details.Signatures)
+	json.Unmarshal([]byte(stmt.ColumnText(12)), &details.Addresses)
+	return details
+}
+
+// the public keys of an account template are kept as json
+const accountTemplateColumns = `address, template, spawned, spawn_layer, spawn_transaction, public_keys,
+	required_signers, owner, total_amount, initial_unlock_amount, vesting_start, vesting_end`
+
+func decodeAccountTemplate(stmt *sql.Statement) *types.AccountTemplateDoc {
+	template := &types.AccountTemplateDoc{
+		Address:             stmt.ColumnText(0),
+		Template:            stmt.ColumnText(1),
+		Spawned:             stmt.ColumnInt64(2) == 1,
+		SpawnLayer:          uint32(stmt.ColumnInt64(3)),
+		SpawnTransaction:    stmt.ColumnText(4),
+		RequiredSigners:     uint8(stmt.ColumnInt64(6)),
+		Owner:               stmt.ColumnText(7),
+		TotalAmount:         uint64(stmt.ColumnInt64(8)),
+		InitialUnlockAmount: uint64(stmt.ColumnInt64(9)),
+		VestingStart:        uint32(stmt.ColumnInt64(10)),
+		VestingEnd:          uint32(stmt.ColumnInt64(11)),
+	}
+	json.Unmarshal([]byte(stmt.ColumnText(5)), &template.PublicKeys)
+	return template
+}
+
+const layerColumns = `id, status, published, last_applied, revert_boundary, reverts, applied_block`
+
+func decodeLayer(stmt *sql.Statement) *types.LayerDoc {
+	return &types.LayerDoc{
+		Layer:          stmt.ColumnInt64(0),
+		Status:         stmt.ColumnInt(1),
+		Published:      stmt.ColumnInt64(2),
+		LastApplied:    stmt.ColumnInt64(3),
+		RevertBoundary: stmt.ColumnInt64(4),
+		Reverts:        stmt.ColumnInt64(5),
+		AppliedBlock:   stmt.ColumnText(6),
+	}
+}
+
+const apiKeyColumns = `id, name, rate, burst, daily_quota, created_at, revoked_at`
+
+func decodeApiKey(stmt *sql.Statement) *types.ApiKeyDoc {
+	return &types.ApiKeyDoc{
+		ID:         stmt.ColumnText(0),
+		Name:       stmt.ColumnText(1),
+		Rate:       stmt.ColumnFloat(2),
+		Burst:      stmt.ColumnInt64(3),
+		DailyQuota: stmt.ColumnInt64(4),
+		CreatedAt:  stmt.ColumnInt64(5),
+		RevokedAt:  stmt.ColumnInt64(6),
+	}
+}
+
+const webhookColumns = `id, owner, url, secret, event, account, node, created_at`
+
+func decodeWebhook(stmt *sql.Statement) *types.WebhookDoc {
+	return &types.WebhookDoc{
+		ID:        stmt.ColumnText(0),
+		Owner:     stmt.ColumnText(1),
+		Url:       stmt.ColumnText(2),
+		Secret:    stmt.ColumnText(3),
+		Event:     stmt.ColumnText(4),
+		Account:   stmt.ColumnText(5),
+		Node:      stmt.ColumnText(6),
+		CreatedAt: stmt.ColumnInt64(7),
+	}
+}
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt, status_code,
+	last_error, created_at, delivered_at`
+
+func decodeWebhookDelivery(stmt *sql.Statement) *types.WebhookDeliveryDoc {
+	return &types.WebhookDeliveryDoc{
+		ID:          stmt.ColumnText(0),
+		WebhookID:   stmt.ColumnText(1),
+		Event:       stmt.ColumnText(2),
+		Payload:     stmt.ColumnText(3),
+		Status:      stmt.ColumnText(4),
+		Attempts:    stmt.ColumnInt64(5),
+		NextAttempt: stmt.ColumnInt64(6),
+		StatusCode:  stmt.ColumnInt64(7),
+		LastError:   stmt.ColumnText(8),
+		CreatedAt:   stmt.ColumnInt64(9),
+		DeliveredAt: stmt.ColumnInt64(10),
+	}
+}
+
+const priceColumns = `timestamp, price, source`
+
+func decodePrice(stmt *sql.Statement) *types.PriceDoc {
+	return &types.PriceDoc{
+		Timestamp: stmt.ColumnInt64(0),
+		Price:     stmt.ColumnFloat(1),
+		Source:    stmt.ColumnText(2),
+	}
+}
+
+const statsColumns = `id, period, period_index, timestamp, first_layer, last_layer, complete, active_smeshers, total_weight,
+	effective_units_committed, total_rewards, vested, circulating_supply, rewards, transactions, transactions_volume,
+	new_accounts, malfeasance`
+
+func decodeStats(stmt *sql.Statement) *types.StatsDoc {
+	return &types.StatsDoc{
+		ID:                      stmt.ColumnText(0),
+		Period:                  stmt.ColumnText(1),
+		Index:                   stmt.ColumnInt64(2),
+		Timestamp:               stmt.ColumnInt64(3),
+		FirstLayer:              stmt.ColumnInt64(4),
+		LastLayer:               stmt.ColumnInt64(5),
+		Complete:                stmt.ColumnInt(6) == 1,
+		ActiveSmeshers:          uint64(stmt.ColumnInt64(7)),
+		TotalWeight:             uint64(stmt.ColumnInt64(8)),
+		EffectiveUnitsCommitted: uint64(stmt.ColumnInt64(9)),
+		TotalRewards:            uint64(stmt.ColumnInt64(10)),
+		Vested:                  uint64(stmt.ColumnInt64(11)),
+		CirculatingSupply:       uint64(stmt.ColumnInt64(12)),
+		Rewards:                 uint64(stmt.ColumnInt64(13)),
+		Transactions:            uint64(stmt.ColumnInt64(14)),
+		TransactionsVolume:      uint64(stmt.ColumnInt64(15)),
+		NewAccounts:             uint64(stmt.ColumnInt64(16)),
+		Malfeasance:             uint64(stmt.ColumnInt64(17)),
+	}
+}
+
+const nodePerformanceColumns = `id, node_id, coinbase, epoch, weight, effective_num_units, eligible_slots, rewarded_slots,
+	missed_slots, expected_rewards, rewards, efficiency, no_rewards`
+
+func decodeNodePerformance(stmt *sql.Statement) *types.NodePerformanceDoc {
+	return &types.NodePerformanceDoc{
+		ID:                stmt.ColumnText(0),
+		NodeID:            stmt.ColumnText(1),
+		Coinbase:          stmt.ColumnText(2),
+		Epoch:             stmt.ColumnInt64(3),
+		Weight:            uint64(stmt.ColumnInt64(4)),
+		EffectiveNumUnits: uint64(stmt.ColumnInt64(5)),
+		EligibleSlots:     stmt.ColumnInt64(6),
+		RewardedSlots:     stmt.ColumnInt64(7),
+		MissedSlots:       stmt.ColumnInt64(8),
+		ExpectedRewards:   uint64(stmt.ColumnInt64(9)),
+		Rewards:           uint64(stmt.ColumnInt64(10)),
+		Efficiency:        stmt.ColumnFloat(11),
+		NoRewards:         stmt.ColumnInt(12) == 1,
+	}
+}
+
+const deadLetterColumns = `id, stream, subject, sequence, published, payload, error, deliveries, created_at`
+
+func decodeDeadLetter(stmt *sql.Statement) *types.DeadLetterDoc {
+	return &types.DeadLetterDoc{
+		ID:         stmt.ColumnText(0),
+		Stream:     stmt.ColumnText(1),
+		Subject:    stmt.ColumnText(2),
+		Sequence:   uint64(stmt.ColumnInt64(3)),
+		Published:  stmt.ColumnInt64(4),
+		Payload:    stmt.ColumnText(5),
+		Error:      stmt.ColumnText(6),
+		Deliveries: uint64(stmt.ColumnInt64(7)),
+		CreatedAt:  stmt.ColumnInt64(8),
+	}
+}
@@ -0,0 +1,984 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spacemeshos/go-spacemesh/sql"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+func bindArgs(args ...interface{}) sql.Encoder {
+	return func(stmt *sql.Statement) {
+		for i, arg := range args {
+			switch v := arg.(type) {
+			case string:
+				stmt.BindText(i+1, v)
+			case int:
+				stmt.BindInt64(i+1, int64(v))
+			case int64:
+				stmt.BindInt64(i+1, v)
+			case uint8:
+				stmt.BindInt64(i+1, int64(v))
+			case uint32:
+				stmt.BindInt64(i+1, int64(v))
+			case uint64:
+				stmt.BindInt64(i+1, int64(v))
+			case bool:
+				stmt.BindInt64(i+1, boolToInt(v))
+			case float64:
+				stmt.BindFloat(i+1, v)
+			default:
+				panic(fmt.Sprintf("unsupported sqlite argument %T", arg))
+			}
+		}
+	}
+}
+
+// pagination mirrors mongo semantics where a limit of 0 means no limit.
+func pagination(skip int64, limit int64) string {
+	if limit == 0 {
+		limit = -1
+	}
+	return fmt.Sprintf(" limit %d offset %d", limit, skip)
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " where " + strings.Join(conditions, " and ")
+}
+
+func layerRange(conditions []string, args []interface{}, firstLayer int, lastLayer int) ([]string, []interface{}) {
+	if firstLayer > -1 {
+		conditions = append(conditions, fmt.Sprintf("layer >= ?%d", len(args)+1))
+		args = append(args, firstLayer)
+	}
+	if lastLayer > -1 {
+		conditions = append(conditions, fmt.Sprintf("layer <= ?%d", len(args)+1))
+		args = append(args, lastLayer)
+	}
+	return conditions, args
+}
+
+// afterRow adds the condition for the rows after cursor in the keysetOrder of key and id.
+func afterRow(conditions []string, args []interface{}, key string, id string, sort int8, after *types.Cursor) ([]string, []interface{}) {
+	if after == nil {
+		return conditions, args
+	}
+	op := ">"
+	if sort < 0 {
+		op = "<"
+	}
+	conditions = append(conditions, fmt.Sprintf("(%s, %s) %s (?%d, ?%d)", key, id, op, len(args)+1, len(args)+2))
+	return conditions, append(args, after.Key, after.ID)
+}
+
+// keysetOrder orders by key then id, so that a page can start after the last row of the previous one.
+func keysetOrder(key string, id string, sort int8) string {
+	return fmt.Sprintf(" order by %s %s, %s %s", key, sortOrder(sort), id, sortOrder(sort))
+}
+
+func (s *SQLiteDB) count(query string, args ...interface{}) (int64, error) {
+	var count int64
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		count = stmt.ColumnInt64(0)
+		return false
+	})
+	return count, err
+}
+
+func (s *SQLiteDB) accounts(query string, args ...interface{}) ([]*types.AccountDoc, error) {
+	var accounts []*types.AccountDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		accounts = append(accounts, decodeAccount(stmt))
+		return true
+	})
+	return accounts, err
+}
+
+func (s *SQLiteDB) rewards(query string, args ...interface{}) ([]*types.RewardsDoc, error) {
+	var rewards []*types.RewardsDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		rewards = append(rewards, decodeReward(stmt))
+		return true
+	})
+	return rewards, err
+}
+
+func (s *SQLiteDB) atxs(query string, args ...interface{}) ([]*types.AtxDoc, error) {
+	var atxs []*types.AtxDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		atxs = append(atxs, decodeAtx(stmt))
+		return true
+	})
+	return atxs, err
+}
+
+func (s *SQLiteDB) transactions(query string, args ...interface{}) ([]*types.TransactionDoc, error) {
+	var transactions []*types.TransactionDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		transactions = append(transactions, decodeTransaction(stmt))
+		return true
+	})
+	return transactions, err
+}
+
+func (s *SQLiteDB) GetAccounts(skip int64, limit int64, sort int8, after *types.Cursor) ([]*types.AccountDoc, error) {
+	conditions, args := afterRow(nil, nil, "balance", "address", sort, after)
+	return s.accounts(`select `+accountColumns+` from accounts`+where(conditions)+
+		keysetOrder("balance", "address", sort)+pagination(skip, limit), args...)
+}
+
+func (s *SQLiteDB) GetAccount(account string) (*types.AccountDoc, error) {
+	accounts, err := s.accounts(`select `+accountColumns+` from accounts where address = ?1`, account)
+	if err != nil {
+		return &types.AccountDoc{}, err
+	}
+	if len(accounts) == 0 {
+		return &types.AccountDoc{}, nil
+	}
+	return accounts[0], nil
+}
+
+func (s *SQLiteDB) GetAccountsByAddress(accounts []string) ([]*types.AccountDoc, error) {
+	if len(accounts) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(accounts))
+	args := make([]interface{}, len(accounts))
+	for i, account := range accounts {
+		placeholders[i] = fmt.Sprintf("?%d", i+1)
+		args[i] = account
+	}
+	return s.accounts(`select `+accountColumns+` from accounts where address in (`+strings.Join(placeholders, ", ")+`)`, args...)
+}
+
+func (s *SQLiteDB) GetNode(nodeId string) (*types.NodeDoc, error) {
+	nodes, err := s.nodes(`select id, malfeasance_received from nodes where id = ?1`, nodeId)
+	if err != nil {
+		return &types.NodeDoc{}, err
+	}
+	if len(nodes) == 0 {
+		return &types.NodeDoc{}, nil
+	}
+	return nodes[0], nil
+}
+
+func (s *SQLiteDB) GetNodesById(nodeIds []string) ([]*types.NodeDoc, error) {
+	if len(nodeIds) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(nodeIds))
+	args := make([]interface{}, len(nodeIds))
+	for i, nodeId := range nodeIds {
+		placeholders[i] = fmt.Sprintf("?%d", i+1)
+		args[i] = nodeId
+	}
+	return s.nodes(`select id, malfeasance_received from nodes where id in (`+strings.Join(placeholders, ", ")+`)`, args...)
+}
+
+func (s *SQLiteDB) nodes(query string, args ...interface{}) ([]*types.NodeDoc, error) {
+	var nodes []*types.NodeDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		nodes = append(nodes, &types.NodeDoc{
+			ID: stmt.ColumnText(0),
+			Malfeasance: types.MalfeasanceNodeDoc{
+				Received: stmt.ColumnInt64(1),
+			},
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		node.Atxs = []types.NodeAtxDoc{}
+		_, err := s.db.Exec(`select coinbase, publish_epoch, effective_num_units, weight, sequence, received
+			from node_atxs where node_id = ?1 order by publish_epoch`,
+			bindArgs(node.ID), func(stmt *sql.Statement) bool {
+				node.Atxs = append(node.Atxs, types.NodeAtxDoc{
+					Coinbase:          stmt.ColumnText(0),
+					PublishEpoch:      uint32(stmt.ColumnInt64(1)),
+					EffectiveNumUnits: uint32(stmt.ColumnInt64(2)),
+					Weight:            uint64(stmt.ColumnInt64(3)),
+					Sequence:          uint64(stmt.ColumnInt64(4)),
+					Received:          stmt.ColumnInt64(5),
+				})
+				return true
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+func (s *SQLiteDB) GetTransaction(transactionId string) (*types.TransactionDoc, error) {
+	transactions, err := s.transactions(`select `+transactionColumns+` from transactions where id = ?1`, transactionId)
+	if err != nil {
+		return &types.TransactionDoc{}, err
+	}
+	if len(transactions) == 0 {
+		return &types.TransactionDoc{}, nil
+	}
+	return transactions[0], nil
+}
+
+func (s *SQLiteDB) GetReward(rewardId string) (*types.RewardsDoc, error) {
+	rewards, err := s.rewards(`select `+rewardColumns+` from rewards where id = ?1`, rewardId)
+	if err != nil {
+		return &types.RewardsDoc{}, err
+	}
+	if len(rewards) == 0 {
+		return &types.RewardsDoc{}, nil
+	}
+	return rewards[0], nil
+}
+
+func (s *SQLiteDB) CountTransactions(account string) (int64, error) {
+	return s.count(`select count(*) from transactions where principal_account = ?1 or receiver_account = ?1`, account)
+}
+
+func allTransactionsFilter(complete bool, method int, minAmount int) (string, []interface{}) {
+	conditions, args := allTransactionsConditions(complete, method, minAmount)
+	return where(conditions), args
+}
+
+func allTransactionsConditions(complete bool, method int, minAmount int) ([]string, []interface{}) {
+	conditions := []string{"complete = ?1"}
+	args := []interface{}{complete}
+	if method > -1 {
+		conditions = append(conditions, fmt.Sprintf("method = ?%d", len(args)+1))
+		args = append(args, method)
+	}
+	if minAmount > -1 {
+		conditions = append(conditions, fmt.Sprintf("amount >= ?%d", len(args)+1))
+		args = append(args, minAmount)
+	}
+	return conditions, args
+}
+
+func (s *SQLiteDB) CountAllTransactions(complete bool, method int, minAmount int) (int64, error) {
+	filter, args := allTransactionsFilter(complete, method, minAmount)
+	return s.count(`select count(*) from transactions`+filter, args...)
+}
+
+func (s *SQLiteDB) CountLayerTransactions(layer int) (int64, error) {
+	return s.count(`select count(*) from transactions where layer = ?1`, layer)
+}
+
+func (s *SQLiteDB) CountLayerRewards(layer int) (int64, error) {
+	return s.count(`select count(*) from rewards where layer = ?1`, layer)
+}
+
+func (s *SQLiteDB) CountRewards(account string, firstLayer int, lastLayer int) (int64, error) {
+	var conditions []string
+	var args []interface{}
+	if account != "" {
+		conditions = append(conditions, "coinbase = ?1")
+		args = append(args, account)
+	}
+	conditions, args = layerRange(conditions, args, firstLayer, lastLayer)
+	return s.count(`select count(*) from rewards`+where(conditions), args...)
+}
+
+func (s *SQLiteDB) CountNodeRewards(node string) (int64, error) {
+	return s.count(`select count(*) from rewards where node_id = ?1`, node)
+}
+
+func (s *SQLiteDB) CountNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
+	return s.count(`select count(*) from rewards where node_id = ?1 and layer >= ?2 and layer < ?3`, node, minLayer, maxLayer)
+}
+
+func (s *SQLiteDB) CountAccountsPostEpoch(epoch int) (int64, error) {
+	return s.count(`select count(distinct coinbase) from account_atxs_epochs where publish_epoch = ?1`, epoch)
+}
+
+func (s *SQLiteDB) GetAccountsGroup(accounts []string) (*types.AccountGroup, error) {
+	group := &types.AccountGroup{}
+	if len(accounts) == 0 {
+		return group, nil
+	}
+	placeholders := make([]string, len(accounts))
+	args := make([]interface{}, len(accounts))
+	for i, account := range accounts {
+		placeholders[i] = fmt.Sprintf("?%d", i+1)
+		args[i] = account
+	}
+	_, err := s.db.Exec(`select coalesce(sum(balance), 0), coalesce(sum(total_rewards), 0) from accounts
+		where address in (`+strings.Join(placeholders, ", ")+`)`,
+		bindArgs(args...), func(stmt *sql.Statement) bool {
+			group.Balance = stmt.ColumnInt64(0)
+			group.TotalRewards = stmt.ColumnInt64(1)
+			return false
+		})
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (s *SQLiteDB) GetAccountsPostEpoch(epoch int, skip int64, limit int64, sort int8) ([]*types.AccountAtxDoc, error) {
+	var results []*types.AccountAtxDoc
+	_, err := s.db.Exec(`select coinbase, publish_epoch, total_effective_num_units, total_weight, total_atx
+		from account_atxs_epochs where publish_epoch = ?1 order by total_weight `+sortOrder(sort)+pagination(skip, limit),
+		bindArgs(epoch), func(stmt *sql.Statement) bool {
+			results = append(results, &types.AccountAtxDoc{
+				Id: types.AccountAtxId{
+					Coinbase:     stmt.ColumnText(0),
+					PublishEpoch: uint32(stmt.ColumnInt64(1)),
+				},
+				TotalEffectiveNumUnits: uint32(stmt.ColumnInt64(2)),
+				TotalWeight:            uint64(stmt.ColumnInt64(3)),
+				TotalAtx:               uint64(stmt.ColumnInt64(4)),
+			})
+			return true
+		})
+	return results, err
+}
+
+func (s *SQLiteDB) SumNodeRewardsLayers(node string, minLayer uint32, maxLayer uint32) (int64, error) {
+	return s.count(`select coalesce(sum(total_reward), 0) from rewards where node_id = ?1 and layer >= ?2 and layer < ?3`,
+		node, minLayer, maxLayer)
+}
+
+func (s *SQLiteDB) SumRewardsLayers(account string, minLayer uint32, maxLayer uint32) (int64, error) {
+	if account != "" {
+		return s.count(`select coalesce(sum(total_reward), 0) from rewards where coinbase = ?1 and layer >= ?2 and layer < ?3`,
+			account, minLayer, maxLayer)
+	}
+	return s.count(`select coalesce(sum(total_reward), 0) from rewards where layer >= ?1 and layer < ?2`, minLayer, maxLayer)
+}
+
+func (s *SQLiteDB) GetRewards(account string, skip int64, limit int64, sort int8, firstLayer int, lastLayer int, after *types.Cursor) ([]*types.RewardsDoc, error) {
+	conditions, args := layerRange([]string{"coinbase = ?1"}, []interface{}{account}, firstLayer, lastLayer)
+	conditions, args = afterRow(conditions, args, "layer", "id", sort, after)
+	return s.rewards(`select `+rewardColumns+` from rewards`+where(conditions)+
+		keysetOrder("layer", "id", sort)+pagination(skip, limit), args...)
+}
+
+func (s *SQLiteDB) GetLayerRewards(layer int, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+	return s.rewards(`select `+rewardColumns+` from rewards where layer = ?1 order by layer `+sortOrder(sort)+
+		pagination(skip, limit), layer)
+}
+
+func (s *SQLiteDB) GetNodeRewards(node string, skip int64, limit int64, sort int8) ([]*types.RewardsDoc, error) {
+	return s.rewards(`select `+rewardColumns+` from rewards where node_id = ?1 order by layer `+sortOrder(sort)+
+		pagination(skip, limit), node)
+}
+
+func (s *SQLiteDB) atxTotals(query string, args ...interface{}) (*types.AggregationAtxTotals, error) {
+	totals := &types.AggregationAtxTotals{}
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		totals.TotalWeight = stmt.ColumnInt64(0)
+		totals.TotalEffectiveNumUnits = stmt.ColumnInt64(1)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (s *SQLiteDB) GetAtxWeightAccount(account string, epoch uint64) (*types.AggregationAtxTotals, error) {
+	return s.atxTotals(`select coalesce(sum(weight), 0), coalesce(sum(effective_num_units), 0) from atxs
+		where coinbase = ?1 and publish_epoch = ?2`, account, epoch)
+}
+
+func (s *SQLiteDB) GetAccountAtxList(account string, epoch uint64) ([]*types.AtxDoc, error) {
+	return s.atxs(`select `+atxColumns+` from atxs where coinbase = ?1 and publish_epoch = ?2`, account, epoch)
+}
+
+func (s *SQLiteDB) GetAtxWeightNode(node string, epoch uint64) (*types.AggregationAtxTotals, error) {
+	return s.atxTotals(`select coalesce(sum(weight), 0), coalesce(sum(effective_num_units), 0) from atxs
+		where node_id = ?1 and publish_epoch = ?2`, node, epoch)
+}
+
+func (s *SQLiteDB) GetTransactions(account string, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
+	return s.transactions(`select `+transactionColumns+` from transactions
+		where (principal_account = ?1 or receiver_account = ?1) and complete = ?2
+		order by layer `+sortOrder(sort)+pagination(skip, limit), account, complete)
+}
+
+func (s *SQLiteDB) GetLayerTransactions(layer int, skip int64, limit int64, sort int8, complete bool) ([]*types.TransactionDoc, error) {
+	return s.transactions(`select `+transactionColumns+` from transactions where layer = ?1 and complete = ?2
+		order by layer `+sortOrder(sort)+pagination(skip, limit), layer, complete)
+}
+
+func (s *SQLiteDB) GetAppliedTransactions(account string, firstLayer int, lastLayer int, limit int64, after *types.Cursor) ([]*types.TransactionDoc, error) {
+	conditions, args := layerRange([]string{"(principal_account = ?1 or receiver_account = ?1 or vault_account = ?1)", "balance_applied = 1"},
+		[]interface{}{account}, firstLayer, lastLayer)
+	conditions, args = afterRow(conditions, args, "layer", "id", 1, after)
+	return s.transactions(`select `+transactionColumns+` from transactions`+where(conditions)+
+		keysetOrder("layer", "id", 1)+pagination(0, limit), args...)
+}
+
+func (s *SQLiteDB) GetNodes(skip int64, limit int64, after *types.Cursor) ([]*types.NodeDoc, error) {
+	var conditions []string
+	var args []interface{}
+	if after != nil {
+		conditions = append(conditions, "id > ?1")
+		args = append(args, after.ID)
+	}
+	return s.nodes(`select id, malfeasance_received from nodes`+where(conditions)+` order by id`+pagination(skip, limit), args...)
+}
+
+func (s *SQLiteDB) GetAllTransactions(skip int64, limit int64, sort int8, complete bool, method int, minAmount int, after *types.Cursor) ([]*types.TransactionDoc, error) {
+	conditions, args := allTransactionsConditions(complete, method, minAmount)
+	conditions, args = afterRow(conditions, args, "layer", "id", sort, after)
+	return s.transactions(`select `+transactionColumns+` from transactions`+where(conditions)+
+		keysetOrder("layer", "id", sort)+pagination(skip, limit), args...)
+}
+
+func (s *SQLiteDB) CountNodes() (int64, error) {
+	return s.count(`select count(distinct node_id) from node_atxs`)
+}
+
+func (s *SQLiteDB) CountAccounts() (int64, error) {
+	return s.count(`select count(*) from accounts`)
+}
+
+func (s *SQLiteDB) CountAtxEpoch(epoch uint64) (int64, error) {
+	return s.count(`select coalesce(max(total_atx), 0) from atxs_epochs where epoch = ?1`, epoch)
+}
+
+func (s *SQLiteDB) FilterAccountAtxNodesForEpoch(account string, epoch uint64, nodes []string) ([]string, error) {
+	results := make([]string, 0)
+	if len(nodes) == 0 {
+		return results, nil
+	}
+	args := []interface{}{account, epoch}
+	placeholders := make([]string, len(nodes))
+	for i, node := range nodes {
+		placeholders[i] = fmt.Sprintf("?%d", len(args)+1)
+		args = append(args, node)
+	}
+	_, err := s.db.Exec(`select node_id from atxs where coinbase = ?1 and publish_epoch = ?2
+		and node_id in (`+strings.Join(placeholders, ", ")+`)`,
+		bindArgs(args...), func(stmt *sql.Statement) bool {
+			results = append(results, stmt.ColumnText(0))
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s *SQLiteDB) CountAccountAtxEpoch(account string, epoch uint64) (int64, error) {
+	return s.count(`select coalesce(max(total_atx), 0) from account_atxs_epochs where coinbase = ?1 and publish_epoch = ?2`,
+		account, epoch)
+}
+
+func (s *SQLiteDB) GetAtxForEpochPaginated(epoch uint64, skip int64, limit int64, sort int8, after *types.Cursor) ([]*types.AtxDoc, error) {
+	conditions, args := afterRow([]string{"publish_epoch = ?1"}, []interface{}{epoch}, "effective_num_units", "id", sort, after)
+	return s.atxs(`select `+atxColumns+` from atxs`+where(conditions)+
+		keysetOrder("effective_num_units", "id", sort)+pagination(skip, limit), args...)
+}
+
+func (s *SQLiteDB) GetAccountAtxEpoch(account string, epoch uint64, skip int64, limit int64, sort int8) ([]*types.AtxDoc, error) {
+	return s.atxs(`select `+atxColumns+` from atxs where coinbase = ?1 and publish_epoch = ?2 order by received `+
+		sortOrder(sort)+pagination(skip, limit), account, epoch)
+}
+
+func (s *SQLiteDB) GetAtxForEpoch(epoch uint64) ([]*types.AtxDoc, error) {
+	return s.atxs(`select `+atxColumns+` from atxs where publish_epoch = ?1 order by id`, epoch)
+}
+
+func (s *SQLiteDB) GetMalfeasanceNodes() ([]*types.NodeDoc, error) {
+	return s.nodes(`select id, malfeasance_received from nodes where malfeasance_received is not null`)
+}
+
+func (s *SQLiteDB) GetAtxEpoch(epoch uint64) (*types.AtxEpochDoc, error) {
+	doc := &types.AtxEpochDoc{}
+	_, err := s.db.Exec(`select epoch, total_effective_num_units, total_weight, total_atx from atxs_epochs where epoch = ?1`,
+		bindArgs(epoch), func(stmt *sql.Statement) bool {
+			doc.ID = stmt.ColumnInt64(0)
+			doc.TotalEffectiveNumUnits = uint64(stmt.ColumnInt64(1))
+			doc.TotalWeight = uint64(stmt.ColumnInt64(2))
+			doc.TotalAtx = uint64(stmt.ColumnInt64(3))
+			return false
+		})
+	return doc, err
+}
+
+func (s *SQLiteDB) GetNetworkInfo() (*types.NetworkInfoDoc, error) {
+	doc := &types.NetworkInfoDoc{}
+	rows, err := s.db.Exec(`select id, circulating_supply from network_info where id = 'info'`, nil,
+		func(stmt *sql.Statement) bool {
+			doc.Id = stmt.ColumnText(0)
+			doc.CirculatingSupply = uint64(stmt.ColumnInt64(1))
+			return false
+		})
+	if err != nil {
+		return doc, err
+	}
+	if rows == 0 {
+		return doc, sql.ErrNotFound
+	}
+	return doc, nil
+}
+
+func (s *SQLiteDB) layers(query string, args ...interface{}) ([]*types.LayerDoc, error) {
+	var layers []*types.LayerDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		layers = append(layers, decodeLayer(stmt))
+		return true
+	})
+	return layers, err
+}
+
+func (s *SQLiteDB) GetProcessedsLayers(skip int64, limit int64, sort int8) ([]*types.LayerDoc, error) {
+	return s.layers(`select ` + layerColumns + ` from layers where status = 3 order by id ` + sortOrder(sort) + pagination(skip, limit))
+}
+
+func (s *SQLiteDB) GetLastProcessedLayer() (*types.LayerDoc, error) {
+	layers, err := s.layers(`select ` + layerColumns + ` from layers where status = 3 order by id desc limit 1`)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) > 0 {
+		return layers[0], nil
+	}
+	return &types.LayerDoc{}, nil
+}
+
+func (s *SQLiteDB) GetLayer(layer int) (*types.LayerDoc, error) {
+	layers, err := s.layers(`select `+layerColumns+` from layers where id = ?1`, layer)
+	if err != nil {
+		return &types.LayerDoc{}, err
+	}
+	if len(layers) == 0 {
+		return &types.LayerDoc{}, nil
+	}
+	return layers[0], nil
+}
+
+func (s *SQLiteDB) GetLayerReverts(layer int) ([]*types.LayerRevertDoc, error) {
+	var reverts []*types.LayerRevertDoc
+	_, err := s.db.Exec(`select layer, reverted_at, boundary, trigger, rewards_reverted, rewards_amount,
+		transactions_reverted, blocks from layer_reverts where layer = ?1 order by reverted_at`,
+		bindArgs(layer), func(stmt *sql.Statement) bool {
+			blocks := []string{}
+			if stmt.ColumnText(7) != "" {
+				blocks = strings.Split(stmt.ColumnText(7), ",")
+			}
+			reverts = append(reverts, &types.LayerRevertDoc{
+				Layer:                stmt.ColumnInt64(0),
+				RevertedAt:           stmt.ColumnInt64(1),
+				Boundary:             stmt.ColumnInt64(2),
+				Trigger:              stmt.ColumnText(3),
+				RewardsReverted:      stmt.ColumnInt64(4),
+				RewardsAmount:        stmt.ColumnInt64(5),
+				TransactionsReverted: stmt.ColumnInt64(6),
+				Blocks:               blocks,
+			})
+			return true
+		})
+	return reverts, err
+}
+
+func (s *SQLiteDB) GetAccountsAfter(account string, limit int64) ([]*types.AccountDoc, error) {
+	return s.accounts(`select `+accountColumns+` from accounts where address > ?1 order by address`+pagination(0, limit), account)
+}
+
+func (s *SQLiteDB) GetAccountsTotals(accounts []string) ([]*types.AccountDoc, error) {
+	if len(accounts) == 0 {
+		return []*types.AccountDoc{}, nil
+	}
+	totals := make(map[string]*types.AccountDoc, len(accounts))
+	// ?1 is the drain vault type and ?2 the opaque one, only used by the sent query
+	placeholders := make([]string, len(accounts))
+	args := []interface{}{transactionparsertypes.TypeDrainVault, transactionparsertypes.TypeOpaque}
+	for i, account := range accounts {
+		totals[account] = &types.AccountDoc{Address: account}
+		placeholders[i] = fmt.Sprintf("?%d", len(args)+1)
+		args = append(args, account)
+	}
+	in := ` in (` + strings.Join(placeholders, ", ") + `)`
+	sender := `case when type = ?1 then vault_account else principal_account end`
+
+	queries := []struct {
+		query  string
+		decode func(total *types.AccountDoc, stmt *sql.Statement)
+	}{
+		{
+			query: `select coinbase, sum(total_reward) from rewards where coinbase` + in + ` group by coinbase`,
+			decode: func(total *types.AccountDoc, stmt *sql.Statement) {
+				total.TotalRewards = uint64(stmt.ColumnInt64(1))
+			},
+		},
+		{
+			query: `select receiver_account, sum(amount) from transactions
+				where balance_applied = 1 and receiver_account` + in + ` group by receiver_account`,
+			decode: func(total *types.AccountDoc, stmt *sql.Statement) {
+				total.Received = uint64(stmt.ColumnInt64(1))
+			},
+		},
+		{
+			query: `select ` + sender + `, sum(amount), sum(case when type = ?2 then fee else gas * gas_price end) from transactions
+				where balance_applied = 1 and ` + sender + in + ` group by 1`,
+			decode: func(total *types.AccountDoc, stmt *sql.Statement) {
+				total.Sent = uint64(stmt.ColumnInt64(1))
+				total.Fees = uint64(stmt.ColumnInt64(2))
+			},
+		},
+	}
+	for _, q := range queries {
+		_, err := s.db.Exec(q.query, bindArgs(args...), func(stmt *sql.Statement) bool {
+			if total, ok := totals[stmt.ColumnText(0)]; ok {
+				q.decode(total, stmt)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]*types.AccountDoc, len(accounts))
+	for i, account := range accounts {
+		result[i] = totals[account]
+	}
+	return result, nil
+}
+
+func (s *SQLiteDB) apiKeys(query string, args ...interface{}) ([]*types.ApiKeyDoc, error) {
+	var apiKeys []*types.ApiKeyDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		apiKeys = append(apiKeys, decodeApiKey(stmt))
+		return true
+	})
+	return apiKeys, err
+}
+
+func (s *SQLiteDB) GetApiKey(id string) (*types.ApiKeyDoc, error) {
+	apiKeys, err := s.apiKeys(`select `+apiKeyColumns+` from api_keys where id = ?1`, id)
+	if err != nil {
+		return &types.ApiKeyDoc{}, err
+	}
+	if len(apiKeys) == 0 {
+		return &types.ApiKeyDoc{}, nil
+	}
+	return apiKeys[0], nil
+}
+
+func (s *SQLiteDB) GetApiKeys() ([]*types.ApiKeyDoc, error) {
+	return s.apiKeys(`select ` + apiKeyColumns + ` from api_keys order by created_at`)
+}
+
+func (s *SQLiteDB) webhooks(query string, args ...interface{}) ([]*types.WebhookDoc, error) {
+	var webhooks []*types.WebhookDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		webhooks = append(webhooks, decodeWebhook(stmt))
+		return true
+	})
+	return webhooks, err
+}
+
+func (s *SQLiteDB) GetWebhook(id string) (*types.WebhookDoc, error) {
+	webhooks, err := s.webhooks(`select `+webhookColumns+` from webhooks where id = ?1`, id)
+	if err != nil {
+		return &types.WebhookDoc{}, err
+	}
+	if len(webhooks) == 0 {
+		return &types.WebhookDoc{}, nil
+	}
+	return webhooks[0], nil
+}
+
+func (s *SQLiteDB) GetWebhooks(owner string) ([]*types.WebhookDoc, error) {
+	if owner == "" {
+		return s.webhooks(`select ` + webhookColumns + ` from webhooks order by created_at`)
+	}
+	return s.webhooks(`select `+webhookColumns+` from webhooks where owner = ?1 order by created_at`, owner)
+}
+
+func (s *SQLiteDB) webhookDeliveries(query string, args ...interface{}) ([]*types.WebhookDeliveryDoc, error) {
+	var deliveries []*types.WebhookDeliveryDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		deliveries = append(deliveries, decodeWebhookDelivery(stmt))
+		return true
+	})
+	return deliveries, err
+}
+
+func (s *SQLiteDB) GetWebhookDeliveries(webhookID string, skip int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+	return s.webhookDeliveries(`select `+webhookDeliveryColumns+` from webhook_deliveries where webhook_id = ?1
+		order by created_at desc, id desc`+pagination(skip, limit), webhookID)
+}
+
+func (s *SQLiteDB) CountWebhookDeliveries(webhookID string) (int64, error) {
+	return s.count(`select count(*) from webhook_deliveries where webhook_id = ?1`, webhookID)
+}
+
+func (s *SQLiteDB) GetDueWebhookDeliveries(now int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+	return s.webhookDeliveries(`select `+webhookDeliveryColumns+` from webhook_deliveries
+		where status = 'pending' and next_attempt <= ?1 order by next_attempt`+pagination(0, limit), now)
+}
+
+func (s *SQLiteDB) deadLetters(query string, args ...interface{}) ([]*types.DeadLetterDoc, error) {
+	var deadLetters []*types.DeadLetterDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		deadLetters = append(deadLetters, decodeDeadLetter(stmt))
+		return true
+	})
+	return deadLetters, err
+}
+
+func (s *SQLiteDB) GetDeadLetter(id string) (*types.DeadLetterDoc, error) {
+	deadLetters, err := s.deadLetters(`select `+deadLetterColumns+` from dead_letters where id = ?1`, id)
+	if err != nil {
+		return &types.DeadLetterDoc{}, err
+	}
+	if len(deadLetters) == 0 {
+		return &types.DeadLetterDoc{}, nil
+	}
+	return deadLetters[0], nil
+}
+
+func (s *SQLiteDB) GetDeadLetters(stream string, skip int64, limit int64) ([]*types.DeadLetterDoc, error) {
+	if stream == "" {
+		return s.deadLetters(`select ` + deadLetterColumns + ` from dead_letters
+			order by created_at desc, id desc` + pagination(skip, limit))
+	}
+	return s.deadLetters(`select `+deadLetterColumns+` from dead_letters where stream = ?1
+		order by created_at desc, id desc`+pagination(skip, limit), stream)
+}
+
+func (s *SQLiteDB) prices(query string, args ...interface{}) ([]*types.PriceDoc, error) {
+	var prices []*types.PriceDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		prices = append(prices, decodePrice(stmt))
+		return true
+	})
+	return prices, err
+}
+
+func (s *SQLiteDB) GetPrices(from int64, to int64) ([]*types.PriceDoc, error) {
+	return s.prices(`select `+priceColumns+` from prices where timestamp >= ?1 and timestamp <= ?2 order by timestamp`, from, to)
+}
+
+func (s *SQLiteDB) GetPriceAt(timestamp int64) (*types.PriceDoc, error) {
+	return s.lastPrice(`select `+priceColumns+` from prices where timestamp <= ?1 order by timestamp desc limit 1`, timestamp)
+}
+
+func (s *SQLiteDB) GetLastPrice(source string) (*types.PriceDoc, error) {
+	return s.lastPrice(`select `+priceColumns+` from prices where source = ?1 order by timestamp desc limit 1`, source)
+}
+
+func (s *SQLiteDB) lastPrice(query string, args ...interface{}) (*types.PriceDoc, error) {
+	prices, err := s.prices(query, args...)
+	if err != nil {
+		return &types.PriceDoc{}, err
+	}
+	if len(prices) == 0 {
+		return &types.PriceDoc{}, nil
+	}
+	return prices[0], nil
+}
+
+func (s *SQLiteDB) GetTransactionsTotals(minLayer uint32, maxLayer uint32) (*types.AggregationTransactionsTotals, error) {
+	totals := &types.AggregationTransactionsTotals{}
+	_, err := s.db.Exec(`select count(*), coalesce(sum(amount), 0) from transactions
+		where balance_applied = 1 and layer >= ?1 and layer < ?2`,
+		bindArgs(minLayer, maxLayer), func(stmt *sql.Statement) bool {
+			totals.Count = stmt.ColumnInt64(0)
+			totals.Volume = stmt.ColumnInt64(1)
+			return false
+		})
+	return totals, err
+}
+
+func (s *SQLiteDB) CountNewAccounts(minLayer uint32, maxLayer uint32) (int64, error) {
+	return s.count(`select count(*) from (
+			select coinbase as address from rewards where layer >= ?1 and layer < ?2
+			union select principal_account from transactions where balance_applied = 1 and layer >= ?1 and layer < ?2
+			union select receiver_account from transactions where balance_applied = 1 and layer >= ?1 and layer < ?2
+		) active
+		where active.address != ''
+			and not exists (select 1 from rewards where coinbase = active.address and layer < ?1)
+			and not exists (select 1 from transactions where principal_account = active.address and balance_applied = 1 and layer < ?1)
+			and not exists (select 1 from transactions where receiver_account = active.address and balance_applied = 1 and layer < ?1)`,
+		minLayer, maxLayer)
+}
+
+func (s *SQLiteDB) CountMalfeasance(from int64, to int64) (int64, error) {
+	return s.count(`select count(*) from nodes where malfeasance_received >= ?1 and malfeasance_received < ?2`, from, to)
+}
+
+func (s *SQLiteDB) stats(query string, args ...interface{}) ([]*types.StatsDoc, error) {
+	var stats []*types.StatsDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		stats = append(stats, decodeStats(stmt))
+		return true
+	})
+	return stats, err
+}
+
+func (s *SQLiteDB) GetStats(period string, from int64, to int64) ([]*types.StatsDoc, error) {
+	return s.stats(`select `+statsColumns+` from stats where period = ?1 and timestamp >= ?2 and timestamp <= ?3 order by timestamp`,
+		period, from, to)
+}
+
+func (s *SQLiteDB) GetLastCompleteStats(period string) (*types.StatsDoc, error) {
+	stats, err := s.stats(`select `+statsColumns+` from stats where period = ?1 and complete = 1 order by timestamp desc limit 1`, period)
+	if err != nil {
+		return &types.StatsDoc{}, err
+	}
+	if len(stats) == 0 {
+		return &types.StatsDoc{}, nil
+	}
+	return stats[0], nil
+}
+
+func (s *SQLiteDB) GetTransactionDetails(id string) (*types.TransactionDetailsDoc, error) {
+	details := &types.TransactionDetailsDoc{}
+	_, err := s.db.Exec(`select `+transactionDetailsColumns+` from transaction_details where id = ?1`, bindArgs(id),
+		func(stmt *sql.Statement) bool {
+			details = decodeTransactionDetails(stmt)
+			return false
+		})
+	if err != nil {
+		return &types.TransactionDetailsDoc{}, err
+	}
+	return details, nil
+}
+
+func (s *SQLiteDB) GetNodesRewardsTotals(minLayer uint32, maxLayer uint32) ([]*types.AggregationNodeRewards, error) {
+	var totals []*types.AggregationNodeRewards
+	_, err := s.db.Exec(`select node_id, sum(total_reward), count(*) from rewards
+		where layer >= ?1 and layer < ?2 group by node_id`,
+		bindArgs(minLayer, maxLayer), func(stmt *sql.Statement) bool {
+			totals = append(totals, &types.AggregationNodeRewards{
+				NodeID: stmt.ColumnText(0),
+				Sum:    stmt.ColumnInt64(1),
+				Count:  stmt.ColumnInt64(2),
+			})
+			return true
+		})
+	return totals, err
+}
+
+func (s *SQLiteDB) GetLastPerformanceEpoch() (int64, error) {
+	return s.count(`select coalesce(max(epoch), -1) from node_performance`)
+}
+
+func (s *SQLiteDB) nodePerformance(query string, args ...interface{}) ([]*types.NodePerformanceDoc, error) {
+	var performance []*types.NodePerformanceDoc
+	_, err := s.db.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		performance = append(performance, decodeNodePerformance(stmt))
+		return true
+	})
+	return performance, err
+}
+
+func (s *SQLiteDB) GetNodePerformance(node string, skip int64, limit int64, sort int8) ([]*types.NodePerformanceDoc, error) {
+	return s.nodePerformance(`select `+nodePerformanceColumns+` from node_performance where node_id = ?1 order by epoch `+
+		sortOrder(sort)+pagination(skip, limit), node)
+}
+
+func (s *SQLiteDB) CountNodePerformance(node string) (int64, error) {
+	return s.count(`select count(*) from node_performance where node_id = ?1`, node)
+}
+
+func (s *SQLiteDB) GetAccountPerformance(account string, epoch int64) ([]*types.NodePerformanceDoc, error) {
+	return s.nodePerformance(`select `+nodePerformanceColumns+` from node_performance where coinbase = ?1 and epoch = ?2
+		order by efficiency, node_id`, account, epoch)
+}
+
+func (s *SQLiteDB) GetAccountPerformanceEpochs(account string, skip int64, limit int64, sort int8) ([]*types.AggregationPerformance, error) {
+	var epochs []*types.AggregationPerformance
+	_, err := s.db.Exec(`select epoch, count(*), sum(no_rewards), sum(eligible_slots), sum(rewarded_slots), sum(missed_slots),
+			sum(expected_rewards), sum(rewards)
+		from node_performance where coinbase = ?1 group by epoch order by epoch `+sortOrder(sort)+pagination(skip, limit),
+		bindArgs(account), func(stmt *sql.Statement) bool {
+			epochs = append(epochs, &types.AggregationPerformance{
+				Epoch:           stmt.ColumnInt64(0),
+				Nodes:           stmt.ColumnInt64(1),
+				NoRewardsNodes:  stmt.ColumnInt64(2),
+				EligibleSlots:   stmt.ColumnInt64(3),
+				RewardedSlots:   stmt.ColumnInt64(4),
+				MissedSlots:     stmt.ColumnInt64(5),
+				ExpectedRewards: stmt.ColumnInt64(6),
+				Rewards:         stmt.ColumnInt64(7),
+			})
+			return true
+		})
+	return epochs, err
+}
+
+func (s *SQLiteDB) CountAccountPerformanceEpochs(account string) (int64, error) {
+	return s.count(`select count(distinct epoch) from node_performance where coinbase = ?1`, account)
+}
+
+func (s *SQLiteDB) CountDeadLetters(stream string) (int64, error) {
+	if stream == "" {
+		return s.count(`select count(*) from dead_letters`)
+	}
+	return s.count(`select count(*) from dead_letters where stream = ?1`, stream)
+}
+
+func (s *SQLiteDB) GetAccountTemplate(address string) (*types.AccountTemplateDoc, error) {
+	template := &types.AccountTemplateDoc{}
+	_, err := s.db.Exec(`select `+accountTemplateColumns+` from account_templates where address = ?1`, bindArgs(address),
+		func(stmt *sql.Statement) bool {
+			template = decodeAccountTemplate(stmt)
+			return false
+		})
+	if err != nil {
+		return &types.AccountTemplateDoc{}, err
+	}
+	return template, nil
+}
+
+func (s *SQLiteDB) GetVaultsOfOwner(owner string) ([]*types.AccountTemplateDoc, error) {
+	var vaults []*types.AccountTemplateDoc
+	_, err := s.db.Exec(`select `+accountTemplateColumns+` from account_templates where owner = ?1 and template = ?2
+		order by spawn_layer, address`, bindArgs(owner, transactionparsertypes.TemplateVault),
+		func(stmt *sql.Statement) bool {
+			vaults = append(vaults, decodeAccountTemplate(stmt))
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vaults, nil
+}
+
+func (s *SQLiteDB) GetDrainedAmount(vault string) (uint64, error) {
+	var drained uint64
+	_, err := s.db.Exec(`select coalesce(sum(amount), 0) from transactions
+		where vault_account = ?1 and type = ?2 and balance_applied = 1`,
+		bindArgs(vault, transactionparsertypes.TypeDrainVault), func(stmt *sql.Statement) bool {
+			drained = uint64(stmt.ColumnInt64(0))
+			return false
+		})
+	if err != nil {
+		return 0, err
+	}
+	return drained, nil
+}
+
+func (s *SQLiteDB) GetLastCounter(account string) (int64, error) {
+	counter := int64(-1)
+	_, err := s.db.Exec(`select coalesce(max(counter), -1) from transactions
+		where principal_account = ?1 and complete = 1`,
+		bindArgs(account), func(stmt *sql.Statement) bool {
+			counter = stmt.ColumnInt64(0)
+			return false
+		})
+	if err != nil {
+		return -1, err
+	}
+	return counter, nil
+}
+
+func (s *SQLiteDB) GetPendingTransactions(account string) ([]*types.TransactionDoc, error) {
+	return s.transactions(`select `+transactionColumns+` from transactions
+		where (principal_account = ?1 or receiver_account = ?1) and complete = 0 and dropped = 0
+		order by counter, published, id`, account)
+}
@@ -0,0 +1,694 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/spacemeshos/go-spacemesh/sql"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+func exists(tx *sql.Tx, query string, args ...interface{}) (bool, error) {
+	rows, err := tx.Exec(query, bindArgs(args...), func(stmt *sql.Statement) bool {
+		return false
+	})
+	return rows > 0, err
+}
+
+// writeOnce runs write in a transaction that also records the stream message of origin. A message already
+// recorded was applied by a delivery that wasn't acked and is skipped.
+func (s *SQLiteDB) writeOnce(origin Origin, write func(tx *sql.Tx) error) error {
+	return s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		if origin.Sequence == 0 {
+			return write(tx)
+		}
+		found, err := exists(tx, `select 1 from processed_messages where id = ?1`, origin.key())
+		if err != nil {
+			return err
+		}
+		if found {
+			slog.Debug("Skip processed message", "stream", origin.Stream, "sequence", origin.Sequence)
+			return nil
+		}
+		if err := write(tx); err != nil {
+			return err
+		}
+		processed := newProcessedMessageDoc(origin)
+		_, err = tx.Exec(`insert into processed_messages (id, stream, sequence, msg_id, processed_at)
+			values (?1, ?2, ?3, ?4, ?5)`,
+			bindArgs(processed.ID, processed.Stream, processed.Sequence, processed.MsgID, processed.ProcessedAt.UnixMilli()), nil)
+		return err
+	})
+}
+
+func (s *SQLiteDB) SaveLayer(layer *nats.LayerUpdate, origin Origin) error {
+	// only store processed layers
+	if layer.Status == 0 {
+		return nil
+	}
+	err := s.writeOnce(origin, func(tx *sql.Tx) error {
+		layerDoc, err := getLayer(tx, layer.LayerID)
+		if err != nil {
+			return err
+		}
+		updated := updatedLayer(layerDoc, layer, origin.Published)
+		_, err = tx.Exec(`insert into layers (id, status, published, last_applied) values (?1, ?2, ?3, ?4)
+			on conflict (id) do update set status = excluded.status, published = excluded.published,
+			last_applied = excluded.last_applied`,
+			bindArgs(layer.LayerID, updated.Status, updated.Published, updated.LastApplied), nil)
+		if err != nil {
+			return err
+		}
+		// sqlite has no ttl index, the processed messages are pruned once a layer
+		_, err = tx.Exec(`delete from processed_messages where processed_at < ?1`,
+			bindArgs(time.Now().Add(-ProcessedRetention).UnixMilli()), nil)
+		return err
+	})
+	if err != nil {
+		slog.Error("Layer transaction failed", "layer", layer.LayerID, "error", err)
+	}
+	return err
+}
+
+func getLayer(tx *sql.Tx, layer uint32) (*types.LayerDoc, error) {
+	var layerDoc *types.LayerDoc
+	_, err := tx.Exec(`select `+layerColumns+` from layers where id = ?1`, bindArgs(layer),
+		func(stmt *sql.Statement) bool {
+			layerDoc = decodeLayer(stmt)
+			return false
+		})
+	return layerDoc, err
+}
+
+// checkLayerContent reverts the stored content of a layer when a record published at published, of the block
+// blockID for a result, shows the layer was executed again. It returns true when the record belongs to already
+// reverted content.
+func checkLayerContent(tx *sql.Tx, layer uint32, published int64, blockID string, trigger string) (bool, error) {
+	if published == 0 {
+		return false, nil
+	}
+	layerDoc, err := getLayer(tx, layer)
+	if err != nil {
+		return false, err
+	}
+	revert, boundary, stale := layerContent(layerDoc, published, blockID)
+	if !revert {
+		return stale, nil
+	}
+
+	if err := revertLayer(tx, layerDoc, boundary, trigger); err != nil {
+		return false, err
+	}
+	reverted := revertedLayer(layerDoc, boundary, published)
+	_, err = tx.Exec(`update layers set published = ?2, revert_boundary = ?3, reverts = ?4 where id = ?1`,
+		bindArgs(layer, reverted.Published, reverted.RevertBoundary, reverted.Reverts), nil)
+	return false, err
+}
+
+// revertLayer removes the rewards of the layer published up to boundary, returns the transactions to not
+// complete, reverses their balance changes and records the revert.
+func revertLayer(tx *sql.Tx, layerDoc *types.LayerDoc, boundary int64, trigger string) error {
+	revertDoc := &types.LayerRevertDoc{
+		Layer:      layerDoc.Layer,
+		RevertedAt: time.Now().UnixMilli(),
+		Boundary:   boundary,
+		Trigger:    trigger,
+		Blocks:     []string{},
+	}
+
+	var rewards []*types.RewardsDoc
+	_, err := tx.Exec(`select `+rewardColumns+` from rewards where layer = ?1 and published <= ?2`,
+		bindArgs(layerDoc.Layer, boundary), func(stmt *sql.Statement) bool {
+			rewards = append(rewards, decodeReward(stmt))
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	for _, reward := range rewards {
+		_, err = tx.Exec(`update accounts set balance = balance - ?2, total_rewards = total_rewards - ?2
+			where address = ?1`, bindArgs(reward.Coinbase, reward.TotalReward), nil)
+		if err != nil {
+			return err
+		}
+		revertDoc.RewardsReverted++
+		revertDoc.RewardsAmount += reward.TotalReward
+	}
+	if revertDoc.RewardsReverted > 0 {
+		_, err = tx.Exec(`update network_info set circulating_supply = circulating_supply - ?1 where id = 'info'`,
+			bindArgs(revertDoc.RewardsAmount), nil)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`delete from rewards where layer = ?1 and published <= ?2`,
+			bindArgs(layerDoc.Layer, boundary), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	var transactions []*types.TransactionDoc
+	_, err = tx.Exec(`select `+transactionColumns+` from transactions where layer = ?1 and published <= ?2 and complete = 1`,
+		bindArgs(layerDoc.Layer, boundary), func(stmt *sql.Statement) bool {
+			transactions = append(transactions, decodeTransaction(stmt))
+			return true
+		})
+	if err != nil {
+		return err
+	}
+	blocks := map[string]bool{}
+	for _, transaction := range transactions {
+		if transaction.BalanceApplied {
+			if err := updateTransactionBalances(tx, transaction, -1); err != nil {
+				return err
+			}
+		}
+		// the accounts it spawned are no longer spawned until it is applied again
+		_, err = tx.Exec(`update account_templates set spawned = 0 where spawn_transaction = ?1`,
+			bindArgs(transaction.ID), nil)
+		if err != nil {
+			return err
+		}
+		if transaction.BlockID != "" && !blocks[transaction.BlockID] {
+			blocks[transaction.BlockID] = true
+			revertDoc.Blocks = append(revertDoc.Blocks, transaction.BlockID)
+		}
+		revertDoc.TransactionsReverted++
+	}
+	if revertDoc.TransactionsReverted > 0 {
+		_, err = tx.Exec(`update transactions set complete = 0, balance_applied = 0
+			where layer = ?1 and published <= ?2 and complete = 1`,
+			bindArgs(layerDoc.Layer, boundary), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`insert into layer_reverts (layer, reverted_at, boundary, trigger, rewards_reverted,
+		rewards_amount, transactions_reverted, blocks) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)`,
+		bindArgs(revertDoc.Layer, revertDoc.RevertedAt, revertDoc.Boundary, revertDoc.Trigger, revertDoc.RewardsReverted,
+			revertDoc.RewardsAmount, revertDoc.TransactionsReverted, strings.Join(revertDoc.Blocks, ",")), nil)
+	if err != nil {
+		return err
+	}
+	slog.Warn("Reverted layer", "layer", layerDoc.Layer, "rewards", revertDoc.RewardsReverted, "transactions", revertDoc.TransactionsReverted)
+	return nil
+}
+
+// updateTransactionBalances applies the balance changes of a transaction, sign -1 reverses them.
+func updateTransactionBalances(tx *sql.Tx, transactionDoc *types.TransactionDoc, sign int64) error {
+	// if amount is 0 there is not point updating the balance for receiver account
+	if transactionDoc.Amount > 0 {
+		_, err := tx.Exec(`insert into accounts (address, balance, received) values (?1, ?2, ?2)
+			on conflict (address) do update set balance = balance + excluded.balance,
+			received = received + excluded.received`,
+			bindArgs(transactionDoc.ReceiverAccount, sign*int64(transactionDoc.Amount)), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	fee := int64(transactionDoc.TotalFee())
+	valueToDeduct := (int64(transactionDoc.Amount) + fee) * -1
+	_, err := tx.Exec(`insert into accounts (address, balance, sent, fees) values (?1, ?2, ?3, ?4)
+		on conflict (address) do update set balance = balance + excluded.balance,
+		sent = sent + excluded.sent, fees = fees + excluded.fees`,
+		bindArgs(transactionSender(transactionDoc), sign*valueToDeduct, sign*int64(transactionDoc.Amount), sign*fee), nil)
+	return err
+}
+
+func (s *SQLiteDB) SaveAtx(atx *nats.Atx, origin Origin) error {
+	atxDoc := newAtxDoc(atx)
+	err := s.writeOnce(origin, func(tx *sql.Tx) error {
+		found, err := exists(tx, `select 1 from atxs where id = ?1`, atxDoc.AtxID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into atxs (`+atxColumns+`) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10)
+			on conflict (id) do update set node_id = excluded.node_id, coinbase = excluded.coinbase,
+			publish_epoch = excluded.publish_epoch, effective_num_units = excluded.effective_num_units,
+			base_tick = excluded.base_tick, weight = excluded.weight, tick_count = excluded.tick_count,
+			sequence = excluded.sequence, received = excluded.received`,
+			bindArgs(atxDoc.AtxID, atxDoc.NodeID, atxDoc.Coinbase, atxDoc.PublishEpoch, atxDoc.EffectiveNumUnits,
+				atxDoc.BaseTick, atxDoc.Weight, atxDoc.TickCount, atxDoc.Sequence, atxDoc.Received), nil)
+		if err != nil {
+			return err
+		}
+
+		// only update counts if inserted new ATX
+		if found {
+			return nil
+		}
+
+		_, err = tx.Exec(`insert into atxs_epochs (epoch, total_effective_num_units, total_weight, total_atx)
+			values (?1, ?2, ?3, 1)
+			on conflict (epoch) do update set
+			total_effective_num_units = total_effective_num_units + excluded.total_effective_num_units,
+			total_weight = total_weight + excluded.total_weight, total_atx = total_atx + 1`,
+			bindArgs(atxDoc.PublishEpoch, atxDoc.EffectiveNumUnits, atxDoc.Weight), nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into account_atxs_epochs (coinbase, publish_epoch, total_effective_num_units, total_weight, total_atx)
+			values (?1, ?2, ?3, ?4, 1)
+			on conflict (coinbase, publish_epoch) do update set
+			total_effective_num_units = total_effective_num_units + excluded.total_effective_num_units,
+			total_weight = total_weight + excluded.total_weight, total_atx = total_atx + 1`,
+			bindArgs(atxDoc.Coinbase, atxDoc.PublishEpoch, atxDoc.EffectiveNumUnits, atxDoc.Weight), nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into nodes (id) values (?1) on conflict (id) do nothing`, bindArgs(atxDoc.NodeID), nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into node_atxs (node_id, coinbase, publish_epoch, effective_num_units, weight, sequence, received)
+			values (?1, ?2, ?3, ?4, ?5, ?6, ?7) on conflict do nothing`,
+			bindArgs(atxDoc.NodeID, atxDoc.Coinbase, atxDoc.PublishEpoch, atxDoc.EffectiveNumUnits, atxDoc.Weight,
+				atxDoc.Sequence, atxDoc.Received), nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into accounts (address) values (?1) on conflict (address) do nothing`,
+			bindArgs(atxDoc.Coinbase), nil)
+		return err
+	})
+	if err != nil {
+		slog.Error("Atx transaction failed", "atx", atx.AtxID, "error", err)
+	}
+	return err
+}
+
+func (s *SQLiteDB) SaveMalfeasance(malfeasance *nats.Malfeasance, origin Origin) error {
+	err := s.writeOnce(origin, func(tx *sql.Tx) error {
+		_, err := tx.Exec(`insert into nodes (id, malfeasance_received) values (?1, ?2)
+			on conflict (id) do update set malfeasance_received = excluded.malfeasance_received`,
+			bindArgs(malfeasance.NodeID, malfeasance.Received), nil)
+		return err
+	})
+	if err != nil {
+		slog.Error("Malfeasance transaction failed", "node", malfeasance.NodeID, "error", err)
+	}
+	return err
+}
+
+func (s *SQLiteDB) SaveTransactions(transaction *nats.Transaction, result bool, origin Origin) error {
+	if !result {
+		transactionDoc := newCreatedTransactionDoc(transaction, s.hrp)
+		transactionDoc.Published = origin.Published
+		err := s.writeOnce(origin, func(tx *sql.Tx) error {
+			// if already saved ignore it
+			_, err := tx.Exec(`insert into transactions (`+transactionColumns+`)
+				values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16, ?17, ?18)
+				on conflict (id) do nothing`,
+				bindArgs(transactionDoc.ID, transactionDoc.Status, transactionDoc.PrincipaAccount, transactionDoc.ReceiverAccount,
+					transactionDoc.VaultAccount, transactionDoc.Fee, transactionDoc.Gas, transactionDoc.GasPrice,
+					transactionDoc.Amount, transactionDoc.Layer, transactionDoc.Counter, transactionDoc.Method,
+					transactionDoc.Type, transactionDoc.Complete, transaction.Header.BlockID, transactionDoc.Published,
+					transactionDoc.BalanceApplied, transactionDoc.Dropped), nil)
+			return err
+		})
+		if err != nil {
+			slog.Error("Transaction failed", "transaction", transaction.ID, "error", err)
+		}
+		return err
+	}
+
+	transactionDoc, transactionData, err := newResultTransactionDoc(transaction, s.hrp)
+	if err != nil {
+		slog.Error("Failed to parse transaction", "transaction", transaction.ID, "error", err)
+		return err
+	}
+	transactionDoc.Published = origin.Published
+	detailsDoc, err := newTransactionDetailsDoc(transaction, transactionData, s.hrp)
+	if err != nil {
+		slog.Error("Failed to parse transaction", "transaction", transaction.ID, "error", err)
+		return err
+	}
+	templateDocs, err := newAccountTemplateDocs(transaction, transactionData, detailsDoc, s.hrp)
+	if err != nil {
+		slog.Error("Failed to parse transaction", "transaction", transaction.ID, "error", err)
+		return err
+	}
+
+	err = s.writeOnce(origin, func(tx *sql.Tx) error {
+		stale, err := checkLayerContent(tx, transaction.Header.LayerID, origin.Published, transaction.Header.BlockID, transaction.ID)
+		if err != nil {
+			return err
+		}
+		if stale {
+			slog.Info("Ignore transaction of reverted layer", "transaction", transaction.ID, "layer", transaction.Header.LayerID)
+			return nil
+		}
+
+		// if not found it got the result before the created so it should update balance,
+		// if already complete it is a duplicate so don't update balances
+		updateBalances := true
+		balanceApplied := false
+		_, err = tx.Exec(`select complete, balance_applied from transactions where id = ?1`, bindArgs(transactionDoc.ID),
+			func(stmt *sql.Statement) bool {
+				updateBalances = stmt.ColumnInt64(0) == 0
+				balanceApplied = stmt.ColumnInt64(0) == 1 && stmt.ColumnInt64(1) == 1
+				return false
+			})
+		if err != nil {
+			return err
+		}
+
+		// if transaction not sucessfull or addressess length less than 2 it means is an ineffective transaction
+		if transaction.Header.Status != uint8(sTypes.TransactionSuccess) || len(transaction.Header.Addresses) < 2 {
+			updateBalances = false
+		}
+		transactionDoc.BalanceApplied = updateBalances || balanceApplied
+
+		_, err = tx.Exec(`insert into transactions (`+transactionColumns+`)
+			values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16, ?17, ?18)
+			on conflict (id) do update set status = excluded.status, principal_account = excluded.principal_account,
+			receiver_account = excluded.receiver_account, vault_account = excluded.vault_account, fee = excluded.fee,
+			gas = excluded.gas, gas_price = excluded.gas_price, amount = excluded.amount, layer = excluded.layer,
+			counter = excluded.counter, method = excluded.method, type = excluded.type, complete = excluded.complete,
+			block_id = excluded.block_id, published = excluded.published, balance_applied = excluded.balance_applied,
+			dropped = excluded.dropped`,
+			bindArgs(transactionDoc.ID, transactionDoc.Status, transactionDoc.PrincipaAccount, transactionDoc.ReceiverAccount,
+				transactionDoc.VaultAccount, transactionDoc.Fee, transactionDoc.Gas, transactionDoc.GasPrice,
+				transactionDoc.Amount, transactionDoc.Layer, transactionDoc.Counter, transactionDoc.Method,
+				transactionDoc.Type, transactionDoc.Complete, transaction.Header.BlockID, transactionDoc.Published,
+				transactionDoc.BalanceApplied, transactionDoc.Dropped), nil)
+		if err != nil {
+			return err
+		}
+
+		if err := saveTransactionDetails(tx, detailsDoc); err != nil {
+			return err
+		}
+		for _, templateDoc := range templateDocs {
+			if err := saveAccountTemplate(tx, templateDoc); err != nil {
+				return err
+			}
+		}
+
+		if transaction.Header.BlockID != "" {
+			_, err = tx.Exec(`insert into layers (id, applied_block) values (?1, ?2)
+				on conflict (id) do update set applied_block = excluded.applied_block`,
+				bindArgs(transaction.Header.LayerID, transaction.Header.BlockID), nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		if !updateBalances {
+			return nil
+		}
+		return updateTransactionBalances(tx, transactionDoc, 1)
+	})
+	if err != nil {
+		slog.Error("Transaction failed", "transaction", transaction.ID, "error", err)
+	}
+	return err
+}
+
+func saveTransactionDetails(tx *sql.Tx, details *types.TransactionDetailsDoc) error {
+	publicKeys, err := json.Marshal(details.PublicKeys)
+	if err != nil {
+		return err
+	}
+	signatures, err := json.Marshal(details.Signatures)
+	if err != nil {
+		return err
+	}
+	addresses, err := json.Marshal(details.Addresses)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`insert or replace into transaction_details (`+transactionDetailsColumns+`)
+		values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14)`,
+		bindArgs(details.ID, details.Raw, details.Template, string(publicKeys), details.RequiredSigners, details.Owner,
+			details.TotalAmount, details.InitialUnlockAmount, details.VestingStart, details.VestingEnd, details.Signature,
+			string(signatures), string(addresses), details.Message), nil)
+	return err
+}
+
+// saveAccountTemplate replaces the template of an account with the one of its successful spawn, otherwise
+// it only records the template when the account has none and marks it spawned on success. The owner of a
+// successful drain is recorded on the vault.
+func saveAccountTemplate(tx *sql.Tx, template *types.AccountTemplateDoc) error {
+	publicKeys, err := json.Marshal(template.PublicKeys)
+	if err != nil {
+		return err
+	}
+	args := bindArgs(template.Address, template.Template, template.Spawned, template.SpawnLayer,
+		template.SpawnTransaction, string(publicKeys), template.RequiredSigners, template.Owner, template.TotalAmount,
+		template.InitialUnlockAmount, template.VestingStart, template.VestingEnd)
+	if template.SpawnTransaction != "" && template.Spawned {
+		_, err = tx.Exec(`insert or replace into account_templates (`+accountTemplateColumns+`)
+			values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12)`, args, nil)
+		return err
+	}
+	_, err = tx.Exec(`insert into account_templates (`+accountTemplateColumns+`)
+		values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12)
+		on conflict (address) do update set spawned = max(spawned, excluded.spawned),
+		template = case when template = '' then excluded.template else template end,
+		owner = case when excluded.owner = '' then owner else excluded.owner end`, args, nil)
+	return err
+}
+
+func (s *SQLiteDB) SaveReward(reward *nats.Reward, origin Origin) error {
+	rewardDoc := newRewardDoc(reward)
+	rewardDoc.Published = origin.Published
+	err := s.writeOnce(origin, func(tx *sql.Tx) error {
+		stale, err := checkLayerContent(tx, reward.Layer, origin.Published, "", reward.ID)
+		if err != nil {
+			return err
+		}
+		if stale {
+			slog.Info("Ignore reward of reverted layer", "reward", reward.ID, "layer", reward.Layer)
+			return nil
+		}
+
+		found, err := exists(tx, `select 1 from rewards where id = ?1`, rewardDoc.Id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into rewards (`+rewardColumns+`) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)
+			on conflict (id) do update set node_id = excluded.node_id, coinbase = excluded.coinbase,
+			atx_id = excluded.atx_id, layer_reward = excluded.layer_reward, total_reward = excluded.total_reward,
+			layer = excluded.layer, published = excluded.published`,
+			bindArgs(rewardDoc.Id, rewardDoc.NodeId, rewardDoc.Coinbase, rewardDoc.AtxID, rewardDoc.LayerReward,
+				rewardDoc.TotalReward, rewardDoc.Layer, rewardDoc.Published), nil)
+		if err != nil {
+			return err
+		}
+
+		// only update counts if inserted new reward
+		if found {
+			return nil
+		}
+
+		_, err = tx.Exec(`insert into accounts (address, balance, total_rewards) values (?1, ?2, ?2)
+			on conflict (address) do update set balance = balance + excluded.balance,
+			total_rewards = total_rewards + excluded.total_rewards`,
+			bindArgs(rewardDoc.Coinbase, reward.Total), nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`insert into network_info (id, circulating_supply) values ('info', ?1)
+			on conflict (id) do update set circulating_supply = circulating_supply + excluded.circulating_supply`,
+			bindArgs(reward.Total), nil)
+		return err
+	})
+	if err != nil {
+		slog.Error("Rewards transaction failed", "reward", reward.ID, "error", err)
+	}
+	return err
+}
+
+func (s *SQLiteDB) RepairAccount(stored *types.AccountDoc, computed *types.AccountDoc) (bool, error) {
+	repaired := false
+	err := s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		// only repair if the sink didn't change the account since it was checked
+		found, err := exists(tx, `select 1 from accounts where address = ?1 and balance = ?2
+			and total_rewards = ?3 and sent = ?4 and fees = ?5 and received = ?6`,
+			stored.Address, stored.Balance, stored.TotalRewards, stored.Sent, stored.Fees, stored.Received)
+		if err != nil || !found {
+			return err
+		}
+		_, err = tx.Exec(`update accounts set balance = ?2, total_rewards = ?3, sent = ?4, fees = ?5, received = ?6
+			where address = ?1`,
+			bindArgs(stored.Address, computed.Balance, computed.TotalRewards, computed.Sent, computed.Fees, computed.Received), nil)
+		repaired = err == nil
+		return err
+	})
+	return repaired, err
+}
+
+func (s *SQLiteDB) SaveApiKey(key *types.ApiKeyDoc) error {
+	_, err := s.db.Exec(`insert into api_keys (`+apiKeyColumns+`) values (?1, ?2, ?3, ?4, ?5, ?6, ?7)`,
+		bindArgs(key.ID, key.Name, key.Rate, key.Burst, key.DailyQuota, key.CreatedAt, key.RevokedAt), nil)
+	return err
+}
+
+func (s *SQLiteDB) RevokeApiKey(id string, revokedAt int64) (bool, error) {
+	revoked := false
+	err := s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		found, err := exists(tx, `select 1 from api_keys where id = ?1 and revoked_at = 0`, id)
+		if err != nil || !found {
+			return err
+		}
+		_, err = tx.Exec(`update api_keys set revoked_at = ?2 where id = ?1`, bindArgs(id, revokedAt), nil)
+		revoked = err == nil
+		return err
+	})
+	return revoked, err
+}
+
+func (s *SQLiteDB) SaveWebhook(webhook *types.WebhookDoc) error {
+	_, err := s.db.Exec(`insert into webhooks (`+webhookColumns+`) values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)`,
+		bindArgs(webhook.ID, webhook.Owner, webhook.Url, webhook.Secret, webhook.Event, webhook.Account, webhook.Node,
+			webhook.CreatedAt), nil)
+	return err
+}
+
+func (s *SQLiteDB) DeleteWebhook(id string, owner string) (bool, error) {
+	deleted := false
+	err := s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		found, err := exists(tx, `select 1 from webhooks where id = ?1 and owner = ?2`, id, owner)
+		if err != nil || !found {
+			return err
+		}
+		if _, err := tx.Exec(`delete from webhooks where id = ?1`, bindArgs(id), nil); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`delete from webhook_deliveries where webhook_id = ?1`, bindArgs(id), nil)
+		deleted = err == nil
+		return err
+	})
+	return deleted, err
+}
+
+func (s *SQLiteDB) SaveWebhookDelivery(delivery *types.WebhookDeliveryDoc) (bool, error) {
+	saved := false
+	err := s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		found, err := exists(tx, `select 1 from webhook_deliveries where id = ?1`, delivery.ID)
+		if err != nil || found {
+			return err
+		}
+		_, err = tx.Exec(`insert into webhook_deliveries (`+webhookDeliveryColumns+`)
+			values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)`,
+			bindArgs(delivery.ID, delivery.WebhookID, delivery.Event, delivery.Payload, delivery.Status, delivery.Attempts,
+				delivery.NextAttempt, delivery.StatusCode, delivery.LastError, delivery.CreatedAt, delivery.DeliveredAt), nil)
+		saved = err == nil
+		return err
+	})
+	return saved, err
+}
+
+func (s *SQLiteDB) UpdateWebhookDelivery(delivery *types.WebhookDeliveryDoc) error {
+	_, err := s.db.Exec(`update webhook_deliveries set status = ?2, attempts = ?3, next_attempt = ?4, status_code = ?5,
+		last_error = ?6, delivered_at = ?7 where id = ?1`,
+		bindArgs(delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttempt, delivery.StatusCode,
+			delivery.LastError, delivery.DeliveredAt), nil)
+	return err
+}
+
+func (s *SQLiteDB) DeleteWebhookDeliveries(createdAt int64) error {
+	_, err := s.db.Exec(`delete from webhook_deliveries where created_at < ?1 and status != 'pending'`,
+		bindArgs(createdAt), nil)
+	return err
+}
+
+func (s *SQLiteDB) SaveDeadLetter(letter *types.DeadLetterDoc) error {
+	_, err := s.db.Exec(`insert or replace into dead_letters (`+deadLetterColumns+`)
+		values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)`,
+		bindArgs(letter.ID, letter.Stream, letter.Subject, letter.Sequence, letter.Published, letter.Payload,
+			letter.Error, letter.Deliveries, letter.CreatedAt), nil)
+	return err
+}
+
+func (s *SQLiteDB) SavePrices(prices []*types.PriceDoc) error {
+	return s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		for _, price := range prices {
+			_, err := tx.Exec(`insert or replace into prices (`+priceColumns+`) values (?1, ?2, ?3)`,
+				bindArgs(price.Timestamp, price.Price, price.Source), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteDB) SaveStats(stats []*types.StatsDoc) error {
+	return s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		for _, doc := range stats {
+			_, err := tx.Exec(`insert or replace into stats (`+statsColumns+`)
+				values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13, ?14, ?15, ?16, ?17, ?18)`,
+				bindArgs(doc.ID, doc.Period, doc.Index, doc.Timestamp, doc.FirstLayer, doc.LastLayer, doc.Complete,
+					doc.ActiveSmeshers, doc.TotalWeight, doc.EffectiveUnitsCommitted, doc.TotalRewards, doc.Vested,
+					doc.CirculatingSupply, doc.Rewards, doc.Transactions, doc.TransactionsVolume, doc.NewAccounts,
+					doc.Malfeasance), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteDB) SaveNodePerformance(performance []*types.NodePerformanceDoc) error {
+	return s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		for _, doc := range performance {
+			_, err := tx.Exec(`insert or replace into node_performance (`+nodePerformanceColumns+`)
+				values (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13)`,
+				bindArgs(doc.ID, doc.NodeID, doc.Coinbase, doc.Epoch, doc.Weight, doc.EffectiveNumUnits, doc.EligibleSlots,
+					doc.RewardedSlots, doc.MissedSlots, doc.ExpectedRewards, doc.Rewards, doc.Efficiency, doc.NoRewards), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteDB) DeleteDeadLetter(id string) (bool, error) {
+	deleted := false
+	err := s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		found, err := exists(tx, `select 1 from dead_letters where id = ?1`, id)
+		if err != nil || !found {
+			return err
+		}
+		_, err = tx.Exec(`delete from dead_letters where id = ?1`, bindArgs(id), nil)
+		deleted = err == nil
+		return err
+	})
+	return deleted, err
+}
+
+func (s *SQLiteDB) DropTransactions(publishedBefore int64, layerBefore uint32) (int64, error) {
+	var dropped int64
+	err := s.db.WithTx(context.TODO(), func(tx *sql.Tx) error {
+		_, err := tx.Exec(`update transactions set dropped = 1 where complete = 0 and dropped = 0
+			and ((published > 0 and published < ?1) or (published = 0 and layer < ?2))`,
+			bindArgs(publishedBefore, layerBefore), nil)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`select changes()`, nil, func(stmt *sql.Statement) bool {
+			dropped = stmt.ColumnInt64(0)
+			return false
+		})
+		return err
+	})
+	return dropped, err
+}
@@ -2,25 +2,26 @@ package database
 
 import (
     "context"
-    "fmt"
-    "log"
+    "errors"
+    "log/slog"
     "time"
 
     sTypes "github.com/spacemeshos/go-spacemesh/common/types"
     "github.com/spacemeshos/go-spacemesh/nats"
-    "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
-    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
     "github.com/swarmbit/spacemesh-state-api/types"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type WriteDB struct {
-    client *mongo.Client
+type MongoWriteDB struct {
+    client   *mongo.Client
+    database string
+    // hrp encodes the addresses parsed from raw transactions
+    hrp string
 }
 
-const database = "spacemesh"
+const defaultDatabase = "spacemesh"
 const rewardsCollection = "rewards"
 const layersCollection = "layers"
 const atxsCollection = "atxs"
@@ -33,19 +34,98 @@ const nodesCountCollection = "nodesCount"
 const networkInfoCollection = "networkInfo"
 const accountsCollection = "accounts"
 const transactionsCollection = "transactions"
-
-func NewWriteDB(dbConnection string) (*WriteDB, error) {
+const layerRevertsCollection = "layerReverts"
+const apiKeysCollection = "apiKeys"
+const webhooksCollection = "webhooks"
+const webhookDeliveriesCollection = "webhookDeliveries"
+const deadLettersCollection = "deadLetters"
+const processedMessagesCollection = "processedMessages"
+const pricesCollection = "prices"
+const statsCollection = "stats"
+const nodePerformanceCollection = "nodePerformance"
+const transactionDetailsCollection = "transactionDetails"
+const accountTemplatesCollection = "accountTemplates"
+const migrationsCollection = "migrations"
+
+func NewMongoWriteDB(dbConnection string, database string, hrp string) (*MongoWriteDB, error) {
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
     client, err := mongo.Connect(ctx, options.Client().ApplyURI(dbConnection).SetMaxPoolSize(10))
-    err = createIndexes(client)
-    log.Println("Created write db")
-    return &WriteDB{
-        client: client,
+    if err != nil {
+        return nil, err
+    }
+    err = createIndexes(client, database)
+    if err != nil {
+        return nil, err
+    }
+    err = migrate(client, database)
+    slog.Info("Created write db", "database", database)
+    return &MongoWriteDB{
+        client:   client,
+        database: database,
+        hrp:      hrp,
     }, err
 }
 
-func createIndexes(client *mongo.Client) error {
+// migration is a one-off change of the stored documents, it returns how many documents it changed.
+type migration struct {
+    name string
+    run  func(db *mongo.Database) (int64, error)
+}
+
+var migrations = []migration{
+    {name: "coinbase-account-counters", run: setCoinbaseAccountCounters},
+}
+
+// migrate runs the migrations the database doesn't have yet, in order, and records each one once it is done.
+func migrate(client *mongo.Client, database string) error {
+    migrationsColl := client.Database(database).Collection(migrationsCollection)
+    for _, m := range migrations {
+        err := migrationsColl.FindOne(context.TODO(), bson.D{{Key: "_id", Value: m.name}}).Err()
+        if err == nil {
+            continue
+        }
+        if !errors.Is(err, mongo.ErrNoDocuments) {
+            return err
+        }
+        changed, err := m.run(client.Database(database))
+        if err != nil {
+            slog.Error("Failed to migrate", "migration", m.name, "error", err)
+            return err
+        }
+        _, err = migrationsColl.InsertOne(context.TODO(), bson.D{
+            {Key: "_id", Value: m.name},
+            {Key: "appliedAt", Value: time.Now().UnixMilli()},
+        })
+        if err != nil {
+            return err
+        }
+        slog.Info("Migrated", "migration", m.name, "documents", changed)
+    }
+    return nil
+}
+
+// setCoinbaseAccountCounters sets the counters of the accounts first seen as the coinbase of an atx, they
+// used to be saved without them and the balance cursor skipped them.
+func setCoinbaseAccountCounters(db *mongo.Database) (int64, error) {
+    result, err := db.Collection(accountsCollection).UpdateMany(
+        context.TODO(),
+        bson.D{{Key: "balance", Value: bson.D{{Key: "$exists", Value: false}}}},
+        bson.D{{Key: "$set", Value: bson.D{
+            {Key: "balance", Value: uint64(0)},
+            {Key: "totalRewards", Value: uint64(0)},
+            {Key: "fees", Value: uint64(0)},
+            {Key: "sent", Value: uint64(0)},
+            {Key: "received", Value: uint64(0)},
+        }}},
+    )
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
+
+func createIndexes(client *mongo.Client, database string) error {
     rewardsColl := client.Database(database).Collection(rewardsCollection)
     rewardsIndexes := []mongo.IndexModel{
         {
@@ -68,11 +148,19 @@ func createIndexes(client *mongo.Client) error {
             },
             Options: options.Index().SetUnique(false),
         },
+        {
+            Keys: bson.D{
+                {Key: "coinbase", Value: 1},
+                {Key: "layer", Value: 1},
+                {Key: "_id", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
     }
 
     _, err := rewardsColl.Indexes().CreateMany(context.TODO(), rewardsIndexes)
     if err != nil {
-        log.Println(err)
+        slog.Error("Failed to create indexes", "error", err)
         return err
     }
 
@@ -94,7 +182,29 @@ func createIndexes(client *mongo.Client) error {
         },
         {
             Keys: bson.D{
+                {Key: "vault_account", Value: 1},
+                {Key: "layer", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "layer", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "complete", Value: 1},
+                {Key: "published", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "complete", Value: 1},
                 {Key: "layer", Value: 1},
+                {Key: "_id", Value: 1},
             },
             Options: options.Index().SetUnique(false),
         },
@@ -102,7 +212,7 @@ func createIndexes(client *mongo.Client) error {
 
     _, err = transactionsColl.Indexes().CreateMany(context.TODO(), transactionsIndexes)
     if err != nil {
-        log.Println(err)
+        slog.Error("Failed to create indexes", "error", err)
         return err
     }
 
@@ -114,11 +224,18 @@ func createIndexes(client *mongo.Client) error {
             },
             Options: options.Index().SetUnique(false),
         },
+        {
+            Keys: bson.D{
+                {Key: "balance", Value: -1},
+                {Key: "_id", Value: -1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
     }
 
     _, err = accountsColl.Indexes().CreateMany(context.TODO(), accountsIndexes)
     if err != nil {
-        log.Println(err)
+        slog.Error("Failed to create indexes", "error", err)
         return err
     }
 
@@ -158,11 +275,19 @@ func createIndexes(client *mongo.Client) error {
             },
             Options: options.Index().SetUnique(false),
         },
+        {
+            Keys: bson.D{
+                {Key: "publishepoch", Value: 1},
+                {Key: "effective_num_units", Value: 1},
+                {Key: "_id", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
     }
 
     _, err = atxColl.Indexes().CreateMany(context.TODO(), atxIndexes)
     if err != nil {
-        log.Println(err)
+        slog.Error("Failed to create indexes", "error", err)
         return err
     }
 
@@ -179,392 +304,1071 @@ func createIndexes(client *mongo.Client) error {
 
     _, err = accountAtxsEpochsColl.Indexes().CreateMany(context.TODO(), accountAtxEpochsIndexes)
     if err != nil {
-        log.Println(err)
+        slog.Error("Failed to create indexes", "error", err)
         return err
     }
-    return nil
-}
 
-func (m *WriteDB) SaveLayer(layer *nats.LayerUpdate) error {
-    // only store processed layers
-    if layer.Status > 0 {
-        layersColl := m.client.Database(database).Collection(layersCollection)
-        _, err := layersColl.UpdateOne(
-            context.TODO(),
-            bson.D{{Key: "_id", Value: layer.LayerID}},
-            bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: layer.Status}}}},
-            options.Update().SetUpsert(true),
-        )
+    layerRevertsColl := client.Database(database).Collection(layerRevertsCollection)
+    layerRevertsIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "layer", Value: 1},
+                {Key: "revertedAt", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
+
+    _, err = layerRevertsColl.Indexes().CreateMany(context.TODO(), layerRevertsIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
         return err
     }
-    return nil
-}
 
-func (m *WriteDB) SaveAtx(atx *nats.Atx) error {
-    session, err := m.client.StartSession()
-    defer session.EndSession(context.TODO())
+    webhooksColl := client.Database(database).Collection(webhooksCollection)
+    webhooksIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "owner", Value: 1},
+                {Key: "createdAt", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
 
-    callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
-        atxsColl := m.client.Database(database).Collection(atxsCollection)
-        atxsEpochsColl := m.client.Database(database).Collection(atxsEpochsCollection)
-        accountAtxsEpochsColl := m.client.Database(database).Collection(accountAtxsEpochsCollection)
-        nodesColl := m.client.Database(database).Collection(nodesCollection)
-        nodesCountColl := m.client.Database(database).Collection(nodesCountCollection)
-        accountsColl := m.client.Database(database).Collection(accountsCollection)
-        weight := getATXWeight(atx.TickCount, uint64(atx.EffectiveNumUnits))
-        atxDoc := &types.AtxDoc{
-            AtxID:             atx.AtxID,
-            NodeID:            atx.NodeID,
-            EffectiveNumUnits: atx.EffectiveNumUnits,
-            BaseTick:          atx.BaseTick,
-            TickCount:         atx.TickCount,
-            Sequence:          atx.Sequence,
-            PublishEpoch:      atx.PublishEpoch,
-            Coinbase:          atx.Coinbase,
-            Received:          atx.Received,
-            Weight:            weight,
-        }
-        updateResult, err := atxsColl.UpdateOne(
-            context.TODO(),
-            bson.D{{Key: "_id", Value: atx.AtxID}},
-            bson.D{{Key: "$set", Value: atxDoc}},
-            options.Update().SetUpsert(true))
-        if err != nil {
-            return updateResult, err
-        }
+    _, err = webhooksColl.Indexes().CreateMany(context.TODO(), webhooksIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
 
-        // only update counts if inserted new ATX
-        if updateResult.UpsertedCount == 1 {
-            updateResult, err = atxsEpochsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: atxDoc.PublishEpoch}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
-                    {Key: "totalWeight", Value: weight},
-                    {Key: "totalAtx", Value: 1},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            if err != nil {
-                return updateResult, err
-            }
+    webhookDeliveriesColl := client.Database(database).Collection(webhookDeliveriesCollection)
+    webhookDeliveriesIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "webhookId", Value: 1},
+                {Key: "createdAt", Value: -1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "status", Value: 1},
+                {Key: "nextAttempt", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
 
-            updateResult, err = accountAtxsEpochsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: bson.M{
-                    "coinbase":      atx.Coinbase,
-                    "publish_epoch": atx.PublishEpoch,
-                }}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
-                    {Key: "totalWeight", Value: weight},
-                    {Key: "totalAtx", Value: 1},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            if err != nil {
-                return updateResult, err
-            }
+    _, err = webhookDeliveriesColl.Indexes().CreateMany(context.TODO(), webhookDeliveriesIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
 
-            updateResult, err = nodesColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: atxDoc.NodeID}},
-                bson.D{{Key: "$addToSet", Value: bson.D{
-                    {Key: "atxs", Value: bson.D{
-                        {Key: "coinbase", Value: atxDoc.Coinbase},
-                        {Key: "effectiveNumUnits", Value: atxDoc.EffectiveNumUnits},
-                        {Key: "sequence", Value: atxDoc.Sequence},
-                        {Key: "weight", Value: atxDoc.Weight},
-                        {Key: "publishEpoch", Value: atxDoc.PublishEpoch},
-                        {Key: "received", Value: atxDoc.Received},
-                    }},
-                }}},
-                options.Update().SetUpsert(true),
-            )
+    deadLettersColl := client.Database(database).Collection(deadLettersCollection)
+    deadLettersIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "stream", Value: 1},
+                {Key: "createdAt", Value: -1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "createdAt", Value: -1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
 
-            if updateResult.UpsertedCount == 1 {
-                updateResult, err = nodesCountColl.UpdateOne(
-                    context.TODO(),
-                    bson.D{{Key: "_id", Value: "nodesCount"}},
-                    bson.D{{Key: "$inc", Value: bson.D{
-                        {Key: "count", Value: 1},
-                    }}},
-                    options.Update().SetUpsert(true),
-                )
-
-                if err != nil {
-                    return updateResult, err
-                }
-            }
+    _, err = deadLettersColl.Indexes().CreateMany(context.TODO(), deadLettersIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
 
-            updateResult, err = accountsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: atxDoc.Coinbase}},
-                bson.D{{Key: "$setOnInsert", Value: bson.D{
-                    {Key: "_id", Value: atxDoc.Coinbase},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            return updateResult, err
-        }
+    pricesColl := client.Database(database).Collection(pricesCollection)
+    pricesIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "source", Value: 1},
+                {Key: "_id", Value: -1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
 
-        return updateResult, err
+    _, err = pricesColl.Indexes().CreateMany(context.TODO(), pricesIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
     }
 
-    // Execute the operations in a transaction
-    if _, err := session.WithTransaction(context.TODO(), callback); err != nil {
-        log.Println("Atx transaction failed: %v", err)
+    statsColl := client.Database(database).Collection(statsCollection)
+    statsIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "period", Value: 1},
+                {Key: "timestamp", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
     }
 
-    fmt.Println("Atx transaction succeeded")
+    _, err = statsColl.Indexes().CreateMany(context.TODO(), statsIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
 
-    return err
+    nodePerformanceColl := client.Database(database).Collection(nodePerformanceCollection)
+    nodePerformanceIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "nodeId", Value: 1},
+                {Key: "epoch", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "coinbase", Value: 1},
+                {Key: "epoch", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "epoch", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
 
-}
+    _, err = nodePerformanceColl.Indexes().CreateMany(context.TODO(), nodePerformanceIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
 
-func (m *WriteDB) SaveMalfeasance(malfeasance *nats.Malfeasance) error {
-    nodesColl := m.client.Database(database).Collection(nodesCollection)
-    _, err := nodesColl.UpdateOne(
-        context.TODO(),
-        bson.D{{Key: "_id", Value: malfeasance.NodeID}},
-        bson.D{{Key: "$set", Value: bson.D{
-            {Key: "malfeasance", Value: bson.D{
-                {Key: "received", Value: malfeasance.Received},
-            }},
-        }}},
-        options.Update().SetUpsert(true),
-    )
-    fmt.Println("Malfeasance succeeded")
-    return err
-}
+    accountTemplatesColl := client.Database(database).Collection(accountTemplatesCollection)
+    accountTemplatesIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "owner", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+        {
+            Keys: bson.D{
+                {Key: "spawnTransaction", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
 
-func (m *WriteDB) SaveTransactions(transaction *nats.Transaction, result bool) error {
-    session, err := m.client.StartSession()
-    defer session.EndSession(context.TODO())
+    _, err = accountTemplatesColl.Indexes().CreateMany(context.TODO(), accountTemplatesIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
 
-    callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+    nodesColl := client.Database(database).Collection(nodesCollection)
+    nodesIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "malfeasance.received", Value: 1},
+            },
+            Options: options.Index().SetUnique(false),
+        },
+    }
 
-        var transactionDoc *types.TransactionDoc
-        if result {
+    _, err = nodesColl.Indexes().CreateMany(context.TODO(), nodesIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
 
-            transactionData, err := transactionparser.Parse(transaction.Raw)
-            if err != nil {
-                fmt.Println("Failed to parse transaction: ", err)
-                return nil, err
-            }
-            receiver := transactionData.Tx.GetReceiver()
-            receiverString := ""
-            if len(receiver.Bytes()) > 0 {
-                receiverString = receiver.String()
-            }
+    processedMessagesColl := client.Database(database).Collection(processedMessagesCollection)
+    processedMessagesIndexes := []mongo.IndexModel{
+        {
+            Keys: bson.D{
+                {Key: "processedAt", Value: 1},
+            },
+            Options: options.Index().SetExpireAfterSeconds(int32(ProcessedRetention.Seconds())),
+        },
+    }
 
-            vaultString := ""
-            if transactionData.Type == transactionparsertypes.TypeDrainVault {
-                vaultString = transactionData.Vault.GetVault().String()
-            }
+    _, err = processedMessagesColl.Indexes().CreateMany(context.TODO(), processedMessagesIndexes)
+    if err != nil {
+        slog.Error("Failed to create indexes", "error", err)
+        return err
+    }
+    return nil
+}
 
-            transactionDoc = &types.TransactionDoc{
-                ID:              transaction.ID,
-                PrincipaAccount: transaction.Header.Principal,
-                ReceiverAccount: receiverString,
-                VaultAccount:    vaultString,
-                Fee:             transaction.Header.Fee,
-                Gas:             transaction.Header.Gas,
-                Layer:           transaction.Header.LayerID,
-                Status:          transaction.Header.Status,
-                Method:          transaction.Header.Method,
-                Type:            transactionData.Tx.GetType(),
-                Amount:          transactionData.Tx.GetAmount(),
-                Counter:         transactionData.Tx.GetCounter(),
-                GasPrice:        transactionData.Tx.GetGasPrice(),
-                Complete:        true,
-            }
+// writeOnce runs write in a transaction that also records the stream message of origin. A message already
+// recorded was applied by a delivery that wasn't acked and is skipped.
+func (m *MongoWriteDB) writeOnce(origin Origin, write func(sessionContext mongo.SessionContext) error) error {
+    session, err := m.client.StartSession()
+    if err != nil {
+        return err
+    }
+    defer session.EndSession(context.TODO())
 
-            transactionsColl := m.client.Database(database).Collection(transactionsCollection)
-            accountsColl := m.client.Database(database).Collection(accountsCollection)
+    callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+        if origin.Sequence == 0 {
+            return nil, write(sessionContext)
+        }
+        processedMessagesColl := m.client.Database(m.database).Collection(processedMessagesCollection)
+        count, err := processedMessagesColl.CountDocuments(sessionContext, bson.D{{Key: "_id", Value: origin.key()}})
+        if err != nil {
+            return nil, err
+        }
+        if count > 0 {
+            slog.Debug("Skip processed message", "stream", origin.Stream, "sequence", origin.Sequence)
+            return nil, nil
+        }
+        if err := write(sessionContext); err != nil {
+            return nil, err
+        }
+        return processedMessagesColl.InsertOne(sessionContext, newProcessedMessageDoc(origin))
+    }
 
-            previousTransaction := transactionsColl.FindOneAndUpdate(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: transaction.ID}},
-                bson.D{{Key: "$set", Value: transactionDoc}},
-                options.FindOneAndUpdate().SetUpsert(true))
+    _, err = session.WithTransaction(context.TODO(), callback)
+    return err
+}
 
-            err = previousTransaction.Err()
-            if err != nil && err != mongo.ErrNoDocuments {
-                return previousTransaction, err
-            }
+func (m *MongoWriteDB) SaveLayer(layer *nats.LayerUpdate, origin Origin) error {
+    // only store processed layers
+    if layer.Status == 0 {
+        return nil
+    }
+    err := m.writeOnce(origin, func(sessionContext mongo.SessionContext) error {
+        layersColl := m.client.Database(m.database).Collection(layersCollection)
+        layerDoc, err := m.getLayer(sessionContext, layer.LayerID)
+        if err != nil {
+            return err
+        }
+        updated := updatedLayer(layerDoc, layer, origin.Published)
+        _, err = layersColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: layer.LayerID}},
+            bson.D{{Key: "$set", Value: bson.D{
+                {Key: "status", Value: updated.Status},
+                {Key: "published", Value: updated.Published},
+                {Key: "lastApplied", Value: updated.LastApplied},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        return err
+    })
+    if err != nil {
+        slog.Error("Layer transaction failed", "layer", layer.LayerID, "error", err)
+    }
+    return err
+}
 
-            updateBalances := false
-
-            if err == mongo.ErrNoDocuments {
-                // if no documents found means it got the result before the created so it should update balance
-                updateBalances = true
-            } else {
-                previousTransactionDoc := &types.TransactionDoc{}
-                err := previousTransaction.Decode(previousTransactionDoc)
-                if err != nil {
-                    return previousTransaction, err
-                }
-                // if not complete means it should update balance, if complete is a duplicate so don't update balances
-                updateBalances = !previousTransactionDoc.Complete
-            }
+func (m *MongoWriteDB) getLayer(ctx context.Context, layer uint32) (*types.LayerDoc, error) {
+    layersColl := m.client.Database(m.database).Collection(layersCollection)
+    layerDoc := &types.LayerDoc{}
+    err := layersColl.FindOne(ctx, bson.D{{Key: "_id", Value: layer}}).Decode(layerDoc)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return layerDoc, nil
+}
 
-            // if transaction not sucessfull or addressess length less than 2 it means is an ineffective transaction
-            if transaction.Header.Status != uint8(sTypes.TransactionSuccess) || len(transaction.Header.Addresses) < 2 {
-                updateBalances = false
-            }
+// checkLayerContent reverts the stored content of a layer when a record published at published, of the block
+// blockID for a result, shows the layer was executed again. It returns true when the record belongs to already
+// reverted content.
+func (m *MongoWriteDB) checkLayerContent(ctx mongo.SessionContext, layer uint32, published int64, blockID string, trigger string) (bool, error) {
+    if published == 0 {
+        return false, nil
+    }
+    layerDoc, err := m.getLayer(ctx, layer)
+    if err != nil {
+        return false, err
+    }
+    revert, boundary, stale := layerContent(layerDoc, published, blockID)
+    if !revert {
+        return stale, nil
+    }
 
-            // if amount is 0 there is not point updating the balance for receiver account
-            if updateBalances && transactionDoc.Amount > 0 {
-                updateResult, err := accountsColl.UpdateOne(
-                    context.TODO(),
-                    bson.D{{Key: "_id", Value: transactionDoc.ReceiverAccount}},
-                    bson.D{{Key: "$inc", Value: bson.D{
-                        {Key: "balance", Value: transactionDoc.Amount},
-                        {Key: "received", Value: transactionDoc.Amount},
-                    }}},
-                    options.Update().SetUpsert(true),
-                )
-                if err != nil {
-                    return updateResult, err
-                }
-            }
+    err = m.revertLayer(ctx, layerDoc, boundary, trigger)
+    if err != nil {
+        return false, err
+    }
+    reverted := revertedLayer(layerDoc, boundary, published)
+    layersColl := m.client.Database(m.database).Collection(layersCollection)
+    _, err = layersColl.UpdateOne(
+        ctx,
+        bson.D{{Key: "_id", Value: layer}},
+        bson.D{{Key: "$set", Value: bson.D{
+            {Key: "published", Value: reverted.Published},
+            {Key: "revertBoundary", Value: reverted.RevertBoundary},
+            {Key: "reverts", Value: reverted.Reverts},
+        }}},
+    )
+    return false, err
+}
+
+// revertLayer removes the rewards of the layer published up to boundary, returns the transactions to not
+// complete, reverses their balance changes and records the revert.
+func (m *MongoWriteDB) revertLayer(ctx mongo.SessionContext, layerDoc *types.LayerDoc, boundary int64, trigger string) error {
+    rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
+    networkInfoColl := m.client.Database(m.database).Collection(networkInfoCollection)
+    layerRevertsColl := m.client.Database(m.database).Collection(layerRevertsCollection)
+
+    // documents saved before publish times were tracked have none and are reverted too
+    filter := bson.D{
+        {Key: "layer", Value: layerDoc.Layer},
+        {Key: "published", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: boundary}}}}},
+    }
+
+    revertDoc := &types.LayerRevertDoc{
+        Layer:      layerDoc.Layer,
+        RevertedAt: time.Now().UnixMilli(),
+        Boundary:   boundary,
+        Trigger:    trigger,
+        Blocks:     []string{},
+    }
+
+    cursor, err := rewardsColl.Find(ctx, filter)
+    if err != nil {
+        return err
+    }
+    var rewards []*types.RewardsDoc
+    if err = cursor.All(ctx, &rewards); err != nil {
+        return err
+    }
+    for _, reward := range rewards {
+        _, err = accountsColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: reward.Coinbase}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "totalRewards", Value: -reward.TotalReward},
+                {Key: "balance", Value: -reward.TotalReward},
+            }}},
+        )
+        if err != nil {
+            return err
+        }
+        revertDoc.RewardsReverted++
+        revertDoc.RewardsAmount += reward.TotalReward
+    }
+    if revertDoc.RewardsReverted > 0 {
+        _, err = networkInfoColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: "info"}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "circulatingSupply", Value: -revertDoc.RewardsAmount},
+            }}},
+        )
+        if err != nil {
+            return err
+        }
+        _, err = rewardsColl.DeleteMany(ctx, filter)
+        if err != nil {
+            return err
+        }
+    }
 
-            // update balance for sender account
-            if updateBalances {
-                senderAccount := transactionDoc.PrincipaAccount
-
-                // if drain vault transaction deduct fees and balance from vault account
-                if transactionData.Type == transactionparsertypes.TypeDrainVault {
-                    senderAccount = transactionDoc.VaultAccount
-                }
-
-                fee := transactionDoc.Gas * transactionDoc.GasPrice
-                valueToDeduct := (int64(transactionDoc.Amount) + int64(fee)) * -1
-                updateResult, err := accountsColl.UpdateOne(
-                    context.TODO(),
-                    bson.D{{Key: "_id", Value: senderAccount}},
-                    bson.D{{Key: "$inc", Value: bson.D{
-                        {Key: "balance", Value: valueToDeduct},
-                        {Key: "sent", Value: transactionDoc.Amount},
-                        {Key: "fees", Value: fee},
-                    }}},
-                    options.Update().SetUpsert(true),
-                )
-                if err != nil {
-                    return updateResult, err
-                }
+    transactionsFilter := bson.D{
+        {Key: "layer", Value: layerDoc.Layer},
+        {Key: "published", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gt", Value: boundary}}}}},
+        {Key: "complete", Value: true},
+    }
+    cursor, err = transactionsColl.Find(ctx, transactionsFilter)
+    if err != nil {
+        return err
+    }
+    var transactions []*types.TransactionDoc
+    if err = cursor.All(ctx, &transactions); err != nil {
+        return err
+    }
+    blocks := map[string]bool{}
+    for _, transaction := range transactions {
+        if transaction.BalanceApplied {
+            err = m.updateTransactionBalances(ctx, transaction, -1)
+            if err != nil {
+                return err
             }
+        }
+        if transaction.BlockID != "" && !blocks[transaction.BlockID] {
+            blocks[transaction.BlockID] = true
+            revertDoc.Blocks = append(revertDoc.Blocks, transaction.BlockID)
+        }
+        revertDoc.TransactionsReverted++
+    }
+    if revertDoc.TransactionsReverted > 0 {
+        // the accounts they spawned are no longer spawned until they are applied again
+        spawns := make([]string, 0, len(transactions))
+        for _, transaction := range transactions {
+            spawns = append(spawns, transaction.ID)
+        }
+        _, err = m.client.Database(m.database).Collection(accountTemplatesCollection).UpdateMany(
+            ctx,
+            bson.D{{Key: "spawnTransaction", Value: bson.D{{Key: "$in", Value: spawns}}}},
+            bson.D{{Key: "$set", Value: bson.D{{Key: "spawned", Value: false}}}},
+        )
+        if err != nil {
+            return err
+        }
+        _, err = transactionsColl.UpdateMany(
+            ctx,
+            transactionsFilter,
+            bson.D{{Key: "$set", Value: bson.D{
+                {Key: "complete", Value: false},
+                {Key: "balance_applied", Value: false},
+            }}},
+        )
+        if err != nil {
+            return err
+        }
+    }
 
-            return previousTransaction, err
-        } else {
-            transactionDoc = &types.TransactionDoc{
-                ID:              transaction.ID,
-                PrincipaAccount: transaction.Header.Principal,
-                Fee:             transaction.Header.Fee,
-                Gas:             transaction.Header.Gas,
-                Layer:           transaction.Header.LayerID,
-                Status:          transaction.Header.Status,
-                Method:          transaction.Header.Method,
-                Complete:        false,
+    _, err = layerRevertsColl.InsertOne(ctx, revertDoc)
+    if err != nil {
+        return err
+    }
+    slog.Warn("Reverted layer", "layer", layerDoc.Layer, "rewards", revertDoc.RewardsReverted, "transactions", revertDoc.TransactionsReverted)
+    return nil
+}
+
+// saveAccountTemplate replaces the template of an account with the one of its successful spawn, otherwise
+// it only records the template when the account has none and marks it spawned on success. The owner of a
+// successful drain is recorded on the vault.
+func (m *MongoWriteDB) saveAccountTemplate(ctx context.Context, templateDoc *types.AccountTemplateDoc) error {
+    accountTemplatesColl := m.client.Database(m.database).Collection(accountTemplatesCollection)
+    filter := bson.D{{Key: "_id", Value: templateDoc.Address}}
+    if templateDoc.SpawnTransaction != "" && templateDoc.Spawned {
+        _, err := accountTemplatesColl.ReplaceOne(ctx, filter, templateDoc, options.Replace().SetUpsert(true))
+        return err
+    }
+
+    onInsert := bson.D{
+        {Key: "spawnLayer", Value: templateDoc.SpawnLayer},
+        {Key: "spawnTransaction", Value: templateDoc.SpawnTransaction},
+        {Key: "publicKeys", Value: templateDoc.PublicKeys},
+        {Key: "requiredSigners", Value: templateDoc.RequiredSigners},
+        {Key: "totalAmount", Value: templateDoc.TotalAmount},
+        {Key: "initialUnlockAmount", Value: templateDoc.InitialUnlockAmount},
+        {Key: "vestingStart", Value: templateDoc.VestingStart},
+        {Key: "vestingEnd", Value: templateDoc.VestingEnd},
+        {Key: "template", Value: templateDoc.Template},
+    }
+    set := bson.D{}
+    if templateDoc.Spawned {
+        set = append(set, bson.E{Key: "spawned", Value: true})
+    } else {
+        onInsert = append(onInsert, bson.E{Key: "spawned", Value: false})
+    }
+    if templateDoc.Owner != "" {
+        set = append(set, bson.E{Key: "owner", Value: templateDoc.Owner})
+    } else {
+        onInsert = append(onInsert, bson.E{Key: "owner", Value: ""})
+    }
+    update := bson.D{{Key: "$setOnInsert", Value: onInsert}}
+    if len(set) > 0 {
+        update = append(update, bson.E{Key: "$set", Value: set})
+    }
+    _, err := accountTemplatesColl.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+    if err != nil || templateDoc.Template == "" {
+        return err
+    }
+
+    // the template of an account first seen in a transaction of an unknown template
+    _, err = accountTemplatesColl.UpdateOne(
+        ctx,
+        bson.D{{Key: "_id", Value: templateDoc.Address}, {Key: "template", Value: ""}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "template", Value: templateDoc.Template}}}},
+    )
+    return err
+}
+
+// updateTransactionBalances applies the balance changes of a transaction, sign -1 reverses them.
+func (m *MongoWriteDB) updateTransactionBalances(ctx context.Context, transactionDoc *types.TransactionDoc, sign int64) error {
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
+
+    // if amount is 0 there is not point updating the balance for receiver account
+    if transactionDoc.Amount > 0 {
+        _, err := accountsColl.UpdateOne(
+            ctx,
+            bson.D{{Key: "_id", Value: transactionDoc.ReceiverAccount}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "balance", Value: sign * int64(transactionDoc.Amount)},
+                {Key: "received", Value: sign * int64(transactionDoc.Amount)},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+    }
+
+    fee := int64(transactionDoc.TotalFee())
+    valueToDeduct := (int64(transactionDoc.Amount) + fee) * -1
+    _, err := accountsColl.UpdateOne(
+        ctx,
+        bson.D{{Key: "_id", Value: transactionSender(transactionDoc)}},
+        bson.D{{Key: "$inc", Value: bson.D{
+            {Key: "balance", Value: sign * valueToDeduct},
+            {Key: "sent", Value: sign * int64(transactionDoc.Amount)},
+            {Key: "fees", Value: sign * fee},
+        }}},
+        options.Update().SetUpsert(true),
+    )
+    return err
+}
+
+func (m *MongoWriteDB) SaveAtx(atx *nats.Atx, origin Origin) error {
+    err := m.writeOnce(origin, func(sessionContext mongo.SessionContext) error {
+        atxsColl := m.client.Database(m.database).Collection(atxsCollection)
+        atxsEpochsColl := m.client.Database(m.database).Collection(atxsEpochsCollection)
+        accountAtxsEpochsColl := m.client.Database(m.database).Collection(accountAtxsEpochsCollection)
+        nodesColl := m.client.Database(m.database).Collection(nodesCollection)
+        nodesCountColl := m.client.Database(m.database).Collection(nodesCountCollection)
+        accountsColl := m.client.Database(m.database).Collection(accountsCollection)
+        atxDoc := newAtxDoc(atx)
+        weight := atxDoc.Weight
+        updateResult, err := atxsColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: atx.AtxID}},
+            bson.D{{Key: "$set", Value: atxDoc}},
+            options.Update().SetUpsert(true))
+        if err != nil {
+            return err
+        }
+
+        // only update counts if inserted new ATX
+        if updateResult.UpsertedCount == 0 {
+            return nil
+        }
+
+        _, err = atxsEpochsColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: atxDoc.PublishEpoch}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
+                {Key: "totalWeight", Value: weight},
+                {Key: "totalAtx", Value: 1},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+
+        _, err = accountAtxsEpochsColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: bson.M{
+                "coinbase":      atx.Coinbase,
+                "publish_epoch": atx.PublishEpoch,
+            }}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "totalEffectiveNumUnits", Value: atx.EffectiveNumUnits},
+                {Key: "totalWeight", Value: weight},
+                {Key: "totalAtx", Value: 1},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+
+        updateResult, err = nodesColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: atxDoc.NodeID}},
+            bson.D{{Key: "$addToSet", Value: bson.D{
+                {Key: "atxs", Value: bson.D{
+                    {Key: "coinbase", Value: atxDoc.Coinbase},
+                    {Key: "effectiveNumUnits", Value: atxDoc.EffectiveNumUnits},
+                    {Key: "sequence", Value: atxDoc.Sequence},
+                    {Key: "weight", Value: atxDoc.Weight},
+                    {Key: "publishEpoch", Value: atxDoc.PublishEpoch},
+                    {Key: "received", Value: atxDoc.Received},
+                }},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+
+        if updateResult.UpsertedCount == 1 {
+            _, err = nodesCountColl.UpdateOne(
+                sessionContext,
+                bson.D{{Key: "_id", Value: "nodesCount"}},
+                bson.D{{Key: "$inc", Value: bson.D{
+                    {Key: "count", Value: 1},
+                }}},
+                options.Update().SetUpsert(true),
+            )
+            if err != nil {
+                return err
             }
+        }
+
+        _, err = accountsColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: atxDoc.Coinbase}},
+            bson.D{{Key: "$setOnInsert", Value: bson.D{
+                {Key: "_id", Value: atxDoc.Coinbase},
+                {Key: "balance", Value: uint64(0)},
+                {Key: "totalRewards", Value: uint64(0)},
+                {Key: "fees", Value: uint64(0)},
+                {Key: "sent", Value: uint64(0)},
+                {Key: "received", Value: uint64(0)},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        return err
+    })
+    if err != nil {
+        slog.Error("Atx transaction failed", "atx", atx.AtxID, "error", err)
+        return err
+    }
 
-            transactionsColl := m.client.Database(database).Collection(transactionsCollection)
+    slog.Debug("Atx transaction succeeded", "atx", atx.AtxID)
+    return nil
+}
 
-            insertResult, err := transactionsColl.InsertOne(
-                context.TODO(),
+func (m *MongoWriteDB) SaveMalfeasance(malfeasance *nats.Malfeasance, origin Origin) error {
+    err := m.writeOnce(origin, func(sessionContext mongo.SessionContext) error {
+        nodesColl := m.client.Database(m.database).Collection(nodesCollection)
+        _, err := nodesColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: malfeasance.NodeID}},
+            bson.D{{Key: "$set", Value: bson.D{
+                {Key: "malfeasance", Value: bson.D{
+                    {Key: "received", Value: malfeasance.Received},
+                }},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        return err
+    })
+    if err != nil {
+        slog.Error("Malfeasance transaction failed", "node", malfeasance.NodeID, "error", err)
+        return err
+    }
+    slog.Debug("Malfeasance succeeded", "node", malfeasance.NodeID)
+    return nil
+}
+
+func (m *MongoWriteDB) SaveTransactions(transaction *nats.Transaction, result bool, origin Origin) error {
+    var write func(sessionContext mongo.SessionContext) error
+    if result {
+        transactionDoc, transactionData, err := newResultTransactionDoc(transaction, m.hrp)
+        if err != nil {
+            slog.Error("Failed to parse transaction", "transaction", transaction.ID, "error", err)
+            return err
+        }
+        transactionDoc.Published = origin.Published
+        detailsDoc, err := newTransactionDetailsDoc(transaction, transactionData, m.hrp)
+        if err != nil {
+            slog.Error("Failed to parse transaction", "transaction", transaction.ID, "error", err)
+            return err
+        }
+        templateDocs, err := newAccountTemplateDocs(transaction, transactionData, detailsDoc, m.hrp)
+        if err != nil {
+            slog.Error("Failed to parse transaction", "transaction", transaction.ID, "error", err)
+            return err
+        }
+        write = func(sessionContext mongo.SessionContext) error {
+            return m.saveResultTransaction(sessionContext, transaction, transactionDoc, detailsDoc, templateDocs, origin)
+        }
+    } else {
+        write = func(sessionContext mongo.SessionContext) error {
+            transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+            transactionDoc := newCreatedTransactionDoc(transaction, m.hrp)
+            transactionDoc.Published = origin.Published
+            _, err := transactionsColl.InsertOne(
+                sessionContext,
                 transactionDoc,
             )
 
             // if already saved ignore error
             if err != nil && docExistsErr(err) {
-                return insertResult, nil
+                return nil
             }
-
-            return insertResult, err
+            return err
         }
     }
 
-    // Execute the operations in a transaction
-    if _, err := session.WithTransaction(context.TODO(), callback); err != nil {
-        log.Println("Transaction failed: %v", err)
+    if err := m.writeOnce(origin, write); err != nil {
+        slog.Error("Transaction failed", "transaction", transaction.ID, "error", err)
+        return err
     }
 
-    fmt.Println("Transaction succeeded")
+    slog.Debug("Transaction succeeded", "transaction", transaction.ID)
+    return nil
+}
 
-    return err
+func (m *MongoWriteDB) saveResultTransaction(sessionContext mongo.SessionContext, transaction *nats.Transaction, transactionDoc *types.TransactionDoc, detailsDoc *types.TransactionDetailsDoc, templateDocs []*types.AccountTemplateDoc, origin Origin) error {
+    stale, err := m.checkLayerContent(sessionContext, transaction.Header.LayerID, origin.Published, transaction.Header.BlockID, transaction.ID)
+    if err != nil {
+        return err
+    }
+    if stale {
+        slog.Info("Ignore transaction of reverted layer", "transaction", transaction.ID, "layer", transaction.Header.LayerID)
+        return nil
+    }
 
-}
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    layersColl := m.client.Database(m.database).Collection(layersCollection)
 
-func (m *WriteDB) SaveReward(reward *nats.Reward) error {
-    session, err := m.client.StartSession()
-    defer session.EndSession(context.TODO())
+    previousTransactionDoc := &types.TransactionDoc{}
+    err = transactionsColl.FindOne(
+        sessionContext,
+        bson.D{{Key: "_id", Value: transaction.ID}},
+    ).Decode(previousTransactionDoc)
+    if err != nil && err != mongo.ErrNoDocuments {
+        return err
+    }
 
-    callback := func(sessionContext mongo.SessionContext) (interface{}, error) {
+    updateBalances := false
+
+    if err == mongo.ErrNoDocuments {
+        // if no documents found means it got the result before the created so it should update balance
+        updateBalances = true
+    } else {
+        // if not complete means it should update balance, if complete is a duplicate so don't update balances
+        updateBalances = !previousTransactionDoc.Complete
+    }
+
+    // if transaction not sucessfull or addressess length less than 2 it means is an ineffective transaction
+    if transaction.Header.Status != uint8(sTypes.TransactionSuccess) || len(transaction.Header.Addresses) < 2 {
+        updateBalances = false
+    }
+    transactionDoc.BalanceApplied = updateBalances || (previousTransactionDoc.Complete && previousTransactionDoc.BalanceApplied)
+
+    _, err = transactionsColl.UpdateOne(
+        sessionContext,
+        bson.D{{Key: "_id", Value: transaction.ID}},
+        bson.D{{Key: "$set", Value: transactionDoc}},
+        options.Update().SetUpsert(true))
+    if err != nil {
+        return err
+    }
+
+    transactionDetailsColl := m.client.Database(m.database).Collection(transactionDetailsCollection)
+    _, err = transactionDetailsColl.ReplaceOne(
+        sessionContext,
+        bson.D{{Key: "_id", Value: transaction.ID}},
+        detailsDoc,
+        options.Replace().SetUpsert(true))
+    if err != nil {
+        return err
+    }
+
+    for _, templateDoc := range templateDocs {
+        if err := m.saveAccountTemplate(sessionContext, templateDoc); err != nil {
+            return err
+        }
+    }
+
+    if transaction.Header.BlockID != "" {
+        _, err = layersColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: transaction.Header.LayerID}},
+            bson.D{{Key: "$set", Value: bson.D{{Key: "appliedBlock", Value: transaction.Header.BlockID}}}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
+        }
+    }
 
-        rewardsColl := m.client.Database(database).Collection(rewardsCollection)
-        accountsColl := m.client.Database(database).Collection(accountsCollection)
-        networkInfoColl := m.client.Database(database).Collection(networkInfoCollection)
+    if !updateBalances {
+        return nil
+    }
+    return m.updateTransactionBalances(sessionContext, transactionDoc, 1)
+}
 
-        rewardDoc := &types.RewardsDoc{
-            Id:          reward.ID,
-            Coinbase:    reward.Coinbase,
-            LayerReward: int64(reward.LayerReward),
-            TotalReward: int64(reward.Total),
-            AtxID:       reward.AtxID,
-            NodeId:      reward.NodeID,
-            Layer:       int64(reward.Layer),
+func (m *MongoWriteDB) SaveReward(reward *nats.Reward, origin Origin) error {
+    err := m.writeOnce(origin, func(sessionContext mongo.SessionContext) error {
+        rewardsColl := m.client.Database(m.database).Collection(rewardsCollection)
+        accountsColl := m.client.Database(m.database).Collection(accountsCollection)
+        networkInfoColl := m.client.Database(m.database).Collection(networkInfoCollection)
+
+        rewardDoc := newRewardDoc(reward)
+        rewardDoc.Published = origin.Published
+
+        stale, err := m.checkLayerContent(sessionContext, reward.Layer, origin.Published, "", reward.ID)
+        if err != nil {
+            return err
+        }
+        if stale {
+            slog.Info("Ignore reward of reverted layer", "reward", reward.ID, "layer", reward.Layer)
+            return nil
         }
 
         updateResult, err := rewardsColl.UpdateOne(
-            context.TODO(),
+            sessionContext,
             bson.D{{Key: "_id", Value: rewardDoc.Id}},
             bson.D{{Key: "$set", Value: rewardDoc}},
             options.Update().SetUpsert(true))
         if err != nil {
-            return updateResult, err
+            return err
         }
 
         // only update counts if inserted new reward
-        if updateResult.UpsertedCount == 1 {
-            updateResult, err = accountsColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: reward.Coinbase}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "totalRewards", Value: reward.Total},
-                    {Key: "balance", Value: reward.Total},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            if err != nil {
-                return updateResult, err
-            }
+        if updateResult.UpsertedCount == 0 {
+            return nil
+        }
 
-            updateResult, err = networkInfoColl.UpdateOne(
-                context.TODO(),
-                bson.D{{Key: "_id", Value: "info"}},
-                bson.D{{Key: "$inc", Value: bson.D{
-                    {Key: "circulatingSupply", Value: reward.Total},
-                }}},
-                options.Update().SetUpsert(true),
-            )
-            return updateResult, err
+        _, err = accountsColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: reward.Coinbase}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "totalRewards", Value: reward.Total},
+                {Key: "balance", Value: reward.Total},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        if err != nil {
+            return err
         }
-        return updateResult, err
+
+        _, err = networkInfoColl.UpdateOne(
+            sessionContext,
+            bson.D{{Key: "_id", Value: "info"}},
+            bson.D{{Key: "$inc", Value: bson.D{
+                {Key: "circulatingSupply", Value: reward.Total},
+            }}},
+            options.Update().SetUpsert(true),
+        )
+        return err
+    })
+    if err != nil {
+        slog.Error("Rewards transaction failed", "reward", reward.ID, "error", err)
+        return err
     }
 
-    // Execute the operations in a transaction
-    if _, err := session.WithTransaction(context.TODO(), callback); err != nil {
-        log.Println("Rewards transaction failed: %v", err)
+    slog.Debug("Rewards transaction succeeded", "reward", reward.ID)
+    return nil
+}
+
+func (m *MongoWriteDB) RepairAccount(stored *types.AccountDoc, computed *types.AccountDoc) (bool, error) {
+    accountsColl := m.client.Database(m.database).Collection(accountsCollection)
+
+    // only repair if the sink didn't change the account since it was checked
+    filter := bson.D{{Key: "_id", Value: stored.Address}}
+    for _, field := range []struct {
+        key   string
+        value uint64
+    }{
+        {"balance", stored.Balance},
+        {"totalRewards", stored.TotalRewards},
+        {"sent", stored.Sent},
+        {"fees", stored.Fees},
+        {"received", stored.Received},
+    } {
+        if field.value == 0 {
+            filter = append(filter, bson.E{Key: field.key, Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}})
+        } else {
+            filter = append(filter, bson.E{Key: field.key, Value: int64(field.value)})
+        }
     }
 
-    fmt.Println("Rewards transaction succeeded")
+    updateResult, err := accountsColl.UpdateOne(
+        context.TODO(),
+        filter,
+        bson.D{{Key: "$set", Value: bson.D{
+            {Key: "balance", Value: int64(computed.Balance)},
+            {Key: "totalRewards", Value: int64(computed.TotalRewards)},
+            {Key: "sent", Value: int64(computed.Sent)},
+            {Key: "fees", Value: int64(computed.Fees)},
+            {Key: "received", Value: int64(computed.Received)},
+        }}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return updateResult.MatchedCount == 1, nil
+}
+
+func (m *MongoWriteDB) SaveApiKey(key *types.ApiKeyDoc) error {
+    apiKeysColl := m.client.Database(m.database).Collection(apiKeysCollection)
+    _, err := apiKeysColl.InsertOne(context.TODO(), key)
+    return err
+}
+
+func (m *MongoWriteDB) RevokeApiKey(id string, revokedAt int64) (bool, error) {
+    apiKeysColl := m.client.Database(m.database).Collection(apiKeysCollection)
+    updateResult, err := apiKeysColl.UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: id}, {Key: "revokedAt", Value: 0}},
+        bson.D{{Key: "$set", Value: bson.D{{Key: "revokedAt", Value: revokedAt}}}},
+    )
+    if err != nil {
+        return false, err
+    }
+    return updateResult.MatchedCount == 1, nil
+}
 
+func (m *MongoWriteDB) SaveWebhook(webhook *types.WebhookDoc) error {
+    webhooksColl := m.client.Database(m.database).Collection(webhooksCollection)
+    _, err := webhooksColl.InsertOne(context.TODO(), webhook)
     return err
+}
+
+func (m *MongoWriteDB) DeleteWebhook(id string, owner string) (bool, error) {
+    webhooksColl := m.client.Database(m.database).Collection(webhooksCollection)
+    webhookDeliveriesColl := m.client.Database(m.database).Collection(webhookDeliveriesCollection)
+    deleteResult, err := webhooksColl.DeleteOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: id}, {Key: "owner", Value: owner}},
+    )
+    if err != nil {
+        return false, err
+    }
+    if deleteResult.DeletedCount == 0 {
+        return false, nil
+    }
+    _, err = webhookDeliveriesColl.DeleteMany(context.TODO(), bson.D{{Key: "webhookId", Value: id}})
+    return true, err
+}
+
+func (m *MongoWriteDB) SaveWebhookDelivery(delivery *types.WebhookDeliveryDoc) (bool, error) {
+    webhookDeliveriesColl := m.client.Database(m.database).Collection(webhookDeliveriesCollection)
+    _, err := webhookDeliveriesColl.InsertOne(context.TODO(), delivery)
+    if mongo.IsDuplicateKeyError(err) {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+func (m *MongoWriteDB) UpdateWebhookDelivery(delivery *types.WebhookDeliveryDoc) error {
+    webhookDeliveriesColl := m.client.Database(m.database).Collection(webhookDeliveriesCollection)
+    _, err := webhookDeliveriesColl.UpdateOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: delivery.ID}},
+        bson.D{{Key: "$set", Value: bson.D{
+            {Key: "status", Value: delivery.Status},
+            {Key: "attempts", Value: delivery.Attempts},
+            {Key: "nextAttempt", Value: delivery.NextAttempt},
+            {Key: "statusCode", Value: delivery.StatusCode},
+            {Key: "lastError", Value: delivery.LastError},
+            {Key: "deliveredAt", Value: delivery.DeliveredAt},
+        }}},
+    )
+    return err
+}
 
+func (m *MongoWriteDB) DeleteWebhookDeliveries(createdAt int64) error {
+    webhookDeliveriesColl := m.client.Database(m.database).Collection(webhookDeliveriesCollection)
+    _, err := webhookDeliveriesColl.DeleteMany(
+        context.TODO(),
+        bson.D{
+            {Key: "createdAt", Value: bson.D{{Key: "$lt", Value: createdAt}}},
+            {Key: "status", Value: bson.D{{Key: "$ne", Value: "pending"}}},
+        },
+    )
+    return err
+}
+
+func (m *MongoWriteDB) DropTransactions(publishedBefore int64, layerBefore uint32) (int64, error) {
+    transactionsColl := m.client.Database(m.database).Collection(transactionsCollection)
+    result, err := transactionsColl.UpdateMany(
+        context.TODO(),
+        bson.D{
+            {Key: "complete", Value: false},
+            {Key: "dropped", Value: bson.D{{Key: "$ne", Value: true}}},
+            {Key: "$or", Value: bson.A{
+                bson.D{{Key: "published", Value: bson.D{{Key: "$gt", Value: 0}, {Key: "$lt", Value: publishedBefore}}}},
+                bson.D{
+                    {Key: "published", Value: bson.D{{Key: "$in", Value: bson.A{0, nil}}}},
+                    {Key: "layer", Value: bson.D{{Key: "$lt", Value: layerBefore}}},
+                },
+            }},
+        },
+        bson.D{{Key: "$set", Value: bson.D{{Key: "dropped", Value: true}}}},
+    )
+    if err != nil {
+        return 0, err
+    }
+    return result.ModifiedCount, nil
+}
+
+func (m *MongoWriteDB) SaveDeadLetter(letter *types.DeadLetterDoc) error {
+    deadLettersColl := m.client.Database(m.database).Collection(deadLettersCollection)
+    _, err := deadLettersColl.ReplaceOne(
+        context.TODO(),
+        bson.D{{Key: "_id", Value: letter.ID}},
+        letter,
+        options.Replace().SetUpsert(true),
+    )
+    return err
+}
+
+func (m *MongoWriteDB) SavePrices(prices []*types.PriceDoc) error {
+    if len(prices) == 0 {
+        return nil
+    }
+    pricesColl := m.client.Database(m.database).Collection(pricesCollection)
+    models := make([]mongo.WriteModel, len(prices))
+    for i, price := range prices {
+        models[i] = mongo.NewReplaceOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: price.Timestamp}}).
+            SetReplacement(price).
+            SetUpsert(true)
+    }
+    _, err := pricesColl.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+    return err
+}
+
+func (m *MongoWriteDB) SaveStats(stats []*types.StatsDoc) error {
+    if len(stats) == 0 {
+        return nil
+    }
+    statsColl := m.client.Database(m.database).Collection(statsCollection)
+    models := make([]mongo.WriteModel, len(stats))
+    for i, doc := range stats {
+        models[i] = mongo.NewReplaceOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: doc.ID}}).
+            SetReplacement(doc).
+            SetUpsert(true)
+    }
+    _, err := statsColl.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+    return err
+}
+
+func (m *MongoWriteDB) SaveNodePerformance(performance []*types.NodePerformanceDoc) error {
+    if len(performance) == 0 {
+        return nil
+    }
+    nodePerformanceColl := m.client.Database(m.database).Collection(nodePerformanceCollection)
+    models := make([]mongo.WriteModel, len(performance))
+    for i, doc := range performance {
+        models[i] = mongo.NewReplaceOneModel().
+            SetFilter(bson.D{{Key: "_id", Value: doc.ID}}).
+            SetReplacement(doc).
+            SetUpsert(true)
+    }
+    _, err := nodePerformanceColl.BulkWrite(context.TODO(), models, options.BulkWrite().SetOrdered(false))
+    return err
+}
+
+func (m *MongoWriteDB) DeleteDeadLetter(id string) (bool, error) {
+    deadLettersColl := m.client.Database(m.database).Collection(deadLettersCollection)
+    deleteResult, err := deadLettersColl.DeleteOne(context.TODO(), bson.D{{Key: "_id", Value: id}})
+    if err != nil {
+        return false, err
+    }
+    return deleteResult.DeletedCount == 1, nil
 }
 
-func (m *WriteDB) CloseWrite() {
+func (m *MongoWriteDB) CloseWrite() {
     m.client.Disconnect(context.TODO())
 }
 
@@ -0,0 +1,310 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+	sTypes "github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// page size of the rewards and transactions read from the db
+const pageSize = 500
+
+// the amounts of the db are in smidge
+const smidgePerSmh = 1_000_000_000
+
+const (
+	TypeReward      = "reward"
+	TypeTransaction = "transaction"
+
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+// Prices resolves the historical usd price of smh.
+type Prices interface {
+	// PricesAt returns the usd price at each of timestamps, unix seconds, -1 when it is unknown.
+	PricesAt(timestamps []int64) []float64
+}
+
+// Row is a change of the balance of the exported account. Amount and Fee are in smidge, Fee is only paid on
+// the out and self rows.
+type Row struct {
+	Time         int64    `json:"time"`
+	Layer        int64    `json:"layer"`
+	Type         string   `json:"type"`
+	ID           string   `json:"id"`
+	Direction    string   `json:"direction"`
+	Counterparty string   `json:"counterparty,omitempty"`
+	Amount       uint64   `json:"amount"`
+	Fee          uint64   `json:"fee"`
+	Method       string   `json:"method,omitempty"`
+	PriceUSD     *float64 `json:"priceUsd"`
+	ValueUSD     *float64 `json:"valueUsd"`
+}
+
+// Exporter writes the rewards and transfers of an account, the rows the balances of the api are made of.
+type Exporter struct {
+	readDB  database.ReadDB
+	network *config.NetworkConfig
+	prices  Prices
+}
+
+// NewExporter exports from the db of network, prices can be nil to leave the usd columns empty.
+func NewExporter(readDB database.ReadDB, network *config.NetworkConfig, prices Prices) *Exporter {
+	return &Exporter{
+		readDB:  readDB,
+		network: network,
+		prices:  prices,
+	}
+}
+
+// Layers returns the layers from and to, inclusive, fall in. A zero time doesn't bound the range and is -1.
+func (e *Exporter) Layers(from time.Time, to time.Time) (int, int) {
+	firstLayer, lastLayer := -1, -1
+	if !from.IsZero() {
+		firstLayer = int(e.network.LayerAt(from.Unix()))
+		if firstLayer < 0 {
+			firstLayer = 0
+		} else if e.network.LayerTime(int64(firstLayer)) < from.Unix() {
+			firstLayer++
+		}
+	}
+	if !to.IsZero() {
+		lastLayer = int(e.network.LayerAt(to.Unix()))
+		if lastLayer < 0 {
+			// nothing happened before genesis, the range is empty
+			return 1, 0
+		}
+	}
+	return firstLayer, lastLayer
+}
+
+// Export writes the rows of account between firstLayer and lastLayer, -1 for no bound, oldest first. The
+// rewards of a layer come before its transactions.
+func (e *Exporter) Export(w io.Writer, format Format, account string, firstLayer int, lastLayer int) error {
+	buffered := bufio.NewWriter(w)
+	out := format.writer(buffered)
+	if err := out.header(); err != nil {
+		return err
+	}
+
+	rewards := &rewardFeed{readDB: e.readDB, account: account, firstLayer: firstLayer, lastLayer: lastLayer}
+	transactions := &transactionFeed{readDB: e.readDB, account: account, firstLayer: firstLayer, lastLayer: lastLayer}
+	// the rows are priced a batch at a time
+	batch := make([]*Row, 0, pageSize)
+	write := func() error {
+		e.price(batch)
+		for _, row := range batch {
+			if err := out.row(row); err != nil {
+				return err
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+	for {
+		reward, err := rewards.peek()
+		if err != nil {
+			return err
+		}
+		transaction, err := transactions.peek()
+		if err != nil {
+			return err
+		}
+
+		var row *Row
+		if reward != nil && (transaction == nil || reward.Layer <= int64(transaction.Layer)) {
+			row = e.rewardRow(reward)
+			rewards.next()
+		} else if transaction != nil {
+			row = e.transactionRow(account, transaction)
+			transactions.next()
+		} else {
+			break
+		}
+		if row == nil {
+			continue
+		}
+		batch = append(batch, row)
+		if len(batch) == pageSize {
+			if err := write(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := write(); err != nil {
+		return err
+	}
+	if err := out.flush(); err != nil {
+		return err
+	}
+	return buffered.Flush()
+}
+
+func (e *Exporter) rewardRow(reward *sTypes.RewardsDoc) *Row {
+	return &Row{
+		Time:         e.network.LayerTime(reward.Layer),
+		Layer:        reward.Layer,
+		Type:         TypeReward,
+		ID:           reward.Id,
+		Direction:    DirectionIn,
+		Counterparty: reward.NodeId,
+		Amount:       uint64(reward.TotalReward),
+	}
+}
+
+// transactionRow returns the row of a transaction for account, nil when it doesn't change its balance, like
+// a drain of a vault for the principal that only signs it.
+func (e *Exporter) transactionRow(account string, transaction *sTypes.TransactionDoc) *Row {
+	sender := transaction.PrincipaAccount
+	if transaction.Type == transactionparsertypes.TypeDrainVault {
+		sender = transaction.VaultAccount
+	}
+	row := &Row{
+		Time:   e.network.LayerTime(int64(transaction.Layer)),
+		Layer:  int64(transaction.Layer),
+		Type:   TypeTransaction,
+		ID:     transaction.ID,
+		Amount: transaction.Amount,
+		Method: method(transaction.Method),
+	}
+	switch {
+	case sender == account && transaction.ReceiverAccount == account:
+		row.Direction = DirectionSelf
+		row.Fee = transaction.TotalFee()
+	case sender == account:
+		row.Direction = DirectionOut
+		row.Counterparty = transaction.ReceiverAccount
+		row.Fee = transaction.TotalFee()
+	case transaction.ReceiverAccount == account:
+		row.Direction = DirectionIn
+		row.Counterparty = sender
+	default:
+		return nil
+	}
+	return row
+}
+
+func (e *Exporter) price(rows []*Row) {
+	if e.prices == nil || len(rows) == 0 {
+		return
+	}
+	timestamps := make([]int64, len(rows))
+	for i, row := range rows {
+		timestamps[i] = row.Time
+	}
+	for i, price := range e.prices.PricesAt(timestamps) {
+		if price < 0 {
+			continue
+		}
+		value := price * float64(rows[i].Amount) / smidgePerSmh
+		rows[i].PriceUSD = &price
+		rows[i].ValueUSD = &value
+	}
+}
+
+func method(method uint8) string {
+	switch method {
+	case 0:
+		return "Spawn"
+	case 16:
+		return "Spend"
+	case 17:
+		return "DrainVault"
+	}
+	return ""
+}
+
+// ParseTime parses a date or an RFC3339 time of a range. A date is the start of its day, or its end when end
+// is set so that the whole day is in the range.
+func ParseTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if strings.Contains(value, "T") {
+		return time.Parse(time.RFC3339, value)
+	}
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not a date or an RFC3339 time", value)
+	}
+	if end {
+		return day.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return day, nil
+}
+
+// rewardFeed and transactionFeed read the rows of the account a page at a time.
+type rewardFeed struct {
+	readDB     database.ReadDB
+	account    string
+	firstLayer int
+	lastLayer  int
+	page       []*sTypes.RewardsDoc
+	after      *sTypes.Cursor
+	done       bool
+}
+
+func (f *rewardFeed) peek() (*sTypes.RewardsDoc, error) {
+	if len(f.page) == 0 && !f.done {
+		page, err := f.readDB.GetRewards(f.account, 0, pageSize, 1, f.firstLayer, f.lastLayer, f.after)
+		if err != nil {
+			return nil, err
+		}
+		f.page = page
+		f.done = len(page) < pageSize
+		if len(page) > 0 {
+			last := page[len(page)-1]
+			f.after = &sTypes.Cursor{Key: last.Layer, ID: last.Id}
+		}
+	}
+	if len(f.page) == 0 {
+		return nil, nil
+	}
+	return f.page[0], nil
+}
+
+func (f *rewardFeed) next() {
+	f.page = f.page[1:]
+}
+
+type transactionFeed struct {
+	readDB     database.ReadDB
+	account    string
+	firstLayer int
+	lastLayer  int
+	page       []*sTypes.TransactionDoc
+	after      *sTypes.Cursor
+	done       bool
+}
+
+func (f *transactionFeed) peek() (*sTypes.TransactionDoc, error) {
+	if len(f.page) == 0 && !f.done {
+		page, err := f.readDB.GetAppliedTransactions(f.account, f.firstLayer, f.lastLayer, pageSize, f.after)
+		if err != nil {
+			return nil, err
+		}
+		f.page = page
+		f.done = len(page) < pageSize
+		if len(page) > 0 {
+			last := page[len(page)-1]
+			f.after = &sTypes.Cursor{Key: int64(last.Layer), ID: last.ID}
+		}
+	}
+	if len(f.page) == 0 {
+		return nil, nil
+	}
+	return f.page[0], nil
+}
+
+func (f *transactionFeed) next() {
+	f.page = f.page[1:]
+}
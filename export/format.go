@@ -0,0 +1,211 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the currency of the amounts in the tax formats
+const currency = "SMH"
+
+// Format is a file format of the export.
+type Format struct {
+	Name        string
+	ContentType string
+	Extension   string
+	writer      func(w io.Writer) rowWriter
+}
+
+var (
+	// CSV has a column per field of Row, the amounts in smh and the time as RFC3339.
+	CSV = Format{Name: "csv", ContentType: "text/csv", Extension: "csv", writer: newCSVWriter}
+	// NDJSON has a Row per line, the amounts in smidge like the rest of the api.
+	NDJSON = Format{Name: "ndjson", ContentType: "application/x-ndjson", Extension: "ndjson", writer: newNDJSONWriter}
+	// Koinly is the universal csv of Koinly.
+	Koinly = Format{Name: "koinly", ContentType: "text/csv", Extension: "csv", writer: newKoinlyWriter}
+	// CoinTracking is the csv import of CoinTracking.
+	CoinTracking = Format{Name: "cointracking", ContentType: "text/csv", Extension: "csv", writer: newCoinTrackingWriter}
+)
+
+var formats = []Format{CSV, NDJSON, Koinly, CoinTracking}
+
+// ParseFormat returns the format with name.
+func ParseFormat(name string) (Format, error) {
+	names := make([]string, len(formats))
+	for i, format := range formats {
+		if format.Name == strings.ToLower(name) {
+			return format, nil
+		}
+		names[i] = format.Name
+	}
+	return Format{}, fmt.Errorf("format must be one of %s", strings.Join(names, ", "))
+}
+
+// FormatNames returns the names of the formats.
+func FormatNames() []string {
+	names := make([]string, len(formats))
+	for i, format := range formats {
+		names[i] = format.Name
+	}
+	return names
+}
+
+type rowWriter interface {
+	header() error
+	row(row *Row) error
+	flush() error
+}
+
+// csvWriter writes the columns then the record of each row.
+type csvWriter struct {
+	w       *csv.Writer
+	columns []string
+	record  func(row *Row) []string
+}
+
+func (c *csvWriter) header() error {
+	return c.w.Write(c.columns)
+}
+
+func (c *csvWriter) row(row *Row) error {
+	return c.w.Write(c.record(row))
+}
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func newCSVWriter(w io.Writer) rowWriter {
+	return &csvWriter{
+		w:       csv.NewWriter(w),
+		columns: []string{"time", "layer", "type", "id", "direction", "counterparty", "amount", "fee", "method", "price_usd", "value_usd"},
+		record: func(row *Row) []string {
+			return []string{
+				time.Unix(row.Time, 0).UTC().Format(time.RFC3339),
+				strconv.FormatInt(row.Layer, 10),
+				row.Type,
+				row.ID,
+				row.Direction,
+				row.Counterparty,
+				smh(row.Amount),
+				smh(row.Fee),
+				row.Method,
+				usd(row.PriceUSD),
+				usd(row.ValueUSD),
+			}
+		},
+	}
+}
+
+func newKoinlyWriter(w io.Writer) rowWriter {
+	return &csvWriter{
+		w: csv.NewWriter(w),
+		columns: []string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency", "Fee Amount",
+			"Fee Currency", "Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"},
+		record: func(row *Row) []string {
+			record := make([]string, 12)
+			record[0] = time.Unix(row.Time, 0).UTC().Format("2006-01-02 15:04 UTC")
+			record[11] = row.ID
+			switch {
+			case row.Type == TypeReward:
+				record[3], record[4] = smh(row.Amount), currency
+				record[9] = "mining"
+				record[10] = "Reward of node " + row.Counterparty
+			case row.Direction == DirectionIn:
+				record[3], record[4] = smh(row.Amount), currency
+				record[10] = "From " + row.Counterparty
+			case row.Direction == DirectionOut && row.Amount > 0:
+				record[1], record[2] = smh(row.Amount), currency
+				record[5], record[6] = smh(row.Fee), currency
+				record[10] = "To " + row.Counterparty
+			default:
+				// the balance only changes by the fee
+				record[1], record[2] = smh(row.Fee), currency
+				record[9] = "cost"
+				record[10] = "Fee of " + row.Method
+			}
+			if row.ValueUSD != nil {
+				record[7], record[8] = usd(row.ValueUSD), "USD"
+			}
+			return record
+		},
+	}
+}
+
+func newCoinTrackingWriter(w io.Writer) rowWriter {
+	return &csvWriter{
+		w: csv.NewWriter(w),
+		columns: []string{"Type", "Buy Amount", "Buy Currency", "Sell Amount", "Sell Currency", "Fee", "Fee Currency",
+			"Exchange", "Trade-Group", "Comment", "Date", "Tx-ID"},
+		record: func(row *Row) []string {
+			record := make([]string, 12)
+			record[7] = "Spacemesh"
+			record[10] = time.Unix(row.Time, 0).UTC().Format(time.DateTime)
+			record[11] = row.ID
+			switch {
+			case row.Type == TypeReward:
+				record[0] = "Mining"
+				record[1], record[2] = smh(row.Amount), currency
+				record[9] = "Reward of node " + row.Counterparty
+			case row.Direction == DirectionIn:
+				record[0] = "Deposit"
+				record[1], record[2] = smh(row.Amount), currency
+				record[9] = "From " + row.Counterparty
+			case row.Direction == DirectionOut && row.Amount > 0:
+				record[0] = "Withdrawal"
+				record[3], record[4] = smh(row.Amount), currency
+				record[5], record[6] = smh(row.Fee), currency
+				record[9] = "To " + row.Counterparty
+			default:
+				record[0] = "Other Fee"
+				record[3], record[4] = smh(row.Fee), currency
+				record[9] = "Fee of " + row.Method
+			}
+			return record
+		},
+	}
+}
+
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) rowWriter {
+	return &ndjsonWriter{encoder: json.NewEncoder(w)}
+}
+
+func (n *ndjsonWriter) header() error {
+	return nil
+}
+
+func (n *ndjsonWriter) row(row *Row) error {
+	return n.encoder.Encode(row)
+}
+
+func (n *ndjsonWriter) flush() error {
+	return nil
+}
+
+// smh formats an amount of smidge in smh, without trailing zeros.
+func smh(smidge uint64) string {
+	whole := strconv.FormatUint(smidge/smidgePerSmh, 10)
+	fraction := strings.TrimRight(fmt.Sprintf("%09d", smidge%smidgePerSmh), "0")
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
+
+// usd formats a usd amount, empty when it is unknown.
+func usd(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
@@ -0,0 +1,88 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// Executor runs the queries of /graphql over the read db.
+type Executor struct {
+	schema        graphql.Schema
+	db            database.ReadDB
+	maxDepth      int
+	maxComplexity int
+}
+
+func NewExecutor(
+	graphQLConfig *config.GraphQLConfig,
+	readDB database.ReadDB,
+	networkUtils *network.NetworkUtils,
+	state *network.NetworkState,
+	priceResolver *price.PriceResolver,
+) (*Executor, error) {
+	r := &resolver{
+		db:            readDB,
+		networkUtils:  networkUtils,
+		state:         state,
+		priceResolver: priceResolver,
+	}
+	schema, err := r.schema()
+	if err != nil {
+		return nil, err
+	}
+	maxDepth := 8
+	if graphQLConfig.MaxDepth > 0 {
+		maxDepth = graphQLConfig.MaxDepth
+	}
+	maxComplexity := 5000
+	if graphQLConfig.MaxComplexity > 0 {
+		maxComplexity = graphQLConfig.MaxComplexity
+	}
+	return &Executor{
+		schema:        schema,
+		db:            readDB,
+		maxDepth:      maxDepth,
+		maxComplexity: maxComplexity,
+	}, nil
+}
+
+// Execute parses, validates and runs a query. It returns a result without data when the query doesn't
+// run, because it is invalid or over the depth and complexity limits.
+func (e *Executor) Execute(ctx context.Context, request *types.GraphQLRequest) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{
+			Body: []byte(request.Query),
+			Name: "GraphQL request",
+		}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	validation := graphql.ValidateDocument(&e.schema, doc, nil)
+	if !validation.IsValid {
+		return &graphql.Result{Errors: validation.Errors}
+	}
+
+	err = checkLimits(&e.schema, doc, request.OperationName, request.Variables, e.maxDepth, e.maxComplexity)
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           doc,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       context.WithValue(ctx, loadersKey{}, newLoaders(e.db)),
+	})
+}
@@ -0,0 +1,156 @@
+package gql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// limits bounds the work of a query before it runs. The depth is the deepest nesting of fields, the
+// complexity is the number of fields that can be resolved: a field costs 1 for each item of the lists it
+// is in, taking the limit argument of a list as its size. Introspection is not counted.
+type limits struct {
+	maxComplexity int
+	fragments     map[string]*ast.FragmentDefinition
+	variables     map[string]interface{}
+}
+
+func checkLimits(schema *graphql.Schema, doc *ast.Document, operationName string, variables map[string]interface{},
+	maxDepth int, maxComplexity int) error {
+	l := &limits{
+		maxComplexity: maxComplexity,
+		fragments:     make(map[string]*ast.FragmentDefinition),
+		variables:     variables,
+	}
+	for _, definition := range doc.Definitions {
+		if fragment, ok := definition.(*ast.FragmentDefinition); ok {
+			l.fragments[fragment.Name.Value] = fragment
+		}
+	}
+	for _, definition := range doc.Definitions {
+		operation, ok := definition.(*ast.OperationDefinition)
+		if !ok || operation.Operation != ast.OperationTypeQuery {
+			continue
+		}
+		if operationName != "" && (operation.Name == nil || operation.Name.Value != operationName) {
+			continue
+		}
+		depth, complexity := l.selectionSet(operation.SelectionSet, schema.QueryType(), 1, 1)
+		if depth > maxDepth {
+			return fmt.Errorf("query depth %d exceeds the maximum of %d", depth, maxDepth)
+		}
+		if complexity > maxComplexity {
+			// complexity saturates above the max, it is not the exact cost
+			return fmt.Errorf("query complexity exceeds the maximum of %d, lower the limit of the nested lists", maxComplexity)
+		}
+	}
+	return nil
+}
+
+// selectionSet returns the depth and complexity of the fields of parent selected at depth, each of them
+// resolved multiplier times.
+func (l *limits) selectionSet(selectionSet *ast.SelectionSet, parent *graphql.Object, depth int, multiplier int) (int, int) {
+	if selectionSet == nil || parent == nil {
+		return depth - 1, 0
+	}
+	maxDepth, complexity := depth-1, 0
+	for _, selection := range selectionSet.Selections {
+		var selectionDepth, selectionComplexity int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			selectionDepth, selectionComplexity = l.field(selection, parent, depth, multiplier)
+		case *ast.InlineFragment:
+			selectionDepth, selectionComplexity = l.selectionSet(selection.SelectionSet, parent, depth, multiplier)
+		case *ast.FragmentSpread:
+			// fragment cycles are rejected by the validation before the limits are checked
+			if fragment, ok := l.fragments[selection.Name.Value]; ok {
+				selectionDepth, selectionComplexity = l.selectionSet(fragment.SelectionSet, parent, depth, multiplier)
+			}
+		}
+		maxDepth = max(maxDepth, selectionDepth)
+		complexity = l.add(complexity, selectionComplexity)
+	}
+	return maxDepth, complexity
+}
+
+func (l *limits) field(field *ast.Field, parent *graphql.Object, depth int, multiplier int) (int, int) {
+	name := field.Name.Value
+	if strings.HasPrefix(name, "__") {
+		return depth, 0
+	}
+	definition, ok := parent.Fields()[name]
+	if !ok {
+		return depth, 0
+	}
+
+	childMultiplier := multiplier
+	fieldType := unwrapNonNull(definition.Type)
+	if list, ok := fieldType.(*graphql.List); ok {
+		childMultiplier = l.multiply(multiplier, l.limit(field, definition))
+		fieldType = unwrapNonNull(list.OfType)
+	}
+	object, ok := fieldType.(*graphql.Object)
+	if !ok || field.SelectionSet == nil {
+		return depth, multiplier
+	}
+	childDepth, childComplexity := l.selectionSet(field.SelectionSet, object, depth+1, childMultiplier)
+	return childDepth, l.add(multiplier, childComplexity)
+}
+
+// limit is the size of a list field, its limit argument or 1 for the lists without one.
+func (l *limits) limit(field *ast.Field, definition *graphql.FieldDefinition) int {
+	size := -1
+	for _, arg := range definition.Args {
+		if arg.Name() == "limit" {
+			size = defaultLimit
+			if value, ok := arg.DefaultValue.(int); ok {
+				size = value
+			}
+		}
+	}
+	if size < 0 {
+		return 1
+	}
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			if parsed, err := strconv.Atoi(value.Value); err == nil {
+				size = parsed
+			}
+		case *ast.Variable:
+			switch variable := l.variables[value.Name.Value].(type) {
+			case float64:
+				size = int(variable)
+			case int:
+				size = variable
+			}
+		}
+	}
+	// the resolvers reject the limits out of range
+	return min(max(size, 1), maxLimit)
+}
+
+// add and multiply saturate above the max complexity so deep queries can't overflow.
+func (l *limits) add(a int, b int) int {
+	return min(a+b, l.maxComplexity+1)
+}
+
+func (l *limits) multiply(a int, b int) int {
+	if a > (l.maxComplexity+1)/b {
+		return l.maxComplexity + 1
+	}
+	return a * b
+}
+
+func unwrapNonNull(fieldType graphql.Type) graphql.Type {
+	if nonNull, ok := fieldType.(*graphql.NonNull); ok {
+		return nonNull.OfType
+	}
+	return fieldType
+}
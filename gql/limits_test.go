@@ -0,0 +1,116 @@
+package gql
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// nestedTransactions selects the transactions of the principal of the transactions of an account, levels times.
+func nestedTransactions(levels int, limit string) string {
+	query := `{ account(address: "sm1") {`
+	for i := 0; i < levels; i++ {
+		query += ` transactions(limit: ` + limit + `) { principal {`
+	}
+	query += ` address`
+	return query + strings.Repeat(" } }", levels) + " } }"
+}
+
+func parse(t *testing.T, query string) *ast.Document {
+	t.Helper()
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestQueryLimits(t *testing.T) {
+	// over the limits the query doesn't run, the executor has no db
+	executor, err := NewExecutor(&config.GraphQLConfig{}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		err       string
+	}{
+		// account, then transactions and principal 3 times, then address
+		{name: "depth 8", query: nestedTransactions(3, "1")},
+		{
+			name:  "depth 9",
+			query: strings.Replace(nestedTransactions(3, "1"), "principal { address", "principal { transactions { id }", 1),
+			err:   "query depth 9 exceeds the maximum of 8",
+		},
+		{
+			name:  "complexity 10101",
+			query: `{ accounts(limit: 100) { transactions(limit: 100) { id } } }`,
+			err:   "query complexity exceeds the maximum of 5000",
+		},
+		{
+			name:      "complexity of a limit variable",
+			query:     `query q($limit: Int) { accounts(limit: $limit) { transactions(limit: $limit) { id } } }`,
+			variables: map[string]interface{}{"limit": float64(100)},
+			err:       "query complexity exceeds the maximum of 5000",
+		},
+		{
+			name:  "default limits",
+			query: `{ accounts { transactions { id } } }`,
+		},
+		{
+			name:  "fragment",
+			query: `{ accounts(limit: 100) { ...txs } } fragment txs on Account { transactions(limit: 100) { id } }`,
+			err:   "query complexity exceeds the maximum of 5000",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ""
+			limits := checkLimits(&executor.schema, parse(t, test.query), "", test.variables, executor.maxDepth,
+				executor.maxComplexity)
+			if limits != nil {
+				err = limits.Error()
+			}
+			if (err == "") != (test.err == "") || !strings.HasPrefix(err, test.err) {
+				t.Errorf("error %q, want %q", err, test.err)
+			}
+		})
+	}
+
+	result := executor.Execute(context.Background(), &types.GraphQLRequest{Query: nestedTransactions(4, "1")})
+	if len(result.Errors) != 1 || result.Data != nil {
+		t.Errorf("query over the depth ran: %v", result.Errors)
+	}
+}
+
+func TestComplexitySaturates(t *testing.T) {
+	executor, err := NewExecutor(&config.GraphQLConfig{MaxDepth: 100}, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100^20 overflows an int64, the cost saturates above the max instead of wrapping to a low one
+	err = checkLimits(&executor.schema, parse(t, nestedTransactions(20, "100")), "", nil, executor.maxDepth,
+		executor.maxComplexity)
+	if err == nil || !strings.HasPrefix(err.Error(), "query complexity exceeds the maximum of 5000") {
+		t.Errorf("error %v, want the complexity one", err)
+	}
+
+	l := &limits{maxComplexity: 5000}
+	if product := l.multiply(math.MaxInt/2, 100); product != 5001 {
+		t.Errorf("product %d, want 5001", product)
+	}
+	if sum := l.add(5001, 5001); sum != 5001 {
+		t.Errorf("sum %d, want 5001", sum)
+	}
+	if product := l.multiply(50, 100); product != 5000 {
+		t.Errorf("product %d, want 5000", product)
+	}
+}
@@ -0,0 +1,96 @@
+package gql
+
+import (
+	"context"
+
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type loadersKey struct{}
+
+// loader batches the lookups of a request by key. Resolvers queue their key and return a thunk, the
+// executor runs the thunks of a level after all the resolvers of that level queued theirs, so the first
+// thunk fetches every queued key with a single query and the others read the cache.
+type loader[V any] struct {
+	fetch  func(keys []string) (map[string]V, error)
+	queued map[string]bool
+	cache  map[string]V
+	keys   []string
+}
+
+func newLoader[V any](fetch func(keys []string) (map[string]V, error)) *loader[V] {
+	return &loader[V]{
+		fetch:  fetch,
+		queued: make(map[string]bool),
+		cache:  make(map[string]V),
+	}
+}
+
+// load returns a thunk resolving to the value of key, or to null when it doesn't exist.
+func (l *loader[V]) load(key string) func() (interface{}, error) {
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.keys = append(l.keys, key)
+	}
+	return func() (interface{}, error) {
+		if len(l.keys) > 0 {
+			keys := l.keys
+			l.keys = nil
+			values, err := l.fetch(keys)
+			if err != nil {
+				// the keys are queued again by the next load
+				for _, k := range keys {
+					delete(l.queued, k)
+				}
+				return nil, err
+			}
+			for k, v := range values {
+				l.cache[k] = v
+			}
+		}
+		value, ok := l.cache[key]
+		if !ok {
+			return nil, nil
+		}
+		return value, nil
+	}
+}
+
+// loaders are created for each request, the executor resolves the fields of a request one at a time so
+// they need no locking.
+type loaders struct {
+	accounts *loader[*types.AccountDoc]
+	nodes    *loader[*types.NodeDoc]
+}
+
+func newLoaders(db database.ReadDB) *loaders {
+	return &loaders{
+		accounts: newLoader(func(keys []string) (map[string]*types.AccountDoc, error) {
+			accounts, err := db.GetAccountsByAddress(keys)
+			if err != nil {
+				return nil, err
+			}
+			values := make(map[string]*types.AccountDoc, len(accounts))
+			for _, account := range accounts {
+				values[account.Address] = account
+			}
+			return values, nil
+		}),
+		nodes: newLoader(func(keys []string) (map[string]*types.NodeDoc, error) {
+			nodes, err := db.GetNodesById(keys)
+			if err != nil {
+				return nil, err
+			}
+			values := make(map[string]*types.NodeDoc, len(nodes))
+			for _, node := range nodes {
+				values[node.ID] = node
+			}
+			return values, nil
+		}),
+	}
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,389 @@
+package gql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type resolver struct {
+	db            database.ReadDB
+	networkUtils  *network.NetworkUtils
+	state         *network.NetworkState
+	priceResolver *price.PriceResolver
+}
+
+// layerSource is a layer with its reverts once they were read.
+type layerSource struct {
+	*types.LayerDoc
+	reverts []*types.LayerRevertDoc
+}
+
+type epochSource struct {
+	*types.Epoch
+	epoch int64
+}
+
+func methodName(method uint8) string {
+	switch method {
+	case 0:
+		return "Spawn"
+	case 16:
+		return "Spend"
+	case 17:
+		return "DrainVault"
+	}
+	return ""
+}
+
+// page reads the offset, limit and sort arguments of a list field.
+func page(p graphql.ResolveParams) (int64, int64, int8, error) {
+	offset, _ := p.Args["offset"].(int)
+	limit, _ := p.Args["limit"].(int)
+	sort, ok := p.Args["sort"].(int)
+	if !ok {
+		sort = 1
+	}
+	if offset < 0 {
+		return 0, 0, 0, errors.New("offset must be greater or equal to 0")
+	}
+	if limit < 1 || limit > maxLimit {
+		return 0, 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+	}
+	return int64(offset), int64(limit), int8(sort), nil
+}
+
+func (r *resolver) usdValue(balance uint64) int64 {
+	priceValue := r.priceResolver.GetPrice()
+	if priceValue > -1 {
+		return int64(priceValue * float64(balance))
+	}
+	return -1
+}
+
+func (r *resolver) loadAccount(p graphql.ResolveParams, address string) (interface{}, error) {
+	if address == "" {
+		return nil, nil
+	}
+	return loadersFrom(p.Context).accounts.load(address), nil
+}
+
+func (r *resolver) loadNode(p graphql.ResolveParams, nodeId string) (interface{}, error) {
+	if nodeId == "" {
+		return nil, nil
+	}
+	return loadersFrom(p.Context).nodes.load(nodeId), nil
+}
+
+func (r *resolver) accounts(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	return r.db.GetAccounts(offset, limit, sort, nil)
+}
+
+func (r *resolver) accountRewards(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	account := p.Source.(*types.AccountDoc)
+	return r.db.GetRewards(account.Address, offset, limit, sort, p.Args["firstLayer"].(int), p.Args["lastLayer"].(int), nil)
+}
+
+func (r *resolver) accountTransactions(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	account := p.Source.(*types.AccountDoc)
+	return r.db.GetTransactions(account.Address, offset, limit, sort, p.Args["complete"].(bool))
+}
+
+func (r *resolver) accountAtxs(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	account := p.Source.(*types.AccountDoc)
+	epoch := p.Args["epoch"].(int)
+	return r.db.GetAccountAtxEpoch(account.Address, uint64(epoch-1), offset, limit, sort)
+}
+
+// accountRewardsDetails is the same as /account/:accountAddress/rewards/details/:epoch, with null instead
+// of the not found responses.
+func (r *resolver) accountRewardsDetails(p graphql.ResolveParams) (interface{}, error) {
+	account := p.Source.(*types.AccountDoc)
+	epoch, ok := p.Args["epoch"].(int)
+	if !ok {
+		epoch = int(r.state.GetInfo().Epoch)
+	} else if epoch < 2 {
+		return nil, errors.New("epoch should be equal or greater than 2")
+	}
+
+	epochAtx, err := r.db.GetAtxEpoch(uint64(epoch - 1))
+	if err != nil {
+		return nil, err
+	}
+	if epochAtx.TotalWeight == 0 {
+		return nil, nil
+	}
+
+	firstLayer, lastLayer := r.networkUtils.GetEpochLayers(uint64(epoch))
+
+	countEpochResult, err := r.db.CountRewards(account.Address, int(firstLayer), int(lastLayer))
+	if err != nil {
+		return nil, err
+	}
+	sumEpochResult, err := r.db.SumRewardsLayers(account.Address, firstLayer, lastLayer)
+	if err != nil {
+		return nil, err
+	}
+	accountAtxs, err := r.db.GetAccountAtxList(account.Address, uint64(epoch-1))
+	if err != nil {
+		return nil, err
+	}
+
+	eligibilityCount := int32(0)
+	totalWeight := uint64(0)
+	totalEffectiveNumUnits := uint32(0)
+	for _, atx := range accountAtxs {
+		eligibilityCountTemp, err := r.networkUtils.GetNumberOfSlots(atx.Weight, epochAtx.TotalWeight, uint32(epoch))
+		if err != nil {
+			return nil, err
+		}
+		eligibilityCount += eligibilityCountTemp
+		totalWeight += atx.Weight
+		totalEffectiveNumUnits += atx.EffectiveNumUnits
+	}
+	if totalWeight == 0 {
+		return nil, nil
+	}
+
+	unitReward := r.state.GetEpochSubsidy(uint32(epoch)) / epochAtx.TotalWeight
+	return &types.RewardDetailsEpoch{
+		Epoch:        int64(epoch),
+		RewardsSum:   sumEpochResult,
+		RewardsCount: countEpochResult,
+		Eligibility: &types.Eligibility{
+			Count:             eligibilityCount,
+			EffectiveNumUnits: int64(totalEffectiveNumUnits),
+			PredictedRewards:  unitReward * totalWeight,
+		},
+	}, nil
+}
+
+func (r *resolver) nodes(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, _, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	return r.db.GetNodes(offset, limit, nil)
+}
+
+func (r *resolver) nodeRewards(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	return r.db.GetNodeRewards(p.Source.(*types.NodeDoc).ID, offset, limit, sort)
+}
+
+func (r *resolver) nodeRewardsDetails(p graphql.ResolveParams) (interface{}, error) {
+	nodeId := p.Source.(*types.NodeDoc).ID
+	networkInfo := r.state.GetInfo()
+	epoch := networkInfo.Epoch
+
+	firstLayer, lastLayer := r.networkUtils.GetEpochLayers(uint64(epoch))
+
+	countEpochResult, err := r.db.CountNodeRewardsLayers(nodeId, firstLayer, lastLayer)
+	if err != nil {
+		return nil, err
+	}
+	sumEpochResult, err := r.db.SumNodeRewardsLayers(nodeId, firstLayer, lastLayer)
+	if err != nil {
+		return nil, err
+	}
+	total, err := r.db.SumNodeRewardsLayers(nodeId, 0, uint32(networkInfo.Layer))
+	if err != nil {
+		return nil, err
+	}
+	return &types.RewardDetails{
+		TotalSum:                 total,
+		CurrentEpoch:             int64(epoch),
+		CurrentEpochRewardsSum:   sumEpochResult,
+		CurrentEpochRewardsCount: countEpochResult,
+	}, nil
+}
+
+func (r *resolver) nodeEligibility(p graphql.ResolveParams) (interface{}, error) {
+	nodeId := p.Source.(*types.NodeDoc).ID
+	networkInfo := r.state.GetInfo()
+	if networkInfo.TotalWeight == 0 {
+		// the network state is not loaded yet
+		return nil, nil
+	}
+	epoch := networkInfo.Epoch
+
+	nodeAtx, err := r.db.GetAtxWeightNode(nodeId, uint64(epoch-1))
+	if err != nil {
+		return nil, err
+	}
+	if nodeAtx.TotalWeight == 0 {
+		return &types.Eligibility{
+			Count:             -1,
+			EffectiveNumUnits: nodeAtx.TotalEffectiveNumUnits,
+		}, nil
+	}
+
+	eligibilityCount, err := r.networkUtils.GetNumberOfSlots(uint64(nodeAtx.TotalWeight), networkInfo.TotalWeight, epoch)
+	if err != nil {
+		return nil, err
+	}
+	unitReward := networkInfo.EpochSubsidy / networkInfo.TotalWeight
+	return &types.Eligibility{
+		Count:             eligibilityCount,
+		EffectiveNumUnits: nodeAtx.TotalEffectiveNumUnits,
+		PredictedRewards:  unitReward * uint64(nodeAtx.TotalWeight),
+	}, nil
+}
+
+func (r *resolver) epoch(p graphql.ResolveParams) (interface{}, error) {
+	epoch := p.Args["epoch"].(int)
+	if epoch < 1 {
+		return nil, errors.New("epoch must be greater than 0")
+	}
+
+	atxEpoch, err := r.db.CountAtxEpoch(uint64(epoch - 1))
+	if err != nil {
+		return nil, err
+	}
+	atxEpochTotals, err := r.db.GetAtxEpoch(uint64(epoch - 1))
+	if err != nil {
+		return nil, err
+	}
+
+	firstLayer, lastLayer := r.networkUtils.GetEpochLayers(uint64(epoch))
+
+	rewardsTotal, err := r.db.SumRewardsLayers("", firstLayer, lastLayer)
+	if err != nil {
+		return nil, err
+	}
+	return &epochSource{
+		Epoch: &types.Epoch{
+			EffectiveUnitsCommited: atxEpochTotals.TotalEffectiveNumUnits,
+			EpochSubsidy:           r.state.GetEpochSubsidy(uint32(epoch)),
+			TotalWeight:            atxEpochTotals.TotalWeight,
+			TotalRewards:           rewardsTotal,
+			TotalActiveSmeshers:    uint64(atxEpoch),
+		},
+		epoch: int64(epoch),
+	}, nil
+}
+
+func (r *resolver) epochAtxs(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	epoch := p.Source.(*epochSource).epoch
+	return r.db.GetAtxForEpochPaginated(uint64(epoch-1), offset, limit, sort, nil)
+}
+
+func (r *resolver) layer(p graphql.ResolveParams) (interface{}, error) {
+	layer := p.Args["layer"].(int)
+	layerDoc, err := r.db.GetLayer(layer)
+	if err != nil {
+		return nil, err
+	}
+	reverts, err := r.db.GetLayerReverts(layer)
+	if err != nil {
+		return nil, err
+	}
+	if layerDoc.Status == 0 && layerDoc.AppliedBlock == "" && len(reverts) == 0 {
+		return nil, nil
+	}
+	layerDoc.Layer = int64(layer)
+	return &layerSource{LayerDoc: layerDoc, reverts: reverts}, nil
+}
+
+func (r *resolver) layers(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := r.db.GetProcessedsLayers(offset, limit, sort)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]*layerSource, len(layers))
+	for i, layer := range layers {
+		sources[i] = &layerSource{LayerDoc: layer}
+	}
+	return sources, nil
+}
+
+func (r *resolver) layerReverts(p graphql.ResolveParams) (interface{}, error) {
+	layer := p.Source.(*layerSource)
+	if layer.reverts != nil {
+		return layer.reverts, nil
+	}
+	reverts, err := r.db.GetLayerReverts(int(layer.Layer))
+	if err != nil {
+		return nil, err
+	}
+	if reverts == nil {
+		reverts = make([]*types.LayerRevertDoc, 0)
+	}
+	return reverts, nil
+}
+
+func (r *resolver) layerRewards(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	return r.db.GetLayerRewards(int(p.Source.(*layerSource).Layer), offset, limit, sort)
+}
+
+func (r *resolver) layerTransactions(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	layer := p.Source.(*layerSource)
+	return r.db.GetLayerTransactions(int(layer.Layer), offset, limit, sort, p.Args["complete"].(bool))
+}
+
+func (r *resolver) transaction(p graphql.ResolveParams) (interface{}, error) {
+	transaction, err := r.db.GetTransaction(p.Args["id"].(string))
+	if err != nil {
+		return nil, err
+	}
+	if transaction.ID == "" {
+		return nil, nil
+	}
+	return transaction, nil
+}
+
+func (r *resolver) transactions(p graphql.ResolveParams) (interface{}, error) {
+	offset, limit, sort, err := page(p)
+	if err != nil {
+		return nil, err
+	}
+	method, ok := p.Args["method"].(int)
+	if !ok {
+		method = -1
+	}
+	minAmount := -1
+	if value, ok := p.Args["minAmount"].(int64); ok {
+		minAmount = int(value)
+	}
+	return r.db.GetAllTransactions(offset, limit, sort, p.Args["complete"].(bool), method, minAmount, nil)
+}
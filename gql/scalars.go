@@ -0,0 +1,78 @@
+package gql
+
+import (
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// BigInt carries smidge amounts, weights and other 64 bit values. They are serialized as decimal strings
+// because javascript numbers lose precision above 2^53, inputs also accept integers.
+var BigInt = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "BigInt",
+	Description: "A 64 bit integer serialized as a decimal string.",
+	Serialize: func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case uint64:
+			return strconv.FormatUint(v, 10)
+		case int32:
+			return strconv.FormatInt(int64(v), 10)
+		case uint32:
+			return strconv.FormatUint(uint64(v), 10)
+		case int:
+			return strconv.Itoa(v)
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		switch v := value.(type) {
+		case string:
+			if parsed, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return parsed
+			}
+		case int:
+			return int64(v)
+		case int64:
+			return v
+		case float64:
+			// json variables decode numbers as float64
+			if v == float64(int64(v)) {
+				return int64(v)
+			}
+		}
+		return nil
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		switch v := valueAST.(type) {
+		case *ast.StringValue:
+			if parsed, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+				return parsed
+			}
+		case *ast.IntValue:
+			if parsed, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+				return parsed
+			}
+		}
+		return nil
+	},
+})
+
+var sortEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "Sort",
+	Values: graphql.EnumValueConfigMap{
+		"ASC":  &graphql.EnumValueConfig{Value: 1},
+		"DESC": &graphql.EnumValueConfig{Value: -1},
+	},
+})
+
+var methodEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "TransactionMethod",
+	Values: graphql.EnumValueConfigMap{
+		"SPAWN":       &graphql.EnumValueConfig{Value: 0},
+		"SPEND":       &graphql.EnumValueConfig{Value: 16},
+		"DRAIN_VAULT": &graphql.EnumValueConfig{Value: 17},
+	},
+})
@@ -0,0 +1,495 @@
+package gql
+
+import (
+	"github.com/graphql-go/graphql"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	defaultLimit = 20
+	// maxLimit is the largest page of a list field, the complexity of a query grows with it
+	maxLimit = 100
+)
+
+// value is a field read from the source object of type S without a query.
+func value[S any](fieldType graphql.Output, get func(source S) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: fieldType,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return get(p.Source.(S)), nil
+		},
+	}
+}
+
+// pageArgs are the arguments of the list fields, with the default sort of the matching rest route.
+func pageArgs(sort int, extra graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args := graphql.FieldConfigArgument{
+		"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+		"limit": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: defaultLimit,
+			Description:  "Between 1 and 100.",
+		},
+		"sort": &graphql.ArgumentConfig{Type: sortEnum, DefaultValue: sort},
+	}
+	for name, arg := range extra {
+		args[name] = arg
+	}
+	return args
+}
+
+func (r *resolver) schema() (graphql.Schema, error) {
+	var (
+		account     *graphql.Object
+		node        *graphql.Object
+		reward      *graphql.Object
+		transaction *graphql.Object
+		atx         *graphql.Object
+	)
+
+	eligibility := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Eligibility",
+		Fields: graphql.Fields{
+			"count": value(graphql.Int, func(e *types.Eligibility) interface{} { return e.Count }),
+			"effectiveNumUnits": value(BigInt, func(e *types.Eligibility) interface{} {
+				return e.EffectiveNumUnits
+			}),
+			"predictedRewards": value(BigInt, func(e *types.Eligibility) interface{} { return e.PredictedRewards }),
+		},
+	})
+
+	rewardDetails := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RewardDetails",
+		Fields: graphql.Fields{
+			"totalSum":     value(BigInt, func(d *types.RewardDetails) interface{} { return d.TotalSum }),
+			"currentEpoch": value(graphql.Int, func(d *types.RewardDetails) interface{} { return d.CurrentEpoch }),
+			"currentEpochRewardsSum": value(BigInt, func(d *types.RewardDetails) interface{} {
+				return d.CurrentEpochRewardsSum
+			}),
+			"currentEpochRewardsCount": value(graphql.Int, func(d *types.RewardDetails) interface{} {
+				return d.CurrentEpochRewardsCount
+			}),
+		},
+	})
+
+	rewardDetailsEpoch := graphql.NewObject(graphql.ObjectConfig{
+		Name: "RewardDetailsEpoch",
+		Fields: graphql.Fields{
+			"epoch":        value(graphql.Int, func(d *types.RewardDetailsEpoch) interface{} { return d.Epoch }),
+			"rewardsSum":   value(BigInt, func(d *types.RewardDetailsEpoch) interface{} { return d.RewardsSum }),
+			"rewardsCount": value(graphql.Int, func(d *types.RewardDetailsEpoch) interface{} { return d.RewardsCount }),
+			"eligibility":  value(eligibility, func(d *types.RewardDetailsEpoch) interface{} { return d.Eligibility }),
+		},
+	})
+
+	layerRevert := graphql.NewObject(graphql.ObjectConfig{
+		Name: "LayerRevert",
+		Fields: graphql.Fields{
+			"revertedAt":      value(BigInt, func(v *types.LayerRevertDoc) interface{} { return v.RevertedAt }),
+			"trigger":         value(graphql.String, func(v *types.LayerRevertDoc) interface{} { return v.Trigger }),
+			"rewardsReverted": value(graphql.Int, func(v *types.LayerRevertDoc) interface{} { return v.RewardsReverted }),
+			"rewardsAmount":   value(BigInt, func(v *types.LayerRevertDoc) interface{} { return v.RewardsAmount }),
+			"transactionsReverted": value(graphql.Int, func(v *types.LayerRevertDoc) interface{} {
+				return v.TransactionsReverted
+			}),
+			"blocks": value(graphql.NewList(graphql.String), func(v *types.LayerRevertDoc) interface{} { return v.Blocks }),
+		},
+	})
+
+	malfeasance := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Malfeasance",
+		Fields: graphql.Fields{
+			"received": value(BigInt, func(m *types.MalfeasanceNodeDoc) interface{} { return m.Received }),
+		},
+	})
+
+	nodeAtx := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NodeAtx",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"publishEpoch": value(graphql.Int, func(a *types.NodeAtxDoc) interface{} { return a.PublishEpoch }),
+				"coinbase":     value(graphql.String, func(a *types.NodeAtxDoc) interface{} { return a.Coinbase }),
+				"account": {
+					Type: account,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.loadAccount(p, p.Source.(*types.NodeAtxDoc).Coinbase)
+					},
+				},
+				"effectiveNumUnits": value(graphql.Int, func(a *types.NodeAtxDoc) interface{} { return a.EffectiveNumUnits }),
+				"weight":            value(BigInt, func(a *types.NodeAtxDoc) interface{} { return a.Weight }),
+				"sequence":          value(BigInt, func(a *types.NodeAtxDoc) interface{} { return a.Sequence }),
+				"received":          value(BigInt, func(a *types.NodeAtxDoc) interface{} { return a.Received }),
+			}
+		}),
+	})
+
+	atx = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Atx",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"atxId":  value(graphql.String, func(a *types.AtxDoc) interface{} { return a.AtxID }),
+				"nodeId": value(graphql.String, func(a *types.AtxDoc) interface{} { return a.NodeID }),
+				"node": {
+					Type: node,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.loadNode(p, p.Source.(*types.AtxDoc).NodeID)
+					},
+				},
+				"coinbase": value(graphql.String, func(a *types.AtxDoc) interface{} { return a.Coinbase }),
+				"account": {
+					Type: account,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.loadAccount(p, p.Source.(*types.AtxDoc).Coinbase)
+					},
+				},
+				"publishEpoch":      value(graphql.Int, func(a *types.AtxDoc) interface{} { return a.PublishEpoch }),
+				"effectiveNumUnits": value(graphql.Int, func(a *types.AtxDoc) interface{} { return a.EffectiveNumUnits }),
+				"weight":            value(BigInt, func(a *types.AtxDoc) interface{} { return a.Weight }),
+				"tickCount":         value(BigInt, func(a *types.AtxDoc) interface{} { return a.TickCount }),
+				"sequence":          value(BigInt, func(a *types.AtxDoc) interface{} { return a.Sequence }),
+				"received":          value(BigInt, func(a *types.AtxDoc) interface{} { return a.Received }),
+			}
+		}),
+	})
+
+	reward = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Reward",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":       value(graphql.String, func(v *types.RewardsDoc) interface{} { return v.Id }),
+				"coinbase": value(graphql.String, func(v *types.RewardsDoc) interface{} { return v.Coinbase }),
+				"account": {
+					Type: account,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.loadAccount(p, p.Source.(*types.RewardsDoc).Coinbase)
+					},
+				},
+				"smesherId": value(graphql.String, func(v *types.RewardsDoc) interface{} { return v.NodeId }),
+				"node": {
+					Type: node,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.loadNode(p, p.Source.(*types.RewardsDoc).NodeId)
+					},
+				},
+				"atxId":       value(graphql.String, func(v *types.RewardsDoc) interface{} { return v.AtxID }),
+				"layer":       value(graphql.Int, func(v *types.RewardsDoc) interface{} { return v.Layer }),
+				"rewards":     value(BigInt, func(v *types.RewardsDoc) interface{} { return v.TotalReward }),
+				"layerReward": value(BigInt, func(v *types.RewardsDoc) interface{} { return v.LayerReward }),
+				"timestamp":   value(BigInt, func(v *types.RewardsDoc) interface{} { return r.networkUtils.LayerTime(v.Layer) }),
+			}
+		}),
+	})
+
+	transaction = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Transaction",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":               value(graphql.String, func(v *types.TransactionDoc) interface{} { return v.ID }),
+				"status":           value(graphql.Int, func(v *types.TransactionDoc) interface{} { return v.Status }),
+				"principalAccount": value(graphql.String, func(v *types.TransactionDoc) interface{} { return v.PrincipaAccount }),
+				"principal": {
+					Type: account,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.loadAccount(p, p.Source.(*types.TransactionDoc).PrincipaAccount)
+					},
+				},
+				"receiverAccount": value(graphql.String, func(v *types.TransactionDoc) interface{} { return v.ReceiverAccount }),
+				"receiver": {
+					Type: account,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.loadAccount(p, p.Source.(*types.TransactionDoc).ReceiverAccount)
+					},
+				},
+				"vaultAccount": value(graphql.String, func(v *types.TransactionDoc) interface{} { return v.VaultAccount }),
+				"fee":          value(BigInt, func(v *types.TransactionDoc) interface{} { return v.TotalFee() }),
+				"amount":       value(BigInt, func(v *types.TransactionDoc) interface{} { return v.Amount }),
+				"layer":        value(graphql.Int, func(v *types.TransactionDoc) interface{} { return v.Layer }),
+				"counter":      value(BigInt, func(v *types.TransactionDoc) interface{} { return v.Counter }),
+				"method":       value(graphql.String, func(v *types.TransactionDoc) interface{} { return methodName(v.Method) }),
+				"type":         value(graphql.Int, func(v *types.TransactionDoc) interface{} { return v.Type }),
+				"complete":     value(graphql.Boolean, func(v *types.TransactionDoc) interface{} { return v.Complete }),
+				"timestamp": value(BigInt, func(v *types.TransactionDoc) interface{} {
+					return r.networkUtils.LayerTime(int64(v.Layer))
+				}),
+			}
+		}),
+	})
+
+	account = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Account",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"address":      value(graphql.String, func(a *types.AccountDoc) interface{} { return a.Address }),
+				"balance":      value(BigInt, func(a *types.AccountDoc) interface{} { return a.Balance }),
+				"usdValue":     value(BigInt, func(a *types.AccountDoc) interface{} { return r.usdValue(a.Balance) }),
+				"totalRewards": value(BigInt, func(a *types.AccountDoc) interface{} { return a.TotalRewards }),
+				"sent":         value(BigInt, func(a *types.AccountDoc) interface{} { return a.Sent }),
+				"received":     value(BigInt, func(a *types.AccountDoc) interface{} { return a.Received }),
+				"fees":         value(BigInt, func(a *types.AccountDoc) interface{} { return a.Fees }),
+				"numberOfTransactions": {
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.db.CountTransactions(p.Source.(*types.AccountDoc).Address)
+					},
+				},
+				"numberOfRewards": {
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.db.CountRewards(p.Source.(*types.AccountDoc).Address, -1, -1)
+					},
+				},
+				"rewards": {
+					Type: graphql.NewList(reward),
+					Args: pageArgs(1, graphql.FieldConfigArgument{
+						"firstLayer": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: -1},
+						"lastLayer":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: -1},
+					}),
+					Resolve: r.accountRewards,
+				},
+				"transactions": {
+					Type: graphql.NewList(transaction),
+					Args: pageArgs(1, graphql.FieldConfigArgument{
+						"complete": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: true},
+					}),
+					Resolve: r.accountTransactions,
+				},
+				"rewardsDetails": {
+					Type:        rewardDetailsEpoch,
+					Description: "Rewards and eligibility of an epoch, the current one by default. Null when the account is not active in it.",
+					Args: graphql.FieldConfigArgument{
+						"epoch": &graphql.ArgumentConfig{Type: graphql.Int},
+					},
+					Resolve: r.accountRewardsDetails,
+				},
+				"atxs": {
+					Type: graphql.NewList(atx),
+					Args: pageArgs(1, graphql.FieldConfigArgument{
+						"epoch": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+					}),
+					Resolve: r.accountAtxs,
+				},
+			}
+		}),
+	})
+
+	node = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Node",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": value(graphql.String, func(n *types.NodeDoc) interface{} { return n.ID }),
+				"atxs": value(graphql.NewList(nodeAtx), func(n *types.NodeDoc) interface{} {
+					atxs := make([]*types.NodeAtxDoc, len(n.Atxs))
+					for i := range n.Atxs {
+						atxs[i] = &n.Atxs[i]
+					}
+					return atxs
+				}),
+				"malfeasance": value(malfeasance, func(n *types.NodeDoc) interface{} {
+					if n.Malfeasance.Received == 0 {
+						return nil
+					}
+					return &n.Malfeasance
+				}),
+				"numberOfRewards": {
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return r.db.CountNodeRewards(p.Source.(*types.NodeDoc).ID)
+					},
+				},
+				"rewards": {
+					Type:    graphql.NewList(reward),
+					Args:    pageArgs(1, nil),
+					Resolve: r.nodeRewards,
+				},
+				"rewardsDetails": {
+					Type:    rewardDetails,
+					Resolve: r.nodeRewardsDetails,
+				},
+				"eligibility": {
+					Type:        eligibility,
+					Description: "Eligibility in the current epoch, count is -1 when the node has no weight in it.",
+					Resolve:     r.nodeEligibility,
+				},
+			}
+		}),
+	})
+
+	layer := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Layer",
+		Fields: graphql.Fields{
+			"layer":        value(graphql.Int, func(l *layerSource) interface{} { return l.Layer }),
+			"status":       value(graphql.Int, func(l *layerSource) interface{} { return l.Status }),
+			"appliedBlock": value(graphql.String, func(l *layerSource) interface{} { return l.AppliedBlock }),
+			"timestamp":    value(BigInt, func(l *layerSource) interface{} { return r.networkUtils.LayerTime(l.Layer) }),
+			"reverts": {
+				Type:    graphql.NewList(layerRevert),
+				Resolve: r.layerReverts,
+			},
+			"numberOfRewards": {
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.db.CountLayerRewards(int(p.Source.(*layerSource).Layer))
+				},
+			},
+			"rewards": {
+				Type:    graphql.NewList(reward),
+				Args:    pageArgs(-1, nil),
+				Resolve: r.layerRewards,
+			},
+			"numberOfTransactions": {
+				Type: graphql.Int,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.db.CountLayerTransactions(int(p.Source.(*layerSource).Layer))
+				},
+			},
+			"transactions": {
+				Type: graphql.NewList(transaction),
+				Args: pageArgs(1, graphql.FieldConfigArgument{
+					"complete": &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: true},
+				}),
+				Resolve: r.layerTransactions,
+			},
+		},
+	})
+
+	epoch := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Epoch",
+		Fields: graphql.Fields{
+			"epoch": value(graphql.Int, func(e *epochSource) interface{} { return e.epoch }),
+			"effectiveUnitsCommited": value(BigInt, func(e *epochSource) interface{} {
+				return e.EffectiveUnitsCommited
+			}),
+			"epochSubsidy":        value(BigInt, func(e *epochSource) interface{} { return e.EpochSubsidy }),
+			"totalWeight":         value(BigInt, func(e *epochSource) interface{} { return e.TotalWeight }),
+			"totalRewards":        value(BigInt, func(e *epochSource) interface{} { return e.TotalRewards }),
+			"totalActiveSmeshers": value(graphql.Int, func(e *epochSource) interface{} { return e.TotalActiveSmeshers }),
+			"atxs": {
+				Type:        graphql.NewList(atx),
+				Description: "The atxs published in the previous epoch, that give weight in this one.",
+				Args:        pageArgs(1, nil),
+				Resolve:     r.epochAtxs,
+			},
+		},
+	})
+
+	nextEpoch := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NetworkInfoNextEpoch",
+		Fields: graphql.Fields{
+			"epoch": value(graphql.Int, func(n *types.NetworkInfoNextEpoch) interface{} { return n.Epoch }),
+			"effectiveUnitsCommited": value(BigInt, func(n *types.NetworkInfoNextEpoch) interface{} {
+				return n.EffectiveUnitsCommited
+			}),
+			"totalActiveSmeshers": value(graphql.Int, func(n *types.NetworkInfoNextEpoch) interface{} {
+				return n.TotalActiveSmeshers
+			}),
+		},
+	})
+
+	networkInfo := graphql.NewObject(graphql.ObjectConfig{
+		Name: "NetworkInfo",
+		Fields: graphql.Fields{
+			"epoch": value(graphql.Int, func(n *types.NetworkInfo) interface{} { return n.Epoch }),
+			"layer": value(graphql.Int, func(n *types.NetworkInfo) interface{} { return n.Layer }),
+			"effectiveUnitsCommited": value(BigInt, func(n *types.NetworkInfo) interface{} {
+				return n.EffectiveUnitsCommited
+			}),
+			"epochSubsidy":        value(BigInt, func(n *types.NetworkInfo) interface{} { return n.EpochSubsidy }),
+			"totalSlots":          value(BigInt, func(n *types.NetworkInfo) interface{} { return n.TotalSlots }),
+			"totalWeight":         value(BigInt, func(n *types.NetworkInfo) interface{} { return n.TotalWeight }),
+			"circulatingSupply":   value(BigInt, func(n *types.NetworkInfo) interface{} { return n.CirculatingSupply }),
+			"rewards":             value(BigInt, func(n *types.NetworkInfo) interface{} { return n.TotalRewards }),
+			"price":               value(graphql.Float, func(n *types.NetworkInfo) interface{} { return n.Price }),
+			"marketCap":           value(BigInt, func(n *types.NetworkInfo) interface{} { return n.MarketCap }),
+			"totalAccounts":       value(BigInt, func(n *types.NetworkInfo) interface{} { return n.TotalAccounts }),
+			"totalActiveSmeshers": value(BigInt, func(n *types.NetworkInfo) interface{} { return n.TotalActiveSmeshers }),
+			"atxHex":              value(graphql.String, func(n *types.NetworkInfo) interface{} { return n.AtxHex }),
+			"atxBase64":           value(graphql.String, func(n *types.NetworkInfo) interface{} { return n.AtxBase64 }),
+			"vested":              value(BigInt, func(n *types.NetworkInfo) interface{} { return n.Vested }),
+			"totalVaulted":        value(BigInt, func(n *types.NetworkInfo) interface{} { return n.TotalVaulted }),
+			"nextEpoch":           value(nextEpoch, func(n *types.NetworkInfo) interface{} { return n.NextEpoch }),
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"account": {
+				Type: account,
+				Args: graphql.FieldConfigArgument{
+					"address": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.loadAccount(p, p.Args["address"].(string))
+				},
+			},
+			"accounts": {
+				Type:    graphql.NewList(account),
+				Args:    pageArgs(-1, nil),
+				Resolve: r.accounts,
+			},
+			"node": {
+				Type: node,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.loadNode(p, p.Args["id"].(string))
+				},
+			},
+			"nodes": {
+				Type: graphql.NewList(node),
+				Args: graphql.FieldConfigArgument{
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultLimit},
+				},
+				Resolve: r.nodes,
+			},
+			"epoch": {
+				Type: epoch,
+				Args: graphql.FieldConfigArgument{
+					"epoch": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: r.epoch,
+			},
+			"layer": {
+				Type: layer,
+				Args: graphql.FieldConfigArgument{
+					"layer": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: r.layer,
+			},
+			"layers": {
+				Type:        graphql.NewList(layer),
+				Description: "The processed layers.",
+				Args:        pageArgs(-1, nil),
+				Resolve:     r.layers,
+			},
+			"transaction": {
+				Type: transaction,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: r.transaction,
+			},
+			"transactions": {
+				Type: graphql.NewList(transaction),
+				Args: pageArgs(1, graphql.FieldConfigArgument{
+					"complete":  &graphql.ArgumentConfig{Type: graphql.Boolean, DefaultValue: true},
+					"method":    &graphql.ArgumentConfig{Type: methodEnum},
+					"minAmount": &graphql.ArgumentConfig{Type: BigInt},
+				}),
+				Resolve: r.transactions,
+			},
+			"networkInfo": {
+				Type: networkInfo,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return r.state.GetInfo(), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: query,
+	})
+}
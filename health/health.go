@@ -0,0 +1,78 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+)
+
+// checkTimeout bounds every check, a dependency that doesn't answer in time is not ready
+const checkTimeout = 3 * time.Second
+
+// Check returns an error when the dependency it checks can't be used.
+type Check func(ctx context.Context) error
+
+// Checker runs the readiness checks of the instance.
+type Checker struct {
+	names  []string
+	checks map[string]Check
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		checks: make(map[string]Check),
+	}
+}
+
+func (c *Checker) Add(name string, check Check) {
+	c.names = append(c.names, name)
+	c.checks[name] = check
+}
+
+// Run runs the checks concurrently. It returns ok or the error of every check, and whether all passed.
+func (c *Checker) Run(ctx context.Context) (map[string]string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	results := make(map[string]string, len(c.names))
+	ready := true
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range c.names {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			err := check(ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				results[name] = err.Error()
+				ready = false
+			} else {
+				results[name] = "ok"
+			}
+		}(name, c.checks[name])
+	}
+	wg.Wait()
+	return results, ready
+}
+
+// LayerFreshness fails when the last processed layer of a network is more than maxLag layers behind the
+// current one, the sink is stuck or far behind.
+func LayerFreshness(readDB database.ReadDB, network *config.NetworkConfig, maxLag int64) Check {
+	return func(ctx context.Context) error {
+		layer, err := readDB.GetLastProcessedLayer()
+		if err != nil {
+			return fmt.Errorf("failed to get last processed layer: %w", err)
+		}
+		current := network.CurrentLayer()
+		if lag := current - layer.Layer; lag > maxLag {
+			return fmt.Errorf("last processed layer %d is %d layers behind the current layer %d", layer.Layer, lag, current)
+		}
+		return nil
+	}
+}
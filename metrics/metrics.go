@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "state_api"
+
+var (
+	// SinkMessages counts the stream messages by how the sink settled them, ack, nak, panic or dead.
+	SinkMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "messages_total",
+		Help:      "Stream messages processed by the sink, by network, stream and result (ack, nak, panic or dead).",
+	}, []string{"network", "stream", "result"})
+
+	// SinkRestarts counts the times a sink consumer failed and subscribed again.
+	SinkRestarts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "consumer_restarts_total",
+		Help:      "Restarts of the sink consumers after a subscription or fetch error, by network and stream.",
+	}, []string{"network", "stream"})
+
+	// SinkLag is how long ago the last message the sink processed was published.
+	SinkLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "lag_seconds",
+		Help:      "Time between the publication of the last processed message of a stream and its processing.",
+	}, []string{"network", "stream"})
+
+	// SinkPending is the number of messages of a consumer not delivered yet.
+	SinkPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "pending_messages",
+		Help:      "Messages of the stream waiting to be delivered to the sink consumer.",
+	}, []string{"network", "stream"})
+
+	// LastLayer is the last layer the sink of a network saved.
+	LastLayer = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "sink",
+		Name:      "last_layer",
+		Help:      "Last layer saved by the sink, by network.",
+	}, []string{"network"})
+
+	// SaveDuration is the latency of the WriteDB save methods.
+	SaveDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "save_duration_seconds",
+		Help:      "Latency of the WriteDB save methods, by network, method and result (ok or error).",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"network", "method", "result"})
+
+	// RequestDuration is the latency of the http requests.
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the http requests, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// PriceFetchFailures counts the failed price fetches by source.
+	PriceFetchFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "price",
+		Name:      "fetch_failures_total",
+		Help:      "Failed fetches of the price, by source.",
+	}, []string{"source"})
+
+	// PriceLastFetch is when the price was last fetched from every source.
+	PriceLastFetch = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "price",
+		Name:      "last_fetch_timestamp_seconds",
+		Help:      "Unix time of the last successful fetch of the price, by source.",
+	}, []string{"source"})
+
+	// PriceOutliers counts the quotes left out of the price for being too far from the median.
+	PriceOutliers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "price",
+		Name:      "outliers_total",
+		Help:      "Quotes rejected for being too far from the median, by source and currency.",
+	}, []string{"source", "currency"})
+)
+
+// Handler serves the metrics in the prometheus format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// WriteDB records the latency of the save methods of the wrapped WriteDB of a network.
+type WriteDB struct {
+	database.WriteDB
+	network string
+}
+
+func NewWriteDB(network string, writeDB database.WriteDB) *WriteDB {
+	return &WriteDB{
+		WriteDB: writeDB,
+		network: network,
+	}
+}
+
+func (w *WriteDB) observe(method string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	SaveDuration.WithLabelValues(w.network, method, result).Observe(time.Since(start).Seconds())
+}
+
+func (w *WriteDB) SaveLayer(layer *nats.LayerUpdate, origin database.Origin) error {
+	start := time.Now()
+	err := w.WriteDB.SaveLayer(layer, origin)
+	w.observe("SaveLayer", start, err)
+	return err
+}
+
+func (w *WriteDB) SaveAtx(atx *nats.Atx, origin database.Origin) error {
+	start := time.Now()
+	err := w.WriteDB.SaveAtx(atx, origin)
+	w.observe("SaveAtx", start, err)
+	return err
+}
+
+func (w *WriteDB) SaveMalfeasance(malfeasance *nats.Malfeasance, origin database.Origin) error {
+	start := time.Now()
+	err := w.WriteDB.SaveMalfeasance(malfeasance, origin)
+	w.observe("SaveMalfeasance", start, err)
+	return err
+}
+
+func (w *WriteDB) SaveTransactions(transaction *nats.Transaction, result bool, origin database.Origin) error {
+	start := time.Now()
+	err := w.WriteDB.SaveTransactions(transaction, result, origin)
+	w.observe("SaveTransactions", start, err)
+	return err
+}
+
+func (w *WriteDB) SaveReward(reward *nats.Reward, origin database.Origin) error {
+	start := time.Now()
+	err := w.WriteDB.SaveReward(reward, origin)
+	w.observe("SaveReward", start, err)
+	return err
+}
+
+func (w *WriteDB) SaveApiKey(key *types.ApiKeyDoc) error {
+	start := time.Now()
+	err := w.WriteDB.SaveApiKey(key)
+	w.observe("SaveApiKey", start, err)
+	return err
+}
+
+func (w *WriteDB) SaveWebhook(webhook *types.WebhookDoc) error {
+	start := time.Now()
+	err := w.WriteDB.SaveWebhook(webhook)
+	w.observe("SaveWebhook", start, err)
+	return err
+}
+
+func (w *WriteDB) SaveWebhookDelivery(delivery *types.WebhookDeliveryDoc) (bool, error) {
+	start := time.Now()
+	saved, err := w.WriteDB.SaveWebhookDelivery(delivery)
+	w.observe("SaveWebhookDelivery", start, err)
+	return saved, err
+}
+
+func (w *WriteDB) SaveDeadLetter(letter *types.DeadLetterDoc) error {
+	start := time.Now()
+	err := w.WriteDB.SaveDeadLetter(letter)
+	w.observe("SaveDeadLetter", start, err)
+	return err
+}
@@ -10,51 +10,63 @@ import (
 	"github.com/spacemeshos/go-spacemesh/tortoise"
 )
 
-const (
-	OneYear       = 105120               // 365 days, in 5-minute intervals
-	VestStart     = OneYear              // one year, in layers
-	VestEnd       = 4 * OneYear
-	OneSmesh = 1000000000 // 1e9 (1bn) smidge per smesh
-	TotalVaulted  = OneSmesh * 150000000 // 150mn smesh
-)
 type NetworkUtils struct {
+	network        *config.NetworkConfig
 	tortoiseConfig tortoise.Config
 }
 
-func NewNetworkUtils() *NetworkUtils {
+func NewNetworkUtils(network *config.NetworkConfig) *NetworkUtils {
+	tortoiseConfig := tortoise.DefaultConfig()
+	if network.LayerSize > 0 {
+		tortoiseConfig.LayerSize = network.LayerSize
+	}
 	return &NetworkUtils{
-		tortoiseConfig: tortoise.DefaultConfig(),
+		network:        network,
+		tortoiseConfig: tortoiseConfig,
 	}
 }
 
+// Network returns the profile of the network.
+func (n *NetworkUtils) Network() *config.NetworkConfig {
+	return n.network
+}
+
+// LayerTime returns the unix time in seconds a layer starts at.
+func (n *NetworkUtils) LayerTime(layer int64) int64 {
+	return n.network.LayerTime(layer)
+}
+
 func (n *NetworkUtils) GetEpoch(layer uint64) sTypes.EpochID {
-	return sTypes.EpochID(layer / config.LayersPerEpoch)
+	return sTypes.EpochID(layer / uint64(n.network.LayersPerEpoch))
 }
 
 func (n *NetworkUtils) GetEpochFirst(epoch uint64) sTypes.LayerID {
-	return sTypes.LayerID(sTypes.EpochID(epoch)).Mul(config.LayersPerEpoch)
+	return sTypes.LayerID(sTypes.EpochID(epoch)).Mul(n.network.LayersPerEpoch)
+}
+
+// GetEpochLayers returns the first layer of an epoch and the first one of the next.
+func (n *NetworkUtils) GetEpochLayers(epoch uint64) (uint32, uint32) {
+	firstLayer := uint32(epoch) * n.network.LayersPerEpoch
+	return firstLayer, firstLayer + n.network.LayersPerEpoch
 }
 
 func (n *NetworkUtils) GetNumberOfSlots(weight uint64, totalWeight uint64, epoch uint32) (int32, error) {
 	layerSize := n.tortoiseConfig.LayerSize
-	minimalWeight := uint64(7_879_129_244)
-	if epoch < 8 {
-		minimalWeight = 107467138
-	}
+	minimalWeight := n.network.MinimalWeight(epoch)
 
-	slots, err := util.GetNumEligibleSlots(weight, minimalWeight, totalWeight, layerSize, 4032)
+	slots, err := util.GetNumEligibleSlots(weight, minimalWeight, totalWeight, layerSize, n.network.LayersPerEpoch)
 	return int32(slots), err
 }
 
 func (n *NetworkUtils) FirstEffectiveGenesis() sTypes.LayerID {
-	return sTypes.LayerID(config.LayersPerEpoch*2 - 1)
+	return sTypes.LayerID(n.network.LayersPerEpoch*2 - 1)
 }
 
 func (n *NetworkUtils) GetEpochSubsidy(epoch uint64) uint64 {
 	genisesLayer := n.FirstEffectiveGenesis()
 	epochFirstLayer := n.GetEpochFirst(epoch)
 	var totalEpochSubsidy uint64 = 0
-	for i := epochFirstLayer; i < epochFirstLayer+config.LayersPerEpoch; i++ {
+	for i := epochFirstLayer; i < epochFirstLayer.Add(n.network.LayersPerEpoch); i++ {
 		totalEpochSubsidy += rewards.TotalSubsidyAtLayer(i.Difference(genisesLayer))
 	}
 	return totalEpochSubsidy
@@ -62,18 +74,30 @@ func (n *NetworkUtils) GetEpochSubsidy(epoch uint64) uint64 {
 
 
 func (n *NetworkUtils) Vested(layer uint64) uint64 {
+	vesting := n.network.Vesting
+	if vesting == nil {
+		return 0
+	}
 	lid := sTypes.LayerID(layer)
-	if lid.Before(VestStart) {
+	if lid.Before(sTypes.LayerID(vesting.Start)) {
 		return 0
 	}
-	if !lid.Before(VestEnd) {
-		return TotalVaulted
+	if !lid.Before(sTypes.LayerID(vesting.End)) {
+		return vesting.Total
 	}
-	vested := new(big.Int).SetUint64(TotalVaulted)
-	vested.Mul(vested, new(big.Int).SetUint64(uint64(lid.Difference(VestStart))))
+	vested := new(big.Int).SetUint64(vesting.Total)
+	vested.Mul(vested, new(big.Int).SetUint64(uint64(lid.Difference(sTypes.LayerID(vesting.Start)))))
 	// Note: VestingStart may equal VestingEnd but division by zero is not possible here since in this case
 	// one of the first two conditionals above would have been triggered and the method would already have
 	// returned.
-	vested.Div(vested, new(big.Int).SetUint64(uint64(VestEnd - VestStart)))
+	vested.Div(vested, new(big.Int).SetUint64(uint64(vesting.End-vesting.Start)))
 	return vested.Uint64()
 }
+
+// TotalVaulted returns the smidge the vaults vest in total.
+func (n *NetworkUtils) TotalVaulted() uint64 {
+	if n.network.Vesting == nil {
+		return 0
+	}
+	return n.network.Vesting.Total
+}
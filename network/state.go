@@ -3,8 +3,7 @@ package network
 import (
     "encoding/base64"
     "encoding/hex"
-    "fmt"
-    "log"
+    "log/slog"
     "sync"
     "time"
 
@@ -16,20 +15,23 @@ import (
 const INFO_KEY = "info"
 
 type NetworkState struct {
-    db             *database.ReadDB
+    db             database.ReadDB
     networkUtils   *NetworkUtils
     networkInfo    *sync.Map
     epochSubsidies *sync.Map
     priceResolver  *price.PriceResolver
+    stop           chan struct{}
+    done           sync.WaitGroup
 }
 
-func NewNetworkState(db *database.ReadDB, networkUtils *NetworkUtils, priceResolver *price.PriceResolver) *NetworkState {
+func NewNetworkState(db database.ReadDB, networkUtils *NetworkUtils, priceResolver *price.PriceResolver) *NetworkState {
     state := &NetworkState{
         db:             db,
         networkUtils:   networkUtils,
         networkInfo:    &sync.Map{},
         epochSubsidies: &sync.Map{},
         priceResolver:  priceResolver,
+        stop:           make(chan struct{}),
     }
     state.fetchNetworkInfo()
     state.periodicNetworkInfoFetch()
@@ -55,88 +57,102 @@ func (n *NetworkState) GetEpochSubsidy(epoch uint32) uint64 {
 }
 
 func (n *NetworkState) periodicNetworkInfoFetch() {
-    ticker := time.NewTicker(60 * time.Second)
-    go func() {
-        for range ticker.C {
-            n.fetchNetworkInfo()
-        }
-    }()
+    n.every(60*time.Second, n.fetchNetworkInfo)
 }
 
 func (n *NetworkState) periodicCalculateSubsidy() {
-    ticker := time.NewTicker(60 * time.Second)
+    n.every(60*time.Second, n.calculateEpochSubsidies)
+}
+
+// every runs f at every interval until Stop.
+func (n *NetworkState) every(interval time.Duration, f func()) {
+    n.done.Add(1)
     go func() {
-        for range ticker.C {
-            n.calculateEpochSubsidies()
+        defer n.done.Done()
+        ticker := time.NewTicker(interval)
+        defer ticker.Stop()
+        for {
+            select {
+            case <-ticker.C:
+                f()
+            case <-n.stop:
+                return
+            }
         }
     }()
 }
 
+// Stop stops the periodic fetches, it waits for the one in progress so the db isn't read after it returns.
+func (n *NetworkState) Stop() {
+    close(n.stop)
+    n.done.Wait()
+}
+
 func (n *NetworkState) fetchNetworkInfo() {
 
-    log.Println("Start fetch network info")
+    slog.Debug("Start fetch network info")
 
     layer, err := n.db.GetLastProcessedLayer()
     if err != nil {
-        fmt.Printf("Failed to get last processed layer: %s", err.Error())
+        slog.Error("Failed to get last processed layer", "error", err)
         return
     }
-    log.Println("Got last processed layer")
+    slog.Debug("Got last processed layer")
 
     epoch := n.networkUtils.GetEpoch(uint64(layer.Layer))
 
     atxEpoch, err := n.db.CountAtxEpoch(uint64(epoch - 1))
     if err != nil {
-        fmt.Printf("Failed to count atx epoch: %s", err.Error())
+        slog.Error("Failed to count atx epoch", "error", err)
         return
     }
-    log.Println("Got atx for epoch count")
+    slog.Debug("Got atx for epoch count")
 
     atxNextEpoch, err := n.db.CountAtxEpoch(uint64(epoch))
     if err != nil {
-        fmt.Printf("Failed to count next atx epoch: %s", err.Error())
+        slog.Error("Failed to count next atx epoch", "error", err)
         return
     }
-    log.Println("Got atx for next epoch count")
+    slog.Debug("Got atx for next epoch count")
 
     totalAccounts, err := n.db.CountAccounts()
     if err != nil {
-        fmt.Printf("Failed to count accounts: %s", err.Error())
+        slog.Error("Failed to count accounts", "error", err)
         return
     }
-    log.Println("Got count accounts")
+    slog.Debug("Got count accounts")
 
     networkInfo, err := n.db.GetNetworkInfo()
     if err != nil {
-        fmt.Printf("Failed to get network info: %s", err.Error())
+        slog.Error("Failed to get network info", "error", err)
         return
     }
-    log.Println("Got network info")
+    slog.Debug("Got network info")
 
     atxEpochTotals, err := n.db.GetAtxEpoch(uint64(epoch - 1))
     if err != nil {
-        fmt.Printf("Failed to get epoch totals: %s", err.Error())
+        slog.Error("Failed to get epoch totals", "error", err)
         return
     }
-    log.Println("Got atx totals")
+    slog.Debug("Got atx totals")
 
     atxNextEpochTotals, err := n.db.GetAtxEpoch(uint64(epoch))
     if err != nil {
-        fmt.Printf("Failed to get next epoch totals: %s", err.Error())
+        slog.Error("Failed to get next epoch totals", "error", err)
         return
     }
-    log.Println("Got atx next epoch totals")
+    slog.Debug("Got atx next epoch totals")
 
     totalSlots, err := n.networkUtils.GetNumberOfSlots(uint64(atxEpochTotals.TotalWeight), atxEpochTotals.TotalWeight, epoch.Uint32())
     if err != nil {
-        fmt.Printf("Failed to get total slots: %s", err.Error())
+        slog.Error("Failed to get total slots", "error", err)
         return
     }
-    log.Println("Got total slots")
+    slog.Debug("Got total slots")
 
-    var genisesAccounts int64 = 28
+    var genisesAccounts = int64(len(n.networkUtils.Network().Vaults))
     var p = n.priceResolver.GetPrice()
-    log.Println("Got price")
+    slog.Debug("Got price")
 
     n.networkInfo.Store(INFO_KEY, &types.NetworkInfo{
         Epoch:                  epoch.Uint32(),
@@ -154,7 +170,7 @@ func (n *NetworkState) fetchNetworkInfo() {
         TotalActiveSmeshers:    uint64(atxEpoch),
         TotalRewards:           networkInfo.CirculatingSupply,
         Vested:                 n.networkUtils.Vested(uint64(layer.Layer)),
-        TotalVaulted:           TotalVaulted,
+        TotalVaulted:           n.networkUtils.TotalVaulted(),
         NextEpoch: &types.NetworkInfoNextEpoch{
             Epoch:                  epoch.Uint32() + 1,
             EffectiveUnitsCommited: int64(atxNextEpochTotals.TotalEffectiveNumUnits),
@@ -167,7 +183,7 @@ func (n *NetworkState) fetchNetworkInfo() {
 func (n *NetworkState) calculateEpochSubsidies() {
     layer, err := n.db.GetLastProcessedLayer()
     if err != nil {
-        fmt.Printf("Failed to get last processed layer: %s", err.Error())
+        slog.Error("Failed to get last processed layer", "error", err)
         return
     }
 
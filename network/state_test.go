@@ -0,0 +1,40 @@
+package network
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/price"
+)
+
+func TestNetworkStateStop(t *testing.T) {
+	db, err := database.NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "state.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.CloseRead()
+	configValues := &config.Config{Price: &config.PriceConfig{
+		DisableHistory: true,
+		Providers:      []*config.PriceProviderConfig{{Type: "static", Prices: map[string]float64{"USD": 2}}},
+	}}
+	priceResolver, err := price.NewPriceResolver(configValues, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer priceResolver.Stop()
+	state := NewNetworkState(db, NewNetworkUtils(config.Mainnet()), priceResolver)
+
+	stopped := make(chan struct{})
+	go func() {
+		state.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't return")
+	}
+}
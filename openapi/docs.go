@@ -0,0 +1,10 @@
+package openapi
+
+import (
+	_ "embed"
+)
+
+// DocsPage is the docs ui, a page that renders /openapi.json and sends requests to the api.
+//
+//go:embed docs.html
+var DocsPage []byte
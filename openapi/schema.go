@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Schema is an OpenAPI 3.0 schema object, the subset the api uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *int64             `json:"minimum,omitempty"`
+	Maximum              *int64             `json:"maximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// schemas builds the component schemas of the go types, following the encoding/json rules so they
+// describe what the handlers send and bind.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// of returns the schema of the type of value. Named structs are added to the components and referenced.
+// In requests only the fields with a binding:"required" tag are required, in responses all the fields
+// without omitempty are.
+func (s *schemas) of(value any, request bool) *Schema {
+	return s.schema(reflect.TypeOf(value), request)
+}
+
+func (s *schemas) schema(t reflect.Type, request bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32", Minimum: Int(0)}
+	case reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: Int(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem(), request)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem(), request)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t, request)
+		}
+		return &Schema{Ref: schemaRefPrefix + s.component(t, request)}
+	}
+	// interfaces can hold any value
+	return &Schema{}
+}
+
+func (s *schemas) component(t reflect.Type, request bool) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.components[name]; taken {
+		// the same name in another package
+		name = strings.ReplaceAll(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:], "-", "") + name
+	}
+	s.names[t] = name
+	// the placeholder ends the recursion of self referencing types
+	s.components[name] = &Schema{}
+	*s.components[name] = *s.object(t, request)
+	return name
+}
+
+func (s *schemas) object(t reflect.Type, request bool) *Schema {
+	object := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.fields(object, t, request)
+	if len(object.Properties) == 0 {
+		object.Properties = nil
+	}
+	return object
+}
+
+func (s *schemas) fields(object *Schema, t reflect.Type, request bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			s.fields(object, fieldType, request)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		object.Properties[name] = s.schema(field.Type, request)
+		required := !strings.Contains(options, "omitempty")
+		if request {
+			required = strings.Contains(field.Tag.Get("binding"), "required")
+		}
+		if required {
+			object.Required = append(object.Required, name)
+		}
+	}
+}
+
+// resolve follows the reference of a schema.
+func (s *schemas) resolve(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return s.components[strings.TrimPrefix(schema.Ref, schemaRefPrefix)]
+	}
+	return schema
+}
+
+// Int returns a pointer to value, for the bounds of schemas and params.
+func Int(value int64) *int64 {
+	return &value
+}
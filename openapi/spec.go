@@ -0,0 +1,301 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Param is a path or query parameter of an operation.
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	// Type is integer, boolean, string or array, array params are strings given several times or comma
+	// separated and Enum applies to their items
+	Type string
+	Enum []string
+	// IgnoreCase matches the Enum regardless of case
+	IgnoreCase bool
+	Default    any
+	Minimum    *int64
+	Maximum    *int64
+	Required   bool
+}
+
+// Operation describes a route: the params and body it takes, validated before the handler runs, and
+// what it returns.
+type Operation struct {
+	ID      string
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	Params  []*Param
+	// Body is a value of the request body type, nil when there is none
+	Body any
+	// Response is a value of the response type, nil when the response has no json body
+	Response any
+	// Status of a successful response, 200 by default
+	Status int
+	// ContentType of the response when it is not json, e.g. text/event-stream
+	ContentType string
+	// Total is set for the lists with the total header, Cursor for the ones with the next and Link headers
+	Total  bool
+	Cursor bool
+	// Admin operations need the admin token instead of an api key
+	Admin bool
+
+	body *Schema
+}
+
+type Info struct {
+	Title       string
+	Version     string
+	Description string
+	// ApiKey documents the x-api-key header, KeyRequired when requests without one are rejected
+	ApiKey      bool
+	KeyRequired bool
+}
+
+// Spec holds the operations of the api, keyed by method and gin path.
+type Spec struct {
+	operations map[string]*Operation
+	schemas    *schemas
+	// networks are the path prefixes the operations are also served under
+	networks []string
+}
+
+func NewSpec(operations []*Operation) *Spec {
+	spec := &Spec{
+		operations: make(map[string]*Operation, len(operations)),
+		schemas:    newSchemas(),
+	}
+	for _, operation := range operations {
+		// the schemas are built once here, the validator reads them from concurrent requests
+		if operation.Body != nil {
+			operation.body = spec.schemas.of(operation.Body, true)
+		}
+		spec.operations[operation.Method+" "+operation.Path] = operation
+	}
+	return spec
+}
+
+// Networks makes the routes under /{name} of each network match the operations of the root routes.
+func (s *Spec) Networks(names []string) {
+	s.networks = names
+}
+
+func (s *Spec) operation(method string, path string) *Operation {
+	if operation := s.operations[method+" "+path]; operation != nil {
+		return operation
+	}
+	if network, rest := s.network(path); network != "" {
+		return s.operations[method+" "+rest]
+	}
+	return nil
+}
+
+// network splits the network prefix off a path, it is empty when the path has none.
+func (s *Spec) network(path string) (string, string) {
+	for _, name := range s.networks {
+		if rest, ok := strings.CutPrefix(path, "/"+name); ok && strings.HasPrefix(rest, "/") {
+			return name, rest
+		}
+	}
+	return "", path
+}
+
+// Document returns the OpenAPI 3 document of the registered routes. Every route needs an operation so
+// the document can't silently miss one.
+func (s *Spec) Document(info *Info, routes gin.RoutesInfo) ([]byte, error) {
+	paths := make(map[string]map[string]any)
+	for _, route := range routes {
+		operation := s.operation(route.Method, route.Path)
+		if operation == nil {
+			return nil, fmt.Errorf("route %s %s has no openapi operation", route.Method, route.Path)
+		}
+		// the routes of every network are documented once, with the network as a path param
+		path := openapiPath(route.Path)
+		network, rest := s.network(route.Path)
+		if s.operations[route.Method+" "+route.Path] != nil {
+			network = ""
+		} else if network != "" {
+			path = "/{network}" + openapiPath(rest)
+		}
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(route.Method)] = s.pathItem(operation, network != "")
+	}
+
+	securitySchemes := map[string]any{
+		"adminToken": map[string]any{
+			"type":   "http",
+			"scheme": "bearer",
+		},
+	}
+	document := map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+	}
+	if info.ApiKey {
+		securitySchemes["apiKey"] = map[string]any{
+			"type": "apiKey",
+			"in":   "header",
+			"name": "x-api-key",
+		}
+		security := []map[string][]string{{"apiKey": {}}}
+		if !info.KeyRequired {
+			// requests without a key get the anonymous limits
+			security = append(security, map[string][]string{})
+		}
+		document["security"] = security
+	}
+	document["components"] = map[string]any{
+		"schemas":         s.schemas.components,
+		"securitySchemes": securitySchemes,
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// pathItem documents an operation, networked for the one served under the prefix of a network.
+func (s *Spec) pathItem(operation *Operation, networked bool) map[string]any {
+	item := map[string]any{
+		"operationId": operation.ID,
+		"summary":     operation.Summary,
+		"tags":        []string{operation.Tag},
+	}
+
+	parameters := make([]map[string]any, 0, len(operation.Params)+1)
+	if networked {
+		item["operationId"] = operation.ID + "ByNetwork"
+		parameters = append(parameters, map[string]any{
+			"name":        "network",
+			"in":          "path",
+			"required":    true,
+			"description": "Name of the network, the root paths serve the first one.",
+			"schema":      &Schema{Type: "string", Enum: s.networks},
+		})
+	}
+	for _, param := range operation.Params {
+		parameter := map[string]any{
+			"name":     param.Name,
+			"in":       param.In,
+			"required": param.Required || param.In == "path",
+			"schema":   param.schema(),
+		}
+		if param.Description != "" {
+			parameter["description"] = param.Description
+		}
+		if param.Type == "array" {
+			parameter["style"] = "form"
+			parameter["explode"] = false
+		}
+		parameters = append(parameters, parameter)
+	}
+	if len(parameters) > 0 {
+		item["parameters"] = parameters
+	}
+
+	if operation.Body != nil {
+		item["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": operation.body},
+			},
+		}
+	}
+
+	status := operation.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := map[string]any{
+		"description": http.StatusText(status),
+	}
+	if operation.Response != nil {
+		response["content"] = map[string]any{
+			"application/json": map[string]any{"schema": s.schemas.of(operation.Response, false)},
+		}
+	} else if operation.ContentType != "" {
+		response["content"] = map[string]any{
+			operation.ContentType: map[string]any{"schema": &Schema{Type: "string"}},
+		}
+	}
+	headers := make(map[string]any)
+	if operation.Total {
+		headers["total"] = header("The number of items of the list.", "integer")
+	}
+	if operation.Cursor {
+		headers["next"] = header("The cursor of the next page, only set on full pages.", "string")
+		headers["Link"] = header("The url of the next page, rel=\"next\".", "string")
+	}
+	if len(headers) > 0 {
+		response["headers"] = headers
+	}
+	item["responses"] = map[string]any{
+		fmt.Sprint(status): response,
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": s.schemas.of(ErrorResponse{}, false)},
+			},
+		},
+	}
+
+	if operation.Admin {
+		item["security"] = []map[string][]string{{"adminToken": {}}}
+	}
+	return item
+}
+
+// ErrorResponse is the body of the error responses.
+type ErrorResponse struct {
+	Error  string `json:"error"`
+	Status string `json:"status,omitempty"`
+}
+
+func (p *Param) schema() *Schema {
+	schema := &Schema{
+		Type:    p.Type,
+		Default: p.Default,
+		Minimum: p.Minimum,
+		Maximum: p.Maximum,
+	}
+	if p.Type == "integer" {
+		schema.Format = "int64"
+	}
+	if p.Type == "array" {
+		schema.Items = &Schema{Type: "string", Enum: p.Enum}
+	} else {
+		schema.Enum = p.Enum
+	}
+	return schema
+}
+
+func header(description string, headerType string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"schema":      &Schema{Type: headerType},
+	}
+}
+
+// openapiPath turns the gin params of a path, :name, into {name}.
+func openapiPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,207 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxBodySize is the largest request body read, in bytes, a larger one is answered with 413.
+const MaxBodySize = 1 << 20
+
+var errBodyTooLarge = fmt.Errorf("body is larger than %d bytes", MaxBodySize)
+
+// Validator rejects the requests with params or bodies that don't match their operation, before the
+// handler runs, so every route answers bad input the same way. Params the operation doesn't declare
+// are ignored.
+func (s *Spec) Validator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		operation := s.operation(c.Request.Method, c.FullPath())
+		if operation == nil {
+			c.Next()
+			return
+		}
+		for _, param := range operation.Params {
+			if err := validateParam(c, param); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if operation.Body != nil {
+			if err := s.validateBody(c, operation); err != nil {
+				status := http.StatusBadRequest
+				if errors.Is(err, errBodyTooLarge) {
+					status = http.StatusRequestEntityTooLarge
+				}
+				c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func validateParam(c *gin.Context, param *Param) error {
+	var values []string
+	if param.In == "path" {
+		values = []string{c.Param(param.Name)}
+	} else {
+		values = c.QueryArray(param.Name)
+	}
+	if len(values) == 0 || (len(values) == 1 && values[0] == "") {
+		if param.Required {
+			return fmt.Errorf("%s is required", param.Name)
+		}
+		return nil
+	}
+
+	switch param.Type {
+	case "integer":
+		value, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a valid integer", param.Name)
+		}
+		if param.Minimum != nil && value < *param.Minimum {
+			return fmt.Errorf("%s must be greater or equal to %d", param.Name, *param.Minimum)
+		}
+		if param.Maximum != nil && value > *param.Maximum {
+			return fmt.Errorf("%s must be lower or equal to %d", param.Name, *param.Maximum)
+		}
+	case "boolean":
+		if values[0] != "true" && values[0] != "false" {
+			return fmt.Errorf("%s must be true or false", param.Name)
+		}
+	case "array":
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				if err := checkEnum(param.Name, item, param.Enum, param.IgnoreCase); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return checkEnum(param.Name, values[0], param.Enum, param.IgnoreCase)
+	}
+	return nil
+}
+
+func checkEnum(name string, value string, enum []string, ignoreCase bool) error {
+	if len(enum) == 0 {
+		return nil
+	}
+	for _, allowed := range enum {
+		if value == allowed || (ignoreCase && strings.EqualFold(value, allowed)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s must be one of %s", name, strings.Join(enum, ", "))
+}
+
+// validateBody checks the json body against the schema of the operation and puts it back for the
+// handler to bind.
+func (s *Spec) validateBody(c *gin.Context, operation *Operation) error {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxBodySize))
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return errBodyTooLarge
+		}
+		return fmt.Errorf("failed to read body")
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return fmt.Errorf("body must be valid json")
+	}
+	return s.validateValue("body", value, operation.body)
+}
+
+func (s *Spec) validateValue(path string, value any, schema *Schema) error {
+	schema = s.schemas.resolve(schema)
+	if value == nil {
+		// null is the zero value of the field, required fields are checked by the object holding them
+		return nil
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range schema.Required {
+			if field, ok := object[name]; !ok || field == nil {
+				return fmt.Errorf("%s is required", fieldPath(path, name))
+			}
+		}
+		for name, field := range object {
+			fieldSchema := schema.Properties[name]
+			if fieldSchema == nil {
+				fieldSchema = schema.AdditionalProperties
+			}
+			if fieldSchema == nil {
+				continue
+			}
+			if err := s.validateValue(fieldPath(path, name), field, fieldSchema); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		for i, item := range items {
+			if err := s.validateValue(fmt.Sprintf("%s[%d]", path, i), item, schema.Items); err != nil {
+				return err
+			}
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		return checkEnum(path, text, schema.Enum, false)
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		integer, err := strconv.ParseInt(number.String(), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", path)
+		}
+		if schema.Minimum != nil && integer < *schema.Minimum {
+			return fmt.Errorf("%s must be greater or equal to %d", path, *schema.Minimum)
+		}
+		if schema.Maximum != nil && integer > *schema.Maximum {
+			return fmt.Errorf("%s must be lower or equal to %d", path, *schema.Maximum)
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("%s must be a number", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be true or false", path)
+		}
+	}
+	return nil
+}
+
+// fieldPath names the fields of the body without the body prefix, accounts[2] instead of body.accounts[2].
+func fieldPath(path string, name string) string {
+	if path == "body" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,61 @@
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testBody struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func TestValidateBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	spec := NewSpec([]*Operation{{
+		ID: "create", Method: http.MethodPost, Path: "/things", Summary: "Creates a thing.",
+		Body: testBody{},
+	}})
+	spec.Networks([]string{"mainnet"})
+	router := gin.New()
+	router.Use(spec.Validator())
+	for _, path := range []string{"/things", "/mainnet/things"} {
+		router.POST(path, func(c *gin.Context) {
+			var body testBody
+			if err := c.ShouldBindJSON(&body); err != nil {
+				c.AbortWithStatus(http.StatusTeapot)
+				return
+			}
+			c.String(http.StatusOK, body.Name)
+		})
+	}
+
+	large := `{"name": "` + strings.Repeat("a", MaxBodySize) + `"}`
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{name: "valid body", path: "/things", body: `{"name": "a"}`, status: http.StatusOK},
+		{name: "valid body of a network", path: "/mainnet/things", body: `{"name": "a"}`, status: http.StatusOK},
+		{name: "invalid json", path: "/things", body: `{"name"`, status: http.StatusBadRequest},
+		{name: "wrong type", path: "/things", body: `{"name": 1}`, status: http.StatusBadRequest},
+		{name: "body under the limit", path: "/things", body: `{"name": "` + strings.Repeat("a", MaxBodySize-13) + `"}`, status: http.StatusOK},
+		{name: "body above the limit", path: "/things", body: large, status: http.StatusRequestEntityTooLarge},
+		{name: "body above the limit of a network", path: "/mainnet/things", body: large, status: http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, bytes.NewBufferString(test.body)))
+			if w.Code != test.status {
+				t.Errorf("status %d, want %d: %s", w.Code, test.status, w.Body.String()[:min(w.Body.Len(), 200)])
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package pending
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+)
+
+// Expirer marks dropped the transactions of a network that got no result within a number of processed layers
+// after they were created, they are no longer pending. A dropped transaction that gets its result after all is
+// saved as any other.
+type Expirer struct {
+	readDB       database.ReadDB
+	writeDB      database.WriteDB
+	networkUtils *network.NetworkUtils
+	dropLayers   int64
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+func NewExpirer(readDB database.ReadDB, writeDB database.WriteDB, networkUtils *network.NetworkUtils, dropLayers int64) *Expirer {
+	return &Expirer{
+		readDB:       readDB,
+		writeDB:      writeDB,
+		networkUtils: networkUtils,
+		dropLayers:   dropLayers,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start expires the transactions now and then every interval minutes in the background.
+func (e *Expirer) Start(interval int) {
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		defer ticker.Stop()
+		for {
+			if _, err := e.Expire(); err != nil {
+				slog.Error("Failed to expire pending transactions", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-e.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the expiry and waits for the one in progress, so the dbs can be closed. It must follow Start.
+func (e *Expirer) Stop() {
+	close(e.stop)
+	<-e.done
+}
+
+// Expire drops the transactions created before the layer dropLayers before the last processed one, so that a
+// sink behind doesn't drop the transactions whose result it didn't process yet. It returns how many.
+func (e *Expirer) Expire() (int64, error) {
+	layer, err := e.readDB.GetLastProcessedLayer()
+	if err != nil {
+		return 0, err
+	}
+	layerBefore := layer.Layer - e.dropLayers
+	if layerBefore <= 0 {
+		return 0, nil
+	}
+	dropped, err := e.writeDB.DropTransactions(e.networkUtils.LayerTime(layerBefore)*1000, uint32(layerBefore))
+	if err != nil {
+		return 0, err
+	}
+	if dropped > 0 {
+		slog.Info("Dropped pending transactions", "transactions", dropped, "before", layerBefore)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,84 @@
+package pending
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// expiryDB records the drops of the expirer, the other methods aren't used by it
+type expiryDB struct {
+	database.ReadDB
+	database.WriteDB
+	layer int64
+
+	mu    sync.Mutex
+	drops []uint32
+}
+
+func (db *expiryDB) GetLastProcessedLayer() (*types.LayerDoc, error) {
+	return &types.LayerDoc{Layer: db.layer}, nil
+}
+
+func (db *expiryDB) DropTransactions(publishedBefore int64, layerBefore uint32) (int64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.drops = append(db.drops, layerBefore)
+	return 1, nil
+}
+
+func TestExpire(t *testing.T) {
+	networkUtils := network.NewNetworkUtils(config.Mainnet())
+	tests := []struct {
+		layer int64
+		drops []uint32
+	}{
+		{layer: 0},
+		{layer: 20},
+		{layer: 21, drops: []uint32{1}},
+		{layer: 1000, drops: []uint32{980}},
+	}
+	for _, test := range tests {
+		db := &expiryDB{layer: test.layer}
+		dropped, err := NewExpirer(db, db, networkUtils, 20).Expire()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dropped != int64(len(test.drops)) || len(db.drops) != len(test.drops) {
+			t.Fatalf("layer %d: dropped %d with drops %v, want %v", test.layer, dropped, db.drops, test.drops)
+		}
+		for i := range test.drops {
+			if db.drops[i] != test.drops[i] {
+				t.Errorf("layer %d: dropped before %d, want %d", test.layer, db.drops[i], test.drops[i])
+			}
+		}
+	}
+}
+
+func TestExpirerStop(t *testing.T) {
+	db := &expiryDB{layer: 100}
+	expirer := NewExpirer(db, db, network.NewNetworkUtils(config.Mainnet()), 20)
+	expirer.Start(60)
+
+	stopped := make(chan struct{})
+	go func() {
+		expirer.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't return")
+	}
+	// the first expiry runs on Start and finishes before Stop returns
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if len(db.drops) != 1 {
+		t.Errorf("expired %d times, want 1", len(db.drops))
+	}
+}
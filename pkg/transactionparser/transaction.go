@@ -2,65 +2,110 @@ package transactionparser
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/spacemeshos/go-scale"
 	"github.com/spacemeshos/go-spacemesh/genvm/core"
-	"github.com/spacemeshos/go-spacemesh/genvm/registry"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
 	"github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
 
 	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
 	v0 "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/v0"
 )
 
-// Parse parses transaction from raw bytes and returns its type.
-func Parse(rawTx []byte) (*transaction.TransactionData, error) {
-	version, method, templateAddress, err := decodeHeader(scale.NewDecoder(bytes.NewReader(rawTx)))
+// VersionParser parses the transactions of a version from their raw bytes, method and template, the template
+// is only set for a spawn. It returns transaction.ErrUnsupported for the ones it has no decoder for.
+type VersionParser func(rawTx *bytes.Buffer, method uint8, template *core.Address) (*transaction.TransactionData, error)
+
+var versions = map[uint8]VersionParser{
+	0: v0.ParseTransaction,
+}
+
+// RegisterVersion registers the parser of a version of transactions, it replaces the one already registered.
+// Registering is not safe while transactions are parsed, versions register at init.
+func RegisterVersion(version uint8, parser VersionParser) {
+	versions[version] = parser
+}
+
+// Parse parses transaction from raw bytes and returns its type. A transaction of a version, template or
+// method without a decoder is returned as an opaque one, only malformed bytes are an error.
+func Parse(rawTx []byte) (txData *transaction.TransactionData, err error) {
+	// a decoder must not take the sink down with it on bytes it does not expect
+	defer func() {
+		if r := recover(); r != nil {
+			txData, err = nil, fmt.Errorf("%w: %w: %v", core.ErrMalformed, transaction.ErrDecoderPanic, r)
+		}
+	}()
+
+	decoder := scale.NewDecoder(bytes.NewReader(rawTx))
+	version, n, err := scale.DecodeCompact8(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode version %w", core.ErrMalformed, err)
+	}
+	parser := versions[version]
+	if parser == nil {
+		// the layout of the header may change with the version, nothing after it is decoded
+		return opaque(&transaction.OpaqueTransaction{
+			Version: version,
+			Body:    rawTx[n:],
+			Reason:  fmt.Sprintf("%s: version %d", transaction.ErrUnsupported, version),
+		}), nil
+	}
+
+	principal, method, templateAddress, headerLen, err := decodeHeader(decoder)
 	if err != nil {
 		return nil, err
 	}
-	switch version {
-	case 0:
-		return v0.ParseTransaction(bytes.NewBuffer(rawTx), method, templateAddress)
-	default:
-		return nil, fmt.Errorf("%w: unsupported version %d", core.ErrMalformed, version)
+	txData, err = parser(bytes.NewBuffer(rawTx), method, templateAddress)
+	if errors.Is(err, transaction.ErrUnsupported) {
+		tx := &transaction.OpaqueTransaction{
+			Version:   version,
+			Principal: principal,
+			Method:    method,
+			Body:      rawTx[n+headerLen:],
+			Reason:    err.Error(),
+		}
+		if method == core.MethodSpawn {
+			tx.Template = templateAddress
+		}
+		return opaque(tx), nil
 	}
+	return txData, err
 }
 
-// decodeHeader decodes version, method and template address from *scale.Decoder
-func decodeHeader(decoder *scale.Decoder) (uint8, uint8, *core.Address, error) {
-	reg := registry.New()
-	wallet.Register(reg)
-	multisig.Register(reg)
-	vesting.Register(reg)
-	vault.Register(reg)
-
-	version, _, err := scale.DecodeCompact8(decoder)
-	if err != nil {
-		return 0, 0, nil, fmt.Errorf("%w: failed to decode version %w", core.ErrMalformed, err)
+func opaque(tx *transaction.OpaqueTransaction) *transaction.TransactionData {
+	return &transaction.TransactionData{
+		Tx:     tx,
+		Opaque: tx,
+		Type:   transaction.TypeOpaque,
 	}
+}
 
+// decodeHeader decodes principal, method and template address from *scale.Decoder, after the version, and
+// returns how many bytes they took.
+func decodeHeader(decoder *scale.Decoder) (core.Address, uint8, *core.Address, int, error) {
 	var principal core.Address
-	if _, err := principal.DecodeScale(decoder); err != nil {
-		return 0, 0, nil, fmt.Errorf("%w failed to decode principal: %w", core.ErrMalformed, err)
+	n, err := principal.DecodeScale(decoder)
+	if err != nil {
+		return core.Address{}, 0, nil, 0, fmt.Errorf("%w failed to decode principal: %w", core.ErrMalformed, err)
 	}
 
-	method, _, err := scale.DecodeCompact8(decoder)
+	method, methodLen, err := scale.DecodeCompact8(decoder)
 	if err != nil {
-		return 0, 0, nil, fmt.Errorf("%w: failed to decode method selector %w", core.ErrMalformed, err)
+		return core.Address{}, 0, nil, 0, fmt.Errorf("%w: failed to decode method selector %w", core.ErrMalformed, err)
 	}
+	n += methodLen
 
 	var templateAddress *core.Address
 	if method == core.MethodSpawn {
 		templateAddress = &core.Address{}
-		if _, err := templateAddress.DecodeScale(decoder); err != nil {
-			return 0, 0, nil, fmt.Errorf("%w failed to decode template address %w", core.ErrMalformed, err)
+		templateLen, err := templateAddress.DecodeScale(decoder)
+		if err != nil {
+			return core.Address{}, 0, nil, 0, fmt.Errorf("%w failed to decode template address %w", core.ErrMalformed, err)
 		}
+		n += templateLen
 	} else {
 		templateAddress = &wallet.TemplateAddress
 	}
 
-	return version, method, templateAddress, nil
+	return principal, uint8(method), templateAddress, n, nil
 }
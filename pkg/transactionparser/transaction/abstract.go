@@ -27,7 +27,24 @@ type TransactionData struct {
 	Sig        *core.Signature
 	Signatures *multisig.Signatures
 	Vault      DecodedVault
-	Type       int
+	Multisig   DecodedMultisig
+	Spawn      DecodedSpawn
+	// Opaque is set instead of the decoded fields when the transaction is not supported
+	Opaque *OpaqueTransaction
+	Type   int
+	// Unsigned is the transaction without its signatures, what they sign after the genesis id
+	Unsigned []byte
+}
+
+// DecodedSpawn is a transaction that spawns an account.
+type DecodedSpawn interface {
+	// GetSpawned returns the address of the spawned account, computed from its template and arguments.
+	GetSpawned() types.Address
+}
+
+// DecodedMultisig is the spawn of an account whose transactions need several signatures.
+type DecodedMultisig interface {
+	GetRequired() uint8
 }
 
 type DecodedVault interface {
@@ -0,0 +1,64 @@
+package transaction
+
+import (
+	"errors"
+
+	"github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+)
+
+var (
+	// ErrUnsupported is returned by the parser of a version for a template or a method it has no decoder for.
+	ErrUnsupported = errors.New("unsupported transaction")
+	// ErrDecoderPanic is returned for bytes that made a decoder panic, they are malformed as well.
+	ErrDecoderPanic = errors.New("decoder panicked")
+)
+
+// OpaqueTransaction is a transaction of a version, template or method without a decoder. Only its header is
+// decoded, what follows it is kept as it is.
+type OpaqueTransaction struct {
+	Version   uint8
+	Principal types.Address
+	Method    uint8
+	// Template is set for a spawn
+	Template *core.Address
+	// Body is the payload and the signatures, or everything after the version when the version is unknown
+	Body []byte
+	// Reason tells what the parser does not support
+	Reason string
+}
+
+// GetType returns transaction type.
+func (t *OpaqueTransaction) GetType() uint8 {
+	return TypeOpaque
+}
+
+// GetAmount returns 0, the arguments are not decoded.
+func (t *OpaqueTransaction) GetAmount() uint64 {
+	return 0
+}
+
+// GetCounter returns 0, the payload is not decoded.
+func (t *OpaqueTransaction) GetCounter() uint64 {
+	return 0
+}
+
+// GetReceiver returns an empty address, the arguments are not decoded.
+func (t *OpaqueTransaction) GetReceiver() types.Address {
+	return types.Address{}
+}
+
+// GetGasPrice returns 0, the payload is not decoded.
+func (t *OpaqueTransaction) GetGasPrice() uint64 {
+	return 0
+}
+
+// GetPrincipal return address which spend gas, empty when the version is unknown.
+func (t *OpaqueTransaction) GetPrincipal() types.Address {
+	return t.Principal
+}
+
+// GetPublicKeys returns nil.
+func (t *OpaqueTransaction) GetPublicKeys() [][]byte {
+	return nil
+}
@@ -1,5 +1,13 @@
 package transaction
 
+import (
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
+)
+
 const (
 	// TypeSpawn is type of the spawn transaction.
 	TypeSpawn = 1 + iota
@@ -12,4 +20,62 @@ const (
 	TypeVestingSpawn
 	TypeVaultSpawn
 	TypeDrainVault
+	// TypeOpaque is the type of a transaction the parser has no decoder for.
+	TypeOpaque
+)
+
+// Names of the templates.
+const (
+	TemplateWallet   = "wallet"
+	TemplateMultisig = "multisig"
+	TemplateVesting  = "vesting"
+	TemplateVault    = "vault"
 )
+
+// TemplateName returns the name of a template address, empty when it isn't known.
+func TemplateName(address core.Address) string {
+	switch address {
+	case wallet.TemplateAddress:
+		return TemplateWallet
+	case multisig.TemplateAddress:
+		return TemplateMultisig
+	case vesting.TemplateAddress:
+		return TemplateVesting
+	case vault.TemplateAddress:
+		return TemplateVault
+	default:
+		return ""
+	}
+}
+
+// TemplateAddress returns the address of a template from its name.
+func TemplateAddress(name string) (core.Address, bool) {
+	switch name {
+	case TemplateWallet:
+		return wallet.TemplateAddress, true
+	case TemplateMultisig:
+		return multisig.TemplateAddress, true
+	case TemplateVesting:
+		return vesting.TemplateAddress, true
+	case TemplateVault:
+		return vault.TemplateAddress, true
+	default:
+		return core.Address{}, false
+	}
+}
+
+// SpawnedTemplate returns the name of the template a spawn transaction spawns, empty for the other types.
+func SpawnedTemplate(txType int) string {
+	switch txType {
+	case TypeSpawn:
+		return TemplateWallet
+	case TypeMultisigSpawn:
+		return TemplateMultisig
+	case TypeVestingSpawn:
+		return TemplateVesting
+	case TypeVaultSpawn:
+		return TemplateVault
+	default:
+		return ""
+	}
+}
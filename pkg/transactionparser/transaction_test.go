@@ -0,0 +1,181 @@
+package transactionparser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/spacemeshos/go-scale"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+)
+
+// corpusEntry is the part of an entry of testdata/corpus.jsonl the tests check, scripts/parsercorpus checks the rest.
+type corpusEntry struct {
+	Name  string `json:"name"`
+	Raw   string `json:"raw"`
+	Error bool   `json:"error,omitempty"`
+	Type  int    `json:"type"`
+}
+
+func readCorpus(t testing.TB) []*corpusEntry {
+	t.Helper()
+	file, err := os.Open("testdata/corpus.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []*corpusEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := &corpusEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			t.Fatalf("corpus line %d: %v", len(entries)+1, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func TestParseCorpus(t *testing.T) {
+	for _, e := range readCorpus(t) {
+		raw, err := hex.DecodeString(e.Raw)
+		if err != nil {
+			t.Fatalf("%s: raw is not hex: %v", e.Name, err)
+		}
+		txData, err := Parse(raw)
+		if e.Error {
+			if err == nil {
+				t.Errorf("%s: parsed bytes that must be rejected", e.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", e.Name, err)
+			continue
+		}
+		if int(txData.Type) != e.Type {
+			t.Errorf("%s: type %d, want %d", e.Name, txData.Type, e.Type)
+		}
+	}
+}
+
+// header encodes the start of a transaction, the template is only written for a spawn.
+func header(t *testing.T, version uint8, principal core.Address, method uint8, template *core.Address) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := scale.NewEncoder(&buf)
+	if _, err := scale.EncodeCompact8(encoder, version); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := principal.EncodeScale(encoder); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scale.EncodeCompact8(encoder, method); err != nil {
+		t.Fatal(err)
+	}
+	if template != nil {
+		if _, err := template.EncodeScale(encoder); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestParseUnsupported(t *testing.T) {
+	principal := core.Address{1, 2, 3}
+	unknownTemplate := core.Address{0xff, 0xee}
+	body := []byte{4, 5, 6, 7}
+
+	tests := []struct {
+		name     string
+		version  uint8
+		method   uint8
+		template *core.Address
+		// the raw bytes are only the version and the body when the version is unknown
+		versionOnly bool
+	}{
+		{name: "unknown method", method: 18},
+		{name: "unknown method above the known ones", method: 200},
+		{name: "spawn of an unknown template", method: core.MethodSpawn, template: &unknownTemplate},
+		{name: "unknown version", version: 1, versionOnly: true},
+		{name: "unknown version above the known ones", version: 60, versionOnly: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var raw []byte
+			if test.versionOnly {
+				raw = append([]byte{test.version << 2}, body...)
+			} else {
+				raw = append(header(t, test.version, principal, test.method, test.template), body...)
+			}
+
+			txData, err := Parse(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if txData.Type != transaction.TypeOpaque || txData.Opaque == nil {
+				t.Fatalf("type %d, want opaque", txData.Type)
+			}
+			if txData.Tx.GetType() != transaction.TypeOpaque {
+				t.Errorf("tx type %d, want opaque", txData.Tx.GetType())
+			}
+			opaque := txData.Opaque
+			if opaque.Version != test.version {
+				t.Errorf("version %d, want %d", opaque.Version, test.version)
+			}
+			if !bytes.Equal(opaque.Body, body) {
+				t.Errorf("body %x, want %x", opaque.Body, body)
+			}
+			if opaque.Reason == "" {
+				t.Error("no reason")
+			}
+			if test.versionOnly {
+				return
+			}
+			if opaque.Principal != principal {
+				t.Errorf("principal %s, want %s", opaque.Principal, principal)
+			}
+			if opaque.Method != test.method {
+				t.Errorf("method %d, want %d", opaque.Method, test.method)
+			}
+			if test.template != nil && (opaque.Template == nil || *opaque.Template != *test.template) {
+				t.Errorf("template %v, want %s", opaque.Template, test.template)
+			}
+			if test.template == nil && opaque.Template != nil {
+				t.Errorf("template %s of a method other than spawn", opaque.Template)
+			}
+		})
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, e := range readCorpus(f) {
+		raw, err := hex.DecodeString(e.Raw)
+		if err != nil {
+			f.Fatalf("%s: raw is not hex: %v", e.Name, err)
+		}
+		f.Add(raw)
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		txData, err := Parse(raw)
+		if err == nil && (txData == nil || txData.Tx == nil) {
+			t.Fatalf("no transaction and no error for %x", raw)
+		}
+		if err != nil && txData != nil {
+			t.Fatalf("transaction with error %v for %x", err, raw)
+		}
+	})
+}
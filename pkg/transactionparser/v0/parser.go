@@ -6,11 +6,7 @@ import (
 	"github.com/spacemeshos/go-spacemesh/codec"
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-spacemesh/genvm/core"
-	"github.com/spacemeshos/go-spacemesh/genvm/registry"
 	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
-	"github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
 
 	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
 )
@@ -20,72 +16,37 @@ const (
 	methodSend  = 16
 )
 
-// ParseTransaction parses a transaction encoded in version 0.
+// ParseTransaction parses a transaction encoded in version 0 with the decoder registered for its template,
+// for a spawn, or for its method.
 // possible two types of transaction:
 // 1. spawn transaction - `&sdk.TxVersion, &principal, &sdk.MethodSpawn, &wallet.TemplateAddress, &wallet.SpawnPayload`
 // 2. spend transaction - `&sdk.TxVersion, &principal, &sdk.MethodSpend, &wallet.SpendPayload.
 // every transaction can be multisig also.
+// It returns transaction.ErrUnsupported when there is no decoder.
 func ParseTransaction(rawTx *bytes.Buffer, method uint8, template *core.Address) (*transaction.TransactionData, error) {
-	reg := registry.New()
-	wallet.Register(reg)
-	multisig.Register(reg)
-	vesting.Register(reg)
-	vault.Register(reg)
-
-	txData := &transaction.TransactionData{}
-	switch method {
-	case methodSpawn:
-		switch *template {
-		case wallet.TemplateAddress:
-			var spawnTx SpawnTransaction
-			if _, err := codec.DecodeFrom(rawTx, &spawnTx); err != nil {
-				return nil, err
-			}
-
-			txData.Tx = &spawnTx
-			txData.Type = transaction.TypeSpawn
-		case multisig.TemplateAddress:
-			var spawnMultisigTx SpawnMultisigTransaction
-			if _, err := codec.DecodeFrom(rawTx, &spawnMultisigTx); err != nil {
-				return nil, err
-			}
-			txData.Tx = &spawnMultisigTx
-			txData.Type = transaction.TypeMultisigSpawn
-		case vault.TemplateAddress:
-			var spawnVaultTx SpawnVaultTransaction
-			if _, err := codec.DecodeFrom(rawTx, &spawnVaultTx); err != nil {
-				return nil, err
-			}
-			txData.Tx = &spawnVaultTx
-			txData.Vault = &spawnVaultTx
-			txData.Type = transaction.TypeVaultSpawn
-		case vesting.TemplateAddress:
-			var spawnMultisigTx SpawnMultisigTransaction
-			if _, err := codec.DecodeFrom(rawTx, &spawnMultisigTx); err != nil {
-				return nil, err
-			}
-			txData.Tx = &spawnMultisigTx
-			txData.Type = transaction.TypeVestingSpawn
-		}
-	case methodSend:
-		var spendTx SpendTransaction
-		if _, err := codec.DecodeFrom(rawTx, &spendTx); err != nil {
-			return nil, err
+	var decoder Decoder
+	if method == methodSpawn {
+		decoder = spawnDecoders[*template]
+		if decoder == nil {
+			return nil, fmt.Errorf("%w: spawn of template %s", transaction.ErrUnsupported, template.String())
 		}
-		txData.Tx = &spendTx
-		txData.Type = transaction.TypeSpend
-	case vesting.MethodDrainVault:
-		var drainVaultTx DrainVaultTransaction
-		if _, err := codec.DecodeFrom(rawTx, &drainVaultTx); err != nil {
-			return nil, err
+	} else {
+		decoder = methodDecoders[method]
+		if decoder == nil {
+			return nil, fmt.Errorf("%w: method %d", transaction.ErrUnsupported, method)
 		}
-		txData.Tx = &drainVaultTx
-		txData.Vault = &drainVaultTx
-		txData.Type = transaction.TypeDrainVault
-	default:
-		return nil, fmt.Errorf("%w: unsupported method %d", core.ErrMalformed, method)
 	}
 
+	raw := rawTx.Bytes()
+	txData := &transaction.TransactionData{}
+	if err := decoder(rawTx, txData); err != nil {
+		return nil, err
+	}
+	if txData.Tx == nil {
+		return nil, fmt.Errorf("%w: decoder of method %d did not decode the transaction", core.ErrMalformed, method)
+	}
+	txData.Unsigned = raw[:len(raw)-rawTx.Len()]
+
 	// decode signature or signatures
 	if rawTx.Len() <= types.EdSignatureSize {
 		var sig core.Signature
@@ -0,0 +1,100 @@
+package v0
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/spacemeshos/go-spacemesh/codec"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
+
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+)
+
+// Decoder decodes a transaction from its raw bytes up to the signatures and sets its fields in txData, Tx and
+// Type at least.
+type Decoder func(rawTx *bytes.Buffer, txData *transaction.TransactionData) error
+
+var (
+	spawnDecoders  = map[core.Address]Decoder{}
+	methodDecoders = map[uint8]Decoder{}
+)
+
+// RegisterSpawn registers the decoder of the spawns of a template, it replaces the one already registered.
+// Registering is not safe while transactions are parsed, templates register at init.
+func RegisterSpawn(template core.Address, decoder Decoder) {
+	spawnDecoders[template] = decoder
+}
+
+// RegisterMethod registers the decoder of a method other than spawn. The template of the principal is not
+// part of a transaction so a method decodes the same for all the templates that have it.
+func RegisterMethod(method uint8, decoder Decoder) {
+	if method == methodSpawn {
+		panic(fmt.Sprintf("method %d is registered by template with RegisterSpawn", method))
+	}
+	methodDecoders[method] = decoder
+}
+
+func init() {
+	RegisterSpawn(wallet.TemplateAddress, func(rawTx *bytes.Buffer, txData *transaction.TransactionData) error {
+		var spawnTx SpawnTransaction
+		if _, err := codec.DecodeFrom(rawTx, &spawnTx); err != nil {
+			return err
+		}
+		txData.Tx = &spawnTx
+		txData.Spawn = &spawnTx
+		txData.Type = transaction.TypeSpawn
+		return nil
+	})
+	RegisterSpawn(multisig.TemplateAddress, decodeSpawnMultisig(transaction.TypeMultisigSpawn))
+	RegisterSpawn(vesting.TemplateAddress, decodeSpawnMultisig(transaction.TypeVestingSpawn))
+	RegisterSpawn(vault.TemplateAddress, func(rawTx *bytes.Buffer, txData *transaction.TransactionData) error {
+		var spawnVaultTx SpawnVaultTransaction
+		if _, err := codec.DecodeFrom(rawTx, &spawnVaultTx); err != nil {
+			return err
+		}
+		txData.Tx = &spawnVaultTx
+		txData.Vault = &spawnVaultTx
+		txData.Spawn = &spawnVaultTx
+		txData.Type = transaction.TypeVaultSpawn
+		return nil
+	})
+
+	RegisterMethod(methodSend, func(rawTx *bytes.Buffer, txData *transaction.TransactionData) error {
+		var spendTx SpendTransaction
+		if _, err := codec.DecodeFrom(rawTx, &spendTx); err != nil {
+			return err
+		}
+		txData.Tx = &spendTx
+		txData.Type = transaction.TypeSpend
+		return nil
+	})
+	RegisterMethod(vesting.MethodDrainVault, func(rawTx *bytes.Buffer, txData *transaction.TransactionData) error {
+		var drainVaultTx DrainVaultTransaction
+		if _, err := codec.DecodeFrom(rawTx, &drainVaultTx); err != nil {
+			return err
+		}
+		txData.Tx = &drainVaultTx
+		txData.Vault = &drainVaultTx
+		txData.Type = transaction.TypeDrainVault
+		return nil
+	})
+}
+
+// decodeSpawnMultisig decodes the spawn of a multisig or of a vesting account, they take the same arguments.
+func decodeSpawnMultisig(txType int) Decoder {
+	return func(rawTx *bytes.Buffer, txData *transaction.TransactionData) error {
+		var spawnMultisigTx SpawnMultisigTransaction
+		if _, err := codec.DecodeFrom(rawTx, &spawnMultisigTx); err != nil {
+			return err
+		}
+		txData.Tx = &spawnMultisigTx
+		txData.Multisig = &spawnMultisigTx
+		txData.Spawn = &spawnMultisigTx
+		txData.Type = txType
+		return nil
+	}
+}
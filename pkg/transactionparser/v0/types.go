@@ -1,10 +1,13 @@
 package v0
 
 import (
+	"fmt"
+
 	"github.com/spacemeshos/go-spacemesh/common/types"
 	"github.com/spacemeshos/go-scale"
 	"github.com/spacemeshos/go-spacemesh/genvm/core"
 	"github.com/spacemeshos/go-spacemesh/genvm/templates/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
 	"github.com/spacemeshos/go-spacemesh/genvm/templates/wallet"
 	"github.com/spacemeshos/go-spacemesh/hash"
 
@@ -36,6 +39,28 @@ func ComputePrincipal(template core.Address, args scale.Encodable) types.Address
 	return types.GenerateAddress(hs[12:])
 }
 
+// TemplatePrincipal computes the address of an account from its template and the arguments of its spawn, the
+// public keys and, for multisig and vesting accounts, how many of them sign.
+func TemplatePrincipal(template core.Address, required uint8, publicKeys [][]byte) (types.Address, error) {
+	switch template {
+	case wallet.TemplateAddress:
+		if len(publicKeys) != 1 {
+			return types.Address{}, fmt.Errorf("a wallet has 1 public key, not %d", len(publicKeys))
+		}
+		var args SpawnArguments
+		copy(args.PublicKey[:], publicKeys[0])
+		return ComputePrincipal(template, &args), nil
+	case multisig.TemplateAddress, vesting.TemplateAddress:
+		args := SpawnMultisigArguments{Required: required, PublicKeys: make([]PublicKey, len(publicKeys))}
+		for i := range publicKeys {
+			copy(args.PublicKeys[i][:], publicKeys[i])
+		}
+		return ComputePrincipal(template, &args), nil
+	default:
+		return types.Address{}, fmt.Errorf("%w: principal of template %s", transaction.ErrUnsupported, template.String())
+	}
+}
+
 // SpawnTransaction initial transaction for wallet.
 type SpawnTransaction struct {
 	Type      uint8
@@ -79,6 +104,11 @@ func (t *SpawnTransaction) GetReceiver() types.Address {
 	return ComputePrincipal(wallet.TemplateAddress, &args)
 }
 
+// GetSpawned returns the address of the spawned wallet.
+func (t *SpawnTransaction) GetSpawned() types.Address {
+	return ComputePrincipal(t.Template, &t.Payload.Arguments)
+}
+
 // GetGasPrice returns gas price of the transaction.
 func (t *SpawnTransaction) GetGasPrice() uint64 {
 	return t.Payload.GasPrice
@@ -140,6 +170,11 @@ func (t *SpawnMultisigTransaction) GetReceiver() types.Address {
 	return ComputePrincipal(multisig.TemplateAddress, &args)
 }
 
+// GetSpawned returns the address of the spawned account, a multisig or a vesting one.
+func (t *SpawnMultisigTransaction) GetSpawned() types.Address {
+	return ComputePrincipal(t.Template, &t.Payload.Arguments)
+}
+
 // GetGasPrice returns gas price of the transaction.
 func (t *SpawnMultisigTransaction) GetGasPrice() uint64 {
 	return t.Payload.GasPrice
@@ -159,6 +194,11 @@ func (t *SpawnMultisigTransaction) GetPublicKeys() [][]byte {
 	return result
 }
 
+// GetRequired returns the number of signatures the transactions of the multisig account need.
+func (t *SpawnMultisigTransaction) GetRequired() uint8 {
+	return t.Payload.Arguments.Required
+}
+
 // SpendTransaction coin transfer transaction. also includes multisig.
 type SpendTransaction struct {
 	Type      uint8
@@ -260,6 +300,11 @@ func (t *SpawnVaultTransaction) GetReceiver() types.Address {
 	return types.Address{}
 }
 
+// GetSpawned returns the address of the spawned vault.
+func (t *SpawnVaultTransaction) GetSpawned() types.Address {
+	return ComputePrincipal(t.Template, &t.Payload.Arguments)
+}
+
 // GetGasPrice returns gas price of the transaction.
 func (t *SpawnVaultTransaction) GetGasPrice() uint64 {
 	return t.Payload.GasPrice
@@ -0,0 +1,42 @@
+package transactionparser
+
+import (
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	"github.com/spacemeshos/go-spacemesh/genvm/core"
+
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+)
+
+// verifyOptions are the ZIP-215 rules the node verifies transactions with, the default ones reject signatures it accepts.
+var verifyOptions = &ed25519.Options{Verify: ed25519.VerifyOptionsZIP_215}
+
+// VerifySignatures verifies the signatures of a transaction with the public keys of its principal, as the
+// templates do, and returns how many of the keys signed it. A multisig part signs with the key at its index
+// in the order of the spawn, a single signature with the only key. genesisID prefixes what is signed.
+func VerifySignatures(txData *transaction.TransactionData, genesisID []byte, publicKeys [][]byte) int {
+	if txData.Unsigned == nil {
+		return 0
+	}
+	body := core.SigningBody(genesisID, txData.Unsigned)
+	if txData.Sig != nil {
+		if len(publicKeys) == 1 && len(publicKeys[0]) == ed25519.PublicKeySize &&
+			ed25519.VerifyWithOptions(publicKeys[0], body, txData.Sig[:], verifyOptions) {
+			return 1
+		}
+		return 0
+	}
+	if txData.Signatures == nil {
+		return 0
+	}
+
+	signed := map[uint8]bool{}
+	for _, part := range *txData.Signatures {
+		if int(part.Ref) >= len(publicKeys) || signed[part.Ref] || len(publicKeys[part.Ref]) != ed25519.PublicKeySize {
+			continue
+		}
+		if ed25519.VerifyWithOptions(publicKeys[part.Ref], body, part.Sig[:], verifyOptions) {
+			signed[part.Ref] = true
+		}
+	}
+	return len(signed)
+}
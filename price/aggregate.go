@@ -0,0 +1,50 @@
+package price
+
+import (
+	"math"
+	"sort"
+)
+
+// sourceQuote is the price of a currency fetched from a provider.
+type sourceQuote struct {
+	source  string
+	price   float64
+	fetched int64
+}
+
+// aggregate returns the median of quotes after rejecting the ones further than maxDeviation, a fraction, from
+// the median of all of them, with the kept and rejected quotes. Two quotes can't tell which one is off, they
+// are always kept.
+func aggregate(quotes []*sourceQuote, maxDeviation float64) (float64, []*sourceQuote, []*sourceQuote) {
+	if len(quotes) < 3 {
+		return median(quotes), quotes, nil
+	}
+	center := median(quotes)
+	kept := make([]*sourceQuote, 0, len(quotes))
+	rejected := make([]*sourceQuote, 0)
+	for _, quote := range quotes {
+		if math.Abs(quote.price-center) <= maxDeviation*center {
+			kept = append(kept, quote)
+		} else {
+			rejected = append(rejected, quote)
+		}
+	}
+	if len(kept) == 0 {
+		// the quotes are spread too far apart to tell the outliers, the median of all is the best guess
+		return center, quotes, nil
+	}
+	return median(kept), kept, rejected
+}
+
+func median(quotes []*sourceQuote) float64 {
+	prices := make([]float64, len(quotes))
+	for i, quote := range quotes {
+		prices[i] = quote.price
+	}
+	sort.Float64s(prices)
+	middle := len(prices) / 2
+	if len(prices)%2 == 0 {
+		return (prices[middle-1] + prices[middle]) / 2
+	}
+	return prices[middle]
+}
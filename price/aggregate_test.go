@@ -0,0 +1,53 @@
+package price
+
+import (
+	"slices"
+	"testing"
+)
+
+func quotes(prices ...float64) []*sourceQuote {
+	quotes := make([]*sourceQuote, len(prices))
+	for i, price := range prices {
+		quotes[i] = &sourceQuote{source: string(rune('a' + i)), price: price}
+	}
+	return quotes
+}
+
+func sources(quotes []*sourceQuote) []string {
+	names := make([]string, len(quotes))
+	for i, quote := range quotes {
+		names[i] = quote.source
+	}
+	return names
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		name     string
+		quotes   []*sourceQuote
+		price    float64
+		kept     []string
+		rejected []string
+	}{
+		{name: "one quote", quotes: quotes(2), price: 2, kept: []string{"a"}},
+		{name: "two quotes far apart are kept", quotes: quotes(1, 3), price: 2, kept: []string{"a", "b"}},
+		{name: "three close quotes", quotes: quotes(1.00, 1.05, 0.98), price: 1, kept: []string{"a", "b", "c"}},
+		{name: "one outlier of three", quotes: quotes(1.00, 1.02, 5), price: 1.01, kept: []string{"a", "b"}, rejected: []string{"c"}},
+		{name: "one low outlier of four", quotes: quotes(2, 2.04, 0.1, 1.96), price: 2, kept: []string{"a", "b", "d"}, rejected: []string{"c"}},
+		{name: "quotes spread apart are all kept", quotes: quotes(1, 2, 10, 20), price: 6, kept: []string{"a", "b", "c", "d"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			price, kept, rejected := aggregate(test.quotes, 0.1)
+			if diff := price - test.price; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("price %f, want %f", price, test.price)
+			}
+			if !slices.Equal(sources(kept), test.kept) {
+				t.Errorf("kept %v, want %v", sources(kept), test.kept)
+			}
+			if !slices.Equal(sources(rejected), test.rejected) {
+				t.Errorf("rejected %v, want %v", sources(rejected), test.rejected)
+			}
+		})
+	}
+}
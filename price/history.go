@@ -0,0 +1,171 @@
+package price
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// maxQuoteAge is how old the latest quote before a time can be to still be its price, a day and a half covers
+// the daily closes when there are no snapshots.
+const maxQuoteAge = 36 * 60 * 60
+
+// the source of the daily closes backfilled from coinpaprika
+const dailySource = "coinpaprika-daily"
+
+// days of the daily closes fetched by request
+const backfillChunkDays = 30
+
+// the daily candles of smh on coinpaprika, a var so the tests serve their own
+var coinpaprikaHistoricalURL = "https://api.coinpaprika.com/v1/coins/smh-spacemesh/ohlcv/historical"
+
+// History serves the usd price of smh at past times from the quotes of the prices collection.
+type History struct {
+	readDB database.ReadDB
+}
+
+func NewHistory(readDB database.ReadDB) *History {
+	return &History{readDB: readDB}
+}
+
+// PriceAt returns the usd price at a unix time in seconds, -1 when it is unknown.
+func (h *History) PriceAt(timestamp int64) float64 {
+	return h.PricesAt([]int64{timestamp})[0]
+}
+
+// PricesAt returns the usd price at each of timestamps, -1 when it is unknown, reading the quotes once.
+func (h *History) PricesAt(timestamps []int64) []float64 {
+	prices := make([]float64, len(timestamps))
+	for i := range prices {
+		prices[i] = -1
+	}
+	if len(timestamps) == 0 {
+		return prices
+	}
+	from, to := timestamps[0], timestamps[0]
+	for _, timestamp := range timestamps {
+		from = min(from, timestamp)
+		to = max(to, timestamp)
+	}
+	quotes, err := h.readDB.GetPrices(from-maxQuoteAge, to)
+	if err != nil {
+		slog.Error("Failed to get prices", "from", from, "to", to, "error", err)
+		return prices
+	}
+	for i, timestamp := range timestamps {
+		if quote := latestQuote(quotes, timestamp); quote != nil {
+			prices[i] = quote.Price
+		}
+	}
+	return prices
+}
+
+// Series returns the price of every interval between from and to, unix seconds, the last quote at the end of
+// each interval. The intervals without a recent enough quote are left out.
+func (h *History) Series(from int64, to int64, interval int64) ([]*types.PricePoint, error) {
+	quotes, err := h.readDB.GetPrices(from-maxQuoteAge, to)
+	if err != nil {
+		return nil, err
+	}
+	points := make([]*types.PricePoint, 0)
+	for start := from - from%interval; start <= to; start += interval {
+		if quote := latestQuote(quotes, min(start+interval-1, to)); quote != nil {
+			points = append(points, &types.PricePoint{Timestamp: start, Price: quote.Price})
+		}
+	}
+	return points, nil
+}
+
+// latestQuote returns the latest of the ordered quotes at or before timestamp, nil when it is older than
+// maxQuoteAge.
+func latestQuote(quotes []*types.PriceDoc, timestamp int64) *types.PriceDoc {
+	next := sort.Search(len(quotes), func(i int) bool {
+		return quotes[i].Timestamp > timestamp
+	})
+	if next == 0 || timestamp-quotes[next-1].Timestamp > maxQuoteAge {
+		return nil
+	}
+	return quotes[next-1]
+}
+
+// backfill saves the daily closes from the day after the last saved one, or since, until yesterday. It returns
+// between chunks once stop is closed.
+func backfill(readDB database.ReadDB, writeDB database.WriteDB, since int64, stop <-chan struct{}) {
+	last, err := readDB.GetLastPrice(dailySource)
+	if err != nil {
+		slog.Error("Failed to get the last daily close", "error", err)
+		return
+	}
+	start := time.Unix(since, 0).UTC().Truncate(24 * time.Hour)
+	if last.Timestamp > 0 {
+		start = time.Unix(last.Timestamp, 0).UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	}
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+
+	saved := 0
+	for ; !start.After(yesterday); start = start.AddDate(0, 0, backfillChunkDays) {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		end := start.AddDate(0, 0, backfillChunkDays-1)
+		if end.After(yesterday) {
+			end = yesterday
+		}
+		closes, err := fetchCoinpaprikaCloses(start, end)
+		if err != nil {
+			// the next backfill starts after the last saved close, a failed old chunk isn't fetched again
+			metrics.PriceFetchFailures.WithLabelValues(dailySource).Inc()
+			slog.Warn("Failed to fetch daily closes", "start", start.Format(time.DateOnly), "end", end.Format(time.DateOnly), "error", err)
+			continue
+		}
+		if err := writeDB.SavePrices(closes); err != nil {
+			slog.Error("Failed to save daily closes", "start", start.Format(time.DateOnly), "error", err)
+			return
+		}
+		saved += len(closes)
+	}
+	if saved > 0 {
+		slog.Info("Backfilled daily closes", "closes", saved)
+	}
+}
+
+func fetchCoinpaprikaCloses(start time.Time, end time.Time) ([]*types.PriceDoc, error) {
+	slog.Debug("Fetch daily closes from coinpaprika", "start", start, "end", end)
+	resp, err := http.Get(fmt.Sprintf("%s?start=%s&end=%s", coinpaprikaHistoricalURL,
+		start.Format(time.DateOnly), end.Format(time.DateOnly)))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("coinpaprika returned %d", resp.StatusCode)
+	}
+
+	var candles []*PriceCandle
+	if err := json.NewDecoder(resp.Body).Decode(&candles); err != nil {
+		return nil, err
+	}
+	closes := make([]*types.PriceDoc, len(candles))
+	for i, candle := range candles {
+		closes[i] = &types.PriceDoc{
+			Timestamp: candle.TimeClose.Unix(),
+			Price:     candle.Close,
+			Source:    dailySource,
+		}
+	}
+	return closes, nil
+}
+
+type PriceCandle struct {
+	TimeClose time.Time `json:"time_close"`
+	Close     float64   `json:"close"`
+}
@@ -0,0 +1,156 @@
+package price
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// a utc midnight
+const day0 = 1700006400
+
+func testDB(t *testing.T) *database.SQLiteDB {
+	t.Helper()
+	db, err := database.NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "prices.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	return db
+}
+
+func TestHistory(t *testing.T) {
+	db := testDB(t)
+	quotes := []*types.PriceDoc{
+		{Timestamp: day0, Price: 1, Source: dailySource},
+		{Timestamp: day0 + 3600, Price: 2, Source: "xt"},
+		{Timestamp: day0 + 7200, Price: 3, Source: "xt"},
+		{Timestamp: day0 + 5*86400, Price: 5, Source: "xt"},
+	}
+	if err := db.SavePrices(quotes); err != nil {
+		t.Fatal(err)
+	}
+	// a quote saved again at the same time replaces the first one
+	if err := db.SavePrices([]*types.PriceDoc{{Timestamp: day0 + 3600, Price: 2.5, Source: "median"}}); err != nil {
+		t.Fatal(err)
+	}
+	history := NewHistory(db)
+
+	timestamps := []int64{day0 + 5000, day0 - 1, day0, day0 + 7200 + maxQuoteAge, day0 + 7201 + maxQuoteAge, day0 + 6*86400}
+	want := []float64{2.5, -1, 1, 3, -1, 5}
+	if prices := history.PricesAt(timestamps); !slices.Equal(prices, want) {
+		t.Errorf("prices %v, want %v", prices, want)
+	}
+	if price := history.PriceAt(day0 + 3599); price != 1 {
+		t.Errorf("price before the first snapshot %f, want the daily close 1", price)
+	}
+
+	points, err := history.Series(day0+10, day0+3*3600-1, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 3 || points[0].Timestamp != day0 || points[0].Price != 1 || points[1].Price != 2.5 || points[2].Price != 3 {
+		t.Errorf("hourly points %v", points)
+	}
+	// the days without a recent enough quote are left out
+	points, err = history.Series(day0, day0+6*86400-1, 86400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timestampsOf := make([]int64, len(points))
+	for i, point := range points {
+		timestampsOf[i] = point.Timestamp
+	}
+	if !slices.Equal(timestampsOf, []int64{day0, day0 + 5*86400}) || points[0].Price != 3 || points[1].Price != 5 {
+		t.Errorf("daily points at %v", timestampsOf)
+	}
+}
+
+// candleServer serves a daily candle for every day of the requested range, the close price is the day of the month.
+func candleServer(t *testing.T, status int) *atomic.Int64 {
+	t.Helper()
+	requests := &atomic.Int64{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		start, err := time.Parse(time.DateOnly, r.URL.Query().Get("start"))
+		if err != nil {
+			t.Errorf("start %q: %v", r.URL.Query().Get("start"), err)
+		}
+		end, err := time.Parse(time.DateOnly, r.URL.Query().Get("end"))
+		if err != nil {
+			t.Errorf("end %q: %v", r.URL.Query().Get("end"), err)
+		}
+		var candles []*PriceCandle
+		for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+			candles = append(candles, &PriceCandle{TimeClose: date.Add(24*time.Hour - time.Second), Close: float64(date.Day())})
+		}
+		json.NewEncoder(w).Encode(candles)
+	}))
+	t.Cleanup(server.Close)
+	url := coinpaprikaHistoricalURL
+	coinpaprikaHistoricalURL = server.URL
+	t.Cleanup(func() { coinpaprikaHistoricalURL = url })
+	return requests
+}
+
+func TestBackfill(t *testing.T) {
+	db := testDB(t)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -40)
+
+	// a stopped backfill doesn't fetch
+	requests := candleServer(t, http.StatusOK)
+	stop := make(chan struct{})
+	close(stop)
+	backfill(db, db, since.Unix(), stop)
+	if requests.Load() != 0 {
+		t.Fatalf("%d requests after the stop", requests.Load())
+	}
+
+	// 40 days until yesterday, in chunks of 30
+	backfill(db, db, since.Unix(), make(chan struct{}))
+	closes, err := db.GetPrices(since.Unix(), today.Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(closes) != 40 || requests.Load() != 2 {
+		t.Fatalf("%d closes in %d requests, want 40 in 2", len(closes), requests.Load())
+	}
+	last := closes[len(closes)-1]
+	if last.Timestamp != today.Unix()-1 || last.Source != dailySource || last.Price != float64(today.AddDate(0, 0, -1).Day()) {
+		t.Errorf("last close %+v, want the one of yesterday", last)
+	}
+
+	// the next backfill starts after the last close, there is nothing to fetch until tomorrow
+	backfill(db, db, since.Unix(), make(chan struct{}))
+	if requests.Load() != 2 {
+		t.Errorf("%d requests, want no new one", requests.Load())
+	}
+}
+
+func TestBackfillFailure(t *testing.T) {
+	db := testDB(t)
+	requests := candleServer(t, http.StatusTooManyRequests)
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -3)
+
+	// a failed chunk is skipped, nothing is saved
+	backfill(db, db, since.Unix(), make(chan struct{}))
+	if requests.Load() != 1 {
+		t.Errorf("%d requests, want 1", requests.Load())
+	}
+	if last, err := db.GetLastPrice(dailySource); err != nil || last.Timestamp != 0 {
+		t.Errorf("last close %+v after a failure: %v", last, err)
+	}
+}
@@ -1,153 +1,314 @@
 package price
 
 import (
-	"encoding/json"
 	"fmt"
-	"github.com/swarmbit/spacemesh-state-api/config"
-	"net/http"
+	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"time"
-	"strconv"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
 )
 
-const priceKey = "priceKey"
+// usd is always quoted, the history and the usd values are in it
+const usdCurrency = "USD"
+
+var defaultCurrencies = []string{"USD", "EUR", "BTC"}
+
+const defaultMaxDeviation = 0.1
 
 type PriceResolver struct {
-	priceMap *sync.Map
-	isXT     bool
+	// priceMap has the PriceCache of every currency
+	priceMap     *sync.Map
+	providers    []PriceProvider
+	currencies   []string
+	maxDeviation float64
+	maxAge       int64
+	// quotes are the last quotes of every provider, only the fetches use them
+	quotes map[string]*providerQuote
+	// history is nil without a db, the prices of the past are unknown then
+	history *History
+	writeDB database.WriteDB
+	stop    chan struct{}
+	done    sync.WaitGroup
 }
 
-func NewPriceResolver(config *config.Config) *PriceResolver {
+// NewPriceResolver fetches the price from the providers of the config periodically. With a db it saves every
+// fetched usd price to the prices collection and backfills the daily closes since the genesis of the first
+// network, unless the history is disabled.
+func NewPriceResolver(config *config.Config, readDB database.ReadDB, writeDB database.WriteDB) (*PriceResolver, error) {
 	fetchTime := 15
-	isXT := false
+	keepHistory := readDB != nil && writeDB != nil
+	priceResolver := &PriceResolver{
+		priceMap:     &sync.Map{},
+		currencies:   defaultCurrencies,
+		maxDeviation: defaultMaxDeviation,
+		quotes:       make(map[string]*providerQuote),
+		stop:         make(chan struct{}),
+	}
+	providers, err := NewProviders(nil)
 	if config.Price != nil {
 		if config.Price.RefreshTime > 0 {
 			fetchTime = config.Price.RefreshTime
 		}
-		if strings.ToLower(config.Price.Provider) == "xt" {
-			isXT = true
+		if config.Price.DisableHistory {
+			keepHistory = false
+		}
+		if len(config.Price.Currencies) > 0 {
+			priceResolver.currencies = currencies(config.Price.Currencies)
 		}
+		if config.Price.MaxDeviation > 0 {
+			priceResolver.maxDeviation = config.Price.MaxDeviation
+		}
+		priceResolver.maxAge = config.Price.MaxAge
+		providers, err = NewProviders(config.Price.Providers)
 	}
-	priceResolver := &PriceResolver{
-		priceMap: &sync.Map{},
-		isXT:     isXT,
+	if priceResolver.maxAge <= 0 {
+		priceResolver.maxAge = int64(3 * fetchTime * 60)
+	}
+	if err != nil {
+		return nil, err
+	}
+	priceResolver.providers = providers
+
+	if keepHistory {
+		network, err := config.Network("")
+		if err != nil {
+			return nil, err
+		}
+		priceResolver.history = NewHistory(readDB)
+		priceResolver.writeDB = writeDB
+		priceResolver.periodicBackfill(readDB, network.Genesis)
 	}
 
 	priceResolver.fetchPrice()
 	priceResolver.periodicPriceFetch(fetchTime)
-	return priceResolver
+	return priceResolver, nil
+}
+
+// currencies returns the configured currencies in upper case, with usd first.
+func currencies(configured []string) []string {
+	upper := []string{usdCurrency}
+	for _, currency := range configured {
+		currency = strings.ToUpper(currency)
+		if !slices.Contains(upper, currency) {
+			upper = append(upper, currency)
+		}
+	}
+	return upper
 }
 
+// GetPrice returns the usd price, -1 when it is unknown or stale.
 func (p *PriceResolver) GetPrice() float64 {
-	priceResponse, present := p.priceMap.Load(priceKey)
-	if !present {
+	price, ok := p.Price(usdCurrency)
+	if !ok {
 		return -1
 	}
-	return priceResponse.(*PriceCache).usdPrice
+	return price
+}
+
+// Price returns the price in currency, false when it isn't quoted or is stale.
+func (p *PriceResolver) Price(currency string) (float64, bool) {
+	priceResponse, present := p.priceMap.Load(currency)
+	if !present {
+		return 0, false
+	}
+	cache := priceResponse.(*PriceCache)
+	if p.stale(cache) {
+		return 0, false
+	}
+	return cache.price, true
+}
+
+// GetQuote returns the latest price in currency with when and where it was fetched from, nil before the first
+// successful fetch. A stale price is returned with Stale set.
+func (p *PriceResolver) GetQuote(currency string) *types.Price {
+	priceResponse, present := p.priceMap.Load(currency)
+	if !present {
+		return nil
+	}
+	cache := priceResponse.(*PriceCache)
+	return &types.Price{
+		Price:     cache.price,
+		Currency:  currency,
+		Timestamp: cache.fetched,
+		Source:    cache.source,
+		Sources:   cache.sources,
+		Stale:     p.stale(cache),
+	}
+}
+
+// Currencies returns the currencies the price is quoted in, upper case.
+func (p *PriceResolver) Currencies() []string {
+	return p.currencies
+}
+
+// HasCurrency tells if the price is quoted in an upper case currency.
+func (p *PriceResolver) HasCurrency(currency string) bool {
+	return slices.Contains(p.currencies, currency)
+}
+
+func (p *PriceResolver) stale(cache *PriceCache) bool {
+	return time.Now().Unix()-cache.fetched > p.maxAge
+}
+
+// History returns the stored prices, nil when they are not kept.
+func (p *PriceResolver) History() *History {
+	return p.history
+}
+
+// PriceAt returns the usd price at a unix time in seconds, -1 when it is unknown.
+func (p *PriceResolver) PriceAt(timestamp int64) float64 {
+	return p.PricesAt([]int64{timestamp})[0]
+}
+
+// PricesAt returns the usd price at each of timestamps, -1 when it is unknown.
+func (p *PriceResolver) PricesAt(timestamps []int64) []float64 {
+	if p.history == nil {
+		prices := make([]float64, len(timestamps))
+		for i := range prices {
+			prices[i] = -1
+		}
+		return prices
+	}
+	return p.history.PricesAt(timestamps)
+}
+
+// periodicBackfill backfills the daily closes now and then every day, until Stop.
+func (p *PriceResolver) periodicBackfill(readDB database.ReadDB, since int64) {
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			backfill(readDB, p.writeDB, since, p.stop)
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+		}
+	}()
 }
 
 func (p *PriceResolver) periodicPriceFetch(refreshTime int) {
-	ticker := time.NewTicker(time.Duration(refreshTime) * time.Minute)
+	p.done.Add(1)
 	go func() {
-		for range ticker.C {
-			p.fetchPrice()
+		defer p.done.Done()
+		ticker := time.NewTicker(time.Duration(refreshTime) * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.fetchPrice()
+			case <-p.stop:
+				return
+			}
 		}
 	}()
 }
 
+// Stop stops the periodic fetches and backfills, it waits for the ones in progress so nothing is saved after it
+// returns.
+func (p *PriceResolver) Stop() {
+	close(p.stop)
+	p.done.Wait()
+}
+
+// fetchPrice fetches the quotes of every provider, then aggregates the ones not older than the max age into
+// the price of every currency. A currency without such quotes keeps its last price until it is stale.
 func (p *PriceResolver) fetchPrice() {
-	if p.isXT {
-		if !p.fetchXT() {
-			p.fetchCoinpaprikaPrice()
+	now := time.Now().Unix()
+	for _, provider := range p.providers {
+		prices, err := provider.Fetch(p.currencies)
+		if err == nil && len(prices) == 0 {
+			err = fmt.Errorf("no quote in %s", strings.Join(p.currencies, ", "))
 		}
-	} else {
-		if !p.fetchCoinpaprikaPrice() {
-			p.fetchXT()
+		if err != nil {
+			metrics.PriceFetchFailures.WithLabelValues(provider.Name()).Inc()
+			slog.Warn("Failed to fetch price", "source", provider.Name(), "error", err)
+			continue
 		}
+		metrics.PriceLastFetch.WithLabelValues(provider.Name()).Set(float64(now))
+		p.quotes[provider.Name()] = &providerQuote{prices: prices, fetched: now}
 	}
-
-}
-
-func (p *PriceResolver) fetchXT() bool {
-	fmt.Println("Fetch price from XT")
-	respXT, err := http.Get("https://www.xt.com/sapi/v4/market/public/ticker/24h?symbol=smh_usdt")
-	if err != nil || respXT.StatusCode == 404 {
-		return false
+	for _, currency := range p.currencies {
+		p.aggregateQuotes(currency, now)
 	}
-	defer respXT.Body.Close()
+}
 
-	var xtResponce PriceXTResponse
-	if err := json.NewDecoder(respXT.Body).Decode(&xtResponce); err != nil {
-		p.priceMap.Delete(priceKey)
-		fmt.Println("Error:", err)
-		return false
+func (p *PriceResolver) aggregateQuotes(currency string, now int64) {
+	quotes := make([]*sourceQuote, 0, len(p.providers))
+	for _, provider := range p.providers {
+		quote := p.quotes[provider.Name()]
+		if quote == nil || now-quote.fetched > p.maxAge {
+			continue
+		}
+		if price, ok := quote.prices[currency]; ok {
+			quotes = append(quotes, &sourceQuote{source: provider.Name(), price: price, fetched: quote.fetched})
+		}
 	}
-
-	if len(xtResponce.Result) > 0 {
-		price, err := strconv.ParseFloat(xtResponce.Result[0].Current, 64)
-		if err != nil {
-			p.priceMap.Delete(priceKey)
-
-			fmt.Println("Error no price on XT response")
-			return false
+	if len(quotes) == 0 {
+		if priceResponse, present := p.priceMap.Load(currency); present {
+			slog.Warn("Price is stale", "currency", currency, "fetched", priceResponse.(*PriceCache).fetched)
 		}
-		p.priceMap.Store(priceKey, &PriceCache{
-			usdPrice: price,
-		})
-		return true
-	} else {
-		p.priceMap.Delete(priceKey)
-		fmt.Println("Error no price on XT response")
+		return
+	}
 
-		return false
+	price, kept, rejected := aggregate(quotes, p.maxDeviation)
+	for _, quote := range rejected {
+		metrics.PriceOutliers.WithLabelValues(quote.source, currency).Inc()
+		slog.Warn("Rejected outlier price", "source", quote.source, "currency", currency, "price", quote.price, "median", price)
 	}
+	cache := &PriceCache{
+		price:   price,
+		sources: make([]string, len(kept)),
+		source:  "median",
+	}
+	for i, quote := range kept {
+		cache.sources[i] = quote.source
+		cache.fetched = max(cache.fetched, quote.fetched)
+	}
+	if len(kept) == 1 {
+		cache.source = kept[0].source
+	}
+	p.priceMap.Store(currency, cache)
 
+	// only the prices with a quote of this fetch are new snapshots
+	if currency == usdCurrency && cache.fetched == now {
+		p.saveSnapshot(time.Unix(now, 0), price, cache.source)
+	}
 }
 
-func (p *PriceResolver) fetchCoinpaprikaPrice() bool {
-	fmt.Println("Fetch price from coinpaprika")
-	resp, err := http.Get("https://api.coinpaprika.com/v1/tickers/smh-spacemesh")
-	if err != nil || resp.StatusCode == 404 {
-		return false
+// saveSnapshot saves a fetched price to the history, at the minute it was fetched so that the instances
+// fetching at the same time save it once.
+func (p *PriceResolver) saveSnapshot(fetched time.Time, price float64, source string) {
+	if p.writeDB == nil {
+		return
 	}
-	defer resp.Body.Close()
-
-	var response PriceResponse
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		p.priceMap.Delete(priceKey)
-		fmt.Println("Error:", err)
-		return false
+	snapshot := &types.PriceDoc{
+		Timestamp: fetched.Truncate(time.Minute).Unix(),
+		Price:     price,
+		Source:    source,
 	}
-
-	value := response.Quotes["USD"]
-	if value == nil {
-		return false
+	if err := p.writeDB.SavePrices([]*types.PriceDoc{snapshot}); err != nil {
+		slog.Error("Failed to save price snapshot", "source", source, "error", err)
 	}
-
-	p.priceMap.Store(priceKey, &PriceCache{
-		usdPrice: value.Price,
-	})
-	return true
-
 }
 
 type PriceCache struct {
-	usdPrice float64
-}
-
-type PriceResponse struct {
-	Quotes map[string]*PriceQuote `json:"quotes"`
-}
-
-type PriceQuote struct {
-	Price float64 `json:"price"`
-}
-
-type PriceXTResponse struct {
-	Result []PriceXTResult `json:"result"`
+	price   float64
+	fetched int64
+	source  string
+	sources []string
 }
 
-type PriceXTResult struct {
-	Current string `json:"c"`
+type providerQuote struct {
+	prices  map[string]float64
+	fetched int64
 }
@@ -0,0 +1,134 @@
+package price
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+func testResolver(t *testing.T, providers ...*config.PriceProviderConfig) *PriceResolver {
+	t.Helper()
+	resolver, err := NewPriceResolver(&config.Config{Price: &config.PriceConfig{
+		DisableHistory: true,
+		Providers:      providers,
+		MaxAge:         60,
+	}}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolver
+}
+
+func TestPriceProviders(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "prices.json")
+	if err := os.WriteFile(file, []byte(`{"usd": 2.2, "eur": -1}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	resolver := testResolver(t,
+		&config.PriceProviderConfig{Type: "static", Prices: map[string]float64{"usd": 2, "EUR": 1.8}},
+		&config.PriceProviderConfig{Type: "static", Name: "other", Prices: map[string]float64{"USD": 2.1}},
+		&config.PriceProviderConfig{Type: "file", File: file},
+	)
+
+	quote := resolver.GetQuote("USD")
+	if quote == nil || quote.Price != 2.1 || quote.Source != "median" || quote.Stale {
+		t.Fatalf("usd quote %+v, want the median 2.1", quote)
+	}
+	if !slices.Equal(quote.Sources, []string{"static", "other", "file"}) {
+		t.Errorf("usd sources %v", quote.Sources)
+	}
+	// the file has no positive eur price, the static one is the only quote
+	quote = resolver.GetQuote("EUR")
+	if quote == nil || quote.Price != 1.8 || quote.Source != "static" {
+		t.Errorf("eur quote %+v, want 1.8 of static", quote)
+	}
+	if _, ok := resolver.Price("BTC"); ok {
+		t.Error("btc price without a quote")
+	}
+}
+
+func TestNewProvidersErrors(t *testing.T) {
+	tests := []*config.PriceProviderConfig{
+		{Type: "static"},
+		{Type: "file"},
+		{Type: "unknown"},
+	}
+	for _, test := range tests {
+		if _, err := NewProviders([]*config.PriceProviderConfig{test}); err == nil {
+			t.Errorf("no error for %+v", test)
+		}
+	}
+	if _, err := NewProviders([]*config.PriceProviderConfig{
+		{Type: "static", Prices: map[string]float64{"USD": 1}},
+		{Type: "static", Prices: map[string]float64{"USD": 2}},
+	}); err == nil {
+		t.Error("no error for two providers with the same name")
+	}
+}
+
+func TestPriceStaleness(t *testing.T) {
+	resolver := testResolver(t, &config.PriceProviderConfig{Type: "static", Prices: map[string]float64{"USD": 2}})
+	if price, ok := resolver.Price("USD"); !ok || price != 2 {
+		t.Fatalf("fresh price %f, %t", price, ok)
+	}
+
+	now := time.Now().Unix()
+	tests := []struct {
+		name string
+		// age of the quote when it was aggregated and now
+		fetchedAge    int64
+		aggregatedAge int64
+		stale         bool
+	}{
+		{name: "recent quote", fetchedAge: 30, aggregatedAge: 0},
+		{name: "quote older than the max age", fetchedAge: 120, aggregatedAge: 120, stale: true},
+		// a quote too old when aggregated is left out, the price it set before stays stale
+		{name: "old quote aggregated again", fetchedAge: 120, aggregatedAge: 0, stale: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver.priceMap = &sync.Map{}
+			resolver.quotes["static"] = &providerQuote{prices: map[string]float64{"USD": 3}, fetched: now - test.fetchedAge}
+			resolver.aggregateQuotes("USD", now-test.fetchedAge)
+			if test.aggregatedAge != test.fetchedAge {
+				resolver.aggregateQuotes("USD", now-test.aggregatedAge)
+			}
+
+			price, ok := resolver.Price("USD")
+			if ok == test.stale {
+				t.Errorf("price %f, ok %t, want stale %t", price, ok, test.stale)
+			}
+			quote := resolver.GetQuote("USD")
+			if quote == nil || quote.Stale != test.stale || quote.Price != 3 {
+				t.Errorf("quote %+v, want price 3 and stale %t", quote, test.stale)
+			}
+			if test.stale && resolver.GetPrice() != -1 {
+				t.Errorf("stale usd price %f, want -1", resolver.GetPrice())
+			}
+		})
+	}
+}
+
+func TestPriceResolverStop(t *testing.T) {
+	resolver := testResolver(t, &config.PriceProviderConfig{Type: "static", Prices: map[string]float64{"USD": 2}})
+
+	stopped := make(chan struct{})
+	go func() {
+		resolver.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't return")
+	}
+	// the price fetched before Stop is still served
+	if price := resolver.GetPrice(); price != 2 {
+		t.Errorf("price %v after Stop, want 2", price)
+	}
+}
@@ -0,0 +1,205 @@
+package price
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+// PriceProvider fetches the price of smh from a source.
+type PriceProvider interface {
+	// Name is the source of the quotes, in the logs, the metrics and the prices collection.
+	Name() string
+	// Fetch returns the price of smh by upper case currency, for the ones of currencies the source quotes.
+	Fetch(currencies []string) (map[string]float64, error)
+}
+
+// NewProviders creates the providers of the config, xt and coinpaprika when there are none.
+func NewProviders(configs []*config.PriceProviderConfig) ([]PriceProvider, error) {
+	if len(configs) == 0 {
+		configs = []*config.PriceProviderConfig{{Type: "xt"}, {Type: "coinpaprika"}}
+	}
+	providers := make([]PriceProvider, 0, len(configs))
+	names := make(map[string]bool)
+	for _, providerConfig := range configs {
+		var provider PriceProvider
+		switch strings.ToLower(providerConfig.Type) {
+		case "xt":
+			provider = &xtProvider{}
+		case "coinpaprika":
+			provider = &coinpaprikaProvider{}
+		case "static":
+			if len(providerConfig.Prices) == 0 {
+				return nil, fmt.Errorf("static price provider needs prices")
+			}
+			provider = &staticProvider{name: "static", prices: upperKeys(providerConfig.Prices)}
+		case "file":
+			if providerConfig.File == "" {
+				return nil, fmt.Errorf("file price provider needs a file")
+			}
+			provider = &fileProvider{name: "file", file: providerConfig.File}
+		default:
+			return nil, fmt.Errorf("unknown price provider %q", providerConfig.Type)
+		}
+		if providerConfig.Name != "" {
+			provider = &namedProvider{PriceProvider: provider, name: providerConfig.Name}
+		}
+		if names[provider.Name()] {
+			return nil, fmt.Errorf("price provider %s is configured twice, give them a name", provider.Name())
+		}
+		names[provider.Name()] = true
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// namedProvider renames a provider, to configure the same type more than once.
+type namedProvider struct {
+	PriceProvider
+	name string
+}
+
+func (n *namedProvider) Name() string {
+	return n.name
+}
+
+// xtProvider quotes the smh/usdt market of XT, as usd.
+type xtProvider struct{}
+
+func (x *xtProvider) Name() string {
+	return "xt"
+}
+
+func (x *xtProvider) Fetch(currencies []string) (map[string]float64, error) {
+	slog.Debug("Fetch price from XT")
+	respXT, err := http.Get("https://www.xt.com/sapi/v4/market/public/ticker/24h?symbol=smh_usdt")
+	if err != nil {
+		return nil, err
+	}
+	defer respXT.Body.Close()
+	if respXT.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("xt returned %d", respXT.StatusCode)
+	}
+
+	var xtResponce PriceXTResponse
+	if err := json.NewDecoder(respXT.Body).Decode(&xtResponce); err != nil {
+		return nil, err
+	}
+	if len(xtResponce.Result) == 0 {
+		return nil, fmt.Errorf("no price on xt response")
+	}
+	price, err := strconv.ParseFloat(xtResponce.Result[0].Current, 64)
+	if err != nil {
+		return nil, err
+	}
+	return pick(map[string]float64{"USD": price}, currencies), nil
+}
+
+// coinpaprikaProvider quotes the coinpaprika ticker of smh in every currency.
+type coinpaprikaProvider struct{}
+
+func (c *coinpaprikaProvider) Name() string {
+	return "coinpaprika"
+}
+
+func (c *coinpaprikaProvider) Fetch(currencies []string) (map[string]float64, error) {
+	slog.Debug("Fetch price from coinpaprika")
+	resp, err := http.Get("https://api.coinpaprika.com/v1/tickers/smh-spacemesh?quotes=" + strings.Join(currencies, ","))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("coinpaprika returned %d", resp.StatusCode)
+	}
+
+	var response PriceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	prices := make(map[string]float64, len(response.Quotes))
+	for currency, quote := range response.Quotes {
+		if quote != nil {
+			prices[strings.ToUpper(currency)] = quote.Price
+		}
+	}
+	return pick(prices, currencies), nil
+}
+
+// staticProvider quotes the prices of its config, for tests and local setups.
+type staticProvider struct {
+	name   string
+	prices map[string]float64
+}
+
+func (s *staticProvider) Name() string {
+	return s.name
+}
+
+func (s *staticProvider) Fetch(currencies []string) (map[string]float64, error) {
+	return pick(s.prices, currencies), nil
+}
+
+// fileProvider quotes the prices of a json file, an object of prices by currency, read at every fetch so that
+// they can be changed while the api runs.
+type fileProvider struct {
+	name string
+	file string
+}
+
+func (f *fileProvider) Name() string {
+	return f.name
+}
+
+func (f *fileProvider) Fetch(currencies []string) (map[string]float64, error) {
+	content, err := os.ReadFile(f.file)
+	if err != nil {
+		return nil, err
+	}
+	var prices map[string]float64
+	if err := json.Unmarshal(content, &prices); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", f.file, err)
+	}
+	return pick(upperKeys(prices), currencies), nil
+}
+
+// pick returns the positive prices of currencies.
+func pick(prices map[string]float64, currencies []string) map[string]float64 {
+	picked := make(map[string]float64, len(currencies))
+	for _, currency := range currencies {
+		if price, ok := prices[currency]; ok && price > 0 {
+			picked[currency] = price
+		}
+	}
+	return picked
+}
+
+func upperKeys(prices map[string]float64) map[string]float64 {
+	upper := make(map[string]float64, len(prices))
+	for currency, price := range prices {
+		upper[strings.ToUpper(currency)] = price
+	}
+	return upper
+}
+
+type PriceResponse struct {
+	Quotes map[string]*PriceQuote `json:"quotes"`
+}
+
+type PriceQuote struct {
+	Price float64 `json:"price"`
+}
+
+type PriceXTResponse struct {
+	Result []PriceXTResult `json:"result"`
+}
+
+type PriceXTResult struct {
+	Current string `json:"c"`
+}
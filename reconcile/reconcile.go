@@ -0,0 +1,263 @@
+package reconcile
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// number of accounts checked per query
+const batchSize = 500
+
+// drifted accounts kept in a report, the counts include all of them
+const maxReportedDrifts = 1000
+
+var (
+	ErrRunning = errors.New("reconcile already running")
+	ErrChanged = errors.New("account changed while checked, retry")
+)
+
+// Reconciler audits the account balances kept by the sink against the rewards and transactions they
+// come from. The balance of an account is its genesis balance plus rewards and received amounts minus
+// sent amounts and fees, where only transactions that changed the balances when saved are counted.
+// Accounts without an account document are not checked nor repaired.
+type Reconciler struct {
+	readDB  database.ReadDB
+	writeDB database.WriteDB
+	genesis map[string]uint64
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	running bool
+	last    *types.ReconcileReport
+}
+
+// NewReconciler audits the accounts of a network, genesis are the balances its vaults started with.
+func NewReconciler(readDB database.ReadDB, writeDB database.WriteDB, genesis map[string]uint64) *Reconciler {
+	return &Reconciler{
+		readDB:  readDB,
+		writeDB: writeDB,
+		genesis: genesis,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs the reconciler every interval minutes in the background.
+func (r *Reconciler) Start(interval int, repair bool) {
+	ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-r.stop:
+				return
+			}
+			if _, err := r.Run(repair); err != nil && !errors.Is(err, ErrRunning) {
+				slog.Error("Reconcile failed", "error", err)
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduled runs and waits for the run in progress, so the dbs can be closed.
+func (r *Reconciler) Stop() {
+	close(r.stop)
+	r.wg.Wait()
+}
+
+// RunAsync starts a run in the background, it returns false if one is already running.
+func (r *Reconciler) RunAsync(repair bool) bool {
+	if !r.begin() {
+		return false
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		if _, err := r.run(repair); err != nil {
+			slog.Error("Reconcile failed", "error", err)
+		}
+	}()
+	return true
+}
+
+// Run checks all the accounts and repairs the drifted ones if repair is set.
+func (r *Reconciler) Run(repair bool) (*types.ReconcileReport, error) {
+	if !r.begin() {
+		return nil, ErrRunning
+	}
+	return r.run(repair)
+}
+
+// Status returns whether a run is in progress and the report of the last finished one.
+func (r *Reconciler) Status() *types.ReconcileStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &types.ReconcileStatus{
+		Running: r.running,
+		Last:    r.last,
+	}
+}
+
+// Account checks a single account, it returns nil if the account has no drift.
+func (r *Reconciler) Account(address string, repair bool) (*types.AccountDrift, error) {
+	stored, err := r.readDB.GetAccount(address)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Address == "" {
+		stored.Address = address
+	}
+	totals, err := r.readDB.GetAccountsTotals([]string{address})
+	if err != nil {
+		return nil, err
+	}
+	drift, changed, err := r.check(stored, r.computed(totals[0]), repair)
+	if changed {
+		return nil, ErrChanged
+	}
+	return drift, err
+}
+
+func (r *Reconciler) begin() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return false
+	}
+	r.running = true
+	return true
+}
+
+func (r *Reconciler) run(repair bool) (*types.ReconcileReport, error) {
+	report := &types.ReconcileReport{
+		StartedAt: time.Now().UnixMilli(),
+		Repair:    repair,
+		Accounts:  make([]*types.AccountDrift, 0),
+	}
+	slog.Info("Reconcile accounts", "repair", repair)
+
+	err := r.reconcile(report)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now().UnixMilli()
+	slog.Info("Reconciled accounts", "checked", report.Checked, "drifted", report.Drifted,
+		"repaired", report.Repaired, "skipped", report.Skipped)
+
+	r.mu.Lock()
+	r.running = false
+	r.last = report
+	r.mu.Unlock()
+	return report, err
+}
+
+func (r *Reconciler) reconcile(report *types.ReconcileReport) error {
+	after := ""
+	for {
+		accounts, err := r.readDB.GetAccountsAfter(after, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(accounts) == 0 {
+			return nil
+		}
+
+		addresses := make([]string, len(accounts))
+		for i, account := range accounts {
+			addresses[i] = account.Address
+		}
+		totals, err := r.readDB.GetAccountsTotals(addresses)
+		if err != nil {
+			return err
+		}
+
+		for i, stored := range accounts {
+			report.Checked++
+			drift, changed, err := r.check(stored, r.computed(totals[i]), report.Repair)
+			if err != nil {
+				return err
+			}
+			if changed {
+				report.Skipped++
+				continue
+			}
+			if drift == nil {
+				continue
+			}
+			report.Drifted++
+			if drift.Repaired {
+				report.Repaired++
+			}
+			if len(report.Accounts) < maxReportedDrifts {
+				report.Accounts = append(report.Accounts, drift)
+			}
+		}
+		after = addresses[len(addresses)-1]
+	}
+}
+
+// computed adds the genesis balance to the totals of an account.
+func (r *Reconciler) computed(totals *types.AccountDoc) *types.AccountDoc {
+	computed := *totals
+	computed.Balance = r.genesis[totals.Address] + totals.TotalRewards + totals.Received - totals.Sent - totals.Fees
+	return &computed
+}
+
+// check compares an account with its computed balances. changed is set when the sink updated the
+// account while it was checked, then the comparison is not reliable and it is left for the next run.
+func (r *Reconciler) check(stored *types.AccountDoc, computed *types.AccountDoc, repair bool) (*types.AccountDrift, bool, error) {
+	if sameBalances(stored, computed) {
+		return nil, false, nil
+	}
+	current, err := r.readDB.GetAccount(stored.Address)
+	if err != nil {
+		return nil, false, err
+	}
+	if current.Address != "" && !sameBalances(stored, current) {
+		return nil, true, nil
+	}
+
+	drift := &types.AccountDrift{
+		Address:  stored.Address,
+		Stored:   balances(stored),
+		Computed: balances(computed),
+	}
+	if repair {
+		repaired, err := r.writeDB.RepairAccount(stored, computed)
+		if err != nil {
+			return nil, false, err
+		}
+		if !repaired {
+			return nil, true, nil
+		}
+		drift.Repaired = true
+		slog.Warn("Repaired account", "address", stored.Address, "stored", int64(stored.Balance), "computed", int64(computed.Balance))
+	}
+	return drift, false, nil
+}
+
+func sameBalances(a *types.AccountDoc, b *types.AccountDoc) bool {
+	return a.Balance == b.Balance &&
+		a.TotalRewards == b.TotalRewards &&
+		a.Sent == b.Sent &&
+		a.Fees == b.Fees &&
+		a.Received == b.Received
+}
+
+func balances(account *types.AccountDoc) *types.AccountBalances {
+	return &types.AccountBalances{
+		Balance:      int64(account.Balance),
+		TotalRewards: int64(account.TotalRewards),
+		Sent:         int64(account.Sent),
+		Fees:         int64(account.Fees),
+		Received:     int64(account.Received),
+	}
+}
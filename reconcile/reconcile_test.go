@@ -0,0 +1,136 @@
+package reconcile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk/wallet"
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// testAccounts saves a reward of 10000 to sender, which sends 1000 to receiver with a fee of 100 gas at price 2.
+func testAccounts(t *testing.T) (*database.SQLiteDB, string, string) {
+	t.Helper()
+	db, err := database.NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "reconcile.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	seed := make([]byte, ed25519.SeedSize)
+	key := ed25519.NewKeyFromSeed(seed)
+	sender := wallet.Address(key.Public().(ed25519.PublicKey))
+	seed[0] = 1
+	receiver := wallet.Address(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+
+	reward := &nats.Reward{ID: "r1", Layer: 1, Coinbase: sender.String(), Total: 10000, LayerReward: 10000, NodeID: "n1", AtxID: "a1"}
+	if err := db.SaveReward(reward, database.Origin{}); err != nil {
+		t.Fatal(err)
+	}
+	transaction := &nats.Transaction{
+		ID:  "t1",
+		Raw: wallet.Spend(key, receiver, 1000, 1, sdk.WithGasPrice(2)),
+		Header: &nats.TransactionHeader{
+			LayerID:   2,
+			BlockID:   "b2",
+			Principal: sender.String(),
+			Method:    16,
+			Gas:       100,
+			Addresses: []string{sender.String(), receiver.String()},
+		},
+	}
+	if err := db.SaveTransactions(transaction, true, database.Origin{}); err != nil {
+		t.Fatal(err)
+	}
+	return db, sender.String(), receiver.String()
+}
+
+func account(t *testing.T, db *database.SQLiteDB, address string) *types.AccountDoc {
+	t.Helper()
+	account, err := db.GetAccount(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+func TestReconcile(t *testing.T) {
+	db, sender, receiver := testAccounts(t)
+	r := NewReconciler(db, db, nil)
+
+	report, err := r.Run(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 2 || report.Drifted != 0 {
+		t.Fatalf("checked %d, drifted %d, want 2 accounts in sync", report.Checked, report.Drifted)
+	}
+
+	// the balance of the sender drifts
+	stored := account(t, db, sender)
+	if stored.Balance != 10000-1000-200 || stored.Sent != 1000 || stored.Fees != 200 {
+		t.Fatalf("sender %+v", stored)
+	}
+	drifted := *stored
+	drifted.Balance += 5
+	if repaired, err := db.RepairAccount(stored, &drifted); err != nil || !repaired {
+		t.Fatalf("drift not saved: %v", err)
+	}
+
+	// a check reports it without changing it
+	report, err = r.Run(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted != 1 || report.Repaired != 0 || len(report.Accounts) != 1 {
+		t.Fatalf("drifted %d, repaired %d, want the sender drifted", report.Drifted, report.Repaired)
+	}
+	drift := report.Accounts[0]
+	if drift.Address != sender || drift.Stored.Balance != 8805 || drift.Computed.Balance != 8800 || drift.Repaired {
+		t.Errorf("drift %+v, stored %+v, computed %+v", drift, drift.Stored, drift.Computed)
+	}
+	if balance := account(t, db, sender).Balance; balance != 8805 {
+		t.Errorf("balance %d after a check, want 8805", balance)
+	}
+	if status := r.Status(); status.Running || status.Last != report {
+		t.Errorf("status %+v, want the last report", status)
+	}
+
+	// a repair brings it back to the computed balances
+	report, err = r.Run(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Drifted != 1 || report.Repaired != 1 || !report.Accounts[0].Repaired {
+		t.Errorf("drifted %d, repaired %d, want the sender repaired", report.Drifted, report.Repaired)
+	}
+	if balance := account(t, db, sender).Balance; balance != 8800 {
+		t.Errorf("balance %d after the repair, want 8800", balance)
+	}
+	if drift, err := r.Account(receiver, false); err != nil || drift != nil {
+		t.Errorf("receiver drift %+v: %v", drift, err)
+	}
+}
+
+func TestReconcileGenesisBalance(t *testing.T) {
+	db, sender, receiver := testAccounts(t)
+	// the receiver is a vault that started with 50000
+	r := NewReconciler(db, db, map[string]uint64{receiver: 50000})
+
+	drift, err := r.Account(receiver, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drift == nil || drift.Stored.Balance != 1000 || drift.Computed.Balance != 51000 || !drift.Repaired {
+		t.Fatalf("receiver drift %+v, want its genesis balance added", drift)
+	}
+	if received := account(t, db, receiver); received.Balance != 51000 || received.Received != 1000 {
+		t.Errorf("receiver %+v after the repair", received)
+	}
+	if drift, err := r.Account(sender, false); err != nil || drift != nil {
+		t.Errorf("sender drift %+v: %v", drift, err)
+	}
+}
@@ -1,28 +1,29 @@
 package route
 
 import (
-    "fmt"
-    "log"
+    "log/slog"
     "net/http"
     "strconv"
 
     "github.com/gin-gonic/gin"
-    "github.com/swarmbit/spacemesh-state-api/config"
+    "github.com/spacemeshos/go-spacemesh/genvm/core"
+    "github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
     "github.com/swarmbit/spacemesh-state-api/database"
     "github.com/swarmbit/spacemesh-state-api/network"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
     "github.com/swarmbit/spacemesh-state-api/price"
     "github.com/swarmbit/spacemesh-state-api/types"
 )
 
 type AccountRoutes struct {
-    db            *database.ReadDB
+    db            database.ReadDB
     networkUtils  *network.NetworkUtils
     state         *network.NetworkState
     priceResolver *price.PriceResolver
 }
 
 func NewAccountRoutes(
-    readDB *database.ReadDB,
+    readDB database.ReadDB,
     networkUtils *network.NetworkUtils,
     state *network.NetworkState,
     priceResolver *price.PriceResolver,
@@ -80,7 +81,7 @@ func (a *AccountRoutes) GetAccountsPost(c *gin.Context) {
 
     accounts, errAccounts := a.db.GetAccountsPostEpoch(epoch-1, int64(offset), int64(limit), sort)
     if err != nil {
-        fmt.Println(err)
+        slog.Error("Failed to fetch accounts", "error", err)
         c.JSON(http.StatusBadRequest, gin.H{
             "error": "failed to fetch accounts",
         })
@@ -89,7 +90,7 @@ func (a *AccountRoutes) GetAccountsPost(c *gin.Context) {
 
     count, errCount := a.db.CountAccountsPostEpoch(epoch - 1)
     if err != nil {
-        fmt.Println(err)
+        slog.Error("Failed to count accounts", "error", err)
         c.JSON(http.StatusBadRequest, gin.H{
             "error": "failed to count accounts",
         })
@@ -149,6 +150,10 @@ func (a *AccountRoutes) GetAccounts(c *gin.Context) {
         })
         return
     }
+    currency, ok := parseCurrency(c, a.priceResolver)
+    if !ok {
+        return
+    }
 
     var sort int8
     if sortStr == "asc" {
@@ -157,7 +162,15 @@ func (a *AccountRoutes) GetAccounts(c *gin.Context) {
         sort = -1
     }
 
-    accounts, errAccounts := a.db.GetAccounts(int64(offset), int64(limit), sort)
+    after, ok := parseCursor(c, sort)
+    if !ok {
+        return
+    }
+    if after != nil {
+        offset = 0
+    }
+
+    accounts, errAccounts := a.db.GetAccounts(int64(offset), int64(limit), sort, after)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{
             "status": "Internal Error",
@@ -184,8 +197,9 @@ func (a *AccountRoutes) GetAccounts(c *gin.Context) {
 
         accountsResponse := make([]*types.ShortAccount, len(accounts))
 
+        priceValue := a.priceResolver.GetPrice()
+        currencyPrice, known := a.priceResolver.Price(currency)
         for i, v := range accounts {
-            priceValue := a.priceResolver.GetPrice()
             dollarValue := int64(-1)
             if priceValue > -1 {
                 dollarValue = int64(priceValue * float64(v.Balance))
@@ -195,9 +209,15 @@ func (a *AccountRoutes) GetAccounts(c *gin.Context) {
                 Address:      v.Address,
                 USDValue:     dollarValue,
                 TotalRewards: v.TotalRewards,
+                Value:        currencyValue(currencyPrice, known, v.Balance),
+                Currency:     currency,
             }
         }
 
+        if limit > 0 && len(accounts) == limit {
+            last := accounts[len(accounts)-1]
+            setNextCursor(c, sort, &types.Cursor{Key: int64(last.Balance), ID: last.Address})
+        }
         c.Header("total", strconv.FormatInt(count, 10))
         c.JSON(200, accountsResponse)
     } else {
@@ -213,6 +233,10 @@ func (a *AccountRoutes) GetAccountGroup(c *gin.Context) {
         c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
         return
     }
+    currency, ok := parseCurrency(c, a.priceResolver)
+    if !ok {
+        return
+    }
     result, err := a.db.GetAccountsGroup(req.Accounts)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{
@@ -228,16 +252,34 @@ func (a *AccountRoutes) GetAccountGroup(c *gin.Context) {
         dollarValue = int64(priceValue * float64(result.Balance))
     }
 
+    currencyPrice, known := a.priceResolver.Price(currency)
     c.JSON(200, &types.AccountGroupResponse{
         Balance:      uint64(result.Balance),
         USDValue:     dollarValue,
         TotalRewards: uint64(result.TotalRewards),
+        Value:        currencyValue(currencyPrice, known, uint64(result.Balance)),
+        Currency:     currency,
     })
 
 }
 
 func (a *AccountRoutes) GetAccount(c *gin.Context) {
     accountAddress := c.Param("accountAddress")
+    currency, ok := parseCurrency(c, a.priceResolver)
+    if !ok {
+        return
+    }
+    layer := uint32(a.state.GetInfo().Layer)
+    if layerStr := c.Query("layer"); layerStr != "" {
+        parsed, err := strconv.ParseUint(layerStr, 10, 32)
+        if err != nil {
+            c.JSON(http.StatusBadRequest, gin.H{
+                "error": "layer must be a valid integer",
+            })
+            return
+        }
+        layer = uint32(parsed)
+    }
     account, err := a.db.GetAccount(accountAddress)
     if err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{
@@ -246,7 +288,21 @@ func (a *AccountRoutes) GetAccount(c *gin.Context) {
         })
         return
     }
-    if account.Address == "" {
+    templateDoc, err := a.db.GetAccountTemplate(accountAddress)
+    if err != nil {
+        slog.Error("Failed to fetch account template", "account", accountAddress, "error", err)
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "status": "Internal Error",
+            "error":  "Failed to fetch account",
+        })
+        return
+    }
+    // a genesis vault is only recorded once it is drained
+    if templateDoc.Address == "" && a.genesisVault(accountAddress) != nil {
+        templateDoc = &types.AccountTemplateDoc{Address: accountAddress, Template: transactionparsertypes.TemplateVault}
+    }
+    // a vault spawned without ever receiving coins has a template but no balance
+    if account.Address == "" && templateDoc.Address == "" {
         c.JSON(http.StatusNotFound, gin.H{
             "status": "Not Found",
             "error":  "Account not found",
@@ -255,7 +311,7 @@ func (a *AccountRoutes) GetAccount(c *gin.Context) {
     }
     numberOfTransactions, err := a.db.CountTransactions(accountAddress)
     if err != nil {
-        log.Println(err)
+        slog.Error("Failed to count transactions", "account", accountAddress, "error", err)
         c.JSON(http.StatusInternalServerError, gin.H{
             "status": "Internal Error",
             "error":  "Failed to fetch account",
@@ -264,13 +320,25 @@ func (a *AccountRoutes) GetAccount(c *gin.Context) {
     }
     numberOfRewards, err := a.db.CountRewards(accountAddress, -1, -1)
     if err != nil {
-        log.Println(err)
+        slog.Error("Failed to count rewards", "account", accountAddress, "error", err)
         c.JSON(http.StatusInternalServerError, gin.H{
             "status": "Internal Error",
             "error":  "Failed to fetch account",
         })
         return
     }
+    var template *types.AccountTemplate
+    if templateDoc.Address != "" {
+        template, err = a.accountTemplate(templateDoc, layer)
+        if err != nil {
+            slog.Error("Failed to fetch vaults", "account", accountAddress, "error", err)
+            c.JSON(http.StatusInternalServerError, gin.H{
+                "status": "Internal Error",
+                "error":  "Failed to fetch account",
+            })
+            return
+        }
+    }
 
     priceValue := a.priceResolver.GetPrice()
     dollarValue := int64(-1)
@@ -278,6 +346,7 @@ func (a *AccountRoutes) GetAccount(c *gin.Context) {
         dollarValue = int64(priceValue * float64(account.Balance))
     }
 
+    currencyPrice, known := a.priceResolver.Price(currency)
     c.JSON(200, &types.Account{
         Balance:  account.Balance,
         USDValue: dollarValue,
@@ -288,9 +357,109 @@ func (a *AccountRoutes) GetAccount(c *gin.Context) {
         NumberOfTransactions: numberOfTransactions,
         Counter:              numberOfTransactions,
         NumberOfRewards:      numberOfRewards,
+        Value:                currencyValue(currencyPrice, known, account.Balance),
+        Currency:             currency,
+        Template:             template,
     })
 }
 
+// accountTemplate converts the template of an account, with the schedule of a vault or of the vaults a
+// vesting account owns as of layer.
+func (a *AccountRoutes) accountTemplate(templateDoc *types.AccountTemplateDoc, layer uint32) (*types.AccountTemplate, error) {
+    template := &types.AccountTemplate{
+        Template:         templateDoc.Template,
+        Spawned:          templateDoc.Spawned,
+        SpawnLayer:       templateDoc.SpawnLayer,
+        SpawnTransaction: templateDoc.SpawnTransaction,
+        PublicKeys:       templateDoc.PublicKeys,
+        RequiredSigners:  templateDoc.RequiredSigners,
+    }
+    switch templateDoc.Template {
+    case transactionparsertypes.TemplateVault:
+        vaultState, err := a.vaultState(templateDoc, layer)
+        if err != nil {
+            return nil, err
+        }
+        template.Vault = vaultState
+    case transactionparsertypes.TemplateVesting:
+        vaults, err := a.db.GetVaultsOfOwner(templateDoc.Address)
+        if err != nil {
+            return nil, err
+        }
+        for _, vaultDoc := range vaults {
+            vaultState, err := a.vaultState(vaultDoc, layer)
+            if err != nil {
+                return nil, err
+            }
+            if vaultState != nil {
+                template.Vaults = append(template.Vaults, vaultState)
+            }
+        }
+    }
+    return template, nil
+}
+
+// genesisVault returns the schedule of a vault of the genesis of the network, its genesis balance vested as
+// configured for the network, nil if address is not one.
+func (a *AccountRoutes) genesisVault(address string) *types.AccountTemplateDoc {
+    network := a.networkUtils.Network()
+    total, ok := network.Vaults[address]
+    if !ok || network.Vesting == nil {
+        return nil
+    }
+    return &types.AccountTemplateDoc{
+        Address:      address,
+        Template:     transactionparsertypes.TemplateVault,
+        TotalAmount:  total,
+        VestingStart: network.Vesting.Start,
+        VestingEnd:   network.Vesting.End,
+    }
+}
+
+// vaultState computes what a vault vested at layer, as the vault template does, and what of it is left to drain.
+// Genesis vaults have no spawn transaction, their schedule is the one of the network config. It returns nil
+// for a vault of unknown schedule.
+func (a *AccountRoutes) vaultState(vaultDoc *types.AccountTemplateDoc, layer uint32) (*types.VaultState, error) {
+    if vaultDoc.SpawnTransaction == "" {
+        genesisVault := a.genesisVault(vaultDoc.Address)
+        if genesisVault == nil {
+            return nil, nil
+        }
+        // the owner is known from its drains
+        genesisVault.Owner = vaultDoc.Owner
+        vaultDoc = genesisVault
+    }
+    drained, err := a.db.GetDrainedAmount(vaultDoc.Address)
+    if err != nil {
+        return nil, err
+    }
+    schedule := &vault.Vault{
+        TotalAmount:         vaultDoc.TotalAmount,
+        InitialUnlockAmount: vaultDoc.InitialUnlockAmount,
+        VestingStart:        core.LayerID(vaultDoc.VestingStart),
+        VestingEnd:          core.LayerID(vaultDoc.VestingEnd),
+    }
+    vested := schedule.Vested(core.LayerID(layer))
+    unlockable := uint64(0)
+    if vested > drained {
+        unlockable = vested - drained
+    }
+    return &types.VaultState{
+        Address: vaultDoc.Address,
+        VaultDetails: types.VaultDetails{
+            Owner:               vaultDoc.Owner,
+            TotalAmount:         vaultDoc.TotalAmount,
+            InitialUnlockAmount: vaultDoc.InitialUnlockAmount,
+            VestingStart:        vaultDoc.VestingStart,
+            VestingEnd:          vaultDoc.VestingEnd,
+        },
+        Layer:      layer,
+        Vested:     vested,
+        Drained:    drained,
+        Unlockable: unlockable,
+    }, nil
+}
+
 func (a *AccountRoutes) GetAccountRewards(c *gin.Context) {
     offsetStr := c.DefaultQuery("offset", "0")
     limitStr := c.DefaultQuery("limit", "20")
@@ -344,8 +513,16 @@ func (a *AccountRoutes) GetAccountRewards(c *gin.Context) {
         sort = 1
     }
 
+    after, ok := parseCursor(c, sort)
+    if !ok {
+        return
+    }
+    if after != nil {
+        offset = 0
+    }
+
     accountAddress := c.Param("accountAddress")
-    rewards, errRewards := a.db.GetRewards(accountAddress, int64(offset), int64(limit), sort, firstLayer, lastLayer)
+    rewards, errRewards := a.db.GetRewards(accountAddress, int64(offset), int64(limit), sort, firstLayer, lastLayer, after)
     count, errCount := a.db.CountRewards(accountAddress, firstLayer, lastLayer)
 
     if errRewards != nil || errCount != nil {
@@ -366,11 +543,16 @@ func (a *AccountRoutes) GetAccountRewards(c *gin.Context) {
                 SmesherId:      v.NodeId,
                 // legacy
                 Time:      "2023-09-05T00:00:00Z",
-                Timestamp: config.GenesisEpochSeconds + (v.Layer * config.LayerDuration),
+                Timestamp: a.networkUtils.LayerTime(v.Layer),
             }
         }
 
+        if limit > 0 && len(rewards) == limit {
+            last := rewards[len(rewards)-1]
+            setNextCursor(c, sort, &types.Cursor{Key: last.Layer, ID: last.Id})
+        }
         c.Header("total", strconv.FormatInt(count, 10))
+        setRewardsUSDValue(a.priceResolver, rewardsResponse)
         c.JSON(200, rewardsResponse)
     } else {
         c.Header("total", strconv.FormatInt(count, 10))
@@ -445,17 +627,19 @@ func (a *AccountRoutes) GetAccountTransactions(c *gin.Context) {
                 PrincipalAccount: v.PrincipaAccount,
                 ReceiverAccount:  v.ReceiverAccount,
                 VaultAccount:     v.VaultAccount,
-                Fee:              v.Gas * v.GasPrice,
+                Fee:              v.TotalFee(),
                 Amount:           v.Amount,
                 Layer:            v.Layer,
                 Counter:          v.Counter,
                 Method:           method,
                 Type:             v.Type,
-                Timestamp:        int64(config.GenesisEpochSeconds + (v.Layer * config.LayerDuration)),
+                Timestamp:        a.networkUtils.LayerTime(int64(v.Layer)),
+                Dropped:          v.Dropped,
             }
         }
 
         c.Header("total", strconv.FormatInt(count, 10))
+        setTransactionsUSDValue(a.priceResolver, transactionsResponse)
         c.JSON(200, transactionsResponse)
     } else {
         c.Header("total", strconv.FormatInt(count, 10))
@@ -463,6 +647,109 @@ func (a *AccountRoutes) GetAccountTransactions(c *gin.Context) {
     }
 }
 
+// GetPendingTransactions returns the transactions of an account waiting for their result, the layer the ones it
+// sent are expected in and its balance once they are applied.
+func (a *AccountRoutes) GetPendingTransactions(c *gin.Context) {
+    accountAddress := c.Param("accountAddress")
+    account, err := a.db.GetAccount(accountAddress)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "status": "Internal Error",
+            "error":  "Failed to fetch account",
+        })
+        return
+    }
+    transactions, err := a.db.GetPendingTransactions(accountAddress)
+    if err != nil {
+        slog.Error("Failed to fetch pending transactions", "account", accountAddress, "error", err)
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "status": "Internal Error",
+            "error":  "Failed to fetch pending transactions",
+        })
+        return
+    }
+    // an account that has not received anything yet only has the transactions sent to it
+    if account.Address == "" && len(transactions) == 0 {
+        c.JSON(http.StatusNotFound, gin.H{
+            "status": "Not Found",
+            "error":  "Account not found",
+        })
+        return
+    }
+    lastCounter, err := a.db.GetLastCounter(accountAddress)
+    if err != nil {
+        slog.Error("Failed to fetch last counter", "account", accountAddress, "error", err)
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "status": "Internal Error",
+            "error":  "Failed to fetch pending transactions",
+        })
+        return
+    }
+
+    response := &types.PendingTransactions{
+        Address:      accountAddress,
+        Balance:      account.Balance,
+        Transactions: make([]*types.PendingTransaction, len(transactions)),
+    }
+    // the transactions of a principal are applied by counter, each needs the next counter and a balance that
+    // pays for it after the ones before it
+    nextLayer := uint32(a.state.GetInfo().Layer) + 1
+    counterKnown := lastCounter >= 0
+    nextCounter := uint64(lastCounter + 1)
+    balance := account.Balance
+    transactionsResponse := make([]*types.Transaction, len(transactions))
+    for i, v := range transactions {
+        timestamp := a.networkUtils.LayerTime(int64(v.Layer))
+        if v.Published > 0 {
+            timestamp = v.Published / 1000
+        }
+        transactionsResponse[i] = &types.Transaction{
+            ID:               v.ID,
+            Status:           v.Status,
+            PrincipalAccount: v.PrincipaAccount,
+            ReceiverAccount:  v.ReceiverAccount,
+            VaultAccount:     v.VaultAccount,
+            Fee:              v.TotalFee(),
+            Amount:           v.Amount,
+            Layer:            v.Layer,
+            Counter:          v.Counter,
+            Method:           methodName(v.Method),
+            Type:             v.Type,
+            Timestamp:        timestamp,
+        }
+        pending := &types.PendingTransaction{Transaction: transactionsResponse[i]}
+        response.Transactions[i] = pending
+        if v.PrincipaAccount != accountAddress {
+            response.PendingReceived += v.Amount
+            continue
+        }
+
+        // a drain moves the coins of the vault, the principal only pays the fee
+        cost := pending.Fee
+        if v.ReceiverAccount != accountAddress && v.Type != transactionparsertypes.TypeDrainVault {
+            cost += v.Amount
+        }
+        switch {
+        case counterKnown && v.Counter < nextCounter:
+            pending.Reason = "the counter was used before"
+        case counterKnown && v.Counter > nextCounter:
+            pending.Reason = "waiting for counter " + strconv.FormatUint(nextCounter, 10)
+        case cost > balance:
+            pending.Reason = "the balance doesn't pay for the amount and the max fee"
+        default:
+            layer := nextLayer
+            pending.EstimatedLayer = &layer
+            balance -= cost
+            response.PendingSent += cost
+            counterKnown = true
+            nextCounter = v.Counter + 1
+        }
+    }
+    response.ProjectedBalance = balance
+    setTransactionsUSDValue(a.priceResolver, transactionsResponse)
+    c.JSON(200, response)
+}
+
 func (a *AccountRoutes) GetAccountRewardsDetails(c *gin.Context) {
     accountAddress := c.Param("accountAddress")
 
@@ -637,8 +924,7 @@ func (a *AccountRoutes) getAccountRewardDetailsForEpoch(c *gin.Context, accountA
         return
     }
 
-    firstLayer := uint32(epoch * config.LayersPerEpoch)
-    lastLayer := firstLayer + config.LayersPerEpoch
+    firstLayer, lastLayer := a.networkUtils.GetEpochLayers(uint64(epoch))
 
     countEpochResult, err := a.db.CountRewards(accountAddress, int(firstLayer), int(lastLayer))
     if err != nil {
@@ -651,7 +937,7 @@ func (a *AccountRoutes) getAccountRewardDetailsForEpoch(c *gin.Context, accountA
 
     sumEpochResult, err := a.db.SumRewardsLayers(accountAddress, firstLayer, lastLayer)
     if err != nil {
-        fmt.Println(err)
+        slog.Error("Failed to sum rewards", "account", accountAddress, "error", err)
         c.JSON(http.StatusInternalServerError, gin.H{
             "status": "Internal Error",
             "error":  "Failed to get epoch rewards sum",
@@ -0,0 +1,288 @@
+package route
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/auth"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/reconcile"
+	"github.com/swarmbit/spacemesh-state-api/sink"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type AdminRoutes struct {
+	readDB        database.ReadDB
+	writeDB       database.WriteDB
+	reconciler    *reconcile.Reconciler
+	authenticator *auth.Authenticator
+	dataSink      *sink.Sink
+}
+
+// NewAdminRoutes creates the admin routes, authenticator is nil when api keys are disabled and dataSink
+// when the instance doesn't run the sink.
+func NewAdminRoutes(readDB database.ReadDB, writeDB database.WriteDB, reconciler *reconcile.Reconciler, authenticator *auth.Authenticator, dataSink *sink.Sink) *AdminRoutes {
+	routes := &AdminRoutes{
+		readDB:        readDB,
+		writeDB:       writeDB,
+		reconciler:    reconciler,
+		authenticator: authenticator,
+		dataSink:      dataSink,
+	}
+	return routes
+}
+
+// AdminAuth only lets through requests with the admin token as bearer token.
+func AdminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearer, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid admin token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (a *AdminRoutes) GetReconcile(c *gin.Context) {
+	c.JSON(200, a.reconciler.Status())
+}
+
+func (a *AdminRoutes) StartReconcile(c *gin.Context) {
+	repair := c.DefaultQuery("repair", "false") == "true"
+
+	if !a.reconciler.RunAsync(repair) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "reconcile already running",
+		})
+		return
+	}
+	c.JSON(http.StatusAccepted, a.reconciler.Status())
+}
+
+func (a *AdminRoutes) ReconcileAccount(c *gin.Context, repair bool) {
+	accountAddress := c.Param("accountAddress")
+
+	drift, err := a.reconciler.Account(accountAddress, repair)
+	if errors.Is(err, reconcile.ErrChanged) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to reconcile account",
+		})
+		return
+	}
+	c.JSON(200, &types.AccountReconcile{
+		Address: accountAddress,
+		Drift:   drift,
+	})
+}
+
+func (a *AdminRoutes) GetApiKeys(c *gin.Context) {
+	keys, err := a.readDB.GetApiKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch api keys",
+		})
+		return
+	}
+
+	keysResponse := make([]*types.ApiKey, len(keys))
+	for i, v := range keys {
+		keysResponse[i] = auth.ApiKeyResponse(v)
+	}
+	c.JSON(200, keysResponse)
+}
+
+func (a *AdminRoutes) IssueApiKey(c *gin.Context) {
+	var req types.ApiKeyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Rate < 0 || req.Burst < 0 || req.DailyQuota < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "rate, burst and dailyQuota must be greater or equal to 0",
+		})
+		return
+	}
+
+	apiKey, err := auth.IssueKey(a.writeDB, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to issue api key",
+		})
+		return
+	}
+	c.JSON(http.StatusCreated, apiKey)
+}
+
+func (a *AdminRoutes) RevokeApiKey(c *gin.Context) {
+	id := c.Param("id")
+
+	revoked, err := a.writeDB.RevokeApiKey(id, time.Now().UnixMilli())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to revoke api key",
+		})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "api key not found or already revoked",
+		})
+		return
+	}
+	if a.authenticator != nil {
+		a.authenticator.Forget(id)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (a *AdminRoutes) GetDeadLetters(c *gin.Context) {
+	stream := c.Query("stream")
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must be a valid integer",
+		})
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be a valid integer",
+		})
+		return
+	}
+	if offset < 0 || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset and limit must be greater or equal to 0",
+		})
+		return
+	}
+
+	count, err := a.readDB.CountDeadLetters(stream)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch dead letters",
+		})
+		return
+	}
+	letters, err := a.readDB.GetDeadLetters(stream, int64(offset), int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch dead letters",
+		})
+		return
+	}
+
+	lettersResponse := make([]*types.DeadLetter, len(letters))
+	for i, v := range letters {
+		lettersResponse[i] = deadLetterResponse(v)
+	}
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, lettersResponse)
+}
+
+func (a *AdminRoutes) GetDeadLetter(c *gin.Context) {
+	letter := a.deadLetter(c)
+	if letter == nil {
+		return
+	}
+	c.JSON(200, deadLetterResponse(letter))
+}
+
+// ReplayDeadLetter saves a dead letter again, it is deleted when that succeeds.
+func (a *AdminRoutes) ReplayDeadLetter(c *gin.Context) {
+	if a.dataSink == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "dead letters are replayed by the instance running the sink",
+		})
+		return
+	}
+	letter := a.deadLetter(c)
+	if letter == nil {
+		return
+	}
+	if err := a.dataSink.Replay(letter); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Failed to replay dead letter: " + err.Error(),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteDeadLetter discards a dead letter that won't be replayed.
+func (a *AdminRoutes) DeleteDeadLetter(c *gin.Context) {
+	deleted, err := a.writeDB.DeleteDeadLetter(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to delete dead letter",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "dead letter not found",
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// deadLetter returns the dead letter of the id param, it answers the request and returns nil when there is none.
+func (a *AdminRoutes) deadLetter(c *gin.Context) *types.DeadLetterDoc {
+	letter, err := a.readDB.GetDeadLetter(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch dead letter",
+		})
+		return nil
+	}
+	if letter.ID == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "dead letter not found",
+		})
+		return nil
+	}
+	return letter
+}
+
+func deadLetterResponse(letter *types.DeadLetterDoc) *types.DeadLetter {
+	return &types.DeadLetter{
+		ID:         letter.ID,
+		Stream:     letter.Stream,
+		Subject:    letter.Subject,
+		Sequence:   letter.Sequence,
+		Published:  letter.Published,
+		Payload:    letter.Payload,
+		Error:      letter.Error,
+		Deliveries: letter.Deliveries,
+		CreatedAt:  letter.CreatedAt,
+	}
+}
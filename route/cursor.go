@@ -0,0 +1,54 @@
+package route
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// cursorToken is the opaque cursor handed to clients, it keeps the sort it was created for.
+type cursorToken struct {
+	Key  int64  `json:"k"`
+	ID   string `json:"i"`
+	Sort int8   `json:"s"`
+}
+
+// parseCursor reads the cursor query parameter, nil if there is none. It writes the bad request
+// response and returns false when the cursor is invalid.
+func parseCursor(c *gin.Context, sort int8) (*types.Cursor, bool) {
+	value := c.Query("cursor")
+	if value == "" {
+		return nil, true
+	}
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	token := &cursorToken{}
+	if err == nil {
+		err = json.Unmarshal(data, token)
+	}
+	if err != nil || token.Sort != sort {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "cursor is not valid for this request",
+		})
+		return nil, false
+	}
+	return &types.Cursor{Key: token.Key, ID: token.ID}, true
+}
+
+// setNextCursor sets the next header to the cursor of the next page and a Link header to its url.
+// Only called for full pages, a shorter one is the last.
+func setNextCursor(c *gin.Context, sort int8, next *types.Cursor) {
+	data, _ := json.Marshal(&cursorToken{Key: next.Key, ID: next.ID, Sort: sort})
+	token := base64.RawURLEncoding.EncodeToString(data)
+
+	url := *c.Request.URL
+	query := url.Query()
+	query.Del("offset")
+	query.Set("cursor", token)
+	url.RawQuery = query.Encode()
+
+	c.Header("next", token)
+	c.Header("Link", "<"+url.RequestURI()+`>; rel="next"`)
+}
@@ -2,7 +2,6 @@ package route
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
 	"github.com/swarmbit/spacemesh-state-api/types"
@@ -11,12 +10,12 @@ import (
 )
 
 type EpochRoutes struct {
-	db           *database.ReadDB
+	db           database.ReadDB
 	networkUtils *network.NetworkUtils
 	state        *network.NetworkState
 }
 
-func NewEpochRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *EpochRoutes {
+func NewEpochRoutes(db database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *EpochRoutes {
 	routes := &EpochRoutes{
 		db:           db,
 		networkUtils: networkUtils,
@@ -53,8 +52,7 @@ func (e *EpochRoutes) GetEpoch(c *gin.Context) {
 		return
 	}
 
-	firstLayer := uint32(epoch * config.LayersPerEpoch)
-	lastLayer := firstLayer + config.LayersPerEpoch
+	firstLayer, lastLayer := e.networkUtils.GetEpochLayers(uint64(epoch))
 
 	rewardsTotal, err := e.db.SumRewardsLayers("", firstLayer, lastLayer)
 	if err != nil {
@@ -116,7 +114,15 @@ func (e *EpochRoutes) GetEpochAtx(c *gin.Context) {
 		sort = 1
 	}
 
-	atxs, errAtx := e.db.GetAtxForEpochPaginated(uint64(epoch-1), int64(offset), int64(limit), sort)
+	after, ok := parseCursor(c, sort)
+	if !ok {
+		return
+	}
+	if after != nil {
+		offset = 0
+	}
+
+	atxs, errAtx := e.db.GetAtxForEpochPaginated(uint64(epoch-1), int64(offset), int64(limit), sort, after)
 	count, errCount := e.db.CountAtxEpoch(uint64(epoch - 1))
 
 	if err != nil {
@@ -145,6 +151,10 @@ func (e *EpochRoutes) GetEpochAtx(c *gin.Context) {
 			}
 		}
 
+		if limit > 0 && len(atxs) == limit {
+			last := atxs[len(atxs)-1]
+			setNextCursor(c, sort, &types.Cursor{Key: int64(last.EffectiveNumUnits), ID: last.AtxID})
+		}
 		c.Header("total", strconv.FormatInt(count, 10))
 		c.JSON(200, atxResponse)
 	} else {
@@ -0,0 +1,89 @@
+package route
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/export"
+)
+
+type ExportRoutes struct {
+	exporter *export.Exporter
+}
+
+func NewExportRoutes(exporter *export.Exporter) *ExportRoutes {
+	routes := &ExportRoutes{
+		exporter: exporter,
+	}
+	return routes
+}
+
+// GetAccountExport streams the rewards and transfers of an account as a file, over a date or a layer range.
+func (e *ExportRoutes) GetAccountExport(c *gin.Context) {
+	format, err := export.ParseFormat(c.DefaultQuery("format", "csv"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	from, err := export.ParseTime(c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be a date or an RFC3339 time",
+		})
+		return
+	}
+	to, err := export.ParseTime(c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "to must be a date or an RFC3339 time",
+		})
+		return
+	}
+	firstLayer, err := strconv.Atoi(c.DefaultQuery("firstLayer", "-1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "firstLayer must be a valid integer",
+		})
+		return
+	}
+	lastLayer, err := strconv.Atoi(c.DefaultQuery("lastLayer", "-1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "lastLayer must be a valid integer",
+		})
+		return
+	}
+	if !from.IsZero() || !to.IsZero() {
+		if firstLayer > -1 || lastLayer > -1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "from and to can't be combined with firstLayer and lastLayer",
+			})
+			return
+		}
+		firstLayer, lastLayer = e.exporter.Layers(from, to)
+	}
+
+	accountAddress := c.Param("accountAddress")
+	c.Header("Content-Type", format.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", accountAddress, format.Extension))
+	c.Status(http.StatusOK)
+
+	if err := e.exporter.Export(c.Writer, format, accountAddress, firstLayer, lastLayer); err != nil {
+		slog.Error("Failed to export account", "account", accountAddress, "format", format.Name, "error", err)
+		if c.Writer.Written() {
+			// the rows already sent can't be taken back, the file ends early
+			return
+		}
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		c.Header("Content-Disposition", "")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to export account",
+		})
+	}
+}
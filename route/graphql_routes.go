@@ -0,0 +1,63 @@
+package route
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/gql"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type GraphQLRoutes struct {
+	executor *gql.Executor
+}
+
+func NewGraphQLRoutes(executor *gql.Executor) *GraphQLRoutes {
+	return &GraphQLRoutes{
+		executor: executor,
+	}
+}
+
+// GetQuery runs a query sent in the query, variables and operationName params.
+func (g *GraphQLRoutes) GetQuery(c *gin.Context) {
+	request := &types.GraphQLRequest{
+		Query:         c.Query("query"),
+		OperationName: c.Query("operationName"),
+	}
+	if request.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "query is required",
+		})
+		return
+	}
+	if variables := c.Query("variables"); variables != "" {
+		if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "variables must be a json object",
+			})
+			return
+		}
+	}
+	g.execute(c, request)
+}
+
+func (g *GraphQLRoutes) PostQuery(c *gin.Context) {
+	var request types.GraphQLRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	g.execute(c, &request)
+}
+
+// execute answers 400 to the queries that didn't run, the errors of the fields of a query that ran are
+// returned with its data.
+func (g *GraphQLRoutes) execute(c *gin.Context, request *types.GraphQLRequest) {
+	result := g.executor.Execute(c.Request.Context(), request)
+	if result.Data == nil && result.HasErrors() {
+		c.JSON(http.StatusBadRequest, result)
+		return
+	}
+	c.JSON(200, result)
+}
@@ -0,0 +1,41 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/health"
+)
+
+type HealthRoutes struct {
+	checker *health.Checker
+}
+
+func NewHealthRoutes(checker *health.Checker) *HealthRoutes {
+	return &HealthRoutes{
+		checker: checker,
+	}
+}
+
+// GetHealth answers as long as the server runs.
+func (h *HealthRoutes) GetHealth(c *gin.Context) {
+	c.JSON(200, gin.H{
+		"status": "ok",
+	})
+}
+
+// GetReady answers 503 while a dependency of the instance fails its check.
+func (h *HealthRoutes) GetReady(c *gin.Context) {
+	checks, ready := h.checker.Run(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "not ready",
+			"checks": checks,
+		})
+		return
+	}
+	c.JSON(200, gin.H{
+		"status": "ready",
+		"checks": checks,
+	})
+}
@@ -2,25 +2,27 @@ package route
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/price"
 	"github.com/swarmbit/spacemesh-state-api/types"
 	"net/http"
 	"strconv"
 )
 
 type LayersRoutes struct {
-	db           *database.ReadDB
-	networkUtils *network.NetworkUtils
-	state        *network.NetworkState
+	db            database.ReadDB
+	networkUtils  *network.NetworkUtils
+	state         *network.NetworkState
+	priceResolver *price.PriceResolver
 }
 
-func NewLayersRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *LayersRoutes {
+func NewLayersRoutes(db database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState, priceResolver *price.PriceResolver) *LayersRoutes {
 	routes := &LayersRoutes{
-		db:           db,
-		networkUtils: networkUtils,
-		state:        state,
+		db:            db,
+		networkUtils:  networkUtils,
+		state:         state,
+		priceResolver: priceResolver,
 	}
 	return routes
 }
@@ -78,6 +80,56 @@ func (l *LayersRoutes) GetLayers(c *gin.Context) {
 	c.JSON(200, layersInt)
 }
 
+func (l *LayersRoutes) GetLayer(c *gin.Context) {
+	layerStr := c.Param("layer")
+
+	layer, err := strconv.Atoi(layerStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "layer must be a valid integer",
+		})
+		return
+	}
+
+	layerDoc, errLayer := l.db.GetLayer(layer)
+	reverts, errReverts := l.db.GetLayerReverts(layer)
+
+	if errLayer != nil || errReverts != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch layer",
+		})
+		return
+	}
+
+	if layerDoc.Status == 0 && layerDoc.AppliedBlock == "" && len(reverts) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Layer not found",
+		})
+		return
+	}
+
+	revertsResponse := make([]*types.LayerRevert, len(reverts))
+	for i, v := range reverts {
+		revertsResponse[i] = &types.LayerRevert{
+			RevertedAt:           v.RevertedAt,
+			Trigger:              v.Trigger,
+			RewardsReverted:      v.RewardsReverted,
+			RewardsAmount:        v.RewardsAmount,
+			TransactionsReverted: v.TransactionsReverted,
+			Blocks:               v.Blocks,
+		}
+	}
+
+	c.JSON(200, &types.Layer{
+		Layer:        int64(layer),
+		Status:       layerDoc.Status,
+		AppliedBlock: layerDoc.AppliedBlock,
+		Timestamp:    l.networkUtils.LayerTime(int64(layer)),
+		Reverts:      revertsResponse,
+	})
+}
+
 func (l *LayersRoutes) GetLayerTransactions(c *gin.Context) {
 	offsetStr := c.DefaultQuery("offset", "0")
 	limitStr := c.DefaultQuery("limit", "20")
@@ -154,16 +206,17 @@ func (l *LayersRoutes) GetLayerTransactions(c *gin.Context) {
 				PrincipalAccount: v.PrincipaAccount,
 				ReceiverAccount:  v.ReceiverAccount,
 				VaultAccount:     v.VaultAccount,
-				Fee:              v.Gas * v.GasPrice,
+				Fee:              v.TotalFee(),
 				Amount:           v.Amount,
 				Layer:            v.Layer,
 				Counter:          v.Counter,
 				Method:           method,
-				Timestamp:        int64(config.GenesisEpochSeconds + (v.Layer * config.LayerDuration)),
+				Timestamp:        l.networkUtils.LayerTime(int64(v.Layer)),
 			}
 		}
 
 		c.Header("total", strconv.FormatInt(count, 10))
+		setTransactionsUSDValue(l.priceResolver, transactionsResponse)
 		c.JSON(200, transactionsResponse)
 	} else {
 		c.Header("total", strconv.FormatInt(count, 10))
@@ -237,11 +290,12 @@ func (l *LayersRoutes) GetLayerRewards(c *gin.Context) {
 				SmesherId:      v.NodeId,
 				// legacy
 				Time:      "2023-09-05T00:00:00Z",
-				Timestamp: config.GenesisEpochSeconds + (v.Layer * config.LayerDuration),
+				Timestamp: l.networkUtils.LayerTime(v.Layer),
 				}
 		}
 
 		c.Header("total", strconv.FormatInt(count, 10))
+		setRewardsUSDValue(l.priceResolver, rewardsResponse)
 		c.JSON(200, rewardsResponse)
 	} else {
 		c.Header("total", strconv.FormatInt(count, 10))
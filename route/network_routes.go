@@ -2,20 +2,65 @@ package route
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
 )
 
 type NetworkRoutes struct {
-	state *network.NetworkState
+	state         *network.NetworkState
+	priceResolver *price.PriceResolver
 }
 
-func NewNetworkRoutes(state *network.NetworkState) *NetworkRoutes {
+func NewNetworkRoutes(state *network.NetworkState, priceResolver *price.PriceResolver) *NetworkRoutes {
 	routes := &NetworkRoutes{
-		state: state,
+		state:         state,
+		priceResolver: priceResolver,
 	}
 	return routes
 }
 
+// GetInfo returns the network state with its price and market cap in the currency query param.
 func (n *NetworkRoutes) GetInfo(c *gin.Context) {
-	c.JSON(200, n.state.GetInfo())
+	currency, ok := parseCurrency(c, n.priceResolver)
+	if !ok {
+		return
+	}
+	info := *n.state.GetInfo()
+	info.Currency = currency
+	info.Price = -1
+	info.MarketCap = 0
+	if price, known := n.priceResolver.Price(currency); known {
+		info.Price = price
+		info.MarketCap = uint64(float64(info.TotalRewards) * price)
+	}
+	c.JSON(200, &info)
+}
+
+// NetworksRoutes lists the networks served by the api.
+type NetworksRoutes struct {
+	networks []*types.Network
+}
+
+func NewNetworksRoutes(profiles []*config.NetworkConfig) *NetworksRoutes {
+	networks := make([]*types.Network, 0, len(profiles))
+	for i, profile := range profiles {
+		networks = append(networks, &types.Network{
+			Name:           profile.Name,
+			Path:           "/" + profile.Name,
+			Hrp:            profile.HRP,
+			Genesis:        profile.Genesis,
+			LayerDuration:  profile.LayerDuration,
+			LayersPerEpoch: profile.LayersPerEpoch,
+			Default:        i == 0,
+		})
+	}
+	return &NetworksRoutes{
+		networks: networks,
+	}
+}
+
+func (n *NetworksRoutes) GetNetworks(c *gin.Context) {
+	c.JSON(200, n.networks)
 }
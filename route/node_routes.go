@@ -1,28 +1,30 @@
 package route
 
 import (
-	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
-	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/price"
 	"github.com/swarmbit/spacemesh-state-api/types"
 )
 
 type NodesRoutes struct {
-	db           *database.ReadDB
-	networkUtils *network.NetworkUtils
-	state        *network.NetworkState
+	db            database.ReadDB
+	networkUtils  *network.NetworkUtils
+	state         *network.NetworkState
+	priceResolver *price.PriceResolver
 }
 
-func NewNodeRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *NodesRoutes {
+func NewNodeRoutes(db database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState, priceResolver *price.PriceResolver) *NodesRoutes {
 	return &NodesRoutes{
-		db:           db,
-		networkUtils: networkUtils,
-		state:        state,
+		db:            db,
+		networkUtils:  networkUtils,
+		state:         state,
+		priceResolver: priceResolver,
 	}
 }
 
@@ -52,7 +54,16 @@ func (n *NodesRoutes) GetNodes(c *gin.Context) {
 		return
 	}
 
-	nodes, errRewards := n.db.GetNodes(int64(offset), int64(limit))
+	// nodes are ordered by id only
+	after, ok := parseCursor(c, 1)
+	if !ok {
+		return
+	}
+	if after != nil {
+		offset = 0
+	}
+
+	nodes, errRewards := n.db.GetNodes(int64(offset), int64(limit), after)
 	count, errCount := n.db.CountNodes()
 
 	if errRewards != nil || errCount != nil {
@@ -62,6 +73,9 @@ func (n *NodesRoutes) GetNodes(c *gin.Context) {
 		})
 	} else if nodes != nil {
 
+		if limit > 0 && len(nodes) == limit {
+			setNextCursor(c, 1, &types.Cursor{ID: nodes[len(nodes)-1].ID})
+		}
 		c.Header("total", strconv.FormatInt(count, 10))
 		c.JSON(200, nodes)
 	} else {
@@ -148,11 +162,12 @@ func (n *NodesRoutes) GetNodeRewards(c *gin.Context) {
 				SmesherId:      v.NodeId,
 				// legacy
 				Time:      "2023-09-05T00:00:00Z",
-				Timestamp: config.GenesisEpochSeconds + (v.Layer * config.LayerDuration),
+				Timestamp: n.networkUtils.LayerTime(v.Layer),
 			}
 		}
 
 		c.Header("total", strconv.FormatInt(count, 10))
+		setRewardsUSDValue(n.priceResolver, rewardsResponse)
 		c.JSON(200, rewardsResponse)
 	} else {
 		c.Header("total", strconv.FormatInt(count, 10))
@@ -166,8 +181,7 @@ func (n *NodesRoutes) GetNodeRewardsDetails(c *gin.Context) {
 	networkInfo := n.state.GetInfo()
 	epoch := networkInfo.Epoch
 
-	firstLayer := uint32(epoch * config.LayersPerEpoch)
-	lastLayer := firstLayer + config.LayersPerEpoch
+	firstLayer, lastLayer := n.networkUtils.GetEpochLayers(uint64(epoch))
 
 	countEpochResult, err := n.db.CountNodeRewardsLayers(nodeId, firstLayer, lastLayer)
 	if err != nil {
@@ -180,7 +194,7 @@ func (n *NodesRoutes) GetNodeRewardsDetails(c *gin.Context) {
 
 	sumEpochResult, err := n.db.SumNodeRewardsLayers(nodeId, firstLayer, lastLayer)
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("Failed to sum node rewards", "node", nodeId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status": "Internal Error",
 			"error":  "Failed to get epoch rewards sum",
@@ -190,7 +204,7 @@ func (n *NodesRoutes) GetNodeRewardsDetails(c *gin.Context) {
 
 	total, err := n.db.SumNodeRewardsLayers(nodeId, 0, uint32(networkInfo.Layer))
 	if err != nil {
-		fmt.Println(err)
+		slog.Error("Failed to sum node rewards", "node", nodeId, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status": "Internal Error",
 			"error":  "Failed to get epoch rewards sum",
@@ -0,0 +1,473 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/export"
+	"github.com/swarmbit/spacemesh-state-api/openapi"
+	"github.com/swarmbit/spacemesh-state-api/stats"
+	"github.com/swarmbit/spacemesh-state-api/stream"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+func offsetParam() *openapi.Param {
+	return &openapi.Param{Name: "offset", In: "query", Type: "integer", Default: 0, Minimum: openapi.Int(0)}
+}
+
+func limitParam() *openapi.Param {
+	return &openapi.Param{Name: "limit", In: "query", Type: "integer", Default: 20, Minimum: openapi.Int(0)}
+}
+
+func sortParam(defaultSort string) *openapi.Param {
+	return &openapi.Param{Name: "sort", In: "query", Type: "string", Enum: []string{"asc", "desc"}, Default: defaultSort}
+}
+
+func cursorParam() *openapi.Param {
+	return &openapi.Param{
+		Name:        "cursor",
+		In:          "query",
+		Type:        "string",
+		Description: "The next header of the previous page, offset is ignored when it is set.",
+	}
+}
+
+func completeParam() *openapi.Param {
+	return &openapi.Param{
+		Name:        "complete",
+		In:          "query",
+		Type:        "boolean",
+		Default:     true,
+		Description: "Only the transactions with a result, false for the ones still in the mempool.",
+	}
+}
+
+func currencyParam() *openapi.Param {
+	return &openapi.Param{
+		Name:        "currency",
+		In:          "query",
+		Type:        "string",
+		Default:     "USD",
+		Description: "Currency of the value and price fields, one of the price currencies of the config, USD, EUR and BTC by default.",
+	}
+}
+
+func pathParam(name string, paramType string) *openapi.Param {
+	param := &openapi.Param{Name: name, In: "path", Type: paramType, Required: true}
+	if paramType == "integer" {
+		param.Minimum = openapi.Int(0)
+	}
+	return param
+}
+
+func streamParams() []*openapi.Param {
+	return []*openapi.Param{
+		{Name: "types", In: "query", Type: "array", Enum: stream.EventTypes},
+		{Name: "account", In: "query", Type: "array"},
+		{Name: "node", In: "query", Type: "array"},
+		{
+			Name:        "fromLayer",
+			In:          "query",
+			Type:        "integer",
+			Minimum:     openapi.Int(0),
+			Description: "Replays the stored events from this layer before the live ones.",
+		},
+		{Name: "apiKey", In: "query", Type: "string", Description: "The api key, for clients that can't set headers."},
+	}
+}
+
+func repairParam() *openapi.Param {
+	return &openapi.Param{Name: "repair", In: "query", Type: "boolean", Default: false}
+}
+
+// operations documents every route of AddRoutes, the validator checks the requests against them and
+// /openapi.json is generated from the ones that are registered.
+func operations() []*openapi.Operation {
+	return []*openapi.Operation{
+		{
+			ID: "getAccounts", Method: http.MethodGet, Path: "/account", Tag: "accounts",
+			Summary:  "Accounts by balance.",
+			Params:   []*openapi.Param{offsetParam(), limitParam(), sortParam("desc"), cursorParam(), currencyParam()},
+			Response: []*types.ShortAccount{}, Total: true, Cursor: true,
+		},
+		{
+			ID: "getAccountGroup", Method: http.MethodPost, Path: "/account/group", Tag: "accounts",
+			Summary: "Summed balances and rewards of a group of accounts.",
+			Params:  []*openapi.Param{currencyParam()},
+			Body:    types.AccounGroupRequest{}, Response: &types.AccountGroupResponse{},
+		},
+		{
+			ID: "getAccountsPost", Method: http.MethodGet, Path: "/account/post/epoch/:epoch", Tag: "accounts",
+			Summary: "Accounts by the space of the atxs published for an epoch.",
+			Params: []*openapi.Param{
+				pathParam("epoch", "integer"), offsetParam(), limitParam(), sortParam("desc"),
+			},
+			Response: []*types.AccountPostResponse{}, Total: true,
+		},
+		{
+			ID: "getAccount", Method: http.MethodGet, Path: "/account/:accountAddress", Tag: "accounts",
+			Summary: "An account, with its template and the vesting of its vaults.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"), currencyParam(),
+				{
+					Name: "layer", In: "query", Type: "integer", Minimum: openapi.Int(0),
+					Description: "Layer the vested amounts of vaults are computed at, the current one by default.",
+				},
+			},
+			Response: &types.Account{},
+		},
+		{
+			ID: "getAccountRewards", Method: http.MethodGet, Path: "/account/:accountAddress/rewards", Tag: "accounts",
+			Summary: "Rewards of an account.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"), offsetParam(), limitParam(), sortParam("asc"), cursorParam(),
+				{Name: "firstLayer", In: "query", Type: "integer", Default: -1},
+				{Name: "lastLayer", In: "query", Type: "integer", Default: -1},
+			},
+			Response: []*types.Reward{}, Total: true, Cursor: true,
+		},
+		{
+			ID: "getAccountTransactions", Method: http.MethodGet, Path: "/account/:accountAddress/transactions", Tag: "accounts",
+			Summary: "Transactions of an account.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"), offsetParam(), limitParam(), sortParam("asc"), completeParam(),
+			},
+			Response: []*types.Transaction{}, Total: true,
+		},
+		{
+			ID: "getAccountPending", Method: http.MethodGet, Path: "/account/:accountAddress/pending", Tag: "accounts",
+			Summary:  "Transactions of an account waiting for their result and its balance once they are applied.",
+			Params:   []*openapi.Param{pathParam("accountAddress", "string")},
+			Response: &types.PendingTransactions{},
+		},
+		{
+			ID: "getAccountExport", Method: http.MethodGet, Path: "/account/:accountAddress/export", Tag: "accounts",
+			Summary: "Rewards and transfers of an account with their time and usd price, as a file for tax reporting.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"),
+				{Name: "format", In: "query", Type: "string", Enum: export.FormatNames(), IgnoreCase: true, Default: "csv"},
+				{Name: "from", In: "query", Type: "string", Description: "A date or an RFC3339 time, the first day of the range."},
+				{Name: "to", In: "query", Type: "string", Description: "A date or an RFC3339 time, the last day of the range."},
+				{Name: "firstLayer", In: "query", Type: "integer", Default: -1},
+				{Name: "lastLayer", In: "query", Type: "integer", Default: -1},
+			},
+			ContentType: "text/csv",
+		},
+		{
+			ID: "getAccountRewardsDetails", Method: http.MethodGet, Path: "/account/:accountAddress/rewards/details", Tag: "accounts",
+			Summary:  "Rewards of an account in the current epoch.",
+			Params:   []*openapi.Param{pathParam("accountAddress", "string")},
+			Response: &types.RewardDetailsEpoch{},
+		},
+		{
+			ID: "getAccountRewardsDetailsEpoch", Method: http.MethodGet, Path: "/account/:accountAddress/rewards/details/:epoch", Tag: "accounts",
+			Summary: "Rewards of an account in an epoch.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"),
+				{Name: "epoch", In: "path", Type: "integer", Required: true, Minimum: openapi.Int(2)},
+			},
+			Response: &types.RewardDetailsEpoch{},
+		},
+		{
+			ID: "getAccountPerformance", Method: http.MethodGet, Path: "/account/:accountAddress/performance", Tag: "accounts",
+			Summary: "Performance of the nodes of an account by ended epoch.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"), offsetParam(), limitParam(), sortParam("desc"),
+			},
+			Response: []*types.AccountPerformance{}, Total: true,
+		},
+		{
+			ID: "getAccountPerformanceEpoch", Method: http.MethodGet, Path: "/account/:accountAddress/performance/:epoch", Tag: "accounts",
+			Summary: "Performance of each node of an account in an ended epoch, the least efficient first.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"),
+				{Name: "epoch", In: "path", Type: "integer", Required: true, Minimum: openapi.Int(2)},
+			},
+			Response: &types.AccountPerformance{},
+		},
+		{
+			ID: "filterEpochActiveNodes", Method: http.MethodPost, Path: "/account/:accountAddress/atx/:epoch/filter-active-nodes", Tag: "accounts",
+			Summary: "The nodes of a list with an atx for an epoch.",
+			Params:  []*openapi.Param{pathParam("accountAddress", "string"), pathParam("epoch", "integer")},
+			Body:    types.NodeFilterRequest{}, Response: &types.ActiveNodesEpoch{},
+		},
+		{
+			ID: "getAccountEpochAtx", Method: http.MethodGet, Path: "/account/:accountAddress/atx/:epoch", Tag: "accounts",
+			Summary: "Atxs of the nodes of an account for an epoch.",
+			Params: []*openapi.Param{
+				pathParam("accountAddress", "string"), pathParam("epoch", "integer"),
+				offsetParam(), limitParam(), sortParam("asc"),
+			},
+			Response: []*types.Atx{}, Total: true,
+		},
+		{
+			ID: "getNetworkInfo", Method: http.MethodGet, Path: "/network/info", Tag: "network",
+			Summary:  "Current state of the network.",
+			Params:   []*openapi.Param{currencyParam()},
+			Response: &types.NetworkInfo{},
+		},
+		{
+			ID: "getNetworks", Method: http.MethodGet, Path: "/networks", Tag: "network",
+			Summary:  "Networks served by the api, the routes of each one are under its path.",
+			Response: []*types.Network{},
+		},
+		{
+			ID: "getPrice", Method: http.MethodGet, Path: "/price", Tag: "price",
+			Summary:  "Latest price of smh, the median of the quotes of the price providers.",
+			Params:   []*openapi.Param{currencyParam()},
+			Response: &types.Price{},
+		},
+		{
+			ID: "getPriceHistory", Method: http.MethodGet, Path: "/price/history", Tag: "price",
+			Summary: "Usd price of smh at the end of every interval of a range, from the stored quotes.",
+			Params: []*openapi.Param{
+				{Name: "from", In: "query", Type: "integer", Minimum: openapi.Int(0), Description: "Unix seconds, 30 days before to by default."},
+				{Name: "to", In: "query", Type: "integer", Minimum: openapi.Int(0), Description: "Unix seconds, now by default."},
+				{Name: "interval", In: "query", Type: "string", Enum: []string{"hour", "day", "week"}, Default: "day"},
+			},
+			Response: []*types.PricePoint{},
+		},
+		{
+			ID: "getNodes", Method: http.MethodGet, Path: "/nodes", Tag: "nodes",
+			Summary:  "Nodes by id.",
+			Params:   []*openapi.Param{offsetParam(), limitParam(), cursorParam()},
+			Response: []*types.NodeDoc{}, Total: true, Cursor: true,
+		},
+		{
+			ID: "getNode", Method: http.MethodGet, Path: "/nodes/:nodeId", Tag: "nodes",
+			Summary:  "A node.",
+			Params:   []*openapi.Param{pathParam("nodeId", "string")},
+			Response: &types.NodeDoc{},
+		},
+		{
+			ID: "getNodeRewards", Method: http.MethodGet, Path: "/nodes/:nodeId/rewards", Tag: "nodes",
+			Summary: "Rewards of a node.",
+			Params: []*openapi.Param{
+				pathParam("nodeId", "string"), offsetParam(), limitParam(), sortParam("asc"),
+			},
+			Response: []*types.Reward{}, Total: true,
+		},
+		{
+			ID: "getNodeRewardsDetails", Method: http.MethodGet, Path: "/nodes/:nodeId/rewards/details", Tag: "nodes",
+			Summary:  "Rewards of a node in the current epoch.",
+			Params:   []*openapi.Param{pathParam("nodeId", "string")},
+			Response: &types.RewardDetails{},
+		},
+		{
+			ID: "getNodeEligibility", Method: http.MethodGet, Path: "/nodes/:nodeId/rewards/eligibility", Tag: "nodes",
+			Summary:  "Eligibility of a node in the current epoch.",
+			Params:   []*openapi.Param{pathParam("nodeId", "string")},
+			Response: &types.Eligibility{},
+		},
+		{
+			ID: "getNodePerformance", Method: http.MethodGet, Path: "/nodes/:nodeId/performance", Tag: "nodes",
+			Summary: "Performance of a node in every ended epoch it had an atx for.",
+			Params: []*openapi.Param{
+				pathParam("nodeId", "string"), offsetParam(), limitParam(), sortParam("desc"),
+			},
+			Response: []*types.NodePerformance{}, Total: true,
+		},
+		{
+			ID: "getEpoch", Method: http.MethodGet, Path: "/epochs/:epoch", Tag: "epochs",
+			Summary:  "An epoch.",
+			Params:   []*openapi.Param{pathParam("epoch", "integer")},
+			Response: &types.Epoch{},
+		},
+		{
+			ID: "getEpochAtx", Method: http.MethodGet, Path: "/epochs/:epoch/atx", Tag: "epochs",
+			Summary: "Atxs published for an epoch.",
+			Params: []*openapi.Param{
+				pathParam("epoch", "integer"), offsetParam(), limitParam(), sortParam("asc"), cursorParam(),
+			},
+			Response: []*types.Atx{}, Total: true, Cursor: true,
+		},
+		{
+			ID: "getStatsSeries", Method: http.MethodGet, Path: "/stats/series", Tag: "stats",
+			Summary: "A statistic of the network for every epoch or day, for charts.",
+			Params: []*openapi.Param{
+				{Name: "metric", In: "query", Type: "string", Enum: stats.MetricNames(), Required: true},
+				{Name: "period", In: "query", Type: "string", Enum: stats.Periods, Default: stats.EpochPeriod},
+				{Name: "from", In: "query", Type: "integer", Minimum: openapi.Int(0), Description: "Unix time in seconds the first period starts at or after."},
+				{Name: "to", In: "query", Type: "integer", Minimum: openapi.Int(0), Description: "Unix time in seconds the last period starts at or before, now by default."},
+			},
+			Response: []*types.StatsPoint{},
+		},
+		{
+			ID: "getLayers", Method: http.MethodGet, Path: "/layers", Tag: "layers",
+			Summary:  "Numbers of the processed layers.",
+			Params:   []*openapi.Param{offsetParam(), limitParam(), sortParam("desc")},
+			Response: []int64{},
+		},
+		{
+			ID: "getLayer", Method: http.MethodGet, Path: "/layers/:layer", Tag: "layers",
+			Summary:  "A layer.",
+			Params:   []*openapi.Param{pathParam("layer", "integer")},
+			Response: &types.Layer{},
+		},
+		{
+			ID: "getLayerTransactions", Method: http.MethodGet, Path: "/layers/:layer/transactions", Tag: "layers",
+			Summary: "Transactions of a layer.",
+			Params: []*openapi.Param{
+				pathParam("layer", "integer"), offsetParam(), limitParam(), sortParam("asc"), completeParam(),
+			},
+			Response: []*types.Transaction{}, Total: true,
+		},
+		{
+			ID: "getLayerRewards", Method: http.MethodGet, Path: "/layers/:layer/rewards", Tag: "layers",
+			Summary: "Rewards of a layer.",
+			Params: []*openapi.Param{
+				pathParam("layer", "integer"), offsetParam(), limitParam(), sortParam("desc"),
+			},
+			Response: []*types.Reward{}, Total: true,
+		},
+		{
+			ID: "getTransactions", Method: http.MethodGet, Path: "/transactions", Tag: "transactions",
+			Summary: "Transactions by layer.",
+			Params: []*openapi.Param{
+				offsetParam(), limitParam(), sortParam("asc"), completeParam(), cursorParam(),
+				{Name: "method", In: "query", Type: "string", Enum: []string{"spawn", "spend", "drainvault"}, IgnoreCase: true},
+				{Name: "minAmount", In: "query", Type: "integer", Default: -1, Description: "Minimum amount in smidge."},
+			},
+			Response: []*types.Transaction{}, Total: true, Cursor: true,
+		},
+		{
+			ID: "getTransaction", Method: http.MethodGet, Path: "/transactions/:transactionId", Tag: "transactions",
+			Summary:  "A transaction, with its decoded payload once it got its result.",
+			Params:   []*openapi.Param{pathParam("transactionId", "string")},
+			Response: &types.Transaction{},
+		},
+		{
+			ID: "decodeTransaction", Method: http.MethodPost, Path: "/transactions/decode", Tag: "transactions",
+			Summary: "Decodes a signed transaction and checks its principal, signatures and counter before it is broadcast.",
+			Body:    types.DecodeTransactionRequest{}, Response: &types.DecodedTransaction{},
+		},
+		{
+			ID: "getPoets", Method: http.MethodGet, Path: "/poets", Tag: "network",
+			Summary:  "Poet servers of the network.",
+			Response: []*config.PoetConfig{},
+		},
+		{
+			ID: "getGraphQL", Method: http.MethodGet, Path: "/graphql", Tag: "graphql",
+			Summary: "Runs a GraphQL query.",
+			Params: []*openapi.Param{
+				{Name: "query", In: "query", Type: "string", Required: true},
+				{Name: "variables", In: "query", Type: "string", Description: "The variables as a json object."},
+				{Name: "operationName", In: "query", Type: "string"},
+			},
+			Response: &graphql.Result{},
+		},
+		{
+			ID: "postGraphQL", Method: http.MethodPost, Path: "/graphql", Tag: "graphql",
+			Summary: "Runs a GraphQL query.",
+			Body:    types.GraphQLRequest{}, Response: &graphql.Result{},
+		},
+		{
+			ID: "getStream", Method: http.MethodGet, Path: "/stream", Tag: "stream",
+			Summary:     "Server sent events of the persisted layers, rewards, transactions and atxs.",
+			Params:      streamParams(),
+			ContentType: "text/event-stream",
+		},
+		{
+			ID: "getStreamWebSocket", Method: http.MethodGet, Path: "/stream/ws", Tag: "stream",
+			Summary: "The events of /stream over a WebSocket.",
+			Params:  streamParams(),
+			Status:  http.StatusSwitchingProtocols,
+		},
+		{
+			ID: "getWebhooks", Method: http.MethodGet, Path: "/webhooks", Tag: "webhooks",
+			Summary:  "Webhooks of the api key.",
+			Response: []*types.Webhook{},
+		},
+		{
+			ID: "createWebhook", Method: http.MethodPost, Path: "/webhooks", Tag: "webhooks",
+			Summary: "Registers a webhook, the secret is only returned here.",
+			Body:    types.WebhookRequest{}, Response: &types.Webhook{}, Status: http.StatusCreated,
+		},
+		{
+			ID: "getWebhook", Method: http.MethodGet, Path: "/webhooks/:id", Tag: "webhooks",
+			Summary:  "A webhook.",
+			Params:   []*openapi.Param{pathParam("id", "string")},
+			Response: &types.Webhook{},
+		},
+		{
+			ID: "deleteWebhook", Method: http.MethodDelete, Path: "/webhooks/:id", Tag: "webhooks",
+			Summary: "Deletes a webhook.",
+			Params:  []*openapi.Param{pathParam("id", "string")},
+			Status:  http.StatusNoContent,
+		},
+		{
+			ID: "getWebhookDeliveries", Method: http.MethodGet, Path: "/webhooks/:id/deliveries", Tag: "webhooks",
+			Summary:  "Deliveries of a webhook, newest first.",
+			Params:   []*openapi.Param{pathParam("id", "string"), offsetParam(), limitParam()},
+			Response: []*types.WebhookDelivery{}, Total: true,
+		},
+		{
+			ID: "getReconcile", Method: http.MethodGet, Path: "/admin/reconcile", Tag: "admin",
+			Summary:  "Status of the reconciler.",
+			Response: &types.ReconcileStatus{}, Admin: true,
+		},
+		{
+			ID: "startReconcile", Method: http.MethodPost, Path: "/admin/reconcile", Tag: "admin",
+			Summary:  "Starts a reconcile of all the accounts.",
+			Params:   []*openapi.Param{repairParam()},
+			Response: &types.ReconcileStatus{}, Status: http.StatusAccepted, Admin: true,
+		},
+		{
+			ID: "checkAccount", Method: http.MethodGet, Path: "/admin/reconcile/account/:accountAddress", Tag: "admin",
+			Summary:  "Drift of the stored balances of an account.",
+			Params:   []*openapi.Param{pathParam("accountAddress", "string")},
+			Response: &types.AccountReconcile{}, Admin: true,
+		},
+		{
+			ID: "reconcileAccount", Method: http.MethodPost, Path: "/admin/reconcile/account/:accountAddress", Tag: "admin",
+			Summary:  "Reconciles an account.",
+			Params:   []*openapi.Param{pathParam("accountAddress", "string"), repairParam()},
+			Response: &types.AccountReconcile{}, Admin: true,
+		},
+		{
+			ID: "getApiKeys", Method: http.MethodGet, Path: "/admin/keys", Tag: "admin",
+			Summary:  "Api keys stored in the db.",
+			Response: []*types.ApiKey{}, Admin: true,
+		},
+		{
+			ID: "issueApiKey", Method: http.MethodPost, Path: "/admin/keys", Tag: "admin",
+			Summary: "Issues an api key, the key is only returned here.",
+			Body:    types.ApiKeyRequest{}, Response: &types.ApiKey{}, Status: http.StatusCreated, Admin: true,
+		},
+		{
+			ID: "revokeApiKey", Method: http.MethodDelete, Path: "/admin/keys/:id", Tag: "admin",
+			Summary: "Revokes an api key.",
+			Params:  []*openapi.Param{pathParam("id", "string")},
+			Status:  http.StatusNoContent, Admin: true,
+		},
+		{
+			ID: "getDeadLetters", Method: http.MethodGet, Path: "/admin/dead-letters", Tag: "admin",
+			Summary: "Stream messages the sink gave up on, the most recent first.",
+			Params: []*openapi.Param{
+				{Name: "stream", In: "query", Type: "string", Description: "only the dead letters of this sink consumer",
+					Enum: []string{"layers", "rewards", "atx", "transactions.result", "transactions.created", "malfeasance"}},
+				offsetParam(), limitParam(),
+			},
+			Response: []*types.DeadLetter{}, Total: true, Admin: true,
+		},
+		{
+			ID: "getDeadLetter", Method: http.MethodGet, Path: "/admin/dead-letters/:id", Tag: "admin",
+			Summary:  "A dead letter with its payload and error.",
+			Params:   []*openapi.Param{pathParam("id", "string")},
+			Response: &types.DeadLetter{}, Admin: true,
+		},
+		{
+			ID: "replayDeadLetter", Method: http.MethodPost, Path: "/admin/dead-letters/:id/replay", Tag: "admin",
+			Summary: "Saves a dead letter again and deletes it, only on the instance running the sink.",
+			Params:  []*openapi.Param{pathParam("id", "string")},
+			Status:  http.StatusNoContent, Admin: true,
+		},
+		{
+			ID: "deleteDeadLetter", Method: http.MethodDelete, Path: "/admin/dead-letters/:id", Tag: "admin",
+			Summary: "Discards a dead letter.",
+			Params:  []*openapi.Param{pathParam("id", "string")},
+			Status:  http.StatusNoContent, Admin: true,
+		},
+	}
+}
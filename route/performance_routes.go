@@ -0,0 +1,211 @@
+package route
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/stats"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type PerformanceRoutes struct {
+	db database.ReadDB
+}
+
+func NewPerformanceRoutes(db database.ReadDB) *PerformanceRoutes {
+	routes := &PerformanceRoutes{
+		db: db,
+	}
+	return routes
+}
+
+// GetNodePerformance returns the performance of a node in every ended epoch it had an atx for.
+func (p *PerformanceRoutes) GetNodePerformance(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+	sortStr := c.DefaultQuery("sort", "desc")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must be a valid integer",
+		})
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be a valid integer",
+		})
+		return
+	}
+
+	if offset < 0 || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset and limit must be greater or equal to 0",
+		})
+		return
+	}
+
+	var sort int8
+	if sortStr == "asc" {
+		sort = 1
+	} else {
+		sort = -1
+	}
+
+	nodeId := c.Param("nodeId")
+	docs, errDocs := p.db.GetNodePerformance(nodeId, int64(offset), int64(limit), sort)
+	count, errCount := p.db.CountNodePerformance(nodeId)
+	if errDocs != nil || errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch performance for node",
+		})
+		return
+	}
+
+	performance := make([]*types.NodePerformance, len(docs))
+	for i, doc := range docs {
+		performance[i] = nodePerformance(doc)
+	}
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, performance)
+}
+
+// GetAccountPerformance returns the performance of the nodes of an account summed by ended epoch.
+func (p *PerformanceRoutes) GetAccountPerformance(c *gin.Context) {
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+	sortStr := c.DefaultQuery("sort", "desc")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must be a valid integer",
+		})
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be a valid integer",
+		})
+		return
+	}
+
+	if offset < 0 || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset and limit must be greater or equal to 0",
+		})
+		return
+	}
+
+	var sort int8
+	if sortStr == "asc" {
+		sort = 1
+	} else {
+		sort = -1
+	}
+
+	accountAddress := c.Param("accountAddress")
+	epochs, errEpochs := p.db.GetAccountPerformanceEpochs(accountAddress, int64(offset), int64(limit), sort)
+	count, errCount := p.db.CountAccountPerformanceEpochs(accountAddress)
+	if errEpochs != nil || errCount != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch performance for account",
+		})
+		return
+	}
+
+	performance := make([]*types.AccountPerformance, len(epochs))
+	for i, epoch := range epochs {
+		performance[i] = &types.AccountPerformance{
+			Epoch:           epoch.Epoch,
+			Nodes:           epoch.Nodes,
+			NoRewardsNodes:  epoch.NoRewardsNodes,
+			EligibleSlots:   epoch.EligibleSlots,
+			RewardedSlots:   epoch.RewardedSlots,
+			MissedSlots:     epoch.MissedSlots,
+			ExpectedRewards: uint64(epoch.ExpectedRewards),
+			Rewards:         uint64(epoch.Rewards),
+			Efficiency:      stats.Efficiency(uint64(epoch.Rewards), uint64(epoch.ExpectedRewards)),
+		}
+	}
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, performance)
+}
+
+// GetAccountPerformanceEpoch returns the performance of the nodes of an account in an ended epoch, the least
+// efficient first.
+func (p *PerformanceRoutes) GetAccountPerformanceEpoch(c *gin.Context) {
+	epoch, err := strconv.ParseInt(c.Param("epoch"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "epoch must be a valid integer",
+		})
+		return
+	}
+	if epoch < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "epoch should be equal or greater than 2",
+		})
+		return
+	}
+
+	accountAddress := c.Param("accountAddress")
+	docs, err := p.db.GetAccountPerformance(accountAddress, epoch)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch performance for account",
+		})
+		return
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": "Not Found",
+			"error":  "No performance of the account for the epoch, it has no nodes or it hasn't ended",
+		})
+		return
+	}
+
+	performance := &types.AccountPerformance{
+		Epoch:            epoch,
+		Nodes:            int64(len(docs)),
+		NodesPerformance: make([]*types.NodePerformance, len(docs)),
+	}
+	for i, doc := range docs {
+		if doc.NoRewards {
+			performance.NoRewardsNodes++
+		}
+		performance.EligibleSlots += doc.EligibleSlots
+		performance.RewardedSlots += doc.RewardedSlots
+		performance.MissedSlots += doc.MissedSlots
+		performance.ExpectedRewards += doc.ExpectedRewards
+		performance.Rewards += doc.Rewards
+		performance.NodesPerformance[i] = nodePerformance(doc)
+	}
+	performance.Efficiency = stats.Efficiency(performance.Rewards, performance.ExpectedRewards)
+	c.JSON(200, performance)
+}
+
+func nodePerformance(doc *types.NodePerformanceDoc) *types.NodePerformance {
+	return &types.NodePerformance{
+		NodeID:            doc.NodeID,
+		Coinbase:          doc.Coinbase,
+		Epoch:             doc.Epoch,
+		Weight:            doc.Weight,
+		EffectiveNumUnits: doc.EffectiveNumUnits,
+		EligibleSlots:     doc.EligibleSlots,
+		RewardedSlots:     doc.RewardedSlots,
+		MissedSlots:       doc.MissedSlots,
+		ExpectedRewards:   doc.ExpectedRewards,
+		Rewards:           doc.Rewards,
+		Efficiency:        doc.Efficiency,
+		NoRewards:         doc.NoRewards,
+	}
+}
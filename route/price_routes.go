@@ -0,0 +1,158 @@
+package route
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// the intervals of the price history, in seconds
+var priceIntervals = map[string]int64{
+	"hour": 60 * 60,
+	"day":  24 * 60 * 60,
+	"week": 7 * 24 * 60 * 60,
+}
+
+// most points of a price history response
+const maxPricePoints = 1000
+
+const smidgePerSmh = 1_000_000_000
+
+type PriceRoutes struct {
+	priceResolver *price.PriceResolver
+}
+
+func NewPriceRoutes(priceResolver *price.PriceResolver) *PriceRoutes {
+	routes := &PriceRoutes{
+		priceResolver: priceResolver,
+	}
+	return routes
+}
+
+func (p *PriceRoutes) GetPrice(c *gin.Context) {
+	currency, ok := parseCurrency(c, p.priceResolver)
+	if !ok {
+		return
+	}
+	quote := p.priceResolver.GetQuote(currency)
+	if quote == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Price not fetched yet",
+		})
+		return
+	}
+	c.JSON(200, quote)
+}
+
+func (p *PriceRoutes) GetPriceHistory(c *gin.Context) {
+	history := p.priceResolver.History()
+	if history == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status": "Not Found",
+			"error":  "Price history is not kept",
+		})
+		return
+	}
+
+	now := time.Now().Unix()
+	to, err := strconv.ParseInt(c.DefaultQuery("to", strconv.FormatInt(now, 10)), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "to must be a valid integer",
+		})
+		return
+	}
+	from, err := strconv.ParseInt(c.DefaultQuery("from", strconv.FormatInt(to-30*priceIntervals["day"], 10)), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be a valid integer",
+		})
+		return
+	}
+	interval, ok := priceIntervals[c.DefaultQuery("interval", "day")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "interval must be hour, day or week",
+		})
+		return
+	}
+	if from < 0 || from > to {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be greater or equal to 0 and not after to",
+		})
+		return
+	}
+	if (to-from)/interval >= maxPricePoints {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "too many intervals, use a longer interval or a shorter range",
+		})
+		return
+	}
+
+	points, err := history.Series(from, to, interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch price history",
+		})
+		return
+	}
+	c.JSON(200, points)
+}
+
+// setRewardsUSDValue sets the usd value of rewards at the price of their time.
+func setRewardsUSDValue(priceResolver *price.PriceResolver, rewards []*types.Reward) {
+	timestamps := make([]int64, len(rewards))
+	for i, reward := range rewards {
+		timestamps[i] = reward.Timestamp
+	}
+	for i, usdPrice := range priceResolver.PricesAt(timestamps) {
+		rewards[i].USDValue = usdValue(usdPrice, uint64(rewards[i].Rewards))
+	}
+}
+
+// setTransactionsUSDValue sets the usd value of the amount of transactions at the price of their time.
+func setTransactionsUSDValue(priceResolver *price.PriceResolver, transactions []*types.Transaction) {
+	timestamps := make([]int64, len(transactions))
+	for i, transaction := range transactions {
+		timestamps[i] = transaction.Timestamp
+	}
+	for i, usdPrice := range priceResolver.PricesAt(timestamps) {
+		transactions[i].USDValue = usdValue(usdPrice, transactions[i].Amount)
+	}
+}
+
+// usdValue is the value of an amount of smidge in the unit of the usdValue fields, -1 when price is unknown.
+func usdValue(price float64, amount uint64) int64 {
+	if price < 0 {
+		return -1
+	}
+	return int64(price * float64(amount))
+}
+
+// parseCurrency returns the currency query param in upper case, USD by default, and answers 400 when the price
+// isn't quoted in it.
+func parseCurrency(c *gin.Context, priceResolver *price.PriceResolver) (string, bool) {
+	currency := strings.ToUpper(c.DefaultQuery("currency", "USD"))
+	if !priceResolver.HasCurrency(currency) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "currency must be one of " + strings.Join(priceResolver.Currencies(), ", "),
+		})
+		return "", false
+	}
+	return currency, true
+}
+
+// currencyValue is the value of an amount of smidge at a price in smh, nil when the price is unknown.
+func currencyValue(price float64, known bool, amount uint64) *float64 {
+	if !known {
+		return nil
+	}
+	value := price * float64(amount) / smidgePerSmh
+	return &value
+}
@@ -1,123 +1,402 @@
 package route
 
 import (
+	"log/slog"
+	"os"
+
 	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/auth"
 	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/export"
+	"github.com/swarmbit/spacemesh-state-api/gql"
+	"github.com/swarmbit/spacemesh-state-api/health"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
 	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/openapi"
 	"github.com/swarmbit/spacemesh-state-api/price"
-	"log"
+	"github.com/swarmbit/spacemesh-state-api/reconcile"
+	"github.com/swarmbit/spacemesh-state-api/sink"
+	"github.com/swarmbit/spacemesh-state-api/stream"
 )
 
-func AddRoutes(readDB *database.ReadDB, router *gin.Engine, priceResolver *price.PriceResolver, configValues *config.Config) {
-	networkUtils := network.NewNetworkUtils()
-	log.Println("Created network utils")
+// Network is what the routes of a network are served from.
+type Network struct {
+	Config     *config.NetworkConfig
+	ReadDB     database.ReadDB
+	WriteDB    database.WriteDB
+	Reconciler *reconcile.Reconciler
+	// Hub is nil when the network doesn't serve /stream
+	Hub *stream.Hub
+	// Sink is nil when this instance doesn't consume the streams of the network
+	Sink *sink.Sink
+	// State is the network info and subsidies refreshed in the background, AddRoutes creates it
+	State *network.NetworkState
+}
+
+// AddRoutes serves every network under /{name}, the first one at the root paths too. Api keys belong to the
+// db of the first network.
+func AddRoutes(router *gin.Engine, networks []*Network, priceResolver *price.PriceResolver, configValues *config.Config, authenticator *auth.Authenticator, checker *health.Checker) {
+	names := make([]string, 0, len(networks))
+	profiles := make([]*config.NetworkConfig, 0, len(networks))
+	for _, n := range networks {
+		names = append(names, n.Config.Name)
+		profiles = append(profiles, n.Config)
+	}
+
+	// the validator has to be added before the routes to run on them
+	spec := openapi.NewSpec(operations())
+	spec.Networks(names)
+	router.Use(spec.Validator())
+
+	for i, n := range networks {
+		group := routeGroups{router.Group("/" + n.Config.Name)}
+		if i == 0 {
+			group = append(group, &router.RouterGroup)
+		}
+		addNetworkRoutes(group, n, priceResolver, configValues)
+	}
+
+	networksRoutes := NewNetworksRoutes(profiles)
+
+	router.GET("/networks", func(c *gin.Context) {
+		networksRoutes.GetNetworks(c)
+	})
+
+	priceRoutes := NewPriceRoutes(priceResolver)
+
+	router.GET("/price", func(c *gin.Context) {
+		priceRoutes.GetPrice(c)
+	})
+
+	router.GET("/price/history", func(c *gin.Context) {
+		priceRoutes.GetPriceHistory(c)
+	})
+
+	if configValues.Admin != nil && configValues.Admin.Token != "" {
+		adminRoutes := NewAdminRoutes(networks[0].ReadDB, networks[0].WriteDB, networks[0].Reconciler, authenticator, networks[0].Sink)
+		admin := router.Group("/admin", AdminAuth(configValues.Admin.Token))
+
+		admin.GET("/keys", func(c *gin.Context) {
+			adminRoutes.GetApiKeys(c)
+		})
+
+		admin.POST("/keys", func(c *gin.Context) {
+			adminRoutes.IssueApiKey(c)
+		})
+
+		admin.DELETE("/keys/:id", func(c *gin.Context) {
+			adminRoutes.RevokeApiKey(c)
+		})
+	}
+
+	info := &openapi.Info{
+		Title:       "Spacemesh State API",
+		Version:     "1.0.0",
+		Description: "Accounts, rewards, nodes, epochs, layers and transactions of Spacemesh networks.",
+	}
+	if configValues.Auth != nil && configValues.Auth.Enabled {
+		info.ApiKey = true
+		info.KeyRequired = configValues.Auth.RequireKey
+	}
+	document, err := spec.Document(info, router.Routes())
+	if err != nil {
+		slog.Error("Failed to create openapi document", "error", err)
+		os.Exit(1)
+	}
+
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.Data(200, "application/json; charset=utf-8", document)
+	})
+
+	router.GET("/docs", func(c *gin.Context) {
+		c.Data(200, "text/html; charset=utf-8", openapi.DocsPage)
+	})
+
+	healthRoutes := NewHealthRoutes(checker)
+
+	router.GET("/healthz", func(c *gin.Context) {
+		healthRoutes.GetHealth(c)
+	})
+
+	router.GET("/readyz", func(c *gin.Context) {
+		healthRoutes.GetReady(c)
+	})
+
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	slog.Info("Added routes")
+}
+
+// addNetworkRoutes adds the routes of a network to the groups it is served under.
+func addNetworkRoutes(group routeGroups, n *Network, priceResolver *price.PriceResolver, configValues *config.Config) {
+	readDB := n.ReadDB
+	networkUtils := network.NewNetworkUtils(n.Config)
+	slog.Info("Created network utils", "network", n.Config.Name)
 	state := network.NewNetworkState(readDB, networkUtils, priceResolver)
-	log.Println("Created state")
+	n.State = state
+	slog.Info("Created state", "network", n.Config.Name)
 	accountRoutes := NewAccountRoutes(readDB, networkUtils, state, priceResolver)
-	networkRoutes := NewNetworkRoutes(state)
+	networkRoutes := NewNetworkRoutes(state, priceResolver)
 	poetRoutes := NewPoetRoutes(configValues)
-	nodeRoutes := NewNodeRoutes(readDB, networkUtils, state)
+	nodeRoutes := NewNodeRoutes(readDB, networkUtils, state, priceResolver)
 	epochRoutes := NewEpochRoutes(readDB, networkUtils, state)
-	layersRoutes := NewLayersRoutes(readDB, networkUtils, state)
-	transactionRoutes := NewTransactionRoutes(readDB, networkUtils, state)
+	statsRoutes := NewStatsRoutes(readDB)
+	performanceRoutes := NewPerformanceRoutes(readDB)
+	layersRoutes := NewLayersRoutes(readDB, networkUtils, state, priceResolver)
+	transactionRoutes := NewTransactionRoutes(readDB, n.Config, networkUtils, state, priceResolver)
+	exportRoutes := NewExportRoutes(export.NewExporter(readDB, n.Config, priceResolver))
 
-	router.GET("/account", func(c *gin.Context) {
+	group.GET("/account", func(c *gin.Context) {
 		accountRoutes.GetAccounts(c)
 	})
 
-	router.POST("/account/group", func(c *gin.Context) {
+	group.POST("/account/group", func(c *gin.Context) {
 		accountRoutes.GetAccountGroup(c)
 	})
 
-	router.GET("/account/post/epoch/:epoch", func(c *gin.Context) {
+	group.GET("/account/post/epoch/:epoch", func(c *gin.Context) {
 		accountRoutes.GetAccountsPost(c)
 	})
 
-	router.GET("/account/:accountAddress", func(c *gin.Context) {
+	group.GET("/account/:accountAddress", func(c *gin.Context) {
 		accountRoutes.GetAccount(c)
 	})
 
-	router.GET("/account/:accountAddress/rewards", func(c *gin.Context) {
+	group.GET("/account/:accountAddress/rewards", func(c *gin.Context) {
 		accountRoutes.GetAccountRewards(c)
 	})
 
-	router.GET("/account/:accountAddress/transactions", func(c *gin.Context) {
+	group.GET("/account/:accountAddress/transactions", func(c *gin.Context) {
 		accountRoutes.GetAccountTransactions(c)
 	})
 
-	router.GET("/account/:accountAddress/rewards/details", func(c *gin.Context) {
+	group.GET("/account/:accountAddress/pending", func(c *gin.Context) {
+		accountRoutes.GetPendingTransactions(c)
+	})
+
+	group.GET("/account/:accountAddress/export", func(c *gin.Context) {
+		exportRoutes.GetAccountExport(c)
+	})
+
+	group.GET("/account/:accountAddress/rewards/details", func(c *gin.Context) {
 		accountRoutes.GetAccountRewardsDetails(c)
 	})
 
-	router.GET("/account/:accountAddress/rewards/details/:epoch", func(c *gin.Context) {
+	group.GET("/account/:accountAddress/rewards/details/:epoch", func(c *gin.Context) {
 		accountRoutes.GetAccountRewardsDetailsEpoch(c)
 	})
 
-	router.POST("/account/:accountAddress/atx/:epoch/filter-active-nodes", func(c *gin.Context) {
+	group.GET("/account/:accountAddress/performance", func(c *gin.Context) {
+		performanceRoutes.GetAccountPerformance(c)
+	})
+
+	group.GET("/account/:accountAddress/performance/:epoch", func(c *gin.Context) {
+		performanceRoutes.GetAccountPerformanceEpoch(c)
+	})
+
+	group.POST("/account/:accountAddress/atx/:epoch/filter-active-nodes", func(c *gin.Context) {
 		accountRoutes.FilterEpochActiveNodes(c)
 	})
 
-	router.GET("/account/:accountAddress/atx/:epoch", func(c *gin.Context) {
+	group.GET("/account/:accountAddress/atx/:epoch", func(c *gin.Context) {
 		accountRoutes.GetEpochAtx(c)
 	})
 
-	router.GET("/network/info", func(c *gin.Context) {
+	group.GET("/network/info", func(c *gin.Context) {
 		networkRoutes.GetInfo(c)
 	})
 
-	router.GET("/nodes", func(c *gin.Context) {
+	group.GET("/nodes", func(c *gin.Context) {
 		nodeRoutes.GetNodes(c)
 	})
-	
-	router.GET("/nodes/:nodeId", func(c *gin.Context) {
+
+	group.GET("/nodes/:nodeId", func(c *gin.Context) {
 		nodeRoutes.GetNode(c)
 	})
 
-	router.GET("/nodes/:nodeId/rewards", func(c *gin.Context) {
+	group.GET("/nodes/:nodeId/rewards", func(c *gin.Context) {
 		nodeRoutes.GetNodeRewards(c)
 	})
 
-	router.GET("/nodes/:nodeId/rewards/details", func(c *gin.Context) {
+	group.GET("/nodes/:nodeId/rewards/details", func(c *gin.Context) {
 		nodeRoutes.GetNodeRewardsDetails(c)
 	})
 
-	router.GET("/nodes/:nodeId/rewards/eligibility", func(c *gin.Context) {
+	group.GET("/nodes/:nodeId/rewards/eligibility", func(c *gin.Context) {
 		nodeRoutes.GetEligibility(c)
 	})
 
-	router.GET("/epochs/:epoch", func(c *gin.Context) {
+	group.GET("/nodes/:nodeId/performance", func(c *gin.Context) {
+		performanceRoutes.GetNodePerformance(c)
+	})
+
+	group.GET("/epochs/:epoch", func(c *gin.Context) {
 		epochRoutes.GetEpoch(c)
 	})
 
-	router.GET("/epochs/:epoch/atx", func(c *gin.Context) {
+	group.GET("/epochs/:epoch/atx", func(c *gin.Context) {
 		epochRoutes.GetEpochAtx(c)
 	})
 
-	router.GET("/layers", func(c *gin.Context) {
+	group.GET("/stats/series", func(c *gin.Context) {
+		statsRoutes.GetSeries(c)
+	})
+
+	group.GET("/layers", func(c *gin.Context) {
 		layersRoutes.GetLayers(c)
 	})
 
-	router.GET("/layers/:layer/transactions", func(c *gin.Context) {
+	group.GET("/layers/:layer", func(c *gin.Context) {
+		layersRoutes.GetLayer(c)
+	})
+
+	group.GET("/layers/:layer/transactions", func(c *gin.Context) {
 		layersRoutes.GetLayerTransactions(c)
 	})
 
-	router.GET("/layers/:layer/rewards", func(c *gin.Context) {
+	group.GET("/layers/:layer/rewards", func(c *gin.Context) {
 		layersRoutes.GetLayerRewards(c)
 	})
 
-	router.GET("/transactions", func(c *gin.Context) {
+	group.GET("/transactions", func(c *gin.Context) {
 		transactionRoutes.GetTransactions(c)
 	})
 
-	router.GET("/transactions/:transactionId", func(c *gin.Context) {
+	group.POST("/transactions/decode", func(c *gin.Context) {
+		transactionRoutes.DecodeTransaction(c)
+	})
+
+	group.GET("/transactions/:transactionId", func(c *gin.Context) {
 		transactionRoutes.GetTransaction(c)
 	})
 
-	router.GET("/poets", func(c *gin.Context) {
+	group.GET("/poets", func(c *gin.Context) {
 		poetRoutes.GetPoets(c)
 	})
 
-	log.Println("Added routes")
+	if configValues.GraphQL != nil && configValues.GraphQL.Enabled {
+		executor, err := gql.NewExecutor(configValues.GraphQL, readDB, networkUtils, state, priceResolver)
+		if err != nil {
+			slog.Error("Failed to create graphql schema", "error", err)
+			os.Exit(1)
+		}
+		graphQLRoutes := NewGraphQLRoutes(executor)
+
+		group.GET("/graphql", func(c *gin.Context) {
+			graphQLRoutes.GetQuery(c)
+		})
+
+		group.POST("/graphql", func(c *gin.Context) {
+			graphQLRoutes.PostQuery(c)
+		})
+	}
+
+	if n.Hub != nil {
+		streamRoutes := NewStreamRoutes(readDB, n.Hub)
+
+		group.GET("/stream", func(c *gin.Context) {
+			streamRoutes.GetEvents(c)
+		})
+
+		group.GET("/stream/ws", func(c *gin.Context) {
+			streamRoutes.GetWebSocket(c)
+		})
+	}
+
+	if configValues.Webhooks != nil && configValues.Webhooks.Enabled {
+		if configValues.Auth != nil && configValues.Auth.Enabled {
+			webhookRoutes := NewWebhookRoutes(readDB, n.WriteDB, configValues.Webhooks.MaxPerKey)
+
+			group.GET("/webhooks", func(c *gin.Context) {
+				webhookRoutes.GetWebhooks(c)
+			})
+
+			group.POST("/webhooks", func(c *gin.Context) {
+				webhookRoutes.CreateWebhook(c)
+			})
+
+			group.GET("/webhooks/:id", func(c *gin.Context) {
+				webhookRoutes.GetWebhook(c)
+			})
+
+			group.DELETE("/webhooks/:id", func(c *gin.Context) {
+				webhookRoutes.DeleteWebhook(c)
+			})
+
+			group.GET("/webhooks/:id/deliveries", func(c *gin.Context) {
+				webhookRoutes.GetWebhookDeliveries(c)
+			})
+		} else {
+			slog.Warn("Webhooks belong to api keys, enable auth to serve /webhooks")
+		}
+	}
+
+	if configValues.Admin != nil && configValues.Admin.Token != "" {
+		// the api keys are served at the root only
+		adminRoutes := NewAdminRoutes(readDB, n.WriteDB, n.Reconciler, nil, n.Sink)
+		admin := group.Group("/admin", AdminAuth(configValues.Admin.Token))
+
+		admin.GET("/reconcile", func(c *gin.Context) {
+			adminRoutes.GetReconcile(c)
+		})
+
+		admin.POST("/reconcile", func(c *gin.Context) {
+			adminRoutes.StartReconcile(c)
+		})
+
+		admin.GET("/reconcile/account/:accountAddress", func(c *gin.Context) {
+			adminRoutes.ReconcileAccount(c, false)
+		})
+
+		admin.POST("/reconcile/account/:accountAddress", func(c *gin.Context) {
+			adminRoutes.ReconcileAccount(c, c.DefaultQuery("repair", "false") == "true")
+		})
+
+		admin.GET("/dead-letters", func(c *gin.Context) {
+			adminRoutes.GetDeadLetters(c)
+		})
+
+		admin.GET("/dead-letters/:id", func(c *gin.Context) {
+			adminRoutes.GetDeadLetter(c)
+		})
+
+		admin.POST("/dead-letters/:id/replay", func(c *gin.Context) {
+			adminRoutes.ReplayDeadLetter(c)
+		})
+
+		admin.DELETE("/dead-letters/:id", func(c *gin.Context) {
+			adminRoutes.DeleteDeadLetter(c)
+		})
+	}
+}
+
+// routeGroups adds each route to all of its groups, the handlers of a network are shared by the paths it is
+// served under.
+type routeGroups []*gin.RouterGroup
+
+func (g routeGroups) GET(path string, handler gin.HandlerFunc) {
+	for _, group := range g {
+		group.GET(path, handler)
+	}
+}
+
+func (g routeGroups) POST(path string, handler gin.HandlerFunc) {
+	for _, group := range g {
+		group.POST(path, handler)
+	}
+}
+
+func (g routeGroups) DELETE(path string, handler gin.HandlerFunc) {
+	for _, group := range g {
+		group.DELETE(path, handler)
+	}
+}
 
+func (g routeGroups) Group(path string, handlers ...gin.HandlerFunc) routeGroups {
+	groups := make(routeGroups, 0, len(g))
+	for _, group := range g {
+		groups = append(groups, group.Group(path, handlers...))
+	}
+	return groups
 }
@@ -0,0 +1,610 @@
+package route
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/oasisprotocol/curve25519-voi/primitives/ed25519"
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk/multisig"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk/vesting"
+	"github.com/spacemeshos/go-spacemesh/genvm/sdk/wallet"
+	vaulttemplate "github.com/spacemeshos/go-spacemesh/genvm/templates/vault"
+	vestingtemplate "github.com/spacemeshos/go-spacemesh/genvm/templates/vesting"
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/health"
+	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/reconcile"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const testGenesisID = "0f6b2f6e8c9f3a1d4b5e7c2a9d8e1f3b6c4a7d0e"
+
+// testNetwork serves the routes of a network from an in-memory sqlite db.
+type testNetwork struct {
+	router   *gin.Engine
+	db       *database.SQLiteDB
+	config   *config.NetworkConfig
+	sequence uint64
+}
+
+func newTestNetwork(t *testing.T) *testNetwork {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	configValues := &config.Config{
+		Networks: []*config.NetworkConfig{{
+			Name:           "testnet",
+			HRP:            "sm",
+			Genesis:        1700006400,
+			LayerDuration:  300,
+			LayersPerEpoch: 48,
+			GenesisID:      testGenesisID,
+			DB:             &config.DBConfig{Type: database.SQLiteType},
+		}},
+		Price: &config.PriceConfig{
+			DisableHistory: true,
+			Providers:      []*config.PriceProviderConfig{{Type: "static", Prices: map[string]float64{"USD": 2}}},
+		},
+	}
+	profiles, err := configValues.NetworkProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.NewSQLiteDB("file:"+name+"?mode=memory&cache=shared", "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	priceResolver, err := price.NewPriceResolver(configValues, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(priceResolver.Stop)
+
+	router := gin.New()
+	network := &Network{
+		Config:     profiles[0],
+		ReadDB:     db,
+		WriteDB:    db,
+		Reconciler: reconcile.NewReconciler(db, db, nil),
+	}
+	AddRoutes(router, []*Network{network}, priceResolver, configValues, nil, health.NewChecker())
+	// the cleanups run last first, the state stops reading before the db is closed
+	t.Cleanup(network.State.Stop)
+	return &testNetwork{router: router, db: db, config: profiles[0]}
+}
+
+func (n *testNetwork) request(t *testing.T, method string, path string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	n.router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return w
+}
+
+// getJSON gets a path and decodes its json response in value, it fails the test on another status.
+func (n *testNetwork) getJSON(t *testing.T, path string, status int, value any) *httptest.ResponseRecorder {
+	t.Helper()
+	w := n.request(t, http.MethodGet, path, "")
+	if w.Code != status {
+		t.Fatalf("GET %s: status %d, want %d: %s", path, w.Code, status, w.Body.String())
+	}
+	if value != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), value); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+	}
+	return w
+}
+
+func (n *testNetwork) origin(stream string, publishedLayer int64) database.Origin {
+	n.sequence++
+	origin := database.Origin{Stream: stream, Sequence: n.sequence}
+	if publishedLayer > 0 {
+		origin.Published = n.config.LayerTime(publishedLayer) * 1000
+	}
+	return origin
+}
+
+func (n *testNetwork) saveReward(t *testing.T, id string, coinbase string, layer uint32, amount uint64, publishedLayer int64) {
+	t.Helper()
+	reward := &nats.Reward{ID: id, Layer: layer, Coinbase: coinbase, Total: amount, LayerReward: amount, NodeID: "n1", AtxID: "a1"}
+	if err := n.db.SaveReward(reward, n.origin("rewards", publishedLayer)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// saveTransaction saves a created transaction, or its result when layer is set.
+func (n *testNetwork) saveTransaction(t *testing.T, id string, raw []byte, principal sTypes.Address, layer uint32, blockID string, publishedLayer int64, addresses ...sTypes.Address) {
+	t.Helper()
+	header := &nats.TransactionHeader{LayerID: layer, BlockID: blockID, Principal: principal.String(), Method: 16, Gas: 100}
+	for _, address := range addresses {
+		header.Addresses = append(header.Addresses, address.String())
+	}
+	stream := "transactions.created"
+	if layer > 0 {
+		stream = "transactions.result"
+	}
+	transaction := &nats.Transaction{ID: id, Raw: raw, Header: header}
+	if err := n.db.SaveTransactions(transaction, layer > 0, n.origin(stream, publishedLayer)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (n *testNetwork) saveLayer(t *testing.T, layer uint32, publishedLayer int64) {
+	t.Helper()
+	if err := n.db.SaveLayer(&nats.LayerUpdate{LayerID: layer, Status: database.LayerStatusApplied}, n.origin("layers", publishedLayer)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (n *testNetwork) transaction(t *testing.T, id string) *types.TransactionDoc {
+	t.Helper()
+	transaction, err := n.db.GetTransaction(id)
+	if err != nil || transaction == nil {
+		t.Fatalf("transaction %s: %v", id, err)
+	}
+	return transaction
+}
+
+func testKey(i byte) (ed25519.PrivateKey, sTypes.Address) {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = i
+	key := ed25519.NewKeyFromSeed(seed)
+	return key, wallet.Address(key.Public().(ed25519.PublicKey))
+}
+
+func genesisOpt(t *testing.T) sdk.Opt {
+	t.Helper()
+	var genesisID sTypes.Hash20
+	decoded, err := hex.DecodeString(testGenesisID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copy(genesisID[:], decoded)
+	return sdk.WithGenesisID(genesisID)
+}
+
+// nextPage returns the url of the Link header of a page, empty on the last page.
+func nextPage(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	link := w.Header().Get("Link")
+	if link == "" {
+		return ""
+	}
+	next, ok := strings.CutSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+	if !ok {
+		t.Fatalf("malformed Link header %q", link)
+	}
+	return next
+}
+
+func TestAccountsCursorPages(t *testing.T) {
+	n := newTestNetwork(t)
+	balances := []uint64{500, 300, 300, 100, 700}
+	for i, balance := range balances {
+		_, address := testKey(byte(i + 1))
+		n.saveReward(t, fmt.Sprintf("r%d", i), address.String(), uint32(i+1), balance, 0)
+	}
+	// an account only seen as the coinbase of an atx has no balance yet
+	_, coinbase := testKey(10)
+	atx := &nats.Atx{AtxID: "a1", NodeID: "n1", Coinbase: coinbase.String(), PublishEpoch: 1, EffectiveNumUnits: 4, TickCount: 10}
+	if err := n.db.SaveAtx(atx, n.origin("atxs", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sort := range []string{"desc", "asc"} {
+		t.Run(sort, func(t *testing.T) {
+			seen := map[string]bool{}
+			var pageBalances []uint64
+			pages := 0
+			for url := "/account?limit=2&sort=" + sort; url != ""; pages++ {
+				var page []*types.Account
+				w := n.getJSON(t, url, http.StatusOK, &page)
+				for _, account := range page {
+					if seen[account.Address] {
+						t.Errorf("account %s on two pages", account.Address)
+					}
+					seen[account.Address] = true
+					pageBalances = append(pageBalances, account.Balance)
+				}
+				url = nextPage(t, w)
+				if url != "" && w.Header().Get("next") == "" {
+					t.Error("Link without the next header")
+				}
+			}
+			if len(seen) != len(balances)+1 || !seen[coinbase.String()] {
+				t.Fatalf("paged %d accounts, want %d with the coinbase only one", len(seen), len(balances)+1)
+			}
+			for i := 1; i < len(pageBalances); i++ {
+				if (sort == "desc" && pageBalances[i] > pageBalances[i-1]) || (sort == "asc" && pageBalances[i] < pageBalances[i-1]) {
+					t.Errorf("balances not sorted %s: %v", sort, pageBalances)
+					break
+				}
+			}
+			if pages < 3 {
+				t.Errorf("%d pages, want at least 3", pages)
+			}
+		})
+	}
+
+	// a cursor only works for the sort it was made for
+	w := n.getJSON(t, "/account?limit=2&sort=desc", http.StatusOK, nil)
+	next := strings.Replace(nextPage(t, w), "sort=desc", "sort=asc", 1)
+	n.getJSON(t, next, http.StatusBadRequest, nil)
+	n.getJSON(t, "/account?cursor=zzz", http.StatusBadRequest, nil)
+}
+
+func TestAccountRewardsCursorPages(t *testing.T) {
+	n := newTestNetwork(t)
+	_, address := testKey(1)
+	for i := 0; i < 25; i++ {
+		n.saveReward(t, fmt.Sprintf("r%02d", i), address.String(), uint32(i/3+1), 1, 0)
+	}
+
+	for _, sort := range []string{"asc", "desc"} {
+		t.Run(sort, func(t *testing.T) {
+			var layers []int64
+			for url := "/account/" + address.String() + "/rewards?limit=7&sort=" + sort; url != ""; {
+				var page []*types.Reward
+				w := n.getJSON(t, url, http.StatusOK, &page)
+				for _, reward := range page {
+					layers = append(layers, reward.Layer)
+				}
+				url = nextPage(t, w)
+			}
+			if len(layers) != 25 {
+				t.Fatalf("paged %d rewards, want 25", len(layers))
+			}
+			for i := 1; i < len(layers); i++ {
+				if (sort == "asc" && layers[i] < layers[i-1]) || (sort == "desc" && layers[i] > layers[i-1]) {
+					t.Fatalf("layers not sorted %s: %v", sort, layers)
+				}
+			}
+		})
+	}
+}
+
+func TestPendingTransactions(t *testing.T) {
+	n := newTestNetwork(t)
+	opts := []sdk.Opt{sdk.WithGasPrice(2)}
+	k0, w0 := testKey(1)
+	k1, w1 := testKey(2)
+	n.saveReward(t, "r1", w0.String(), 1, 10000, 0)
+	// counter 1 is applied, then 2, 3 and 3 again are pending, 5 leaves a gap and 9 is old enough to drop
+	n.saveTransaction(t, "applied", wallet.Spend(k0, w1, 100, 1, opts...), w0, 3, "b3", 3, w0, w1)
+	n.saveTransaction(t, "c2", wallet.Spend(k0, w1, 1000, 2, opts...), w0, 0, "", 40)
+	n.saveTransaction(t, "c3", wallet.Spend(k0, w1, 2000, 3, opts...), w0, 0, "", 40)
+	n.saveTransaction(t, "c3b", wallet.Spend(k0, w1, 50, 3, opts...), w0, 0, "", 41)
+	n.saveTransaction(t, "c5", wallet.Spend(k0, w1, 10, 5, opts...), w0, 0, "", 41)
+	n.saveTransaction(t, "old", wallet.Spend(k0, w1, 10, 9, opts...), w0, 0, "", 5)
+	n.saveTransaction(t, "in", wallet.Spend(k1, w0, 77, 1, opts...), w1, 0, "", 40)
+	for layer := uint32(1); layer <= 45; layer++ {
+		n.saveLayer(t, layer, 0)
+	}
+
+	var pending types.PendingTransactions
+	n.getJSON(t, "/account/"+w0.String()+"/pending", http.StatusOK, &pending)
+	reasons := map[string]string{}
+	for _, transaction := range pending.Transactions {
+		reasons[transaction.ID] = transaction.Reason
+		// only the transactions sent by the account are estimated
+		if transaction.PrincipalAccount == w0.String() && (transaction.Reason == "") != (transaction.EstimatedLayer != nil) {
+			t.Errorf("%s: estimated layer %v with reason %q", transaction.ID, transaction.EstimatedLayer, transaction.Reason)
+		}
+	}
+	if len(reasons) != 6 {
+		t.Fatalf("pending %v, want the 5 sent and the received one", reasons)
+	}
+	if reasons["c2"] != "" || reasons["c3"] != "" || reasons["in"] != "" {
+		t.Errorf("includable transactions with a reason: %v", reasons)
+	}
+	for _, id := range []string{"c3b", "c5", "old"} {
+		if reasons[id] == "" {
+			t.Errorf("%s has no reason", id)
+		}
+	}
+	if pending.Balance != 9700 || pending.PendingReceived != 77 || pending.ProjectedBalance != pending.Balance-pending.PendingSent {
+		t.Errorf("balance %d, pending sent %d and received %d, projected %d", pending.Balance, pending.PendingSent,
+			pending.PendingReceived, pending.ProjectedBalance)
+	}
+	// c2 and c3 with their max fee, 100 gas at price 2
+	if pending.PendingSent != 1000+2000+2*200 {
+		t.Errorf("pending sent %d, want 3400", pending.PendingSent)
+	}
+
+	// the old one is dropped, its result arriving later completes it
+	dropped, err := n.db.DropTransactions(n.config.LayerTime(25)*1000, 25)
+	if err != nil || dropped != 1 {
+		t.Fatalf("dropped %d, %v, want 1", dropped, err)
+	}
+	n.getJSON(t, "/transactions/old", http.StatusOK, nil)
+	transaction := n.transaction(t, "old")
+	if !transaction.Dropped || transaction.Complete {
+		t.Errorf("old transaction dropped %t, complete %t", transaction.Dropped, transaction.Complete)
+	}
+	n.getJSON(t, "/account/"+w0.String()+"/pending", http.StatusOK, &pending)
+	if len(pending.Transactions) != 5 {
+		t.Errorf("%d pending after the drop, want 5", len(pending.Transactions))
+	}
+	n.saveTransaction(t, "old", wallet.Spend(k0, w1, 10, 9, opts...), w0, 44, "b44", 44, w0, w1)
+	transaction = n.transaction(t, "old")
+	if transaction.Dropped || !transaction.Complete {
+		t.Errorf("old transaction with a result dropped %t, complete %t", transaction.Dropped, transaction.Complete)
+	}
+}
+
+func TestDecodeTransaction(t *testing.T) {
+	n := newTestNetwork(t)
+	opts := []sdk.Opt{genesisOpt(t)}
+	k0, w0 := testKey(1)
+	k1, w1 := testKey(2)
+	k2, _ := testKey(3)
+	n.saveTransaction(t, "spawn0", wallet.SelfSpawn(k0, 0, opts...), w0, 5, "b5", 0, w0)
+	n.saveTransaction(t, "spend0", wallet.Spend(k0, w1, 10, 3, opts...), w0, 5, "b5", 0, w0, w1)
+
+	hexBody := func(raw []byte) string {
+		return `{"raw":"` + hex.EncodeToString(raw) + `"}`
+	}
+	tampered := wallet.Spend(k0, w1, 7, 5, opts...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		valid  bool
+	}{
+		{name: "self spawn", body: hexBody(wallet.SelfSpawn(k1, 0, opts...)), status: http.StatusOK, valid: true},
+		{name: "spend after the last counter", body: hexBody(wallet.Spend(k0, w1, 7, 5, opts...)), status: http.StatusOK, valid: true},
+		{name: "spend of a used counter", body: hexBody(wallet.Spend(k0, w1, 7, 2, opts...)), status: http.StatusOK},
+		{name: "signed with another key", body: hexBody(wallet.Spend(k1, w1, 7, 5, opts...)), status: http.StatusOK},
+		{name: "unknown principal", body: hexBody(wallet.Spend(k2, w1, 7, 5, opts...)), status: http.StatusOK},
+		{name: "tampered signature", body: hexBody(tampered), status: http.StatusOK},
+		{name: "another genesis", body: hexBody(wallet.SelfSpawn(k1, 0, sdk.WithGenesisID(sTypes.Hash20{1}))), status: http.StatusOK},
+		{name: "opaque version", body: `{"raw":"04"}`, status: http.StatusOK},
+		{name: "not hex", body: `{"raw":"zz"}`, status: http.StatusBadRequest},
+		{name: "truncated", body: hexBody(wallet.SelfSpawn(k1, 0, opts...)[:10]), status: http.StatusBadRequest},
+		{name: "unknown encoding", body: `{"raw":"00","encoding":"foo"}`, status: http.StatusBadRequest},
+		{name: "no raw", body: `{}`, status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := n.request(t, http.MethodPost, "/transactions/decode", test.body)
+			if w.Code != test.status {
+				t.Fatalf("status %d, want %d: %s", w.Code, test.status, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var decoded types.DecodedTransaction
+			if err := json.Unmarshal(w.Body.Bytes(), &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if decoded.Valid != test.valid {
+				t.Errorf("valid %t, want %t: %s", decoded.Valid, test.valid, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAccountExport(t *testing.T) {
+	n := newTestNetwork(t)
+	k0, w0 := testKey(1)
+	_, w1 := testKey(2)
+	for i := 0; i < 3; i++ {
+		n.saveReward(t, fmt.Sprintf("r%d", i), w0.String(), uint32(100+i*10), 1_500_000_000, 0)
+	}
+	n.saveTransaction(t, "t1", wallet.Spend(k0, w1, 1_000_000_000, 1), w0, 105, "b105", 0, w0, w1)
+
+	w := n.request(t, http.MethodGet, "/account/"+w0.String()+"/export", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("content type %q", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), w0.String()+".csv") {
+		t.Errorf("content disposition %q", w.Header().Get("Content-Disposition"))
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "time,layer,type") {
+		t.Fatalf("csv of %d lines, want the columns, 3 rewards and a transfer:\n%s", len(lines), w.Body.String())
+	}
+
+	// a layer range keeps the rows in it, the network prefix serves the same routes
+	w = n.request(t, http.MethodGet, "/testnet/account/"+w0.String()+"/export?format=ndjson&firstLayer=105&lastLayer=110", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("ndjson status %d: %s", w.Code, w.Body.String())
+	}
+	if rows := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(rows) != 2 {
+		t.Errorf("%d ndjson rows in layers 105 to 110, want 2:\n%s", len(rows), w.Body.String())
+	}
+
+	for _, query := range []string{"format=xls", "from=bad", "from=2023-07-14&firstLayer=3"} {
+		if w := n.request(t, http.MethodGet, "/account/"+w0.String()+"/export?"+query, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, w.Code)
+		}
+	}
+}
+
+func TestLayerRevert(t *testing.T) {
+	n := newTestNetwork(t)
+	k0, w0 := testKey(1)
+	_, w1 := testKey(2)
+	n.saveReward(t, "r0", w0.String(), 1, 10000, 2)
+	// layer 5 is executed with a reward and a transaction, then applied
+	n.saveReward(t, "r1", w0.String(), 5, 1000, 5)
+	n.saveTransaction(t, "t1", wallet.Spend(k0, w1, 100, 1), w0, 5, "b1", 5, w0, w1)
+	n.saveLayer(t, 5, 6)
+
+	// it is executed again, its reward published after the applied update reverts the first content
+	n.saveReward(t, "r2", w0.String(), 5, 2000, 7)
+
+	var layer types.Layer
+	n.getJSON(t, "/layers/5", http.StatusOK, &layer)
+	if len(layer.Reverts) != 1 {
+		t.Fatalf("reverts %+v, want one", layer.Reverts)
+	}
+	revert := layer.Reverts[0]
+	if revert.RewardsReverted != 1 || revert.RewardsAmount != 1000 || revert.TransactionsReverted != 1 || revert.Trigger != "r2" {
+		t.Errorf("revert %+v, want r1 and t1 reverted by r2", revert)
+	}
+	if len(revert.Blocks) != 1 || revert.Blocks[0] != "b1" {
+		t.Errorf("reverted blocks %v, want b1", revert.Blocks)
+	}
+
+	n.getJSON(t, "/transactions/t1", http.StatusOK, nil)
+	if n.transaction(t, "t1").Complete {
+		t.Error("reverted transaction still complete")
+	}
+	var account types.Account
+	n.getJSON(t, "/account/"+w1.String(), http.StatusOK, &account)
+	if account.Balance != 0 {
+		t.Errorf("receiver balance %d after the revert, want 0", account.Balance)
+	}
+	n.getJSON(t, "/account/"+w0.String(), http.StatusOK, &account)
+	if account.Balance != 12000 {
+		t.Errorf("balance %d after the revert, want the rewards r0 and r2", account.Balance)
+	}
+
+	// the reverted reward delivered again is stale, it isn't counted
+	n.saveReward(t, "r1", w0.String(), 5, 1000, 5)
+	n.getJSON(t, "/account/"+w0.String(), http.StatusOK, &account)
+	if account.Balance != 12000 {
+		t.Errorf("balance %d after a reverted reward was delivered again, want 12000", account.Balance)
+	}
+	n.getJSON(t, "/layers/5", http.StatusOK, &layer)
+	if len(layer.Reverts) != 1 {
+		t.Errorf("%d reverts after a stale reward, want one", len(layer.Reverts))
+	}
+}
+
+func TestGenesisVaultState(t *testing.T) {
+	n := newTestNetwork(t)
+	k0, owner := testKey(1)
+	_, receiver := testKey(2)
+	_, vault := testKey(3)
+	n.config.Vaults = map[string]uint64{vault.String(): 1000}
+	n.config.Vesting = &config.VestingConfig{Start: 100, End: 200, Total: 1000}
+
+	vaultAt := func(address string, layer int) *types.VaultState {
+		t.Helper()
+		var account types.Account
+		n.getJSON(t, fmt.Sprintf("/account/%s?layer=%d", address, layer), http.StatusOK, &account)
+		if account.Template == nil {
+			t.Fatalf("%s has no template", address)
+		}
+		if account.Template.Vault != nil {
+			return account.Template.Vault
+		}
+		if len(account.Template.Vaults) != 1 {
+			t.Fatalf("%s has vaults %v, want the genesis vault", address, account.Template.Vaults)
+		}
+		return account.Template.Vaults[0]
+	}
+
+	// not drained yet the vault has no template doc, its schedule is the one of the config
+	state := vaultAt(vault.String(), 150)
+	if state.TotalAmount != 1000 || state.VestingStart != 100 || state.VestingEnd != 200 || state.Owner != "" ||
+		state.Vested != 500 || state.Unlockable != 500 {
+		t.Errorf("genesis vault before its drain %+v", state)
+	}
+
+	drain := vesting.DrainVault(0, k0, owner, vault, receiver, 300, 1, genesisOpt(t)).Raw()
+	n.saveTransaction(t, "drain", drain, owner, 120, "b120", 120, owner, vault, receiver)
+	tests := []struct {
+		layer      int
+		vested     uint64
+		unlockable uint64
+	}{
+		{50, 0, 0},
+		{150, 500, 200},
+		{199, 990, 690},
+		{250, 1000, 700},
+	}
+	for _, test := range tests {
+		state := vaultAt(vault.String(), test.layer)
+		if state.Layer != uint32(test.layer) || state.Owner != owner.String() || state.Vested != test.vested ||
+			state.Drained != 300 || state.Unlockable != test.unlockable {
+			t.Errorf("layer %d: vault %+v, want vested %d and unlockable %d", test.layer, state, test.vested, test.unlockable)
+		}
+	}
+
+	// the drain tells the owner, the vault is listed with the vesting account
+	if state := vaultAt(owner.String(), 150); state.Address != vault.String() || state.Unlockable != 200 {
+		t.Errorf("vault of the owner %+v", state)
+	}
+}
+
+func TestTransactionDetails(t *testing.T) {
+	n := newTestNetwork(t)
+	opts := []sdk.Opt{genesisOpt(t)}
+	k0, w0 := testKey(1)
+	k1, _ := testKey(2)
+	_, w2 := testKey(3)
+	vestingAccount := multisig.Address(vestingtemplate.TemplateAddress, 2, k0.Public().(ed25519.PublicKey), k1.Public().(ed25519.PublicKey))
+
+	spend := wallet.Spend(k0, w2, 100, 1, opts...)
+	n.saveTransaction(t, "spend", spend, w0, 3, "b3", 3, w0, w2)
+	args := &vaulttemplate.SpawnArguments{Owner: vestingAccount, TotalAmount: 1000, InitialUnlockAmount: 100, VestingStart: 10, VestingEnd: 20}
+	spawn := vesting.Spawn(0, k0, vestingAccount, vaulttemplate.TemplateAddress, args, 1, opts...)
+	spawn.Add(*vesting.Spawn(1, k1, vestingAccount, vaulttemplate.TemplateAddress, args, 1, opts...).Part(1))
+	n.saveTransaction(t, "vault", spawn.Raw(), vestingAccount, 3, "b3", 3, vestingAccount)
+	n.saveTransaction(t, "pending", wallet.Spend(k0, w2, 100, 2, opts...), w0, 0, "", 3)
+	failed := &nats.Transaction{ID: "failed", Raw: wallet.Spend(k0, w2, 1_000_000, 3, opts...), Header: &nats.TransactionHeader{
+		LayerID:   4,
+		BlockID:   "b4",
+		Principal: w0.String(),
+		Method:    16,
+		Gas:       100,
+		Status:    uint8(sTypes.TransactionFailure),
+		Message:   "insufficient funds",
+		Addresses: []string{w0.String()},
+	}}
+	if err := n.db.SaveTransactions(failed, true, n.origin("transactions.result", 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	var transaction types.Transaction
+	n.getJSON(t, "/transactions/spend", http.StatusOK, &transaction)
+	details := transaction.Details
+	if details == nil || details.Template != "wallet" || details.Raw != hex.EncodeToString(spend) ||
+		details.Signature != hex.EncodeToString(spend[len(spend)-ed25519.SignatureSize:]) || len(details.Signatures) != 0 ||
+		len(details.Addresses) != 2 || details.Vault != nil {
+		t.Errorf("spend details %+v", details)
+	}
+
+	transaction = types.Transaction{}
+	n.getJSON(t, "/transactions/vault", http.StatusOK, &transaction)
+	details = transaction.Details
+	if details == nil || details.Template != "vault" || details.Signature != "" || len(details.Signatures) != 2 ||
+		details.Signatures[0].Signer != 0 || details.Signatures[1].Signer != 1 {
+		t.Fatalf("vault spawn details %+v", details)
+	}
+	vault := details.Vault
+	if vault == nil || vault.Owner != vestingAccount.String() || vault.TotalAmount != 1000 || vault.InitialUnlockAmount != 100 ||
+		vault.VestingStart != 10 || vault.VestingEnd != 20 {
+		t.Errorf("vault details %+v", vault)
+	}
+
+	// the transactions without a result have no details
+	transaction = types.Transaction{}
+	n.getJSON(t, "/transactions/pending", http.StatusOK, &transaction)
+	if transaction.Details != nil {
+		t.Errorf("pending transaction details %+v", transaction.Details)
+	}
+	transaction = types.Transaction{}
+	n.getJSON(t, "/transactions/failed", http.StatusOK, &transaction)
+	if transaction.Details == nil || transaction.Details.Message != "insufficient funds" {
+		t.Errorf("failed transaction details %+v", transaction.Details)
+	}
+}
@@ -0,0 +1,83 @@
+package route
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/stats"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+type StatsRoutes struct {
+	db database.ReadDB
+}
+
+func NewStatsRoutes(db database.ReadDB) *StatsRoutes {
+	routes := &StatsRoutes{
+		db: db,
+	}
+	return routes
+}
+
+// GetSeries returns a statistic of every epoch or day between from and to, from the collected statistics.
+func (s *StatsRoutes) GetSeries(c *gin.Context) {
+	metric, ok := stats.Metrics[c.Query("metric")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "metric must be one of " + strings.Join(stats.MetricNames(), ", "),
+		})
+		return
+	}
+	period := c.DefaultQuery("period", stats.EpochPeriod)
+	if !slices.Contains(stats.Periods, period) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "period must be one of " + strings.Join(stats.Periods, ", "),
+		})
+		return
+	}
+	from, err := strconv.ParseInt(c.DefaultQuery("from", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be a valid integer",
+		})
+		return
+	}
+	to, err := strconv.ParseInt(c.DefaultQuery("to", strconv.FormatInt(time.Now().Unix(), 10)), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "to must be a valid integer",
+		})
+		return
+	}
+	if from < 0 || from > to {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "from must be greater or equal to 0 and not after to",
+		})
+		return
+	}
+
+	docs, err := s.db.GetStats(period, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch statistics",
+		})
+		return
+	}
+	points := make([]*types.StatsPoint, len(docs))
+	for i, doc := range docs {
+		points[i] = &types.StatsPoint{
+			Timestamp:  doc.Timestamp,
+			FirstLayer: doc.FirstLayer,
+			LastLayer:  doc.LastLayer,
+			Value:      metric(doc),
+			Complete:   doc.Complete,
+		}
+	}
+	c.JSON(200, points)
+}
@@ -0,0 +1,270 @@
+package route
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/stream"
+)
+
+// interval of the keepalive messages, proxies close idle connections
+const keepAliveInterval = 30 * time.Second
+
+const writeTimeout = 10 * time.Second
+
+// most accounts and nodes a subscription can filter by
+const maxStreamFilter = 100
+
+var upgrader = websocket.Upgrader{
+	// the api is open to any origin, like the rest of the routes
+	CheckOrigin: func(r *http.Request) bool {
+		return true
+	},
+}
+
+type StreamRoutes struct {
+	db  database.ReadDB
+	hub *stream.Hub
+}
+
+func NewStreamRoutes(db database.ReadDB, hub *stream.Hub) *StreamRoutes {
+	routes := &StreamRoutes{
+		db:  db,
+		hub: hub,
+	}
+	return routes
+}
+
+// streamSubscription is a subscription with the events to replay before the live ones.
+type streamSubscription struct {
+	filter     *stream.Filter
+	sub        *stream.Subscription
+	buffered   []*stream.Event
+	replayFrom int64
+	replayTo   int64
+}
+
+func (s *StreamRoutes) GetEvents(c *gin.Context) {
+	fromLayer := c.Query("fromLayer")
+	if fromLayer == "" {
+		// EventSource sends the id of the last event it got when it reconnects
+		fromLayer = c.GetHeader("Last-Event-ID")
+	}
+	subscription, ok := s.subscribe(c, fromLayer)
+	if !ok {
+		return
+	}
+	defer subscription.sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	send := func(event *stream.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.Layer, event.Type, data)
+		c.Writer.Flush()
+		return err
+	}
+	keepAlive := func() error {
+		_, err := fmt.Fprint(c.Writer, ": keepalive\n\n")
+		c.Writer.Flush()
+		return err
+	}
+	s.run(c.Request.Context(), subscription, send, keepAlive)
+}
+
+func (s *StreamRoutes) GetWebSocket(c *gin.Context) {
+	subscription, ok := s.subscribe(c, c.Query("fromLayer"))
+	if !ok {
+		return
+	}
+	defer subscription.sub.Close()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader already wrote the error response
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		// the stream is one way, reading only handles the control messages and notices the close
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	send := func(event *stream.Event) error {
+		conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		return conn.WriteJSON(event)
+	}
+	keepAlive := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout))
+	}
+	s.run(ctx, subscription, send, keepAlive)
+}
+
+// subscribe reads the filters of the request and subscribes to the hub. It writes the bad request
+// response and returns false when they are not valid.
+func (s *StreamRoutes) subscribe(c *gin.Context, fromLayerStr string) (*streamSubscription, bool) {
+	filter := &stream.Filter{
+		Types:    make(map[string]bool),
+		Accounts: make(map[string]bool),
+		Nodes:    make(map[string]bool),
+	}
+	for _, eventType := range queryList(c, "types") {
+		valid := false
+		for _, v := range stream.EventTypes {
+			valid = valid || v == eventType
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "types must be a list of " + strings.Join(stream.EventTypes, ", "),
+			})
+			return nil, false
+		}
+		filter.Types[eventType] = true
+	}
+	for _, account := range queryList(c, "account") {
+		filter.Accounts[account] = true
+	}
+	for _, node := range queryList(c, "node") {
+		filter.Nodes[strings.ToLower(node)] = true
+	}
+	if len(filter.Accounts)+len(filter.Nodes) > maxStreamFilter {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("at most %d accounts and nodes can be filtered", maxStreamFilter),
+		})
+		return nil, false
+	}
+
+	fromLayer := int64(-1)
+	if fromLayerStr != "" {
+		layer, err := strconv.ParseInt(fromLayerStr, 10, 64)
+		if err != nil || layer < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "fromLayer must be a valid layer",
+			})
+			return nil, false
+		}
+		fromLayer = layer
+	}
+
+	sub, buffered, covered := s.hub.Subscribe(filter, fromLayer)
+	subscription := &streamSubscription{
+		filter:     filter,
+		sub:        sub,
+		buffered:   buffered,
+		replayFrom: fromLayer,
+		replayTo:   covered,
+	}
+	if fromLayer < 0 {
+		return subscription, true
+	}
+
+	// layers before the ones the hub has all the events of are read from the db
+	if covered == -1 {
+		lastLayer, err := s.db.GetLastProcessedLayer()
+		if err != nil {
+			sub.Close()
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status": "Internal Error",
+				"error":  "Failed to fetch last layer",
+			})
+			return nil, false
+		}
+		subscription.replayTo = lastLayer.Layer + 1
+	}
+	if subscription.replayTo-fromLayer > stream.MaxReplayLayers {
+		sub.Close()
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("fromLayer must be within the last %d layers", stream.MaxReplayLayers),
+		})
+		return nil, false
+	}
+	return subscription, true
+}
+
+// run sends the replayed events then the live ones until the client leaves or falls behind.
+func (s *StreamRoutes) run(ctx context.Context, subscription *streamSubscription, send func(*stream.Event) error, keepAlive func() error) {
+	lastLayer := subscription.replayFrom
+	sendEvent := func(event *stream.Event) error {
+		lastLayer = max(lastLayer, event.Layer)
+		return send(event)
+	}
+
+	if subscription.replayFrom >= 0 && subscription.replayFrom < subscription.replayTo {
+		err := stream.Replay(s.db, s.hub.Network(), subscription.filter, subscription.replayFrom, subscription.replayTo, sendEvent)
+		if err != nil {
+			slog.Error("Failed to replay stream events", "error", err)
+			return
+		}
+	}
+	for _, event := range subscription.buffered {
+		if err := sendEvent(event); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-subscription.sub.C:
+			if !ok {
+				// the client resumes from the last layer it got, it may get some events again
+				send(&stream.Event{
+					Type:  "dropped",
+					Layer: lastLayer,
+					Data: gin.H{
+						"error": "subscription ended, subscriber too slow or server shutting down, resume from the layer of this event",
+					},
+				})
+				return
+			}
+			if err := sendEvent(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := keepAlive(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// queryList returns the values of a query parameter given several times or comma separated.
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range c.QueryArray(key) {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
@@ -1,10 +1,18 @@
 package route
 
 import (
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
     "github.com/gin-gonic/gin"
+    "github.com/spacemeshos/go-spacemesh/genvm/core"
     "github.com/swarmbit/spacemesh-state-api/config"
     "github.com/swarmbit/spacemesh-state-api/database"
     "github.com/swarmbit/spacemesh-state-api/network"
+    "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+    v0 "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/v0"
+    "github.com/swarmbit/spacemesh-state-api/price"
     "github.com/swarmbit/spacemesh-state-api/types"
     "net/http"
     "strconv"
@@ -12,16 +20,20 @@ import (
 )
 
 type TransactionRoutes struct {
-    db           *database.ReadDB
-    networkUtils *network.NetworkUtils
-    state        *network.NetworkState
+    db            database.ReadDB
+    network       *config.NetworkConfig
+    networkUtils  *network.NetworkUtils
+    state         *network.NetworkState
+    priceResolver *price.PriceResolver
 }
 
-func NewTransactionRoutes(db *database.ReadDB, networkUtils *network.NetworkUtils, state *network.NetworkState) *TransactionRoutes {
+func NewTransactionRoutes(db database.ReadDB, networkConfig *config.NetworkConfig, networkUtils *network.NetworkUtils, state *network.NetworkState, priceResolver *price.PriceResolver) *TransactionRoutes {
     routes := &TransactionRoutes{
-        db:           db,
-        networkUtils: networkUtils,
-        state:        state,
+        db:            db,
+        network:       networkConfig,
+        networkUtils:  networkUtils,
+        state:         state,
+        priceResolver: priceResolver,
     }
     return routes
 }
@@ -82,7 +94,15 @@ func (t *TransactionRoutes) GetTransactions(c *gin.Context) {
 
     complete := completeStr == "true"
 
-    transactions, errRewards := t.db.GetAllTransactions(int64(offset), int64(limit), sort, complete, method, minAmount)
+    after, ok := parseCursor(c, sort)
+    if !ok {
+        return
+    }
+    if after != nil {
+        offset = 0
+    }
+
+    transactions, errRewards := t.db.GetAllTransactions(int64(offset), int64(limit), sort, complete, method, minAmount, after)
     count, errCount := t.db.CountAllTransactions(complete, method, minAmount)
 
     if errRewards != nil || errCount != nil {
@@ -111,16 +131,22 @@ func (t *TransactionRoutes) GetTransactions(c *gin.Context) {
                 PrincipalAccount: v.PrincipaAccount,
                 ReceiverAccount:  v.ReceiverAccount,
                 VaultAccount:     v.VaultAccount,
-                Fee:              v.Gas * v.GasPrice,
+                Fee:              v.TotalFee(),
                 Amount:           v.Amount,
                 Layer:            v.Layer,
                 Counter:          v.Counter,
                 Method:           method,
-                Timestamp:        int64(config.GenesisEpochSeconds + (v.Layer * config.LayerDuration)),
+                Timestamp:        t.networkUtils.LayerTime(int64(v.Layer)),
+                Dropped:          v.Dropped,
             }
         }
 
+        if limit > 0 && len(transactions) == limit {
+            last := transactions[len(transactions)-1]
+            setNextCursor(c, sort, &types.Cursor{Key: int64(last.Layer), ID: last.ID})
+        }
         c.Header("total", strconv.FormatInt(count, 10))
+        setTransactionsUSDValue(t.priceResolver, transactionsResponse)
         c.JSON(200, transactionsResponse)
     } else {
         c.Header("total", strconv.FormatInt(count, 10))
@@ -158,17 +184,325 @@ func (t *TransactionRoutes) GetTransaction(c *gin.Context) {
         method = "DrainVault"
     }
 
-    c.JSON(200, &types.Transaction{
+    transactionResponse := &types.Transaction{
         ID:               transaction.ID,
         Status:           transaction.Status,
         PrincipalAccount: transaction.PrincipaAccount,
         ReceiverAccount:  transaction.ReceiverAccount,
         VaultAccount:     transaction.VaultAccount,
-        Fee:              transaction.Gas * transaction.GasPrice,
+        Fee:              transaction.TotalFee(),
         Amount:           transaction.Amount,
         Layer:            transaction.Layer,
         Counter:          transaction.Counter,
         Method:           method,
-        Timestamp:        int64(config.GenesisEpochSeconds + (transaction.Layer * config.LayerDuration)),
-    })
+        Timestamp:        t.networkUtils.LayerTime(int64(transaction.Layer)),
+        Dropped:          transaction.Dropped,
+    }
+    setTransactionsUSDValue(t.priceResolver, []*types.Transaction{transactionResponse})
+
+    details, err := t.db.GetTransactionDetails(transactionId)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "status": "Internal Error",
+            "error":  "Failed to fetch transaction details",
+        })
+        return
+    }
+    if details.ID != "" {
+        transactionResponse.Details = transactionDetails(details)
+    }
+    c.JSON(200, transactionResponse)
+}
+
+func transactionDetails(details *types.TransactionDetailsDoc) *types.TransactionDetails {
+    response := &types.TransactionDetails{
+        Template:        details.Template,
+        PublicKeys:      details.PublicKeys,
+        RequiredSigners: details.RequiredSigners,
+        Signature:       details.Signature,
+        Addresses:       details.Addresses,
+        Message:         details.Message,
+        Raw:             details.Raw,
+    }
+    if details.Owner != "" {
+        response.Vault = &types.VaultDetails{
+            Owner:               details.Owner,
+            TotalAmount:         details.TotalAmount,
+            InitialUnlockAmount: details.InitialUnlockAmount,
+            VestingStart:        details.VestingStart,
+            VestingEnd:          details.VestingEnd,
+        }
+    }
+    for _, part := range details.Signatures {
+        response.Signatures = append(response.Signatures, &types.SignaturePart{
+            Signer:    part.Ref,
+            Signature: part.Signature,
+        })
+    }
+    return response
+}
+
+// DecodeTransaction decodes a signed transaction without broadcasting it and checks its principal, its
+// signatures and its counter against what is known of the principal.
+func (t *TransactionRoutes) DecodeTransaction(c *gin.Context) {
+    var req types.DecodeTransactionRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+        return
+    }
+    raw, err := decodeRawTransaction(req.Raw, req.Encoding)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": err.Error(),
+        })
+        return
+    }
+    txData, err := transactionparser.Parse(raw)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": "Failed to decode transaction: " + err.Error(),
+        })
+        return
+    }
+    decoded, err := decodedTransaction(txData, t.network.HRP)
+    if err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{
+            "error": "Failed to decode transaction: " + err.Error(),
+        })
+        return
+    }
+    if txData.Opaque != nil {
+        c.JSON(200, decoded)
+        return
+    }
+
+    principal := txData.Tx.GetPrincipal()
+    principalTemplate, err := t.db.GetAccountTemplate(decoded.Principal)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "status": "Internal Error",
+            "error":  "Failed to fetch principal template",
+        })
+        return
+    }
+    lastCounter, err := t.db.GetLastCounter(decoded.Principal)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{
+            "status": "Internal Error",
+            "error":  "Failed to fetch principal counter",
+        })
+        return
+    }
+
+    // a principal that was not spawned can only spawn itself, with the keys of the spawn
+    template := principalTemplate.Template
+    publicKeys := principalTemplate.PublicKeys
+    required := int(principalTemplate.RequiredSigners)
+    if !principalTemplate.Spawned || len(publicKeys) == 0 {
+        template = ""
+        publicKeys = nil
+        if txData.Spawn != nil && len(decoded.PublicKeys) > 0 {
+            template = decoded.Template
+            publicKeys = decoded.PublicKeys
+            required = int(decoded.RequiredSigners)
+        }
+    }
+    if decoded.Template == "" {
+        decoded.Template = principalTemplate.Template
+    }
+    if template == transactionparsertypes.TemplateWallet {
+        required = 1
+    }
+
+    checks := &types.TransactionChecks{}
+    keys, err := decodePublicKeys(publicKeys)
+    if template == "" || err != nil {
+        checks.Principal = &types.TransactionCheck{Reason: "the public keys of the principal are unknown"}
+        checks.Signatures = &types.TransactionCheck{Reason: "the public keys of the principal are unknown"}
+    } else {
+        templateAddress, _ := transactionparsertypes.TemplateAddress(template)
+        expected, err := v0.TemplatePrincipal(templateAddress, uint8(required), keys)
+        if err != nil {
+            checks.Principal = &types.TransactionCheck{Reason: err.Error()}
+        } else {
+            checks.Principal = newTransactionCheck(expected == principal, "the principal is not the account of its public keys")
+        }
+
+        genesisID := t.network.GenesisIDBytes()
+        if genesisID == nil {
+            checks.Signatures = &types.TransactionCheck{Reason: "the genesis id of the network is not configured"}
+        } else {
+            verified := transactionparser.VerifySignatures(txData, genesisID, keys)
+            checks.Signatures = newTransactionCheck(verified >= required, "fewer signatures verified than required")
+            checks.Signatures.Verified = &verified
+            checks.Signatures.Required = &required
+        }
+    }
+
+    if lastCounter < 0 {
+        checks.Counter = &types.TransactionCheck{Reason: "no transaction of the principal was recorded"}
+    } else {
+        checks.Counter = newTransactionCheck(int64(decoded.Counter) > lastCounter, "the counter is not above the latest one of the principal")
+        checks.Counter.Latest = &lastCounter
+    }
+
+    decoded.Checks = checks
+    decoded.Valid = checks.Signatures.Valid != nil && *checks.Signatures.Valid &&
+        (checks.Principal.Valid == nil || *checks.Principal.Valid) &&
+        (checks.Counter.Valid == nil || *checks.Counter.Valid)
+    c.JSON(200, decoded)
+}
+
+// decodeRawTransaction decodes raw in encoding, hex or base64, and guesses it when encoding is empty.
+func decodeRawTransaction(raw string, encoding string) ([]byte, error) {
+    switch strings.ToLower(encoding) {
+    case "hex":
+        decoded, err := hex.DecodeString(strings.TrimPrefix(raw, "0x"))
+        if err != nil {
+            return nil, errors.New("raw must be valid hex")
+        }
+        return decoded, nil
+    case "base64":
+        decoded, err := base64.StdEncoding.DecodeString(raw)
+        if err != nil {
+            return nil, errors.New("raw must be valid base64")
+        }
+        return decoded, nil
+    case "":
+        if decoded, err := hex.DecodeString(strings.TrimPrefix(raw, "0x")); err == nil {
+            return decoded, nil
+        }
+        if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil {
+            return decoded, nil
+        }
+        return nil, errors.New("raw must be valid hex or base64")
+    default:
+        return nil, errors.New("encoding must be hex or base64")
+    }
+}
+
+// decodedTransaction returns the fields the parser decoded of a transaction, its addresses encoded with the
+// prefix hrp.
+func decodedTransaction(txData *transactionparsertypes.TransactionData, hrp string) (*types.DecodedTransaction, error) {
+    principal, err := database.EncodeAddress(txData.Tx.GetPrincipal(), hrp)
+    if err != nil {
+        return nil, err
+    }
+    decoded := &types.DecodedTransaction{
+        Type:      txData.Type,
+        Template:  transactionparsertypes.SpawnedTemplate(txData.Type),
+        Principal: principal,
+        Amount:    txData.Tx.GetAmount(),
+        Counter:   txData.Tx.GetCounter(),
+        GasPrice:  txData.Tx.GetGasPrice(),
+    }
+
+    if txData.Opaque != nil {
+        decoded.Unsupported = txData.Opaque.Reason
+        // the header of a version without a parser is not decoded
+        if txData.Opaque.Principal == (core.Address{}) {
+            decoded.Principal = ""
+            return decoded, nil
+        }
+        decoded.Method = methodName(txData.Opaque.Method)
+        if txData.Opaque.Template != nil {
+            decoded.Template = transactionparsertypes.TemplateName(*txData.Opaque.Template)
+        }
+        return decoded, nil
+    }
+
+    switch {
+    case txData.Spawn != nil:
+        decoded.Method = methodName(0)
+        spawned, err := database.EncodeAddress(txData.Spawn.GetSpawned(), hrp)
+        if err != nil {
+            return nil, err
+        }
+        decoded.Spawned = spawned
+    case txData.Type == transactionparsertypes.TypeDrainVault:
+        decoded.Method = methodName(17)
+    default:
+        decoded.Method = methodName(16)
+    }
+    if txData.Spawn == nil && txData.Tx.GetReceiver() != (core.Address{}) {
+        receiver, err := database.EncodeAddress(txData.Tx.GetReceiver(), hrp)
+        if err != nil {
+            return nil, err
+        }
+        decoded.Receiver = receiver
+    }
+
+    for _, publicKey := range txData.Tx.GetPublicKeys() {
+        decoded.PublicKeys = append(decoded.PublicKeys, hex.EncodeToString(publicKey))
+    }
+    if txData.Multisig != nil {
+        decoded.RequiredSigners = txData.Multisig.GetRequired()
+    }
+    if txData.Vault != nil {
+        if txData.Type == transactionparsertypes.TypeVaultSpawn {
+            owner, err := database.EncodeAddress(txData.Vault.GetOwner(), hrp)
+            if err != nil {
+                return nil, err
+            }
+            decoded.VaultDetails = &types.VaultDetails{
+                Owner:               owner,
+                TotalAmount:         txData.Vault.GetTotalAmount(),
+                InitialUnlockAmount: txData.Vault.GetInitialUnlockAmount(),
+                VestingStart:        txData.Vault.GetVestingStart().Uint32(),
+                VestingEnd:          txData.Vault.GetVestingEnd().Uint32(),
+            }
+        } else {
+            vault, err := database.EncodeAddress(txData.Vault.GetVault(), hrp)
+            if err != nil {
+                return nil, err
+            }
+            decoded.Vault = vault
+        }
+    }
+
+    if txData.Sig != nil {
+        decoded.Signature = hex.EncodeToString(txData.Sig[:])
+    }
+    if txData.Signatures != nil {
+        for _, part := range *txData.Signatures {
+            decoded.Signatures = append(decoded.Signatures, &types.SignaturePart{
+                Signer:    part.Ref,
+                Signature: hex.EncodeToString(part.Sig[:]),
+            })
+        }
+    }
+    return decoded, nil
+}
+
+func decodePublicKeys(publicKeys []string) ([][]byte, error) {
+    keys := make([][]byte, len(publicKeys))
+    for i, publicKey := range publicKeys {
+        key, err := hex.DecodeString(publicKey)
+        if err != nil {
+            return nil, err
+        }
+        keys[i] = key
+    }
+    return keys, nil
+}
+
+func newTransactionCheck(valid bool, reason string) *types.TransactionCheck {
+    check := &types.TransactionCheck{Valid: &valid}
+    if !valid {
+        check.Reason = reason
+    }
+    return check
+}
+
+func methodName(method uint8) string {
+    switch method {
+    case 0:
+        return "Spawn"
+    case 16:
+        return "Spend"
+    case 17:
+        return "DrainVault"
+    default:
+        return strconv.Itoa(int(method))
+    }
 }
@@ -0,0 +1,225 @@
+package route
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/auth"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+	"github.com/swarmbit/spacemesh-state-api/webhook"
+)
+
+type WebhookRoutes struct {
+	readDB    database.ReadDB
+	writeDB   database.WriteDB
+	maxPerKey int
+}
+
+func NewWebhookRoutes(readDB database.ReadDB, writeDB database.WriteDB, maxPerKey int) *WebhookRoutes {
+	if maxPerKey <= 0 {
+		maxPerKey = 20
+	}
+	routes := &WebhookRoutes{
+		readDB:    readDB,
+		writeDB:   writeDB,
+		maxPerKey: maxPerKey,
+	}
+	return routes
+}
+
+// owner returns the api key of the request, webhooks belong to it. It writes the unauthorized response
+// and returns false when the request has no key.
+func (w *WebhookRoutes) owner(c *gin.Context) (string, bool) {
+	owner := auth.KeyID(c)
+	if owner == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "webhooks need an api key, send it in the " + auth.ApiKeyHeader + " header",
+		})
+		return "", false
+	}
+	return owner, true
+}
+
+// webhook returns the webhook of the id param if it belongs to owner. It writes the error response and
+// returns nil otherwise.
+func (w *WebhookRoutes) webhook(c *gin.Context, owner string) *types.WebhookDoc {
+	doc, err := w.readDB.GetWebhook(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch webhook",
+		})
+		return nil
+	}
+	if doc.ID == "" || doc.Owner != owner {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "webhook not found",
+		})
+		return nil
+	}
+	return doc
+}
+
+func (w *WebhookRoutes) GetWebhooks(c *gin.Context) {
+	owner, ok := w.owner(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := w.readDB.GetWebhooks(owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch webhooks",
+		})
+		return
+	}
+
+	webhooksResponse := make([]*types.Webhook, len(webhooks))
+	for i, v := range webhooks {
+		webhooksResponse[i] = webhook.WebhookResponse(v)
+	}
+	c.JSON(200, webhooksResponse)
+}
+
+func (w *WebhookRoutes) CreateWebhook(c *gin.Context) {
+	owner, ok := w.owner(c)
+	if !ok {
+		return
+	}
+
+	var req types.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	doc, err := webhook.NewWebhook(owner, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	webhooks, err := w.readDB.GetWebhooks(owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch webhooks",
+		})
+		return
+	}
+	if len(webhooks) >= w.maxPerKey {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("an api key can register at most %d webhooks", w.maxPerKey),
+		})
+		return
+	}
+
+	if err := w.writeDB.SaveWebhook(doc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to save webhook",
+		})
+		return
+	}
+	webhookResponse := webhook.WebhookResponse(doc)
+	webhookResponse.Secret = doc.Secret
+	c.JSON(http.StatusCreated, webhookResponse)
+}
+
+func (w *WebhookRoutes) GetWebhook(c *gin.Context) {
+	owner, ok := w.owner(c)
+	if !ok {
+		return
+	}
+	doc := w.webhook(c, owner)
+	if doc == nil {
+		return
+	}
+	c.JSON(200, webhook.WebhookResponse(doc))
+}
+
+func (w *WebhookRoutes) DeleteWebhook(c *gin.Context) {
+	owner, ok := w.owner(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := w.writeDB.DeleteWebhook(c.Param("id"), owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to delete webhook",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "webhook not found",
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (w *WebhookRoutes) GetWebhookDeliveries(c *gin.Context) {
+	owner, ok := w.owner(c)
+	if !ok {
+		return
+	}
+
+	offsetStr := c.DefaultQuery("offset", "0")
+	limitStr := c.DefaultQuery("limit", "20")
+
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset must be a valid integer",
+		})
+		return
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit must be a valid integer",
+		})
+		return
+	}
+	if offset < 0 || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "offset and limit must be greater or equal to 0",
+		})
+		return
+	}
+
+	doc := w.webhook(c, owner)
+	if doc == nil {
+		return
+	}
+
+	count, err := w.readDB.CountWebhookDeliveries(doc.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch webhook deliveries",
+		})
+		return
+	}
+	deliveries, err := w.readDB.GetWebhookDeliveries(doc.ID, int64(offset), int64(limit))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status": "Internal Error",
+			"error":  "Failed to fetch webhook deliveries",
+		})
+		return
+	}
+
+	deliveriesResponse := make([]*types.WebhookDelivery, len(deliveries))
+	for i, v := range deliveries {
+		deliveriesResponse[i] = webhook.DeliveryResponse(v)
+	}
+	c.Header("total", strconv.FormatInt(count, 10))
+	c.JSON(200, deliveriesResponse)
+}
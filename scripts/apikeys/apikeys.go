@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/auth"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+func main() {
+	configPath := flag.String("config", "", "state api config, the db of the network selects the database holding the keys")
+	issue := flag.String("issue", "", "issue a key with this name, the key is only printed once")
+	rate := flag.Float64("rate", 0, "requests per second of the issued key, 0 for the default")
+	burst := flag.Int64("burst", 0, "burst of the issued key, 0 for the default")
+	dailyQuota := flag.Int64("daily-quota", 0, "requests per UTC day of the issued key, 0 for the default")
+	revoke := flag.String("revoke", "", "revoke the key with this id")
+	list := flag.Bool("list", false, "list the keys")
+	networkName := flag.String("network", "", "network of the config whose db is used, the first one by default")
+	flag.Parse()
+
+	if *configPath == "" || (*issue == "" && *revoke == "" && !*list) {
+		log.Println("Usage: apikeys -config <path to config> (-issue <name> [-rate r] [-burst b] [-daily-quota q] | -revoke <id> | -list)")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	configValues := readConfig(*configPath)
+	network, err := configValues.Network(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	readDB, writeDB, err := database.Open(network)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer readDB.CloseRead()
+	defer writeDB.CloseWrite()
+
+	var result interface{}
+	switch {
+	case *issue != "":
+		result, err = auth.IssueKey(writeDB, &types.ApiKeyRequest{
+			Name:       *issue,
+			Rate:       *rate,
+			Burst:      *burst,
+			DailyQuota: *dailyQuota,
+		})
+	case *revoke != "":
+		var revoked bool
+		revoked, err = writeDB.RevokeApiKey(*revoke, time.Now().UnixMilli())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !revoked {
+			log.Fatal("Api key not found or already revoked")
+		}
+		// running servers cache keys for a minute
+		log.Println("Api key revoked")
+		return
+	default:
+		var keys []*types.ApiKeyDoc
+		keys, err = readDB.GetApiKeys()
+		keysResponse := make([]*types.ApiKey, len(keys))
+		for i, v := range keys {
+			keysResponse[i] = auth.ApiKeyResponse(v)
+		}
+		result = keysResponse
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readConfig(filePath string) *config.Config {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	configValues := config.Config{}
+	err = decoder.Decode(&configValues)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &configValues
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sTypes "github.com/spacemeshos/go-spacemesh/common/types"
+	"github.com/swarmbit/spacemesh-state-api/backfill"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+)
+
+func main() {
+	configPath := flag.String("config", "", "state api config, the db of the network selects the database to fill")
+	statePath := flag.String("state", "", "go-spacemesh state.sql to replay")
+	dumpPath := flag.String("dump", "", "NDJSON dump of nats records to replay, one record per line")
+	kind := flag.String("kind", "", "record kind of the dump: "+strings.Join(backfill.Kinds, ", "))
+	checkpointPath := flag.String("checkpoint", "backfill-checkpoint.json", "file used to resume an interrupted backfill, empty to disable")
+	continueOnError := flag.Bool("continue-on-error", false, "log and skip records that fail to save instead of stopping")
+	networkName := flag.String("network", "", "network of the config whose db is used, the first one by default")
+	flag.Parse()
+
+	if *configPath == "" || (*statePath == "") == (*dumpPath == "") {
+		log.Println("Usage: backfill -config <path to config> (-state <state.sql> | -dump <dump.ndjson> -kind <kind>)")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	source := *statePath
+	if source == "" {
+		source = *dumpPath
+	}
+	source, err := filepath.Abs(source)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	checkpoint, err := backfill.LoadCheckpoint(*checkpointPath, source)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	configValues := readConfig(*configPath)
+	network, err := configValues.Network(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	readDB, writeDB, err := database.Open(network)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	b := backfill.NewBackfill(network, writeDB, checkpoint, *continueOnError)
+	if *statePath != "" {
+		// the addresses read from state.sql are encoded with the prefix of the network
+		sTypes.SetNetworkHRP(network.HRP)
+		err = b.ReplayState(source)
+	} else {
+		err = b.ReplayDump(source, *kind)
+	}
+	writeDB.CloseWrite()
+	readDB.CloseRead()
+	if err != nil {
+		log.Fatal("Backfill stopped, run again to resume: ", err)
+	}
+	log.Println("Backfill finished")
+}
+
+func readConfig(filePath string) *config.Config {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	configValues := config.Config{}
+	err = decoder.Decode(&configValues)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &configValues
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/export"
+	"github.com/swarmbit/spacemesh-state-api/price"
+)
+
+func main() {
+	configPath := flag.String("config", "", "state api config, the db of the network is exported from")
+	networkName := flag.String("network", "", "network of the config whose db is used, the first one by default")
+	account := flag.String("account", "", "account to export")
+	formatName := flag.String("format", "csv", "csv, ndjson, koinly or cointracking")
+	fromFlag := flag.String("from", "", "first day of the range, a date or an RFC3339 time")
+	toFlag := flag.String("to", "", "last day of the range, a date or an RFC3339 time")
+	firstLayer := flag.Int("firstLayer", -1, "first layer of the range, instead of -from")
+	lastLayer := flag.Int("lastLayer", -1, "last layer of the range, instead of -to")
+	noPrices := flag.Bool("noPrices", false, "leave the usd columns empty instead of reading the prices collection")
+	outPath := flag.String("out", "", "file to write, stdout by default")
+	flag.Parse()
+
+	if *configPath == "" || *account == "" {
+		log.Println("Usage: export -config <path to config> -account <address> [-format csv] [-from <date>] [-to <date>] [-out <file>]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	format, err := export.ParseFormat(*formatName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	from, err := export.ParseTime(*fromFlag, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	to, err := export.ParseTime(*toFlag, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	configValues := readConfig(*configPath)
+	network, err := configValues.Network(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	readDB, writeDB, err := database.Open(network)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer readDB.CloseRead()
+	defer writeDB.CloseWrite()
+
+	var prices export.Prices
+	if !*noPrices {
+		// the price history is kept in the db of the first network
+		pricesDB := readDB
+		first, _ := configValues.Network("")
+		if first.Name != network.Name {
+			firstReadDB, firstWriteDB, err := database.Open(first)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer firstReadDB.CloseRead()
+			defer firstWriteDB.CloseWrite()
+			pricesDB = firstReadDB
+		}
+		prices = price.NewHistory(pricesDB)
+	}
+	exporter := export.NewExporter(readDB, network, prices)
+	if !from.IsZero() || !to.IsZero() {
+		if *firstLayer > -1 || *lastLayer > -1 {
+			log.Fatal("-from and -to can't be combined with -firstLayer and -lastLayer")
+		}
+		*firstLayer, *lastLayer = exporter.Layers(from, to)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := exporter.Export(out, format, *account, *firstLayer, *lastLayer); err != nil {
+		log.Println("Export failed: ", err)
+		os.Exit(1)
+	}
+}
+
+func readConfig(filePath string) *config.Config {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	configValues := config.Config{}
+	err = decoder.Decode(&configValues)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &configValues
+}
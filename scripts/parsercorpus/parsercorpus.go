@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser"
+	"github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+)
+
+// entry is a raw transaction of the corpus and what the parser decodes of it.
+type entry struct {
+	Name string `json:"name"`
+	// Raw is the signed transaction in hex
+	Raw string `json:"raw"`
+	// Error is set for bytes the parser must reject, the other fields are then ignored
+	Error      bool   `json:"error,omitempty"`
+	Type       int    `json:"type"`
+	Principal  string `json:"principal"`
+	Receiver   string `json:"receiver"`
+	Amount     uint64 `json:"amount"`
+	Counter    uint64 `json:"counter"`
+	GasPrice   uint64 `json:"gasPrice"`
+	Signatures int    `json:"signatures"`
+	// Spawned is the address of the account a spawn spawns
+	Spawned string `json:"spawned,omitempty"`
+	// Reason is the reason of an opaque transaction
+	Reason string `json:"reason,omitempty"`
+}
+
+func main() {
+	corpusPath := flag.String("corpus", "pkg/transactionparser/testdata/corpus.jsonl", "corpus of raw transactions, one json entry per line")
+	mutations := flag.Int("mutations", 2000, "random mutations of each entry, its truncations are always parsed")
+	seed := flag.Int64("seed", 1, "seed of the mutations")
+	flag.Parse()
+
+	entries := readCorpus(*corpusPath)
+	random := rand.New(rand.NewSource(*seed))
+	failures, parsed := 0, 0
+	for _, e := range entries {
+		raw, err := hex.DecodeString(e.Raw)
+		if err != nil {
+			log.Fatalf("%s: raw is not hex: %v", e.Name, err)
+		}
+		if err := check(e, raw); err != nil {
+			log.Printf("FAIL %s: %v", e.Name, err)
+			failures++
+		}
+
+		// every prefix, then random changes of the bytes
+		inputs := make([][]byte, 0, len(raw)+*mutations)
+		for i := 0; i < len(raw); i++ {
+			inputs = append(inputs, raw[:i])
+		}
+		for i := 0; i < *mutations; i++ {
+			inputs = append(inputs, mutate(random, raw))
+		}
+		for _, input := range inputs {
+			parsed++
+			if err := invariants(input); err != nil {
+				log.Printf("FAIL %s mutated to %x: %v", e.Name, input, err)
+				failures++
+			}
+		}
+	}
+
+	log.Printf("%d entries, %d mutated inputs parsed, %d failures", len(entries), parsed, failures)
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
+
+func readCorpus(path string) []*entry {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	var entries []*entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := &entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			log.Fatalf("corpus line %d: %v", len(entries)+1, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	return entries
+}
+
+// check parses an entry and compares what is decoded with what the entry expects.
+func check(e *entry, raw []byte) error {
+	txData, err := transactionparser.Parse(raw)
+	if e.Error {
+		if err == nil {
+			return errors.New("parsed bytes that must be rejected")
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := invariants(raw); err != nil {
+		return err
+	}
+
+	signatures := 0
+	if txData.Sig != nil {
+		signatures = 1
+	}
+	if txData.Signatures != nil {
+		signatures = len(*txData.Signatures)
+	}
+	spawned := ""
+	if txData.Spawn != nil {
+		spawned = txData.Spawn.GetSpawned().String()
+	}
+	reason := ""
+	if txData.Opaque != nil {
+		reason = txData.Opaque.Reason
+	}
+	got := &entry{
+		Name:       e.Name,
+		Raw:        e.Raw,
+		Type:       txData.Type,
+		Principal:  txData.Tx.GetPrincipal().String(),
+		Receiver:   txData.Tx.GetReceiver().String(),
+		Amount:     txData.Tx.GetAmount(),
+		Counter:    txData.Tx.GetCounter(),
+		GasPrice:   txData.Tx.GetGasPrice(),
+		Signatures: signatures,
+		Spawned:    spawned,
+		Reason:     reason,
+	}
+	if *got != *e {
+		return fmt.Errorf("decoded %+v", *got)
+	}
+	return nil
+}
+
+// invariants parses bytes that may be malformed, the parser must reject them or return a transaction whose
+// fields can all be read.
+func invariants(raw []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic reading the transaction: %v", r)
+		}
+	}()
+
+	txData, err := transactionparser.Parse(raw)
+	if errors.Is(err, transaction.ErrDecoderPanic) {
+		return err
+	}
+	if err != nil {
+		return nil
+	}
+	if txData == nil || txData.Tx == nil {
+		return errors.New("no transaction and no error")
+	}
+	if (txData.Type == transaction.TypeOpaque) != (txData.Opaque != nil) {
+		return fmt.Errorf("type %d with opaque %v", txData.Type, txData.Opaque != nil)
+	}
+	switch txData.Type {
+	case transaction.TypeVaultSpawn, transaction.TypeDrainVault:
+		if txData.Vault == nil {
+			return fmt.Errorf("type %d without vault", txData.Type)
+		}
+	}
+	switch txData.Type {
+	case transaction.TypeSpawn, transaction.TypeMultisigSpawn, transaction.TypeVestingSpawn, transaction.TypeVaultSpawn:
+		if txData.Spawn == nil {
+			return fmt.Errorf("type %d without spawn", txData.Type)
+		}
+		txData.Spawn.GetSpawned()
+	}
+	txData.Tx.GetType()
+	txData.Tx.GetAmount()
+	txData.Tx.GetCounter()
+	txData.Tx.GetReceiver()
+	txData.Tx.GetGasPrice()
+	txData.Tx.GetPrincipal()
+	txData.Tx.GetPublicKeys()
+	return nil
+}
+
+// mutate returns a copy of raw with a few bytes flipped, replaced, inserted, removed or appended.
+func mutate(random *rand.Rand, raw []byte) []byte {
+	mutated := append([]byte{}, raw...)
+	for changes := 1 + random.Intn(4); changes > 0; changes-- {
+		position := 0
+		if len(mutated) > 0 {
+			position = random.Intn(len(mutated))
+		}
+		switch random.Intn(5) {
+		case 0:
+			if len(mutated) > 0 {
+				mutated[position] ^= byte(1 << random.Intn(8))
+			}
+		case 1:
+			if len(mutated) > 0 {
+				mutated[position] = []byte{0x00, 0xff, 0x7f, 0x80, 0x03}[random.Intn(5)]
+			}
+		case 2:
+			mutated = append(mutated[:position], append([]byte{byte(random.Intn(256))}, mutated[position:]...)...)
+		case 3:
+			if len(mutated) > 0 {
+				mutated = append(mutated[:position], mutated[position+1:]...)
+			}
+		case 4:
+			extra := make([]byte, 1+random.Intn(96))
+			random.Read(extra)
+			mutated = append(mutated, extra...)
+		}
+	}
+	return mutated
+}
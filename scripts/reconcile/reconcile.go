@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/reconcile"
+)
+
+func main() {
+	configPath := flag.String("config", "", "state api config, the db of the network selects the database to audit")
+	repair := flag.Bool("repair", false, "overwrite drifted account balances with the computed ones")
+	account := flag.String("account", "", "only reconcile this account")
+	networkName := flag.String("network", "", "network of the config whose db is used, the first one by default")
+	flag.Parse()
+
+	if *configPath == "" {
+		log.Println("Usage: reconcile -config <path to config> [-repair] [-account <address>]")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	configValues := readConfig(*configPath)
+	network, err := configValues.Network(*networkName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	readDB, writeDB, err := database.Open(network)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer readDB.CloseRead()
+	defer writeDB.CloseWrite()
+
+	reconciler := reconcile.NewReconciler(readDB, writeDB, network.GenesisBalances())
+	var result interface{}
+	if *account != "" {
+		var drift interface{}
+		drift, err = reconciler.Account(*account, *repair)
+		result = map[string]interface{}{"address": *account, "drift": drift}
+	} else {
+		result, err = reconciler.Run(*repair)
+	}
+
+	// the report is printed even when the run stopped half way
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if encodeErr := encoder.Encode(result); encodeErr != nil {
+		log.Println(encodeErr)
+	}
+	if err != nil {
+		log.Println("Reconcile failed: ", err)
+		os.Exit(1)
+	}
+}
+
+func readConfig(filePath string) *config.Config {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	configValues := config.Config{}
+	err = decoder.Decode(&configValues)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &configValues
+}
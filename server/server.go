@@ -1,47 +1,69 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/swarmbit/spacemesh-state-api/auth"
 	"github.com/swarmbit/spacemesh-state-api/config"
 	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/health"
+	"github.com/swarmbit/spacemesh-state-api/logging"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/pending"
 	"github.com/swarmbit/spacemesh-state-api/price"
+	"github.com/swarmbit/spacemesh-state-api/reconcile"
 	"github.com/swarmbit/spacemesh-state-api/route"
 	"github.com/swarmbit/spacemesh-state-api/sink"
+	"github.com/swarmbit/spacemesh-state-api/stats"
+	"github.com/swarmbit/spacemesh-state-api/stream"
+	"github.com/swarmbit/spacemesh-state-api/webhook"
 )
 
 func StartServer(configValues *config.Config) {
+	logging.Setup(configValues.Log)
 
-	connection := configValues.DB.Uri
-	writeDB, err := database.NewWriteDB(connection)
+	profiles, err := configValues.NetworkProfiles()
 	if err != nil {
-		panic("Failed to open document write db")
+		slog.Error("Invalid networks", "error", err)
+		os.Exit(1)
 	}
-	readDB, err := database.NewReadDB(connection)
-	if err != nil {
-		panic("Failed to open document read db")
-	}
-	log.Println("Created dbs")
 
-	priceResolver := price.NewPriceResolver(configValues)
-	log.Println("Created price resolver")
+	checker := health.NewChecker()
 
-	if configValues.Nats.Enabled {
-		s := sink.NewSink(configValues, writeDB)
-		s.StartRewardsSink()
-		s.StartLayersSink()
-		s.StartAtxSink()
-		s.StartTransactionCreatedSink()
-		s.StartTransactionResultSink()
-		s.StartMalfeasanceSink()
+	streamEnabled := configValues.Stream != nil && configValues.Stream.Enabled
+	webhooksEnabled := configValues.Webhooks != nil && configValues.Webhooks.Enabled
+
+	networks := make([]*route.Network, 0, len(profiles))
+	// the background jobs of every network, stopped before its dbs are closed
+	jobs := make([][]job, 0, len(profiles))
+	for i, profile := range profiles {
+		n, networkJobs := startNetwork(configValues, profile, checker, i == 0, streamEnabled, webhooksEnabled)
+		networks = append(networks, n)
+		jobs = append(jobs, networkJobs)
 	}
+	defaultDB := networks[0].ReadDB
+
+	// the price is the same on every network, its history is kept in the db of the first one
+	priceResolver, err := price.NewPriceResolver(configValues, defaultDB, networks[0].WriteDB)
+	if err != nil {
+		slog.Error("Invalid price config", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Created price resolver")
+	// it saves the price snapshots and daily closes to the db of the first network
+	jobs[0] = append(jobs[0], job{"price resolver", priceResolver.Stop})
 
 	gin.SetMode(gin.ReleaseMode)
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Recovery(), logging.Middleware(), metrics.Middleware())
 
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
@@ -55,34 +77,200 @@ func StartServer(configValues *config.Config) {
 		}
 		c.Next()
 	})
-	route.AddRoutes(readDB, router, priceResolver, configValues)
+
+	var authenticator *auth.Authenticator
+	if configValues.Auth != nil && configValues.Auth.Enabled {
+		authenticator = auth.NewAuthenticator(configValues.Auth, defaultDB)
+		names := make([]string, 0, len(profiles))
+		for _, profile := range profiles {
+			names = append(names, profile.Name)
+		}
+		authenticator.Networks(names)
+		router.Use(authenticator.Middleware())
+	}
+	route.AddRoutes(router, networks, priceResolver, configValues, authenticator, checker)
+	for i, n := range networks {
+		jobs[i] = append(jobs[i], job{"network state", n.State.Stop})
+	}
 
 	server := &http.Server{
 		Addr:    configValues.Server.Port,
 		Handler: router,
 	}
 
+	shutdownTimeout := 30
+	if configValues.Server.ShutdownTimeout > 0 {
+		shutdownTimeout = configValues.Server.ShutdownTimeout
+	}
+	for _, n := range networks {
+		if n.Hub != nil {
+			// the streams never go idle, shutdown would wait for them until the timeout
+			server.RegisterOnShutdown(n.Hub.Close)
+		}
+	}
+
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	stopped := make(chan struct{})
 
 	go func() {
-		<-quit
-		writeDB.CloseWrite()
-		readDB.CloseRead()
-		log.Println("receive interrupt signal")
-		if err := server.Close(); err != nil {
-			log.Fatal("Server Close:", err)
+		defer close(stopped)
+		sig := <-quit
+		slog.Info("Received signal, shutting down", "signal", sig.String(), "timeout", shutdownTimeout)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(shutdownTimeout)*time.Second)
+		defer cancel()
+
+		// the requests in flight still need the dbs, they are closed last
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Failed to finish the requests in flight", "error", err)
+		}
+		for i, n := range networks {
+			if n.Sink != nil {
+				if err := n.Sink.Stop(ctx); err != nil {
+					slog.Error("Failed to settle the fetched sink messages, they will be delivered again", "network", n.Config.Name, "error", err)
+				} else {
+					slog.Info("Stopped sink", "network", n.Config.Name)
+				}
+			}
+			for _, j := range jobs[i] {
+				j.stop()
+				slog.Info("Stopped "+j.name, "network", n.Config.Name)
+			}
+			n.WriteDB.CloseWrite()
+			n.ReadDB.CloseRead()
 		}
 	}()
 
-	log.Println("Listen and serve")
+	slog.Info("Listen and serve", "address", configValues.Server.Port)
 	if err := server.ListenAndServe(); err != nil {
 		if err == http.ErrServerClosed {
-			log.Println("Server closed under request")
+			slog.Info("Server closed under request")
 		} else {
-			log.Fatal("Server closed unexpect")
+			slog.Error("Server closed unexpectedly", "error", err)
+			os.Exit(1)
+		}
+	}
+	<-stopped
+
+	slog.Info("Server exiting")
+}
+
+// job is a background job of a network, stop waits for the work in progress to finish.
+type job struct {
+	name string
+	stop func()
+}
+
+// startNetwork opens the dbs of a network and starts its reconciler, sink, stream hub and webhooks, it returns the
+// jobs it started besides the sink. The checks of the first network keep their plain names, the others are
+// prefixed with the name of their network.
+func startNetwork(configValues *config.Config, profile *config.NetworkConfig, checker *health.Checker, first bool, streamEnabled bool, webhooksEnabled bool) (*route.Network, []job) {
+	checkName := func(name string) string {
+		if first {
+			return name
+		}
+		return profile.Name + "." + name
+	}
+
+	readDB, openedWriteDB, err := database.Open(profile)
+	if err != nil {
+		slog.Error("Failed to open dbs", "network", profile.Name, "error", err)
+		panic("Failed to open document dbs")
+	}
+	var writeDB database.WriteDB = metrics.NewWriteDB(profile.Name, openedWriteDB)
+	slog.Info("Created dbs", "network", profile.Name)
+
+	checker.Add(checkName("db"), readDB.Ping)
+
+	var jobs []job
+	reconciler := reconcile.NewReconciler(readDB, writeDB, profile.GenesisBalances())
+	// the reconciler also runs the reconciles started by the admin routes
+	jobs = append(jobs, job{"reconciler", reconciler.Stop})
+	if configValues.Reconcile != nil && configValues.Reconcile.Enabled {
+		interval := 60
+		if configValues.Reconcile.Interval > 0 {
+			interval = configValues.Reconcile.Interval
+		}
+		reconciler.Start(interval, configValues.Reconcile.Repair)
+		slog.Info("Started reconciler", "network", profile.Name)
+	}
+
+	if configValues.Stats != nil && configValues.Stats.Enabled {
+		interval := 10
+		if configValues.Stats.Interval > 0 {
+			interval = configValues.Stats.Interval
+		}
+		collector := stats.NewCollector(readDB, writeDB, network.NewNetworkUtils(profile))
+		collector.Start(interval)
+		jobs = append(jobs, job{"statistics collector", collector.Stop})
+		slog.Info("Started statistics collector", "network", profile.Name)
+	}
+
+	if configValues.Pending != nil && configValues.Pending.Enabled {
+		dropLayers := int64(20)
+		if configValues.Pending.DropLayers > 0 {
+			dropLayers = configValues.Pending.DropLayers
+		}
+		interval := 1
+		if configValues.Pending.Interval > 0 {
+			interval = configValues.Pending.Interval
+		}
+		expirer := pending.NewExpirer(readDB, writeDB, network.NewNetworkUtils(profile), dropLayers)
+		expirer.Start(interval)
+		jobs = append(jobs, job{"pending transactions expirer", expirer.Stop})
+		slog.Info("Started pending transactions expirer", "network", profile.Name)
+	}
+
+	natsEnabled := profile.Nats != nil && profile.Nats.Enabled
+
+	// the hub gets the events of the sink, the stream routes and the webhooks need it
+	var hub *stream.Hub
+	if (streamEnabled || webhooksEnabled) && natsEnabled {
+		buffer := 10000
+		if configValues.Stream != nil && configValues.Stream.Buffer > 0 {
+			buffer = configValues.Stream.Buffer
+		}
+		hub = stream.NewHub(profile, buffer)
+		slog.Info("Created stream hub", "network", profile.Name)
+	} else if streamEnabled {
+		slog.Warn("Stream needs the nats sink, not serving /stream", "network", profile.Name)
+	}
+
+	if webhooksEnabled && hub != nil {
+		dispatcher := webhook.NewDispatcher(configValues.Webhooks, readDB, writeDB, hub)
+		dispatcher.Start()
+		jobs = append(jobs, job{"webhook dispatcher", dispatcher.Stop})
+		slog.Info("Started webhook dispatcher", "network", profile.Name)
+	}
+	var streamHub *stream.Hub
+	if streamEnabled {
+		streamHub = hub
+	}
+
+	var s *sink.Sink
+	if natsEnabled {
+		var sinkDB database.WriteDB = writeDB
+		if hub != nil {
+			sinkDB = stream.NewPublishingWriteDB(writeDB, readDB, hub)
+		}
+		s = sink.NewSink(profile, sinkDB)
+		s.Start()
+
+		// only the instance running the sink can catch up, the others stay ready while it does
+		maxLayerLag := int64(12)
+		if configValues.Health != nil && configValues.Health.MaxLayerLag > 0 {
+			maxLayerLag = configValues.Health.MaxLayerLag
 		}
+		checker.Add(checkName("nats"), s.Ping)
+		checker.Add(checkName("layers"), health.LayerFreshness(readDB, profile, maxLayerLag))
 	}
 
-	log.Println("Server exiting")
+	return &route.Network{
+		Config:     profile,
+		ReadDB:     readDB,
+		WriteDB:    writeDB,
+		Reconciler: reconciler,
+		Hub:        streamHub,
+		Sink:       s,
+	}, jobs
 }
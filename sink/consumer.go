@@ -0,0 +1,241 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	fetchBatch = 100
+	// fetchWait bounds a fetch so the consumer notices the shutdown and the lost connections
+	fetchWait  = 30 * time.Second
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+	// a failing message is delivered again after redeliveryWait, doubled on each delivery up to
+	// maxRedeliveryWait, so a db outage of a few minutes doesn't use up the deliveries of valid messages.
+	// It is also the ack wait, a message not settled by then is delivered again.
+	redeliveryWait    = 30 * time.Second
+	maxRedeliveryWait = 10 * time.Minute
+)
+
+// redeliveryDelay is how long a message that failed on its deliveries-th delivery waits to be delivered again.
+func redeliveryDelay(deliveries uint64) time.Duration {
+	delay := redeliveryWait
+	for i := uint64(1); i < deliveries && delay < maxRedeliveryWait; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRedeliveryWait)
+}
+
+// consumer is a durable pull consumer of a stream. It is supervised, when subscribing or fetching fails it
+// subscribes again after a backoff, and it hands the messages to a bounded pool of workers, so a slow db
+// holds back the fetches instead of piling up goroutines.
+type consumer struct {
+	// network and name label the metrics and logs
+	network string
+	name    string
+	stream  string
+	subject string
+	durable string
+	// workers is how many messages are handled at the same time, 1 keeps the order of the stream
+	workers int
+	// handle saves the payload of a message, an error naks it so it is delivered again
+	handle func(data []byte, origin database.Origin) error
+	// maxDeliver is how many times a failing message is delivered before it is dead lettered
+	maxDeliver uint64
+	writeDB    database.WriteDB
+}
+
+// run consumes until ctx is done, the messages already fetched are handled before it returns.
+func (c *consumer) run(ctx context.Context, js nats.JetStreamContext) {
+	slog.Info("Start sink consumer", "network", c.network, "stream", c.name, "workers", c.workers)
+	backoff := minBackoff
+	for {
+		started := time.Now()
+		err := c.consume(ctx, js)
+		if ctx.Err() != nil {
+			slog.Info("Stopped sink consumer", "stream", c.name)
+			return
+		}
+		// a consumer that ran for a while failed on something new, it doesn't keep the backoff of older failures
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		metrics.SinkRestarts.WithLabelValues(c.network, c.name).Inc()
+		slog.Error("Sink consumer failed, restarting", "stream", c.name, "error", err, "backoff", backoff.String())
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopped sink consumer", "stream", c.name)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func (c *consumer) consume(ctx context.Context, js nats.JetStreamContext) error {
+	if err := c.ensure(ctx, js); err != nil {
+		return err
+	}
+	// bound to the durable, unsubscribing doesn't delete it
+	sub, err := js.PullSubscribe(c.subject, c.durable, nats.Bind(c.stream, c.durable), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	jobs := make(chan *nats.Msg)
+	var wg sync.WaitGroup
+	wg.Add(c.workers)
+	for i := 0; i < c.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				c.process(msg)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	for {
+		fetchCtx, cancel := context.WithTimeout(ctx, fetchWait)
+		msgs, err := sub.Fetch(fetchBatch, nats.Context(fetchCtx))
+		cancel()
+		// the fetched messages are handled even on shutdown, the ack wait would deliver them late otherwise
+		for _, msg := range msgs {
+			jobs <- msg
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+				slog.Debug("No messages to fetch", "stream", c.name)
+				continue
+			}
+			return fmt.Errorf("failed to fetch: %w", err)
+		}
+	}
+}
+
+// consumerConfig is the config of the durable, the server redelivers a message that isn't settled with the
+// backoff the consumer naks with, and stops after maxDeliver deliveries.
+func (c *consumer) consumerConfig() *nats.ConsumerConfig {
+	var backoff []time.Duration
+	for i := uint64(1); i < c.maxDeliver; i++ {
+		backoff = append(backoff, redeliveryDelay(i))
+	}
+	return &nats.ConsumerConfig{
+		Durable:       c.durable,
+		FilterSubject: c.subject,
+		AckPolicy:     nats.AckExplicitPolicy,
+		AckWait:       redeliveryWait,
+		MaxDeliver:    int(c.maxDeliver),
+		BackOff:       backoff,
+	}
+}
+
+// ensure creates the durable consumer when the stream doesn't have it yet, and updates its redelivery when it
+// was created with another one.
+func (c *consumer) ensure(ctx context.Context, js nats.JetStreamContext) error {
+	config := c.consumerConfig()
+	info, err := js.ConsumerInfo(c.stream, c.durable, nats.Context(ctx))
+	if err == nil {
+		if info.Config.AckWait == config.AckWait && info.Config.MaxDeliver == config.MaxDeliver &&
+			slices.Equal(info.Config.BackOff, config.BackOff) {
+			return nil
+		}
+		updated := info.Config
+		updated.AckWait = config.AckWait
+		updated.MaxDeliver = config.MaxDeliver
+		updated.BackOff = config.BackOff
+		if _, err := js.UpdateConsumer(c.stream, &updated, nats.Context(ctx)); err != nil {
+			return fmt.Errorf("failed to update consumer: %w", err)
+		}
+		slog.Info("Updated sink consumer redelivery", "stream", c.name, "durable", c.durable, "maxDeliver", config.MaxDeliver)
+		return nil
+	}
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return fmt.Errorf("failed to get consumer: %w", err)
+	}
+	_, err = js.AddConsumer(c.stream, config, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to create consumer: %w", err)
+	}
+	slog.Info("Created sink consumer", "stream", c.name, "durable", c.durable)
+	return nil
+}
+
+// process settles a message. A message that keeps failing, or can't be decoded, is dead lettered so it
+// doesn't come back forever, a panic counts as a failure instead of taking down the sink.
+func (c *consumer) process(msg *nats.Msg) {
+	// a message without metadata is handled as a first delivery without an origin
+	meta, _ := msg.Metadata()
+	c.settle(msg, meta)
+}
+
+// settle handles a message delivered with meta, nil when it has none, and returns how it was settled.
+func (c *consumer) settle(msg *nats.Msg, meta *nats.MsgMetadata) string {
+	panicked, err := c.handleSafely(msg, meta)
+	if err == nil {
+		return c.ack(msg, meta)
+	}
+	deliveries := deliveryCount(meta)
+	if !errors.Is(err, database.ErrMalformed) && deliveries < c.maxDeliver {
+		if panicked {
+			return c.nak(msg, meta, "panic")
+		}
+		return c.nak(msg, meta, "nak")
+	}
+	return c.deadLetter(msg, meta, deliveries, err)
+}
+
+func (c *consumer) handleSafely(msg *nats.Msg, meta *nats.MsgMetadata) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("Panic processing message", "stream", c.name, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+			panicked = true
+		}
+	}()
+	return false, c.handle(msg.Data, origin(msg, meta))
+}
+
+// deadLetter stores the message with its error and terminates it, it is naked if it can't be stored.
+func (c *consumer) deadLetter(msg *nats.Msg, meta *nats.MsgMetadata, deliveries uint64, cause error) string {
+	letter := &types.DeadLetterDoc{
+		Stream:     c.name,
+		Subject:    msg.Subject,
+		Payload:    string(msg.Data),
+		Error:      cause.Error(),
+		Deliveries: deliveries,
+		CreatedAt:  time.Now().UnixMilli(),
+	}
+	if meta != nil {
+		letter.Sequence = meta.Sequence.Stream
+		letter.Published = meta.Timestamp.UnixMilli()
+	}
+	letter.ID = fmt.Sprintf("%s-%d", c.name, letter.Sequence)
+
+	if err := c.writeDB.SaveDeadLetter(letter); err != nil {
+		slog.Error("Failed to save dead letter", "stream", c.name, "sequence", letter.Sequence, "error", err)
+		return c.nak(msg, meta, "nak")
+	}
+	slog.Warn("Dead lettered message", "stream", c.name, "id", letter.ID, "deliveries", deliveries, "error", cause)
+	msg.Term()
+	return c.settled(meta, "dead")
+}
@@ -1,338 +1,271 @@
 package sink
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/nats-io/nats.go"
 	natsS "github.com/spacemeshos/go-spacemesh/nats"
-	"github.com/swarmbit/spacemesh-state-api/database"
 	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/metrics"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// the streams of the sink consumers, they label the metrics and logs
+const (
+	layersStream              = "layers"
+	rewardsStream             = "rewards"
+	atxStream                 = "atx"
+	transactionsResultStream  = "transactions.result"
+	transactionsCreatedStream = "transactions.created"
+	malfeasanceStream         = "malfeasance"
 )
 
 type Sink struct {
-	WriteDB                *database.WriteDB
-	layersSub              *nats.Subscription
-	rewardsSub             *nats.Subscription
-	atxSub                 *nats.Subscription
-	transactionsResultSub  *nats.Subscription
-	transactionsCreatedSub *nats.Subscription
-	malfeasanceSub         *nats.Subscription
+	WriteDB database.WriteDB
+	// network labels the metrics and logs of the consumers
+	network   string
+	nc        *nats.Conn
+	js        nats.JetStreamContext
+	consumers []*consumer
+	cancel    context.CancelFunc
+	done      sync.WaitGroup
 }
 
-func NewSink(configValues *config.Config, writeDB *database.WriteDB) *Sink {
-	nc, err := nats.Connect(configValues.Nats.Uri)
+// NewSink consumes the streams of the nats of a network into its db.
+func NewSink(network *config.NetworkConfig, writeDB database.WriteDB) *Sink {
+	natsConfig := network.Nats
+	// the consumers wait for the connection, the pod doesn't restart when nats starts after it
+	nc, err := nats.Connect(natsConfig.Uri, nats.RetryOnFailedConnect(true), nats.MaxReconnects(-1), nats.Name("state-api-"+network.Name))
 	if err != nil {
 		panic("Failed to connect to NATS")
 	}
-	js, _ := nc.JetStream()
-
-	js.AddConsumer("layers", &nats.ConsumerConfig{
-		Durable:        "state-api-process-layers",
-		DeliverSubject: "layers",
-		DeliverGroup:   "state-api-process-layers",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("rewards", &nats.ConsumerConfig{
-		Durable:        "state-api-process-rewards",
-		DeliverSubject: "rewards",
-		DeliverGroup:   "state-api-process-rewards",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("atx", &nats.ConsumerConfig{
-		Durable:        "state-api-process-atx",
-		DeliverSubject: "atx",
-		DeliverGroup:   "state-api-process-atx",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("transactions", &nats.ConsumerConfig{
-		Durable:        "state-api-process-transactions-result",
-		DeliverSubject: "transactions.result",
-		DeliverGroup:   "state-api-process-transactions",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("transactions", &nats.ConsumerConfig{
-		Durable:        "state-api-process-transactions-created",
-		DeliverSubject: "transactions.created",
-		DeliverGroup:   "state-api-process-transactions",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	js.AddConsumer("malfeasance", &nats.ConsumerConfig{
-		Durable:        "state-api-process-malfeasance",
-		DeliverSubject: "malfeasance",
-		DeliverGroup:   "state-api-process-malfeasance",
-		AckPolicy:      nats.AckExplicitPolicy,
-		DeliverPolicy:  nats.DeliverLastPolicy,
-	})
-
-	fmt.Println("Connect to nats stream")
-	layersSub, err := js.PullSubscribe("layers", "state-api-process-layers", nats.BindStream("layers"))
+	js, err := nc.JetStream()
 	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+		panic("Failed to create NATS JetStream context")
 	}
-	rewardsSub, err := js.PullSubscribe("rewards", "state-api-process-rewards", nats.BindStream("rewards"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	workers := 16
+	if natsConfig.Workers > 0 {
+		workers = natsConfig.Workers
 	}
-	atxSub, err := js.PullSubscribe("atx", "state-api-process-atx", nats.BindStream("atx"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	maxDeliver := uint64(5)
+	if natsConfig.MaxDeliver > 0 {
+		maxDeliver = uint64(natsConfig.MaxDeliver)
 	}
-	transactionsResultSub, err := js.PullSubscribe("transactions.result", "state-api-process-transactions-result", nats.BindStream("transactions"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+
+	s := &Sink{
+		nc:      nc,
+		js:      js,
+		WriteDB: writeDB,
+		network: network.Name,
 	}
-	transactionsCreatedSub, err := js.PullSubscribe("transactions.created", "state-api-process-transactions-created", nats.BindStream("transactions"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	s.consumers = []*consumer{
+		{name: layersStream, stream: "layers", subject: "layers", durable: "state-api-process-layers", workers: 1, handle: s.processLayerMessage},
+		{name: rewardsStream, stream: "rewards", subject: "rewards", durable: "state-api-process-rewards", workers: workers, handle: s.processRewardMessage},
+		{name: atxStream, stream: "atx", subject: "atx", durable: "state-api-process-atx", workers: workers, handle: s.processAtxMessage},
+		{name: transactionsResultStream, stream: "transactions", subject: "transactions.result", durable: "state-api-process-transactions-result", workers: 1, handle: s.transactionHandler(transactionsResultStream, true)},
+		{name: transactionsCreatedStream, stream: "transactions", subject: "transactions.created", durable: "state-api-process-transactions-created", workers: 1, handle: s.transactionHandler(transactionsCreatedStream, false)},
+		{name: malfeasanceStream, stream: "malfeasance", subject: "malfeasance", durable: "state-api-process-malfeasance", workers: 1, handle: s.processMalfeasanceMessage},
 	}
-	malfeasanceSub, err := js.PullSubscribe("malfeasance", "state-api-process-malfeasance", nats.BindStream("malfeasance"))
-	if err != nil {
-		fmt.Println("Failed to subscribe: ", err)
+	for _, c := range s.consumers {
+		c.network = network.Name
+		c.maxDeliver = maxDeliver
+		c.writeDB = writeDB
 	}
-	return &Sink{
-		layersSub:              layersSub,
-		rewardsSub:             rewardsSub,
-		atxSub:                 atxSub,
-		transactionsResultSub:  transactionsResultSub,
-		transactionsCreatedSub: transactionsCreatedSub,
-		malfeasanceSub:         malfeasanceSub,
-		WriteDB:                writeDB,
+	return s
+}
+
+// Start runs the consumers of all the streams until Stop.
+func (s *Sink) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, c := range s.consumers {
+		s.done.Add(1)
+		go func(c *consumer) {
+			defer s.done.Done()
+			c.run(ctx, s.js)
+		}(c)
 	}
 }
 
-func (s *Sink) StartRewardsSink() {
-	fmt.Println("Start rewards sink")
+// Stop stops fetching, waits for the fetched messages to be settled and closes the connection. It returns
+// the error of ctx when it is done first, the messages not settled yet are delivered again later.
+func (s *Sink) Stop(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	stopped := make(chan struct{})
 	go func() {
-		for {
-			msgs, err := s.rewardsSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			var wg sync.WaitGroup
-			wg.Add(len(msgs))
-			for _, msg := range msgs {
-				go s.processRewardMessage(msg, &wg)
-			}
-			wg.Wait()
-		}
+		s.done.Wait()
+		close(stopped)
 	}()
+	defer s.nc.Close()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (s *Sink) processRewardMessage(msg *nats.Msg, wg *sync.WaitGroup) {
-	defer wg.Done()
-	fmt.Println("New reward")
-	var reward *natsS.Reward
-	errJson := json.Unmarshal(msg.Data, &reward)
-	fmt.Println("Next reward: ", reward.Layer)
-	if errJson != nil {
-		fmt.Println("Error parsing json reward: ", errJson)
-		msg.Nak()
-		return
+// Replay saves the payload of a dead letter again, once the cause of its failure is fixed, and deletes it.
+func (s *Sink) Replay(letter *types.DeadLetterDoc) error {
+	for _, c := range s.consumers {
+		if c.name != letter.Stream {
+			continue
+		}
+		replayed := database.Origin{
+			Published: letter.Published,
+			Stream:    c.stream,
+			Sequence:  letter.Sequence,
+		}
+		if err := c.handle([]byte(letter.Payload), replayed); err != nil {
+			return err
+		}
+		slog.Info("Replayed dead letter", "id", letter.ID, "stream", letter.Stream)
+		_, err := s.WriteDB.DeleteDeadLetter(letter.ID)
+		return err
 	}
-	saveErr := s.WriteDB.SaveReward(reward)
+	return fmt.Errorf("unknown stream %s", letter.Stream)
+}
 
-	if saveErr != nil {
-		fmt.Println("Failed to save reward")
-		msg.Nak()
-	} else {
-		fmt.Println("Reward saved")
-		msg.AckSync()
+func (s *Sink) processRewardMessage(data []byte, origin database.Origin) error {
+	var reward natsS.Reward
+	if err := json.Unmarshal(data, &reward); err != nil {
+		slog.Error("Failed to parse json reward", "error", err)
+		return poison(err)
+	}
+	slog.Debug("Next reward", "layer", reward.Layer)
+	if err := s.WriteDB.SaveReward(&reward, origin); err != nil {
+		slog.Error("Failed to save reward", "layer", reward.Layer, "coinbase", reward.Coinbase, "error", err)
+		return err
 	}
+	slog.Debug("Reward saved", "layer", reward.Layer, "coinbase", reward.Coinbase)
+	return nil
 }
 
-func (s *Sink) StartLayersSink() {
-	fmt.Println("Start layers sink")
-
-	go func() {
-		for {
-			msgs, err := s.layersSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			fmt.Println("New layers")
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
-				fmt.Println("Layer: ", string(msg.Data))
-				var layer *natsS.LayerUpdate
-				errJson := json.Unmarshal(msg.Data, &layer)
-				fmt.Println("Next layer: ", layer.LayerID)
-				if errJson != nil {
-					fmt.Println("Error parsing json layer: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveLayer(layer)
-				if saveErr != nil {
-					fmt.Println("Failed to save layer")
-					msg.Nak()
-				} else {
-					fmt.Println("Layer saved")
-					msg.AckSync()
-				}
-			}
-		}
-	}()
+func (s *Sink) processLayerMessage(data []byte, origin database.Origin) error {
+	var layer natsS.LayerUpdate
+	if err := json.Unmarshal(data, &layer); err != nil {
+		slog.Error("Failed to parse json layer", "error", err)
+		return poison(err)
+	}
+	slog.Debug("Next layer", "layer", layer.LayerID)
+	if err := s.WriteDB.SaveLayer(&layer, origin); err != nil {
+		slog.Error("Failed to save layer", "layer", layer.LayerID, "status", layer.Status, "error", err)
+		return err
+	}
+	slog.Debug("Layer saved", "layer", layer.LayerID, "status", layer.Status)
+	metrics.LastLayer.WithLabelValues(s.network).Set(float64(layer.LayerID))
+	return nil
 }
 
-func (s *Sink) StartAtxSink() {
-	fmt.Println("Start atx sink")
-	go func() {
-		for {
-			msgs, err := s.atxSub.Fetch(100, nats.MaxWait(360*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
+func (s *Sink) processAtxMessage(data []byte, origin database.Origin) error {
+	var atx natsS.Atx
+	if err := json.Unmarshal(data, &atx); err != nil {
+		slog.Error("Failed to parse json atx", "error", err)
+		return poison(err)
+	}
+	slog.Debug("Next atx", "node", atx.NodeID)
+	if err := s.WriteDB.SaveAtx(&atx, origin); err != nil {
+		slog.Error("Failed to save atx", "atx", atx.AtxID, "node", atx.NodeID, "error", err)
+		return err
+	}
+	slog.Debug("Atx saved", "atx", atx.AtxID, "node", atx.NodeID, "epoch", atx.PublishEpoch)
+	return nil
+}
 
-			var wg sync.WaitGroup
-			wg.Add(len(msgs))
-			for _, msg := range msgs {
-				go s.processAtxMessage(msg, &wg)
-			}
-			wg.Wait()
+// transactionHandler saves the transactions of the created and result streams, result tells them apart.
+func (s *Sink) transactionHandler(stream string, result bool) func(data []byte, origin database.Origin) error {
+	return func(data []byte, origin database.Origin) error {
+		var transaction natsS.Transaction
+		if err := json.Unmarshal(data, &transaction); err != nil {
+			slog.Error("Failed to parse json transaction", "stream", stream, "error", err)
+			return poison(err)
 		}
-	}()
+		slog.Debug("Next transaction", "stream", stream, "transaction", transaction.ID)
+		if err := s.WriteDB.SaveTransactions(&transaction, result, origin); err != nil {
+			slog.Error("Failed to save transaction", "stream", stream, "transaction", transaction.ID, "error", err)
+			return err
+		}
+		slog.Debug("Transaction saved", "stream", stream, "transaction", transaction.ID)
+		return nil
+	}
 }
 
-func (s *Sink) processAtxMessage(msg *nats.Msg, wg *sync.WaitGroup) {
-	defer wg.Done()
-	fmt.Println("Atx: ", string(msg.Data))
-	var atx *natsS.Atx
-	errJson := json.Unmarshal(msg.Data, &atx)
-	fmt.Println("Next atx: ", atx.NodeID)
-	if errJson != nil {
-		fmt.Println("Error parsing json atx: ", errJson)
-		msg.Nak()
-		return
+func (s *Sink) processMalfeasanceMessage(data []byte, origin database.Origin) error {
+	var malfeasance natsS.Malfeasance
+	if err := json.Unmarshal(data, &malfeasance); err != nil {
+		slog.Error("Failed to parse json malfeasance", "error", err)
+		return poison(err)
 	}
-	saveErr := s.WriteDB.SaveAtx(atx)
-	if saveErr != nil {
-		fmt.Println("Failed to save atx")
-		msg.Nak()
-	} else {
-		fmt.Println("Atx saved")
-		msg.AckSync()
+	slog.Debug("Next malfeasance", "node", malfeasance.NodeID)
+	if err := s.WriteDB.SaveMalfeasance(&malfeasance, origin); err != nil {
+		slog.Error("Failed to save malfeasance", "node", malfeasance.NodeID, "error", err)
+		return err
 	}
+	slog.Debug("Malfeasance saved", "node", malfeasance.NodeID)
+	return nil
 }
 
-func (s *Sink) StartTransactionResultSink() {
-	fmt.Println("Start transaction result sink")
-
-	go func() {
-		for {
-
-			msgs, err := s.transactionsResultSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
-
-				fmt.Println("Transaction: ", string(msg.Data))
-				var transaction *natsS.Transaction
-				errJson := json.Unmarshal(msg.Data, &transaction)
-				fmt.Println("Next transaction: ", transaction)
-				if errJson != nil {
-					fmt.Println("Error parsing json transaction: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveTransactions(transaction, true)
-				if saveErr != nil {
-					fmt.Println("Failed to save transaction")
-					msg.Nak()
-				} else {
-					fmt.Println("Transaction saved")
-					msg.AckSync()
-				}
-			}
-
-		}
-	}()
+// Ping fails when the connection to nats is lost, the subscriptions get nothing until it reconnects.
+func (s *Sink) Ping(ctx context.Context) error {
+	if !s.nc.IsConnected() {
+		return errors.New("not connected to nats: " + s.nc.Status().String())
+	}
+	return nil
 }
 
-func (s *Sink) StartTransactionCreatedSink() {
-	fmt.Println("Start transaction created sink")
-
-	go func() {
-		for {
-
-			msgs, err := s.transactionsCreatedSub.Fetch(100, nats.MaxWait(2*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
-
-				fmt.Println("Transaction: ", string(msg.Data))
-				var transaction *natsS.Transaction
-				errJson := json.Unmarshal(msg.Data, &transaction)
-				fmt.Println("Next transaction: ", transaction)
-				if errJson != nil {
-					fmt.Println("Error parsing json transaction: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveTransactions(transaction, false)
-				if saveErr != nil {
-					fmt.Println("Failed to save transaction")
-					msg.Nak()
-				} else {
-					fmt.Println("Transaction saved")
-					msg.AckSync()
-				}
-			}
-
-		}
-	}()
+// ack and nak settle a message, counting it and recording the lag of its stream. A nak delays the next
+// delivery by the backoff of the deliveries so far.
+func (c *consumer) ack(msg *nats.Msg, meta *nats.MsgMetadata) string {
+	msg.AckSync()
+	return c.settled(meta, "ack")
 }
 
-func (s *Sink) StartMalfeasanceSink() {
-	fmt.Println("Start malfeasance created sink")
+func (c *consumer) nak(msg *nats.Msg, meta *nats.MsgMetadata, result string) string {
+	msg.NakWithDelay(redeliveryDelay(deliveryCount(meta)))
+	return c.settled(meta, result)
+}
 
-	go func() {
-		for {
+func (c *consumer) settled(meta *nats.MsgMetadata, result string) string {
+	metrics.SinkMessages.WithLabelValues(c.network, c.name, result).Inc()
+	if meta == nil {
+		return result
+	}
+	metrics.SinkLag.WithLabelValues(c.network, c.name).Set(time.Since(meta.Timestamp).Seconds())
+	metrics.SinkPending.WithLabelValues(c.network, c.name).Set(float64(meta.NumPending))
+	return result
+}
 
-			msgs, err := s.malfeasanceSub.Fetch(100, nats.MaxWait(8736*time.Hour))
-			if err == nats.ErrTimeout {
-				fmt.Println("Error ", err.Error())
-				continue
-			}
-			for _, msg := range msgs {
+func deliveryCount(meta *nats.MsgMetadata) uint64 {
+	if meta == nil {
+		return 1
+	}
+	return meta.NumDelivered
+}
 
-				fmt.Println("Malfeasance: ", string(msg.Data))
-				var malfeasance *natsS.Malfeasance
-				errJson := json.Unmarshal(msg.Data, &malfeasance)
-				fmt.Println("Next Malfeasance: ", malfeasance)
-				if errJson != nil {
-					fmt.Println("Error parsing json malfeasance: ", errJson)
-					msg.Nak()
-					continue
-				}
-				saveErr := s.WriteDB.SaveMalfeasance(malfeasance)
-				if saveErr != nil {
-					fmt.Println("Failed to save malfeasance")
-					msg.Nak()
-				} else {
-					fmt.Println("Malfeasance saved")
-					msg.AckSync()
-				}
-			}
+// poison marks an error of a payload that can't be decoded, it is dead lettered without being retried.
+func poison(err error) error {
+	return fmt.Errorf("%w: %w", database.ErrMalformed, err)
+}
 
-		}
-	}()
+// origin returns when and where the message was published on its stream, the db uses it to order records of
+// different streams and to apply a message once.
+func origin(msg *nats.Msg, meta *nats.MsgMetadata) database.Origin {
+	if meta == nil {
+		return database.Origin{}
+	}
+	return database.Origin{
+		Published: meta.Timestamp.UnixMilli(),
+		Stream:    meta.Stream,
+		Sequence:  meta.Sequence.Stream,
+		MsgID:     msg.Header.Get(nats.MsgIdHdr),
+	}
 }
@@ -0,0 +1,163 @@
+package sink
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	coinbase      = "sm1qqqqqq8y5rupfpqzvw4n5mcfcld5aa8utsp3agc2hryuj"
+	rewardPayload = `{"id":"r1","layer":10,"totalReward":1000,"layerReward":1000,"coinbase":"` + coinbase + `","atxID":"a1","nodeID":"n1"}`
+)
+
+// testSink consumes the rewards stream into a new sqlite db, without a nats connection.
+func testSink(t *testing.T) (*Sink, *database.SQLiteDB) {
+	t.Helper()
+	db, err := database.NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "sink.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	s := &Sink{WriteDB: db, network: "test"}
+	s.consumers = []*consumer{
+		{name: rewardsStream, stream: "rewards", subject: "rewards", durable: "state-api-process-rewards", workers: 1, handle: s.processRewardMessage},
+	}
+	for _, c := range s.consumers {
+		c.network = "test"
+		c.maxDeliver = 5
+		c.writeDB = db
+	}
+	return s, db
+}
+
+func delivery(sequence uint64, deliveries uint64) *nats.MsgMetadata {
+	return &nats.MsgMetadata{
+		Stream:       "rewards",
+		Sequence:     nats.SequencePair{Stream: sequence},
+		NumDelivered: deliveries,
+		Timestamp:    time.UnixMilli(1700006400000),
+	}
+}
+
+func TestRedeliveryDelay(t *testing.T) {
+	tests := []struct {
+		deliveries uint64
+		delay      time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{6, 10 * time.Minute},
+		{1000, 10 * time.Minute},
+	}
+	for _, test := range tests {
+		if delay := redeliveryDelay(test.deliveries); delay != test.delay {
+			t.Errorf("delivery %d: delay %s, want %s", test.deliveries, delay, test.delay)
+		}
+	}
+
+	s, _ := testSink(t)
+	config := s.consumers[0].consumerConfig()
+	backoff := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute}
+	if config.MaxDeliver != 5 || config.AckWait != redeliveryWait || !slices.Equal(config.BackOff, backoff) {
+		t.Errorf("max deliver %d, ack wait %s, backoff %v, want 5, %s and %v", config.MaxDeliver, config.AckWait,
+			config.BackOff, redeliveryWait, backoff)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	s, db := testSink(t)
+	c := s.consumers[0]
+	c.handle = func(data []byte, origin database.Origin) error {
+		return errors.New("db is down")
+	}
+
+	msg := &nats.Msg{Subject: "rewards", Data: []byte(rewardPayload)}
+	for deliveries := uint64(1); deliveries < c.maxDeliver; deliveries++ {
+		if result := c.settle(msg, delivery(7, deliveries)); result != "nak" {
+			t.Fatalf("delivery %d settled with %s, want nak", deliveries, result)
+		}
+		if letter, err := db.GetDeadLetter("rewards-7"); err != nil || letter.ID != "" {
+			t.Fatalf("dead letter %q after delivery %d: %v", letter.ID, deliveries, err)
+		}
+	}
+	if result := c.settle(msg, delivery(7, c.maxDeliver)); result != "dead" {
+		t.Fatalf("last delivery settled with %s, want dead", result)
+	}
+	letter, err := db.GetDeadLetter("rewards-7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if letter.Stream != rewardsStream || letter.Sequence != 7 || letter.Deliveries != 5 || letter.Payload != rewardPayload ||
+		letter.Error != "db is down" || letter.Published != 1700006400000 {
+		t.Errorf("dead letter %+v", letter)
+	}
+
+	// a panic is delivered again, a malformed payload is dead lettered on its first delivery
+	c.handle = func(data []byte, origin database.Origin) error {
+		panic("bug")
+	}
+	if result := c.settle(msg, delivery(8, 1)); result != "panic" {
+		t.Errorf("panic settled with %s, want panic", result)
+	}
+	c.handle = s.processRewardMessage
+	malformed := &nats.Msg{Subject: "rewards", Data: []byte("{")}
+	if result := c.settle(malformed, delivery(9, 1)); result != "dead" {
+		t.Errorf("malformed payload settled with %s, want dead", result)
+	}
+	if letter, err := db.GetDeadLetter("rewards-9"); err != nil || letter.Deliveries != 1 {
+		t.Errorf("malformed dead letter %+v: %v", letter, err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s, db := testSink(t)
+	letter := &types.DeadLetterDoc{
+		ID:         "rewards-7",
+		Stream:     rewardsStream,
+		Subject:    "rewards",
+		Sequence:   7,
+		Published:  1700006400000,
+		Payload:    rewardPayload,
+		Error:      "db is down",
+		Deliveries: 5,
+	}
+	if err := db.SaveDeadLetter(letter); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Replay(letter); err != nil {
+		t.Fatal(err)
+	}
+	if reward, err := db.GetReward("r1"); err != nil || reward.TotalReward != 1000 {
+		t.Fatalf("replayed reward %+v: %v", reward, err)
+	}
+	if deleted, err := db.GetDeadLetter("rewards-7"); err != nil || deleted.ID != "" {
+		t.Errorf("dead letter %q not deleted: %v", deleted.ID, err)
+	}
+
+	// replayed again it is the same stream message, it isn't applied twice
+	if err := s.Replay(letter); err != nil {
+		t.Fatal(err)
+	}
+	account, err := db.GetAccount(coinbase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Balance != 1000 {
+		t.Errorf("balance %d after two replays, want 1000", account.Balance)
+	}
+
+	letter.Stream = "unknown"
+	if err := s.Replay(letter); err == nil {
+		t.Error("replayed a dead letter of an unknown stream")
+	}
+}
@@ -0,0 +1,109 @@
+package stats
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"math/big"
+
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// firstPerformanceEpoch is the first epoch with rewards, the atxs of the ones before don't target any.
+const firstPerformanceEpoch = 2
+
+// nodes saved per write
+const performanceBatch = 1000
+
+// Efficiency is rewards in percent of expected, rounded to two decimals, 0 when nothing is expected.
+func Efficiency(rewards uint64, expected uint64) float64 {
+	if expected == 0 {
+		return 0
+	}
+	return math.Round(float64(rewards)/float64(expected)*10000) / 100
+}
+
+// collectPerformance saves the performance of the nodes of every epoch after the last saved one, finalizeLayers
+// after its last layer is processed so the rewards saved late are counted.
+func (c *Collector) collectPerformance(processedLayer int64) error {
+	last, err := c.readDB.GetLastPerformanceEpoch()
+	if err != nil {
+		return err
+	}
+	for epoch := max(last+1, firstPerformanceEpoch); ; epoch++ {
+		_, nextLayer := c.networkUtils.GetEpochLayers(uint64(epoch))
+		if int64(nextLayer)-1+finalizeLayers > processedLayer {
+			return nil
+		}
+		performance, err := c.performance(uint64(epoch))
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(performance); start += performanceBatch {
+			if err := c.writeDB.SaveNodePerformance(performance[start:min(start+performanceBatch, len(performance))]); err != nil {
+				return err
+			}
+		}
+		slog.Debug("Collected node performance", "epoch", epoch, "nodes", len(performance))
+	}
+}
+
+// performance compares the rewards of every node with an atx targeting an epoch with the share of the subsidy
+// of its weight and with its eligibility slots.
+func (c *Collector) performance(epoch uint64) ([]*types.NodePerformanceDoc, error) {
+	atxTotals, err := c.readDB.GetAtxEpoch(epoch - 1)
+	if err != nil || atxTotals.TotalWeight == 0 {
+		return nil, err
+	}
+	atxs, err := c.readDB.GetAtxForEpoch(epoch - 1)
+	if err != nil {
+		return nil, err
+	}
+	firstLayer, nextLayer := c.networkUtils.GetEpochLayers(epoch)
+	totals, err := c.readDB.GetNodesRewardsTotals(firstLayer, nextLayer)
+	if err != nil {
+		return nil, err
+	}
+	rewards := make(map[string]*types.AggregationNodeRewards, len(totals))
+	for _, total := range totals {
+		rewards[total.NodeID] = total
+	}
+
+	// subsidy times weight overflows uint64
+	subsidy := new(big.Int).SetUint64(c.networkUtils.GetEpochSubsidy(epoch))
+	totalWeight := new(big.Int).SetUint64(atxTotals.TotalWeight)
+	performance := make([]*types.NodePerformanceDoc, 0, len(atxs))
+	seen := make(map[string]bool, len(atxs))
+	for _, atx := range atxs {
+		// a second atx of a node in an epoch is a malfeasance, the first one stands
+		if seen[atx.NodeID] {
+			continue
+		}
+		seen[atx.NodeID] = true
+
+		slots, err := c.networkUtils.GetNumberOfSlots(atx.Weight, atxTotals.TotalWeight, uint32(epoch))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the slots of node %s in epoch %d: %w", atx.NodeID, epoch, err)
+		}
+		expected := new(big.Int).Mul(subsidy, new(big.Int).SetUint64(atx.Weight))
+		doc := &types.NodePerformanceDoc{
+			ID:                fmt.Sprintf("%s-%d", atx.NodeID, epoch),
+			NodeID:            atx.NodeID,
+			Coinbase:          atx.Coinbase,
+			Epoch:             int64(epoch),
+			Weight:            atx.Weight,
+			EffectiveNumUnits: uint64(atx.EffectiveNumUnits),
+			EligibleSlots:     int64(slots),
+			ExpectedRewards:   expected.Quo(expected, totalWeight).Uint64(),
+		}
+		if reward, ok := rewards[atx.NodeID]; ok {
+			doc.Rewards = uint64(reward.Sum)
+			doc.RewardedSlots = reward.Count
+		}
+		doc.MissedSlots = max(doc.EligibleSlots-doc.RewardedSlots, 0)
+		doc.Efficiency = Efficiency(doc.Rewards, doc.ExpectedRewards)
+		doc.NoRewards = doc.Rewards == 0
+		performance = append(performance, doc)
+	}
+	return performance, nil
+}
@@ -0,0 +1,238 @@
+package stats
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// Periods of the statistics.
+const (
+	EpochPeriod = "epoch"
+	DayPeriod   = "day"
+)
+
+var Periods = []string{EpochPeriod, DayPeriod}
+
+const secondsPerDay = 24 * 60 * 60
+
+// periods saved per write
+const saveBatch = 100
+
+// a period is complete this many layers after its last one is processed, the rewards of a layer can be saved
+// after the layer itself
+const finalizeLayers = 10
+
+// Metrics read each statistic of the series from the statistics of a period.
+var Metrics = map[string]func(*types.StatsDoc) uint64{
+	"activeSmeshers":          func(s *types.StatsDoc) uint64 { return s.ActiveSmeshers },
+	"totalWeight":             func(s *types.StatsDoc) uint64 { return s.TotalWeight },
+	"effectiveUnitsCommitted": func(s *types.StatsDoc) uint64 { return s.EffectiveUnitsCommitted },
+	"circulatingSupply":       func(s *types.StatsDoc) uint64 { return s.CirculatingSupply },
+	"vested":                  func(s *types.StatsDoc) uint64 { return s.Vested },
+	"totalRewards":            func(s *types.StatsDoc) uint64 { return s.TotalRewards },
+	"rewards":                 func(s *types.StatsDoc) uint64 { return s.Rewards },
+	"transactions":            func(s *types.StatsDoc) uint64 { return s.Transactions },
+	"transactionsVolume":      func(s *types.StatsDoc) uint64 { return s.TransactionsVolume },
+	"newAccounts":             func(s *types.StatsDoc) uint64 { return s.NewAccounts },
+	"malfeasance":             func(s *types.StatsDoc) uint64 { return s.Malfeasance },
+}
+
+// MetricNames are the names of Metrics, sorted.
+func MetricNames() []string {
+	names := make([]string, 0, len(Metrics))
+	for name := range Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Collector saves the statistics of every epoch and day of a network, so that the series don't need to be
+// computed from the rewards and transactions when they are read, and the performance of every node once an
+// epoch ends.
+type Collector struct {
+	readDB       database.ReadDB
+	writeDB      database.WriteDB
+	networkUtils *network.NetworkUtils
+	stop         chan struct{}
+	done         chan struct{}
+}
+
+func NewCollector(readDB database.ReadDB, writeDB database.WriteDB, networkUtils *network.NetworkUtils) *Collector {
+	return &Collector{
+		readDB:       readDB,
+		writeDB:      writeDB,
+		networkUtils: networkUtils,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// Start collects the statistics now and then every interval minutes in the background.
+func (c *Collector) Start(interval int) {
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		defer ticker.Stop()
+		for {
+			if err := c.Collect(); err != nil {
+				slog.Error("Failed to collect statistics", "error", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the collection and waits for the one in progress, so the dbs can be closed. It must follow Start.
+func (c *Collector) Stop() {
+	close(c.stop)
+	<-c.done
+}
+
+// Collect saves the statistics of every period after the last complete one up to the last processed layer,
+// the one in progress included. A period is collected again until finalizeLayers after its last layer, so the
+// rewards saved late are counted. The first run goes back to genesis.
+func (c *Collector) Collect() error {
+	layer, err := c.readDB.GetLastProcessedLayer()
+	if err != nil {
+		return err
+	}
+	for _, period := range Periods {
+		if err := c.collect(period, layer.Layer); err != nil {
+			return fmt.Errorf("failed to collect %s statistics: %w", period, err)
+		}
+	}
+	if err := c.collectPerformance(layer.Layer); err != nil {
+		return fmt.Errorf("failed to collect node performance: %w", err)
+	}
+	return nil
+}
+
+func (c *Collector) collect(period string, processedLayer int64) error {
+	last, err := c.readDB.GetLastCompleteStats(period)
+	if err != nil {
+		return err
+	}
+	index := c.firstIndex(period)
+	var totalRewards uint64
+	if last.ID != "" {
+		index = last.Index + 1
+		totalRewards = last.TotalRewards
+	}
+
+	batch := make([]*types.StatsDoc, 0, saveBatch)
+	collected := 0
+	for ; ; index++ {
+		firstLayer, lastLayer := c.layers(period, index)
+		if firstLayer > processedLayer {
+			break
+		}
+		doc, err := c.stats(period, index, firstLayer, min(lastLayer, processedLayer), totalRewards)
+		if err != nil {
+			return err
+		}
+		doc.LastLayer = lastLayer
+		doc.Complete = lastLayer+finalizeLayers <= processedLayer
+		totalRewards = doc.TotalRewards
+		batch = append(batch, doc)
+		if len(batch) == saveBatch {
+			if err := c.writeDB.SaveStats(batch); err != nil {
+				return err
+			}
+			collected += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := c.writeDB.SaveStats(batch); err != nil {
+		return err
+	}
+	collected += len(batch)
+	slog.Debug("Collected statistics", "period", period, "periods", collected)
+	return nil
+}
+
+// firstIndex returns the index of the period of genesis.
+func (c *Collector) firstIndex(period string) int64 {
+	if period == DayPeriod {
+		return c.networkUtils.Network().Genesis / secondsPerDay
+	}
+	return 0
+}
+
+// layers returns the first and last layer of a period, the layers of a day are the ones starting in it.
+func (c *Collector) layers(period string, index int64) (int64, int64) {
+	if period == DayPeriod {
+		start := index * secondsPerDay
+		network := c.networkUtils.Network()
+		return network.LayerAt(start-1) + 1, network.LayerAt(start + secondsPerDay - 1)
+	}
+	firstLayer, nextLayer := c.networkUtils.GetEpochLayers(uint64(index))
+	return int64(firstLayer), int64(nextLayer) - 1
+}
+
+// stats computes the statistics of a period from its first layer to lastLayer, totalRewards are the rewards
+// paid before it.
+func (c *Collector) stats(period string, index int64, firstLayer int64, lastLayer int64, totalRewards uint64) (*types.StatsDoc, error) {
+	doc := &types.StatsDoc{
+		ID:         fmt.Sprintf("%s-%d", period, index),
+		Period:     period,
+		Index:      index,
+		Timestamp:  c.networkUtils.LayerTime(firstLayer),
+		FirstLayer: firstLayer,
+	}
+	if period == DayPeriod {
+		doc.Timestamp = index * secondsPerDay
+	}
+
+	// the atxs targeting an epoch are published in the one before
+	epoch := uint64(c.networkUtils.GetEpoch(uint64(firstLayer)))
+	if epoch > 0 {
+		atxTotals, err := c.readDB.GetAtxEpoch(epoch - 1)
+		if err != nil {
+			return nil, err
+		}
+		doc.ActiveSmeshers = atxTotals.TotalAtx
+		doc.TotalWeight = atxTotals.TotalWeight
+		doc.EffectiveUnitsCommitted = atxTotals.TotalEffectiveNumUnits
+	}
+
+	rewards, err := c.readDB.SumRewardsLayers("", uint32(firstLayer), uint32(lastLayer+1))
+	if err != nil {
+		return nil, err
+	}
+	doc.Rewards = uint64(rewards)
+	doc.TotalRewards = totalRewards + doc.Rewards
+	doc.Vested = c.networkUtils.Vested(uint64(lastLayer))
+	doc.CirculatingSupply = doc.TotalRewards + doc.Vested
+
+	transactions, err := c.readDB.GetTransactionsTotals(uint32(firstLayer), uint32(lastLayer+1))
+	if err != nil {
+		return nil, err
+	}
+	doc.Transactions = uint64(transactions.Count)
+	doc.TransactionsVolume = uint64(transactions.Volume)
+
+	newAccounts, err := c.readDB.CountNewAccounts(uint32(firstLayer), uint32(lastLayer+1))
+	if err != nil {
+		return nil, err
+	}
+	doc.NewAccounts = uint64(newAccounts)
+
+	// malfeasance proofs are received in milliseconds
+	malfeasance, err := c.readDB.CountMalfeasance(c.networkUtils.LayerTime(firstLayer)*1000, c.networkUtils.LayerTime(lastLayer+1)*1000)
+	if err != nil {
+		return nil, err
+	}
+	doc.Malfeasance = uint64(malfeasance)
+	return doc, nil
+}
@@ -0,0 +1,128 @@
+package stats
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/network"
+)
+
+const coinbase = "sm1qqqqqq8y5rupfpqzvw4n5mcfcld5aa8utsp3agc2hryuj"
+
+// testCollector collects the statistics of a network of 48 layers per epoch in a new sqlite db.
+func testCollector(t *testing.T) (*Collector, *database.SQLiteDB) {
+	t.Helper()
+	configValues := &config.Config{Networks: []*config.NetworkConfig{{
+		Name:           "test",
+		HRP:            "sm",
+		Genesis:        1700006400,
+		LayerDuration:  300,
+		LayersPerEpoch: 48,
+		DB:             &config.DBConfig{Type: database.SQLiteType},
+	}}}
+	profiles, err := configValues.NetworkProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.NewSQLiteDB("file:"+filepath.Join(t.TempDir(), "stats.sql"), "sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.CloseRead)
+	return NewCollector(db, db, network.NewNetworkUtils(profiles[0])), db
+}
+
+func saveReward(t *testing.T, db *database.SQLiteDB, id string, layer uint32, amount uint64) {
+	t.Helper()
+	reward := &nats.Reward{ID: id, Layer: layer, Coinbase: coinbase, Total: amount, LayerReward: amount, NodeID: "n1", AtxID: "a1"}
+	if err := db.SaveReward(reward, database.Origin{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectCompletesAfterMargin(t *testing.T) {
+	c, db := testCollector(t)
+	saveReward(t, db, "r1", 10, 100)
+
+	// epoch 0 is layers 0 to 47, its last layer is processed but a reward of it is saved late
+	if err := c.collect(EpochPeriod, 47); err != nil {
+		t.Fatal(err)
+	}
+	last, err := db.GetLastCompleteStats(EpochPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != "" {
+		t.Fatalf("epoch %d complete right after its last layer", last.Index)
+	}
+	saveReward(t, db, "r2", 47, 50)
+
+	if err := c.collect(EpochPeriod, 47+finalizeLayers); err != nil {
+		t.Fatal(err)
+	}
+	last, err = db.GetLastCompleteStats(EpochPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.ID != "epoch-0" || !last.Complete {
+		t.Fatalf("last complete %q, want epoch-0", last.ID)
+	}
+	if last.Rewards != 150 || last.TotalRewards != 150 {
+		t.Errorf("rewards %d, total %d, want the late reward counted", last.Rewards, last.TotalRewards)
+	}
+
+	// a complete period is not collected again
+	saveReward(t, db, "r3", 20, 1)
+	if err := c.collect(EpochPeriod, 100); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := db.GetStats(EpochPeriod, 0, 1<<40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 3 || stats[0].Rewards != 150 {
+		t.Fatalf("stats %+v, want epochs 0 to 2 with epoch 0 unchanged", stats)
+	}
+	if stats[1].Complete || stats[2].Complete {
+		t.Errorf("epochs 1 and 2 complete before %d layers after their end", finalizeLayers)
+	}
+}
+
+func TestCollectPerformanceAfterMargin(t *testing.T) {
+	c, db := testCollector(t)
+	// the atx published in epoch 1 targets epoch 2, layers 96 to 143
+	atx := &nats.Atx{AtxID: "a1", NodeID: "n1", Coinbase: coinbase, PublishEpoch: 1, EffectiveNumUnits: 4, TickCount: 10}
+	if err := db.SaveAtx(atx, database.Origin{}); err != nil {
+		t.Fatal(err)
+	}
+	saveReward(t, db, "r1", 100, 100)
+
+	if err := c.collectPerformance(143); err != nil {
+		t.Fatal(err)
+	}
+	last, err := db.GetLastPerformanceEpoch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != -1 {
+		t.Fatalf("performance of epoch %d saved right after its last layer", last)
+	}
+	saveReward(t, db, "r2", 143, 50)
+
+	if err := c.collectPerformance(143 + finalizeLayers); err != nil {
+		t.Fatal(err)
+	}
+	performance, err := db.GetNodePerformance("n1", 0, 10, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(performance) != 1 || performance[0].Epoch != 2 {
+		t.Fatalf("performance %+v, want epoch 2", performance)
+	}
+	if performance[0].Rewards != 150 || performance[0].RewardedSlots != 2 {
+		t.Errorf("rewards %d in %d slots, want the late reward counted", performance[0].Rewards, performance[0].RewardedSlots)
+	}
+}
@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+func layerEvent(network *config.NetworkConfig, layer *types.LayerDoc) *Event {
+	return &Event{
+		Type:  LayerEvent,
+		Layer: layer.Layer,
+		key:   fmt.Sprintf("layer:%d:%d", layer.Layer, layer.Status),
+		Data: &types.Layer{
+			Layer:        layer.Layer,
+			Status:       layer.Status,
+			AppliedBlock: layer.AppliedBlock,
+			Timestamp:    network.LayerTime(layer.Layer),
+			Reverts:      make([]*types.LayerRevert, 0),
+		},
+	}
+}
+
+func rewardEvent(network *config.NetworkConfig, reward *types.RewardsDoc) *Event {
+	return &Event{
+		Type:  RewardEvent,
+		Layer: reward.Layer,
+		key:   "reward:" + reward.Id,
+		Data: &types.Reward{
+			Account: reward.Coinbase,
+			Rewards: reward.TotalReward,
+			// legacy
+			RewardsDisplay: "",
+			Layer:          reward.Layer,
+			SmesherId:      reward.NodeId,
+			// legacy
+			Time:      "2023-09-05T00:00:00Z",
+			Timestamp: network.LayerTime(reward.Layer),
+			// the events are not priced, the rewards routes are
+			USDValue: -1,
+		},
+		accounts: []string{reward.Coinbase},
+		nodeID:   reward.NodeId,
+	}
+}
+
+// transactionEvent uses the current layer for mempool transactions that don't have one yet.
+func transactionEvent(network *config.NetworkConfig, transaction *types.TransactionDoc) *Event {
+	method := "Spend"
+	if transaction.Method == 17 {
+		method = "DrainVault"
+	}
+	layer := int64(transaction.Layer)
+	if layer == 0 {
+		layer = network.CurrentLayer()
+	}
+	return &Event{
+		Type:  TransactionEvent,
+		Layer: layer,
+		key:   fmt.Sprintf("transaction:%s:%v", transaction.ID, transaction.Complete),
+		Data: &types.StreamTransaction{
+			Transaction: &types.Transaction{
+				ID:               transaction.ID,
+				Status:           transaction.Status,
+				PrincipalAccount: transaction.PrincipaAccount,
+				ReceiverAccount:  transaction.ReceiverAccount,
+				VaultAccount:     transaction.VaultAccount,
+				Fee:              transaction.TotalFee(),
+				Amount:           transaction.Amount,
+				Layer:            transaction.Layer,
+				Counter:          transaction.Counter,
+				Method:           method,
+				Type:             transaction.Type,
+				Timestamp:        network.LayerTime(int64(transaction.Layer)),
+				USDValue:         -1,
+			},
+			Complete: transaction.Complete,
+		},
+		accounts: []string{transaction.PrincipaAccount, transaction.ReceiverAccount, transaction.VaultAccount},
+	}
+}
+
+// atxEvent is sent in the layer the atx was saved in, atxs don't belong to a layer.
+func atxEvent(network *config.NetworkConfig, atx *types.AtxDoc) *Event {
+	return &Event{
+		Type:  AtxEvent,
+		Layer: network.CurrentLayer(),
+		key:   "atx:" + atx.AtxID,
+		Data: &types.StreamAtx{
+			NodeId:            atx.NodeID,
+			AtxId:             atx.AtxID,
+			Coinbase:          atx.Coinbase,
+			PublishEpoch:      atx.PublishEpoch,
+			EffectiveNumUnits: atx.EffectiveNumUnits,
+			Received:          atx.Received,
+		},
+		accounts: []string{atx.Coinbase},
+		nodeID:   atx.NodeID,
+	}
+}
+
+func malfeasanceEvent(nodeID string, layer int64, received int64) *Event {
+	return &Event{
+		Type:  MalfeasanceEvent,
+		Layer: layer,
+		key:   fmt.Sprintf("malfeasance:%s:%d", nodeID, layer),
+		Data: &types.StreamMalfeasance{
+			NodeId:   nodeID,
+			Layer:    layer,
+			Received: received,
+		},
+		nodeID: nodeID,
+	}
+}
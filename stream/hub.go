@@ -0,0 +1,203 @@
+package stream
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+const (
+	LayerEvent       = "layer"
+	RewardEvent      = "reward"
+	TransactionEvent = "transaction"
+	AtxEvent         = "atx"
+	MalfeasanceEvent = "malfeasance"
+)
+
+var EventTypes = []string{LayerEvent, RewardEvent, TransactionEvent, AtxEvent, MalfeasanceEvent}
+
+// events buffered per subscriber, a subscriber that falls further behind is dropped
+const subscriberBuffer = 1024
+
+// Event is a record persisted by the sink, sent to the subscribers after it is saved.
+type Event struct {
+	Type  string `json:"type"`
+	Layer int64  `json:"layer"`
+	Data  any    `json:"data"`
+
+	key      string
+	accounts []string
+	nodeID   string
+}
+
+// Key identifies the record of an event and its state, an event sent again has the same key.
+func (e *Event) Key() string {
+	return e.key
+}
+
+// Filter selects the events of a subscription, an empty set matches everything. Layer events are not
+// about an account or node and pass the account and node filters.
+type Filter struct {
+	Types    map[string]bool
+	Accounts map[string]bool
+	Nodes    map[string]bool
+}
+
+func (f *Filter) wants(eventType string) bool {
+	return len(f.Types) == 0 || f.Types[eventType]
+}
+
+func (f *Filter) match(event *Event) bool {
+	if !f.wants(event.Type) {
+		return false
+	}
+	if event.Type == LayerEvent || (len(f.Accounts) == 0 && len(f.Nodes) == 0) {
+		return true
+	}
+	for _, account := range event.accounts {
+		if f.Accounts[account] {
+			return true
+		}
+	}
+	return event.nodeID != "" && f.Nodes[strings.ToLower(event.nodeID)]
+}
+
+// Subscription receives the matching events on C until it is closed. The hub also closes C when the
+// subscriber falls too far behind.
+type Subscription struct {
+	C <-chan *Event
+
+	hub    *Hub
+	filter *Filter
+	events chan *Event
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subscribers[s]; ok {
+		delete(s.hub.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Hub fans out the events of the sink to the subscribers. It keeps the last events in a ring buffer
+// so subscribers can resume from a recent layer.
+type Hub struct {
+	// network the events belong to, a hub gets the events of a single sink
+	network     *config.NetworkConfig
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	ring        []*Event
+	next        int
+	full        bool
+	// covered is the first layer with all its events published by this process still in the ring,
+	// -1 until the first event
+	covered int64
+	closed  bool
+}
+
+func NewHub(network *config.NetworkConfig, size int) *Hub {
+	return &Hub{
+		network:     network,
+		subscribers: make(map[*Subscription]struct{}),
+		ring:        make([]*Event, size),
+		covered:     -1,
+	}
+}
+
+// Network returns the network of the events.
+func (h *Hub) Network() *config.NetworkConfig {
+	return h.network
+}
+
+func (h *Hub) Publish(event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// events of the first layer seen may have been saved before this process started
+	if h.covered == -1 {
+		h.covered = event.Layer + 1
+	}
+	if h.full {
+		evicted := h.ring[h.next]
+		h.covered = max(h.covered, evicted.Layer+1)
+	}
+	h.ring[h.next] = event
+	h.next = (h.next + 1) % len(h.ring)
+	if h.next == 0 {
+		h.full = true
+	}
+
+	for s := range h.subscribers {
+		if !s.filter.match(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			delete(h.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Close ends the subscriptions on shutdown, like the ones too far behind their clients get a dropped event
+// and resume on another instance.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subscribers {
+		delete(h.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Closed reports whether the hub was closed, its subscriptions are closed right away.
+func (h *Hub) Closed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closed
+}
+
+// Subscribe registers a subscription. With fromLayer >= 0 it also returns the matching buffered
+// events of the layers from max(fromLayer, covered), and covered, the first layer the buffer has all
+// the events of, -1 when nothing was published yet. Older layers have to be read from the db.
+func (h *Hub) Subscribe(filter *Filter, fromLayer int64) (*Subscription, []*Event, int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	events := make(chan *Event, subscriberBuffer)
+	s := &Subscription{
+		C:      events,
+		hub:    h,
+		filter: filter,
+		events: events,
+	}
+	if h.closed {
+		close(events)
+	} else {
+		h.subscribers[s] = struct{}{}
+	}
+
+	var replay []*Event
+	if fromLayer >= 0 && h.covered != -1 {
+		from := max(fromLayer, h.covered)
+		start := 0
+		if h.full {
+			start = h.next
+		}
+		for i := range h.ring {
+			event := h.ring[(start+i)%len(h.ring)]
+			if event == nil {
+				break
+			}
+			if event.Layer >= from && filter.match(event) {
+				replay = append(replay, event)
+			}
+		}
+	}
+	return s, replay, h.covered
+}
@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+)
+
+func TestHubClose(t *testing.T) {
+	hub := NewHub(&config.NetworkConfig{Name: "mainnet"}, 8)
+	sub, _, _ := hub.Subscribe(&Filter{}, -1)
+	if hub.Closed() {
+		t.Fatal("hub closed before Close")
+	}
+
+	hub.Close()
+	if !hub.Closed() {
+		t.Fatal("hub not closed after Close")
+	}
+	if _, ok := <-sub.C; ok {
+		t.Fatal("subscription open after Close")
+	}
+	// subscribing to a closed hub returns a closed subscription, Closed tells it apart from one fallen behind
+	sub, _, _ = hub.Subscribe(&Filter{}, -1)
+	if _, ok := <-sub.C; ok {
+		t.Fatal("subscription to a closed hub is open")
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub(&config.NetworkConfig{Name: "mainnet"}, 8)
+	sub, _, _ := hub.Subscribe(&Filter{}, -1)
+	for i := 0; i <= subscriberBuffer; i++ {
+		hub.Publish(&Event{Type: LayerEvent, Layer: int64(i)})
+	}
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d events, want %d", received, subscriberBuffer)
+	}
+	if hub.Closed() {
+		t.Error("hub closed after dropping a subscriber")
+	}
+}
@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+)
+
+// MaxReplayLayers is the most layers read from the db to resume a subscription.
+const MaxReplayLayers = 1000
+
+// Replay sends the matching events of the layers from up to to (excluded) read from the db. Only layers,
+// rewards and transactions with a result can be read back, atxs and malfeasance proofs are not replayed.
+func Replay(readDB database.ReadDB, network *config.NetworkConfig, filter *Filter, from int64, to int64, send func(*Event) error) error {
+	for layer := from; layer < to; layer++ {
+		var events []*Event
+
+		if filter.wants(LayerEvent) {
+			layerDoc, err := readDB.GetLayer(int(layer))
+			if err != nil {
+				return err
+			}
+			if layerDoc.Status != 0 {
+				events = append(events, layerEvent(network, layerDoc))
+			}
+		}
+
+		if filter.wants(RewardEvent) {
+			rewards, err := readDB.GetLayerRewards(int(layer), 0, 0, 1)
+			if err != nil {
+				return err
+			}
+			for _, reward := range rewards {
+				events = append(events, rewardEvent(network, reward))
+			}
+		}
+
+		if filter.wants(TransactionEvent) {
+			transactions, err := readDB.GetLayerTransactions(int(layer), 0, 0, 1, true)
+			if err != nil {
+				return err
+			}
+			for _, transaction := range transactions {
+				events = append(events, transactionEvent(network, transaction))
+			}
+		}
+
+		for _, event := range events {
+			if !filter.match(event) {
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
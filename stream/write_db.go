@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"log/slog"
+
+	"github.com/spacemeshos/go-spacemesh/nats"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// PublishingWriteDB saves through the wrapped WriteDB and then publishes what was saved to the hub.
+// Layers, rewards and transactions are read back so the events carry the persisted state, and records
+// the db ignored (e.g. rewards of a reverted layer) are not published.
+type PublishingWriteDB struct {
+	database.WriteDB
+	readDB database.ReadDB
+	hub    *Hub
+}
+
+func NewPublishingWriteDB(writeDB database.WriteDB, readDB database.ReadDB, hub *Hub) *PublishingWriteDB {
+	return &PublishingWriteDB{
+		WriteDB: writeDB,
+		readDB:  readDB,
+		hub:     hub,
+	}
+}
+
+func (p *PublishingWriteDB) SaveLayer(layer *nats.LayerUpdate, origin database.Origin) error {
+	if err := p.WriteDB.SaveLayer(layer, origin); err != nil {
+		return err
+	}
+	layerDoc, err := p.readDB.GetLayer(int(layer.LayerID))
+	if err != nil {
+		slog.Error("Failed to read layer to publish", "layer", layer.LayerID, "error", err)
+		return nil
+	}
+	if layerDoc.Status == 0 {
+		layerDoc = &types.LayerDoc{Layer: int64(layer.LayerID), Status: layer.Status}
+	}
+	p.hub.Publish(layerEvent(p.hub.network, layerDoc))
+	return nil
+}
+
+func (p *PublishingWriteDB) SaveAtx(atx *nats.Atx, origin database.Origin) error {
+	if err := p.WriteDB.SaveAtx(atx, origin); err != nil {
+		return err
+	}
+	p.hub.Publish(atxEvent(p.hub.network, &types.AtxDoc{
+		AtxID:             atx.AtxID,
+		NodeID:            atx.NodeID,
+		Coinbase:          atx.Coinbase,
+		PublishEpoch:      atx.PublishEpoch,
+		EffectiveNumUnits: atx.EffectiveNumUnits,
+		Received:          atx.Received,
+	}))
+	return nil
+}
+
+func (p *PublishingWriteDB) SaveMalfeasance(malfeasance *nats.Malfeasance, origin database.Origin) error {
+	if err := p.WriteDB.SaveMalfeasance(malfeasance, origin); err != nil {
+		return err
+	}
+	p.hub.Publish(malfeasanceEvent(malfeasance.NodeID, int64(malfeasance.LayerID), malfeasance.Received))
+	return nil
+}
+
+func (p *PublishingWriteDB) SaveTransactions(transaction *nats.Transaction, result bool, origin database.Origin) error {
+	if err := p.WriteDB.SaveTransactions(transaction, result, origin); err != nil {
+		return err
+	}
+	transactionDoc, err := p.readDB.GetTransaction(transaction.ID)
+	if err != nil {
+		slog.Error("Failed to read transaction to publish", "transaction", transaction.ID, "error", err)
+		return nil
+	}
+	// a created message read after the result doesn't change the transaction
+	if transactionDoc.ID == "" || (!result && transactionDoc.Complete) {
+		return nil
+	}
+	p.hub.Publish(transactionEvent(p.hub.network, transactionDoc))
+	return nil
+}
+
+func (p *PublishingWriteDB) SaveReward(reward *nats.Reward, origin database.Origin) error {
+	if err := p.WriteDB.SaveReward(reward, origin); err != nil {
+		return err
+	}
+	rewardDoc, err := p.readDB.GetReward(reward.ID)
+	if err != nil {
+		slog.Error("Failed to read reward to publish", "reward", reward.ID, "error", err)
+		return nil
+	}
+	if rewardDoc.Id == "" {
+		return nil
+	}
+	p.hub.Publish(rewardEvent(p.hub.network, rewardDoc))
+	return nil
+}
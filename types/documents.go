@@ -1,5 +1,11 @@
 package types
 
+import (
+    "time"
+
+    transactionparsertypes "github.com/swarmbit/spacemesh-state-api/pkg/transactionparser/transaction"
+)
+
 type RewardsDoc struct {
     Id          string `bson:"_id"`
     NodeId      string `bson:"node_id"`
@@ -8,11 +14,31 @@ type RewardsDoc struct {
     LayerReward int64  `bson:"layerReward"`
     TotalReward int64  `bson:"totalReward"`
     Layer       int64  `bson:"layer"`
+    Published   int64  `bson:"published"`
 }
 
 type LayerDoc struct {
     Layer  int64 `bson:"_id"`
     Status int   `bson:"status"`
+    // Published is the publish time of the applied update of the current layer content, 0 until it is applied
+    Published int64 `bson:"published"`
+    // LastApplied is the publish time of the latest applied update, it is reported again without new content
+    LastApplied int64 `bson:"lastApplied"`
+    // RevertBoundary is the publish time up to which the layer content was reverted
+    RevertBoundary int64  `bson:"revertBoundary"`
+    Reverts        int64  `bson:"reverts"`
+    AppliedBlock   string `bson:"appliedBlock"`
+}
+
+type LayerRevertDoc struct {
+    Layer                int64    `bson:"layer"`
+    RevertedAt           int64    `bson:"revertedAt"`
+    Boundary             int64    `bson:"boundary"`
+    Trigger              string   `bson:"trigger"`
+    RewardsReverted      int64    `bson:"rewardsReverted"`
+    RewardsAmount        int64    `bson:"rewardsAmount"`
+    TransactionsReverted int64    `bson:"transactionsReverted"`
+    Blocks               []string `bson:"blocks"`
 }
 
 type NodeDoc struct {
@@ -86,6 +112,51 @@ type TransactionDoc struct {
     Method          uint8  `json:"method"`
     Type            uint8  `json:"type"`
     Complete        bool   `json:"complete"`
+    BlockID         string `bson:"block_id"`
+    Published       int64  `bson:"published"`
+    // BalanceApplied is set when the transaction changed the account balances
+    BalanceApplied bool `bson:"balance_applied"`
+    // Dropped is set when a transaction got no result for the drop layers after it was created
+    Dropped bool `bson:"dropped"`
+}
+
+// TotalFee is the fee charged to the principal, the max fee before the result. The gas price of an opaque
+// transaction isn't decoded, its fee is the one of its result.
+func (t *TransactionDoc) TotalFee() uint64 {
+    if t.Type == transactionparsertypes.TypeOpaque {
+        return t.Fee
+    }
+    return t.Gas * t.GasPrice
+}
+
+// TransactionDetailsDoc is what the parser decodes from a raw transaction besides the fields of its
+// TransactionDoc, with the addresses and the message of its result.
+type TransactionDetailsDoc struct {
+    ID string `bson:"_id"`
+    // Raw is the hex of the signed transaction
+    Raw string `bson:"raw"`
+    // Template is the template spawned by a spawn and the one of the principal otherwise, empty when unknown
+    Template string `bson:"template"`
+    // PublicKeys and RequiredSigners are the arguments of a spawn, in hex
+    PublicKeys      []string `bson:"publicKeys"`
+    RequiredSigners uint8    `bson:"requiredSigners"`
+    // Owner, TotalAmount, InitialUnlockAmount, VestingStart and VestingEnd are the arguments of a vault spawn
+    Owner               string `bson:"owner"`
+    TotalAmount         uint64 `bson:"totalAmount"`
+    InitialUnlockAmount uint64 `bson:"initialUnlockAmount"`
+    VestingStart        uint32 `bson:"vestingStart"`
+    VestingEnd          uint32 `bson:"vestingEnd"`
+    // Signature is set for a single signer, Signatures for several
+    Signature  string              `bson:"signature"`
+    Signatures []*SignaturePartDoc `bson:"signatures"`
+    Addresses  []string            `bson:"addresses"`
+    Message    string              `bson:"message"`
+}
+
+// SignaturePartDoc is the signature of the signer at index Ref of a multisig account, in hex.
+type SignaturePartDoc struct {
+    Ref       uint8  `bson:"ref"`
+    Signature string `bson:"signature"`
 }
 
 type AccountDoc struct {
@@ -94,6 +165,155 @@ type AccountDoc struct {
     TotalRewards uint64 `bson:"totalRewards"`
     Fees         uint64 `bson:"fees"`
     Sent         uint64 `bson:"sent"`
+    Received     uint64 `bson:"received"`
+}
+
+// AccountTemplateDoc is the template of an account, with the arguments it was spawned with when its spawn
+// transaction was seen.
+type AccountTemplateDoc struct {
+    Address  string `bson:"_id"`
+    Template string `bson:"template"`
+    // Spawned is set once a spawn of the account or a transaction of it succeeded
+    Spawned          bool   `bson:"spawned"`
+    SpawnLayer       uint32 `bson:"spawnLayer"`
+    SpawnTransaction string `bson:"spawnTransaction"`
+    // PublicKeys, in hex, and RequiredSigners are the arguments of a wallet, multisig or vesting account
+    PublicKeys      []string `bson:"publicKeys"`
+    RequiredSigners uint8    `bson:"requiredSigners"`
+    // Owner, TotalAmount, InitialUnlockAmount, VestingStart and VestingEnd are the arguments of a vault
+    Owner               string `bson:"owner"`
+    TotalAmount         uint64 `bson:"totalAmount"`
+    InitialUnlockAmount uint64 `bson:"initialUnlockAmount"`
+    VestingStart        uint32 `bson:"vestingStart"`
+    VestingEnd          uint32 `bson:"vestingEnd"`
+}
+
+type ApiKeyDoc struct {
+    // ID is the sha256 of the key, the key itself is not stored
+    ID         string  `bson:"_id"`
+    Name       string  `bson:"name"`
+    Rate       float64 `bson:"rate"`
+    Burst      int64   `bson:"burst"`
+    DailyQuota int64   `bson:"dailyQuota"`
+    CreatedAt  int64   `bson:"createdAt"`
+    RevokedAt  int64   `bson:"revokedAt"`
+}
+
+type WebhookDoc struct {
+    ID string `bson:"_id"`
+    // Owner is the id of the api key that registered the webhook
+    Owner     string `bson:"owner"`
+    Url       string `bson:"url"`
+    Secret    string `bson:"secret"`
+    Event     string `bson:"event"`
+    Account   string `bson:"account"`
+    Node      string `bson:"node"`
+    CreatedAt int64  `bson:"createdAt"`
+}
+
+type WebhookDeliveryDoc struct {
+    // ID is derived from the webhook and the event, so an event is delivered once per webhook
+    ID          string `bson:"_id"`
+    WebhookID   string `bson:"webhookId"`
+    Event       string `bson:"event"`
+    Payload     string `bson:"payload"`
+    Status      string `bson:"status"`
+    Attempts    int64  `bson:"attempts"`
+    NextAttempt int64  `bson:"nextAttempt"`
+    StatusCode  int64  `bson:"statusCode"`
+    LastError   string `bson:"lastError"`
+    CreatedAt   int64  `bson:"createdAt"`
+    DeliveredAt int64  `bson:"deliveredAt"`
+}
+
+// PriceDoc is a usd quote of smh, a snapshot of the price resolver or a daily close backfilled from the provider.
+type PriceDoc struct {
+    // Timestamp is the unix time in seconds of the quote, the end of the day for a daily close
+    Timestamp int64   `bson:"_id"`
+    Price     float64 `bson:"price"`
+    // Source is the provider of a snapshot, or the provider with a -daily suffix for a daily close
+    Source string `bson:"source"`
+}
+
+// StatsDoc are the statistics of the network over an epoch or a utc day.
+type StatsDoc struct {
+    // ID is the period and its index, e.g. epoch-12 or day-19845
+    ID     string `bson:"_id"`
+    Period string `bson:"period"`
+    // Index is the epoch, or the days since the unix epoch
+    Index int64 `bson:"index"`
+    // Timestamp is the unix time in seconds the period starts at
+    Timestamp  int64 `bson:"timestamp"`
+    FirstLayer int64 `bson:"firstLayer"`
+    LastLayer  int64 `bson:"lastLayer"`
+    // Complete is set a few layers after the last layer of the period is processed, the period is updated until then
+    Complete bool `bson:"complete"`
+    // ActiveSmeshers, TotalWeight and EffectiveUnitsCommitted are of the atxs targeting the epoch of the period
+    ActiveSmeshers          uint64 `bson:"activeSmeshers"`
+    TotalWeight             uint64 `bson:"totalWeight"`
+    EffectiveUnitsCommitted uint64 `bson:"effectiveUnitsCommitted"`
+    // TotalRewards, Vested and CirculatingSupply are at the end of the period, the supply is their sum
+    TotalRewards      uint64 `bson:"totalRewards"`
+    Vested            uint64 `bson:"vested"`
+    CirculatingSupply uint64 `bson:"circulatingSupply"`
+    Rewards           uint64 `bson:"rewards"`
+    // Transactions and TransactionsVolume count the transactions that changed the balances and sum their amounts
+    Transactions       uint64 `bson:"transactions"`
+    TransactionsVolume uint64 `bson:"transactionsVolume"`
+    // NewAccounts are the accounts whose balance changed for the first time in the period
+    NewAccounts uint64 `bson:"newAccounts"`
+    // Malfeasance counts the nodes whose malfeasance proof was received in the period
+    Malfeasance uint64 `bson:"malfeasance"`
+}
+
+// NodePerformanceDoc compares what a node earned in an epoch with what its atx made it eligible for, saved once
+// the epoch ends.
+type NodePerformanceDoc struct {
+    // ID is the node and the epoch, e.g. <node id>-12
+    ID                string `bson:"_id"`
+    NodeID            string `bson:"nodeId"`
+    Coinbase          string `bson:"coinbase"`
+    Epoch             int64  `bson:"epoch"`
+    Weight            uint64 `bson:"weight"`
+    EffectiveNumUnits uint64 `bson:"effectiveNumUnits"`
+    // EligibleSlots are the proposals the node is eligible for in the epoch, RewardedSlots the layers it was
+    // rewarded in and MissedSlots the eligible slots left
+    EligibleSlots int64 `bson:"eligibleSlots"`
+    RewardedSlots int64 `bson:"rewardedSlots"`
+    MissedSlots   int64 `bson:"missedSlots"`
+    // ExpectedRewards is the share of the subsidy of the epoch of the weight of the node, the fees left out
+    ExpectedRewards uint64 `bson:"expectedRewards"`
+    Rewards         uint64 `bson:"rewards"`
+    // Efficiency is Rewards in percent of ExpectedRewards
+    Efficiency float64 `bson:"efficiency"`
+    // NoRewards is set when the node had an atx for the epoch and earned nothing in it
+    NoRewards bool `bson:"noRewards"`
+}
+
+// DeadLetterDoc is a stream message the sink gave up on, kept to be inspected and replayed.
+type DeadLetterDoc struct {
+    // ID is the consumer and the stream sequence of the message
+    ID string `bson:"_id"`
+    // Stream is the sink consumer of the message, e.g. rewards or transactions.result
+    Stream    string `bson:"stream"`
+    Subject   string `bson:"subject"`
+    Sequence  uint64 `bson:"sequence"`
+    Published int64  `bson:"published"`
+    Payload   string `bson:"payload"`
+    Error     string `bson:"error"`
+    // Deliveries is how many times the message was delivered before it was dead lettered
+    Deliveries uint64 `bson:"deliveries"`
+    CreatedAt  int64  `bson:"createdAt"`
+}
+
+// ProcessedMessageDoc records a stream message applied by the sink, in the transaction of its writes.
+type ProcessedMessageDoc struct {
+    // ID is the stream and the stream sequence of the message
+    ID          string    `bson:"_id"`
+    Stream      string    `bson:"stream"`
+    Sequence    uint64    `bson:"sequence"`
+    MsgID       string    `bson:"msgId"`
+    ProcessedAt time.Time `bson:"processedAt"`
 }
 
 type NetworkInfoDoc struct {
@@ -113,6 +333,28 @@ type AggregationTotal struct {
     TotalSum int64 `bson:"totalSum"`
 }
 
+type AggregationTransactionsTotals struct {
+    Count  int64 `bson:"count"`
+    Volume int64 `bson:"volume"`
+}
+
+type AggregationNodeRewards struct {
+    NodeID string `bson:"_id"`
+    Sum    int64  `bson:"sum"`
+    Count  int64  `bson:"count"`
+}
+
+type AggregationPerformance struct {
+    Epoch           int64 `bson:"_id"`
+    Nodes           int64 `bson:"nodes"`
+    NoRewardsNodes  int64 `bson:"noRewardsNodes"`
+    EligibleSlots   int64 `bson:"eligibleSlots"`
+    RewardedSlots   int64 `bson:"rewardedSlots"`
+    MissedSlots     int64 `bson:"missedSlots"`
+    ExpectedRewards int64 `bson:"expectedRewards"`
+    Rewards         int64 `bson:"rewards"`
+}
+
 type AggregationAtxTotals struct {
     TotalWeight            int64 `bson:"totalWeight"`
     TotalEffectiveNumUnits int64 `bson:"totalEffectiveNumUnits"`
@@ -1,9 +1,44 @@
 package types
 
 type NodeFilterRequest struct {
-	Nodes []string `json:"nodes"`
+	Nodes []string `json:"nodes" binding:"required"`
 }
 
 type AccounGroupRequest struct {
-	Accounts []string `json:"accounts"`
-}
\ No newline at end of file
+	Accounts []string `json:"accounts" binding:"required"`
+}
+type ApiKeyRequest struct {
+	Name       string  `json:"name" binding:"required"`
+	Rate       float64 `json:"rate"`
+	Burst      int64   `json:"burst"`
+	DailyQuota int64   `json:"dailyQuota"`
+}
+
+type WebhookRequest struct {
+	Url string `json:"url" binding:"required"`
+	// Secret signs the deliveries, one is generated when empty
+	Secret  string `json:"secret"`
+	Event   string `json:"event" binding:"required"`
+	Account string `json:"account"`
+	Node    string `json:"node"`
+}
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query" binding:"required"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// DecodeTransactionRequest is a signed transaction to decode, Raw is in hex or base64 as Encoding tells, the
+// encoding is guessed when it is empty.
+type DecodeTransactionRequest struct {
+	Raw      string `json:"raw" binding:"required"`
+	Encoding string `json:"encoding"`
+}
+
+// Cursor is the position of the last item of a page, the next page starts after it. Key is the value
+// of the sort field and ID breaks the ties.
+type Cursor struct {
+	Key int64  `json:"k"`
+	ID  string `json:"i"`
+}
@@ -25,12 +25,18 @@ type ShortAccount struct {
     Balance      uint64 `json:"balance"`
     USDValue     int64  `json:"usdValue"`
     Address      string `json:"address"`
+    // Value is the value of the balance in Currency, null when its price is unknown
+    Value    *float64 `json:"value"`
+    Currency string   `json:"currency"`
 }
 
 type AccountGroupResponse struct {
     TotalRewards uint64 `json:"totalRewards"`
     Balance      uint64 `json:"balance"`
     USDValue     int64  `json:"usdValue"`
+    // Value is the value of the balance in Currency, null when its price is unknown
+    Value    *float64 `json:"value"`
+    Currency string   `json:"currency"`
 }
 type AccountPostResponse struct {
     Account                string `json:"account"`
@@ -48,6 +54,36 @@ type Account struct {
     NumberOfRewards      int64  `json:"numberOfRewards"`
     TotalRewards         uint64 `json:"totalRewards"`
     Address              string `json:"address"`
+    // Value is the value of the balance in Currency, null when its price is unknown
+    Value    *float64 `json:"value"`
+    Currency string   `json:"currency"`
+    // Template is the smart account of the address, not set until one of its transactions is seen
+    Template *AccountTemplate `json:"template,omitempty"`
+}
+
+// AccountTemplate is the template of an account and what it was spawned with.
+type AccountTemplate struct {
+    Template         string   `json:"template"`
+    Spawned          bool     `json:"spawned"`
+    SpawnLayer       uint32   `json:"spawnLayer,omitempty"`
+    SpawnTransaction string   `json:"spawnTransaction,omitempty"`
+    PublicKeys       []string `json:"publicKeys,omitempty"`
+    RequiredSigners  uint8    `json:"requiredSigners,omitempty"`
+    // Vault is the schedule of a vault, Vaults the ones a vesting account owns
+    Vault  *VaultState   `json:"vault,omitempty"`
+    Vaults []*VaultState `json:"vaults,omitempty"`
+}
+
+// VaultState is the vesting schedule of a vault and what of it is vested at Layer.
+type VaultState struct {
+    Address string `json:"address"`
+    VaultDetails
+    Layer uint32 `json:"layer"`
+    // Vested follows the vault template, Drained is what the owner drained so far and Unlockable what is vested
+    // and not drained yet
+    Vested     uint64 `json:"vested"`
+    Drained    uint64 `json:"drained"`
+    Unlockable uint64 `json:"unlockable"`
 }
 
 type Reward struct {
@@ -58,6 +94,85 @@ type Reward struct {
     SmesherId      string `json:"smesherId"`
     Time           string `json:"time"`
     Timestamp      int64  `json:"timestamp"`
+    // USDValue is the value of the reward at the price of its layer time, -1 when it is unknown
+    USDValue int64 `json:"usdValue"`
+}
+
+// Price is the latest usd quote of smh.
+type Price struct {
+    Price    float64 `json:"price"`
+    Currency string  `json:"currency"`
+    // Timestamp is when the latest quote of the price was fetched, in unix seconds
+    Timestamp int64 `json:"timestamp"`
+    // Source is the provider of the price, median when it aggregates several
+    Source  string   `json:"source"`
+    Sources []string `json:"sources"`
+    // Stale is set when the latest quote is older than the max age, the values in the currency are unknown then
+    Stale bool `json:"stale"`
+}
+
+// StatsPoint is the value of a statistic over an epoch or a day starting at Timestamp.
+type StatsPoint struct {
+    Timestamp  int64  `json:"timestamp"`
+    FirstLayer int64  `json:"firstLayer"`
+    LastLayer  int64  `json:"lastLayer"`
+    Value      uint64 `json:"value"`
+    // Complete is false for the period in progress, its value still changes
+    Complete bool `json:"complete"`
+}
+
+// NodePerformance is what a node earned in an epoch against what its atx made it eligible for.
+type NodePerformance struct {
+    NodeID            string  `json:"nodeId"`
+    Coinbase          string  `json:"coinbase"`
+    Epoch             int64   `json:"epoch"`
+    Weight            uint64  `json:"weight"`
+    EffectiveNumUnits uint64  `json:"effectiveNumUnits"`
+    EligibleSlots     int64   `json:"eligibleSlots"`
+    RewardedSlots     int64   `json:"rewardedSlots"`
+    MissedSlots       int64   `json:"missedSlots"`
+    ExpectedRewards   uint64  `json:"expectedRewards"`
+    Rewards           uint64  `json:"rewards"`
+    Efficiency        float64 `json:"efficiency"`
+    NoRewards         bool    `json:"noRewards"`
+}
+
+// AccountPerformance sums the performance of the nodes of a coinbase in an epoch.
+type AccountPerformance struct {
+    Epoch           int64   `json:"epoch"`
+    Nodes           int64   `json:"nodes"`
+    NoRewardsNodes  int64   `json:"noRewardsNodes"`
+    EligibleSlots   int64   `json:"eligibleSlots"`
+    RewardedSlots   int64   `json:"rewardedSlots"`
+    MissedSlots     int64   `json:"missedSlots"`
+    ExpectedRewards uint64  `json:"expectedRewards"`
+    Rewards         uint64  `json:"rewards"`
+    Efficiency      float64 `json:"efficiency"`
+    // NodesPerformance are the nodes, the least efficient first, set for a single epoch only
+    NodesPerformance []*NodePerformance `json:"nodesPerformance,omitempty"`
+}
+
+// PricePoint is the usd price of smh at the end of an interval starting at Timestamp.
+type PricePoint struct {
+    Timestamp int64   `json:"timestamp"`
+    Price     float64 `json:"price"`
+}
+
+type Layer struct {
+    Layer        int64          `json:"layer"`
+    Status       int            `json:"status"`
+    AppliedBlock string         `json:"appliedBlock"`
+    Timestamp    int64          `json:"timestamp"`
+    Reverts      []*LayerRevert `json:"reverts"`
+}
+
+type LayerRevert struct {
+    RevertedAt           int64    `json:"revertedAt"`
+    Trigger              string   `json:"trigger"`
+    RewardsReverted      int64    `json:"rewardsReverted"`
+    RewardsAmount        int64    `json:"rewardsAmount"`
+    TransactionsReverted int64    `json:"transactionsReverted"`
+    Blocks               []string `json:"blocks"`
 }
 
 type Transaction struct {
@@ -73,6 +188,101 @@ type Transaction struct {
     Method           string `json:"method"`
     Type             uint8  `json:"type"`
     Timestamp        int64  `json:"timestamp"`
+    // USDValue is the value of the amount at the price of its layer time, -1 when it is unknown
+    USDValue int64 `json:"usdValue"`
+    // Details is the decoded payload, only set for a single transaction that got its result
+    Details *TransactionDetails `json:"details,omitempty"`
+    // Dropped is set when the transaction got no result in time, it is no longer pending
+    Dropped bool `json:"dropped,omitempty"`
+}
+
+// TransactionDetails is the decoded payload of a transaction, its byte fields in hex.
+type TransactionDetails struct {
+    Template        string           `json:"template"`
+    PublicKeys      []string         `json:"publicKeys,omitempty"`
+    RequiredSigners uint8            `json:"requiredSigners,omitempty"`
+    Vault           *VaultDetails    `json:"vault,omitempty"`
+    Signature       string           `json:"signature,omitempty"`
+    Signatures      []*SignaturePart `json:"signatures,omitempty"`
+    Addresses       []string         `json:"addresses"`
+    Message         string           `json:"message,omitempty"`
+    Raw             string           `json:"raw"`
+}
+
+// VaultDetails are the arguments of a vault spawn, the vesting layers included.
+type VaultDetails struct {
+    Owner               string `json:"owner"`
+    TotalAmount         uint64 `json:"totalAmount"`
+    InitialUnlockAmount uint64 `json:"initialUnlockAmount"`
+    VestingStart        uint32 `json:"vestingStart"`
+    VestingEnd          uint32 `json:"vestingEnd"`
+}
+
+// SignaturePart is the signature of the signer at index Signer of a multisig account.
+type SignaturePart struct {
+    Signer    uint8  `json:"signer"`
+    Signature string `json:"signature"`
+}
+
+// PendingTransactions are the transactions of an account without a result, sent or received, and its balance
+// once the pending transactions it sent are applied.
+type PendingTransactions struct {
+    Address string `json:"address"`
+    Balance uint64 `json:"balance"`
+    // PendingSent is the amount and the max fee of the transactions sent that are expected to be included,
+    // ProjectedBalance is the balance without it. PendingReceived is the amount of the transactions received.
+    PendingSent      uint64                `json:"pendingSent"`
+    PendingReceived  uint64                `json:"pendingReceived"`
+    ProjectedBalance uint64                `json:"projectedBalance"`
+    Transactions     []*PendingTransaction `json:"transactions"`
+}
+
+// PendingTransaction is a transaction waiting for its result, its fee is the max fee until then. EstimatedLayer
+// is the layer a transaction sent by the account is expected in, null with a Reason when it can't be included.
+type PendingTransaction struct {
+    *Transaction
+    EstimatedLayer *uint32 `json:"estimatedLayer"`
+    Reason         string  `json:"reason,omitempty"`
+}
+
+// DecodedTransaction is a signed transaction decoded from its raw bytes and the checks it passed before it is
+// broadcast. Valid is only set when its signatures were verified and none of its checks failed.
+type DecodedTransaction struct {
+    Type            int                `json:"type"`
+    Method          string             `json:"method,omitempty"`
+    Template        string             `json:"template,omitempty"`
+    Principal       string             `json:"principal,omitempty"`
+    Receiver        string             `json:"receiver,omitempty"`
+    Spawned         string             `json:"spawned,omitempty"`
+    Vault           string             `json:"vault,omitempty"`
+    VaultDetails    *VaultDetails      `json:"vaultDetails,omitempty"`
+    Amount          uint64             `json:"amount"`
+    Counter         uint64             `json:"counter"`
+    GasPrice        uint64             `json:"gasPrice"`
+    PublicKeys      []string           `json:"publicKeys,omitempty"`
+    RequiredSigners uint8              `json:"requiredSigners,omitempty"`
+    Signature       string             `json:"signature,omitempty"`
+    Signatures      []*SignaturePart   `json:"signatures,omitempty"`
+    Unsupported     string             `json:"unsupported,omitempty"`
+    Checks          *TransactionChecks `json:"checks,omitempty"`
+    Valid           bool               `json:"valid"`
+}
+
+type TransactionChecks struct {
+    Principal  *TransactionCheck `json:"principal"`
+    Signatures *TransactionCheck `json:"signatures"`
+    Counter    *TransactionCheck `json:"counter"`
+}
+
+// TransactionCheck is the result of a check of a decoded transaction, Valid is null when it could not be run
+// and Reason tells why. Verified and Required count the signers of the signatures check, Latest is the
+// highest counter recorded for the principal in the counter check.
+type TransactionCheck struct {
+    Valid    *bool  `json:"valid"`
+    Reason   string `json:"reason,omitempty"`
+    Verified *int   `json:"verified,omitempty"`
+    Required *int   `json:"required,omitempty"`
+    Latest   *int64 `json:"latest,omitempty"`
 }
 
 type RewardDetails struct {
@@ -96,6 +306,18 @@ type Eligibility struct {
     PredictedRewards  uint64 `json:"predictedRewards"`
 }
 
+// Network is a network served by the api, its routes are under Path.
+type Network struct {
+    Name           string `json:"name"`
+    Path           string `json:"path"`
+    Hrp            string `json:"hrp"`
+    Genesis        int64  `json:"genesis"`
+    LayerDuration  int64  `json:"layerDuration"`
+    LayersPerEpoch uint32 `json:"layersPerEpoch"`
+    // Default is set for the network also served at the root paths
+    Default bool `json:"default"`
+}
+
 type NetworkInfo struct {
     Epoch                  uint32                `json:"epoch"`
     Layer                  uint64                `json:"layer"`
@@ -106,6 +328,7 @@ type NetworkInfo struct {
     CirculatingSupply      uint64                `json:"circulatingSupply"`
     TotalRewards           uint64                `json:"rewards"`
     Price                  float64               `json:"price"`
+    Currency               string                `json:"currency"`
     MarketCap              uint64                `json:"marketCap"`
     TotalAccounts          uint64                `json:"totalAccounts"`
     TotalActiveSmeshers    uint64                `json:"totalActiveSmeshers"`
@@ -121,3 +344,128 @@ type NetworkInfoNextEpoch struct {
     EffectiveUnitsCommited int64  `json:"effectiveUnitsCommited"`
     TotalActiveSmeshers    int64  `json:"totalActiveSmeshers"`
 }
+
+type AccountBalances struct {
+    Balance      int64 `json:"balance"`
+    TotalRewards int64 `json:"totalRewards"`
+    Sent         int64 `json:"sent"`
+    Fees         int64 `json:"fees"`
+    Received     int64 `json:"received"`
+}
+
+type AccountDrift struct {
+    Address  string           `json:"address"`
+    Stored   *AccountBalances `json:"stored"`
+    Computed *AccountBalances `json:"computed"`
+    Repaired bool             `json:"repaired"`
+}
+
+type AccountReconcile struct {
+    Address string        `json:"address"`
+    Drift   *AccountDrift `json:"drift"`
+}
+
+type ReconcileReport struct {
+    StartedAt  int64           `json:"startedAt"`
+    FinishedAt int64           `json:"finishedAt"`
+    Repair     bool            `json:"repair"`
+    Checked    int64           `json:"checked"`
+    Drifted    int64           `json:"drifted"`
+    Repaired   int64           `json:"repaired"`
+    // Skipped counts the drifted accounts the sink changed while they were checked
+    Skipped  int64           `json:"skipped"`
+    Error    string          `json:"error,omitempty"`
+    Accounts []*AccountDrift `json:"accounts"`
+}
+
+type ReconcileStatus struct {
+    Running bool             `json:"running"`
+    Last    *ReconcileReport `json:"last"`
+}
+
+type ApiKey struct {
+    ID   string `json:"id"`
+    Name string `json:"name"`
+    // Key is only returned when the key is issued
+    Key        string  `json:"key,omitempty"`
+    Rate       float64 `json:"rate"`
+    Burst      int64   `json:"burst"`
+    DailyQuota int64   `json:"dailyQuota"`
+    CreatedAt  int64   `json:"createdAt"`
+    RevokedAt  int64   `json:"revokedAt"`
+}
+
+type StreamTransaction struct {
+    *Transaction
+    // Complete is false for a transaction seen in the mempool and true once it has a result
+    Complete bool `json:"complete"`
+}
+
+type StreamAtx struct {
+    NodeId            string `json:"nodeId"`
+    AtxId             string `json:"atxId"`
+    Coinbase          string `json:"coinbase"`
+    PublishEpoch      uint32 `json:"publishEpoch"`
+    EffectiveNumUnits uint32 `json:"effectiveNumUnits"`
+    Received          int64  `json:"received"`
+}
+
+type StreamMalfeasance struct {
+    NodeId   string `json:"nodeId"`
+    Layer    int64  `json:"layer"`
+    Received int64  `json:"received"`
+}
+
+type Webhook struct {
+    ID      string `json:"id"`
+    Url     string `json:"url"`
+    Event   string `json:"event"`
+    Account string `json:"account,omitempty"`
+    Node    string `json:"node,omitempty"`
+    // Secret is only returned when the webhook is registered
+    Secret    string `json:"secret,omitempty"`
+    CreatedAt int64  `json:"createdAt"`
+}
+
+type WebhookDelivery struct {
+    ID          string `json:"id"`
+    WebhookID   string `json:"webhookId"`
+    Event       string `json:"event"`
+    Status      string `json:"status"`
+    Attempts    int64  `json:"attempts"`
+    NextAttempt int64  `json:"nextAttempt"`
+    StatusCode  int64  `json:"statusCode"`
+    LastError   string `json:"lastError"`
+    CreatedAt   int64  `json:"createdAt"`
+    DeliveredAt int64  `json:"deliveredAt"`
+}
+
+type DeadLetter struct {
+    ID         string `json:"id"`
+    Stream     string `json:"stream"`
+    Subject    string `json:"subject"`
+    Sequence   uint64 `json:"sequence"`
+    Published  int64  `json:"published"`
+    Payload    string `json:"payload"`
+    Error      string `json:"error"`
+    Deliveries uint64 `json:"deliveries"`
+    CreatedAt  int64  `json:"createdAt"`
+}
+
+// WebhookPayload is the body posted to a webhook.
+type WebhookPayload struct {
+    ID        string `json:"id"`
+    WebhookID string `json:"webhookId"`
+    Event     string `json:"event"`
+    Layer     int64  `json:"layer"`
+    CreatedAt int64  `json:"createdAt"`
+    Data      any    `json:"data"`
+}
+
+type MissingAtx struct {
+    NodeId string `json:"nodeId"`
+    // PublishEpoch is the epoch the atx had to be published in to be eligible in TargetEpoch
+    PublishEpoch int64 `json:"publishEpoch"`
+    TargetEpoch  int64 `json:"targetEpoch"`
+    LayersLeft   int64 `json:"layersLeft"`
+}
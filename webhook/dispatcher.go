@@ -0,0 +1,385 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/stream"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	// webhooks are reloaded from the db at this interval, a new or deleted one takes up to it to apply
+	refreshInterval  = 30 * time.Second
+	deliverInterval  = 5 * time.Second
+	atxCheckInterval = 10 * time.Minute
+	cleanupInterval  = time.Hour
+	// delivered and failed deliveries are kept this long
+	deliveryRetention = 7 * 24 * time.Hour
+	// deliveries read and sent at once
+	deliveryBatch   = 100
+	deliveryWorkers = 8
+	// the first retry is after minBackoff, doubling up to maxBackoff
+	minBackoff = 30 * time.Second
+	maxBackoff = time.Hour
+)
+
+// Dispatcher matches the events of the stream hub with the registered webhooks, saves a delivery for
+// each match and sends the pending deliveries, retrying the failed ones with backoff. It also checks
+// before the end of every epoch that the watched nodes published an atx for the next one.
+type Dispatcher struct {
+	readDB         database.ReadDB
+	writeDB        database.WriteDB
+	hub            *stream.Hub
+	client         *http.Client
+	maxAttempts    int64
+	atxCheckLayers int64
+	wake           chan struct{}
+	stop           chan struct{}
+	wg             sync.WaitGroup
+
+	mu       sync.Mutex
+	webhooks []*types.WebhookDoc
+	// sub is the current subscription of listen, closed on Stop
+	sub *stream.Subscription
+}
+
+func NewDispatcher(webhooksConfig *config.WebhooksConfig, readDB database.ReadDB, writeDB database.WriteDB, hub *stream.Hub) *Dispatcher {
+	maxAttempts := 8
+	if webhooksConfig.MaxAttempts > 0 {
+		maxAttempts = webhooksConfig.MaxAttempts
+	}
+	timeout := 10
+	if webhooksConfig.Timeout > 0 {
+		timeout = webhooksConfig.Timeout
+	}
+	atxCheckLayers := 576
+	if webhooksConfig.AtxCheckLayers > 0 {
+		atxCheckLayers = webhooksConfig.AtxCheckLayers
+	}
+	return &Dispatcher{
+		readDB:         readDB,
+		writeDB:        writeDB,
+		hub:            hub,
+		client:         newClient(time.Duration(timeout)*time.Second, webhooksConfig.AllowPrivate),
+		maxAttempts:    int64(maxAttempts),
+		atxCheckLayers: int64(atxCheckLayers),
+		wake:           make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+	}
+}
+
+func (d *Dispatcher) Start() {
+	d.refresh()
+	d.wg.Add(5)
+	go d.listen()
+	go d.every(refreshInterval, d.refresh)
+	go d.every(atxCheckInterval, d.checkAtxs)
+	go d.every(cleanupInterval, d.cleanup)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(deliverInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-d.wake:
+			case <-d.stop:
+				return
+			}
+			d.deliverDue()
+		}
+	}()
+}
+
+// Stop ends the background work and waits for the deliveries and checks in progress, so the dbs can be closed.
+// The pending deliveries are sent by the next instance.
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.mu.Lock()
+	sub := d.sub
+	d.mu.Unlock()
+	if sub != nil {
+		sub.Close()
+	}
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) stopped() bool {
+	select {
+	case <-d.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *Dispatcher) every(interval time.Duration, run func()) {
+	defer d.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			run()
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) refresh() {
+	webhooks, err := d.readDB.GetWebhooks("")
+	if err != nil {
+		slog.Error("Failed to load webhooks", "error", err)
+		return
+	}
+	d.mu.Lock()
+	d.webhooks = webhooks
+	d.mu.Unlock()
+}
+
+func (d *Dispatcher) current() []*types.WebhookDoc {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.webhooks
+}
+
+// listen subscribes to the hub again when it falls behind, resuming from the last layer it saw. The
+// events seen twice have the same delivery id and are not saved again.
+func (d *Dispatcher) listen() {
+	defer d.wg.Done()
+	filter := &stream.Filter{
+		Types: map[string]bool{
+			stream.RewardEvent:      true,
+			stream.TransactionEvent: true,
+			stream.MalfeasanceEvent: true,
+		},
+	}
+	lastLayer := int64(-1)
+	for {
+		sub, buffered, _ := d.hub.Subscribe(filter, lastLayer)
+		d.mu.Lock()
+		d.sub = sub
+		d.mu.Unlock()
+		// Stop closes the subscription it sees, one made after it is closed here
+		if d.stopped() {
+			sub.Close()
+			return
+		}
+		for _, event := range buffered {
+			d.dispatch(event)
+		}
+		for event := range sub.C {
+			lastLayer = max(lastLayer, event.Layer)
+			d.dispatch(event)
+		}
+		if d.stopped() || d.hub.Closed() {
+			return
+		}
+		slog.Warn("Webhooks fell behind the stream", "resumeLayer", lastLayer)
+	}
+}
+
+func (d *Dispatcher) dispatch(event *stream.Event) {
+	for _, webhook := range d.current() {
+		if matches(webhook, event) {
+			d.enqueue(webhook, event.Key(), event.Layer, event.Data)
+		}
+	}
+}
+
+func matches(webhook *types.WebhookDoc, event *stream.Event) bool {
+	switch webhook.Event {
+	case RewardEvent:
+		reward, ok := event.Data.(*types.Reward)
+		return ok && ((webhook.Account != "" && reward.Account == webhook.Account) ||
+			(webhook.Node != "" && strings.EqualFold(reward.SmesherId, webhook.Node)))
+	case IncomingTransactionEvent:
+		transaction, ok := event.Data.(*types.StreamTransaction)
+		return ok && transaction.Complete && transaction.Status == 0 && transaction.ReceiverAccount == webhook.Account
+	case MalfeasanceEvent:
+		malfeasance, ok := event.Data.(*types.StreamMalfeasance)
+		return ok && strings.EqualFold(malfeasance.NodeId, webhook.Node)
+	}
+	return false
+}
+
+func (d *Dispatcher) enqueue(webhook *types.WebhookDoc, key string, layer int64, data any) {
+	now := time.Now().UnixMilli()
+	payload := &types.WebhookPayload{
+		ID:        deliveryID(webhook.ID, key),
+		WebhookID: webhook.ID,
+		Event:     webhook.Event,
+		Layer:     layer,
+		CreatedAt: now,
+		Data:      data,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("Failed to encode webhook payload", "webhook", webhook.ID, "error", err)
+		return
+	}
+	saved, err := d.writeDB.SaveWebhookDelivery(&types.WebhookDeliveryDoc{
+		ID:          payload.ID,
+		WebhookID:   webhook.ID,
+		Event:       webhook.Event,
+		Payload:     string(body),
+		Status:      StatusPending,
+		NextAttempt: now,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		slog.Error("Failed to save webhook delivery", "webhook", webhook.ID, "error", err)
+		return
+	}
+	if saved {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// checkAtxs reports the nodes of missing_atx webhooks without an atx in the current epoch, once
+// fewer than atxCheckLayers layers are left in it.
+func (d *Dispatcher) checkAtxs() {
+	network := d.hub.Network()
+	layer := network.CurrentLayer()
+	layersPerEpoch := int64(network.LayersPerEpoch)
+	epoch := layer / layersPerEpoch
+	layersLeft := (epoch+1)*layersPerEpoch - layer
+	if layersLeft > d.atxCheckLayers {
+		return
+	}
+	for _, webhook := range d.current() {
+		if webhook.Event != MissingAtxEvent {
+			continue
+		}
+		totals, err := d.readDB.GetAtxWeightNode(webhook.Node, uint64(epoch))
+		if err != nil {
+			slog.Error("Failed to check atx of node", "node", webhook.Node, "error", err)
+			continue
+		}
+		if totals.TotalEffectiveNumUnits > 0 {
+			continue
+		}
+		d.enqueue(webhook, fmt.Sprintf("missing_atx:%d", epoch), layer, &types.MissingAtx{
+			NodeId:       webhook.Node,
+			PublishEpoch: epoch,
+			TargetEpoch:  epoch + 1,
+			LayersLeft:   layersLeft,
+		})
+	}
+}
+
+func (d *Dispatcher) cleanup() {
+	before := time.Now().Add(-deliveryRetention).UnixMilli()
+	if err := d.writeDB.DeleteWebhookDeliveries(before); err != nil {
+		slog.Error("Failed to delete old webhook deliveries", "error", err)
+	}
+}
+
+func (d *Dispatcher) deliverDue() {
+	for {
+		deliveries, err := d.readDB.GetDueWebhookDeliveries(time.Now().UnixMilli(), deliveryBatch)
+		if err != nil {
+			slog.Error("Failed to fetch webhook deliveries", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		workers := make(chan struct{}, deliveryWorkers)
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			workers <- struct{}{}
+			go func(delivery *types.WebhookDeliveryDoc) {
+				defer wg.Done()
+				d.deliver(delivery)
+				<-workers
+			}(delivery)
+		}
+		wg.Wait()
+
+		if len(deliveries) < deliveryBatch || d.stopped() {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) deliver(delivery *types.WebhookDeliveryDoc) {
+	webhook, err := d.readDB.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		slog.Error("Failed to fetch webhook", "webhook", delivery.WebhookID, "error", err)
+		return
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	if webhook.ID == "" {
+		delivery.Status = StatusFailed
+		delivery.LastError = "webhook deleted"
+	} else if statusCode, err := d.post(webhook, delivery, now); err != nil {
+		delivery.StatusCode = int64(statusCode)
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= d.maxAttempts {
+			delivery.Status = StatusFailed
+		} else {
+			delivery.NextAttempt = now.Add(backoff(delivery.Attempts)).UnixMilli()
+		}
+	} else {
+		delivery.StatusCode = int64(statusCode)
+		delivery.LastError = ""
+		delivery.Status = StatusDelivered
+		delivery.DeliveredAt = now.UnixMilli()
+	}
+
+	if err := d.writeDB.UpdateWebhookDelivery(delivery); err != nil {
+		slog.Error("Failed to update webhook delivery", "delivery", delivery.ID, "error", err)
+	}
+}
+
+func (d *Dispatcher) post(webhook *types.WebhookDoc, delivery *types.WebhookDeliveryDoc, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "spacemesh-state-api-webhooks")
+	request.Header.Set(IdHeader, webhook.ID)
+	request.Header.Set(DeliveryHeader, delivery.ID)
+	request.Header.Set(EventHeader, delivery.Event)
+	request.Header.Set(TimestampHeader, fmt.Sprint(timestamp))
+	request.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+func backoff(attempts int64) time.Duration {
+	wait := minBackoff
+	for i := int64(1); i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
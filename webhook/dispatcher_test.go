@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/config"
+	"github.com/swarmbit/spacemesh-state-api/database"
+	"github.com/swarmbit/spacemesh-state-api/stream"
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+// emptyDB has no webhooks nor deliveries, the other methods aren't used without them
+type emptyDB struct {
+	database.ReadDB
+	database.WriteDB
+}
+
+func (db *emptyDB) GetWebhooks(owner string) ([]*types.WebhookDoc, error) {
+	return nil, nil
+}
+
+func (db *emptyDB) GetDueWebhookDeliveries(now int64, limit int64) ([]*types.WebhookDeliveryDoc, error) {
+	return nil, nil
+}
+
+func stopWithin(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	stopped := make(chan struct{})
+	go func() {
+		d.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't return")
+	}
+}
+
+func TestDispatcherStop(t *testing.T) {
+	db := &emptyDB{}
+	hub := stream.NewHub(&config.NetworkConfig{Name: "mainnet"}, 8)
+	d := NewDispatcher(&config.WebhooksConfig{Enabled: true}, db, db, hub)
+	d.Start()
+	stopWithin(t, d)
+	if hub.Closed() {
+		t.Error("Stop closed the hub")
+	}
+}
+
+func TestDispatcherStopAfterHubClose(t *testing.T) {
+	db := &emptyDB{}
+	hub := stream.NewHub(&config.NetworkConfig{Name: "mainnet"}, 8)
+	d := NewDispatcher(&config.WebhooksConfig{Enabled: true}, db, db, hub)
+	d.Start()
+	hub.Close()
+	stopWithin(t, d)
+}
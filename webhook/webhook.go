@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swarmbit/spacemesh-state-api/types"
+)
+
+const (
+	RewardEvent              = "reward"
+	IncomingTransactionEvent = "incoming_transaction"
+	MalfeasanceEvent         = "malfeasance"
+	MissingAtxEvent          = "missing_atx"
+)
+
+var Events = []string{RewardEvent, IncomingTransactionEvent, MalfeasanceEvent, MissingAtxEvent}
+
+const (
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+	IdHeader        = "X-Webhook-Id"
+)
+
+const minSecretLength = 16
+
+// NewWebhook validates a request and builds the webhook of owner, with a generated secret if it has none.
+func NewWebhook(owner string, request *types.WebhookRequest) (*types.WebhookDoc, error) {
+	target, err := url.Parse(request.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, errors.New("url must be an absolute http or https url")
+	}
+
+	account := strings.TrimSpace(request.Account)
+	node := strings.ToLower(strings.TrimSpace(request.Node))
+	switch request.Event {
+	case RewardEvent:
+		if account == "" && node == "" {
+			return nil, errors.New("reward webhooks need an account or a node")
+		}
+	case IncomingTransactionEvent:
+		if account == "" {
+			return nil, errors.New("incoming_transaction webhooks need an account")
+		}
+		node = ""
+	case MalfeasanceEvent, MissingAtxEvent:
+		if node == "" {
+			return nil, errors.New(request.Event + " webhooks need a node")
+		}
+		account = ""
+	default:
+		return nil, errors.New("event must be one of " + strings.Join(Events, ", "))
+	}
+
+	secret := request.Secret
+	if secret == "" {
+		secret, err = randomHex(24)
+		if err != nil {
+			return nil, err
+		}
+		secret = "whsec_" + secret
+	} else if len(secret) < minSecretLength {
+		return nil, errors.New("secret must be at least " + strconv.Itoa(minSecretLength) + " characters")
+	}
+
+	id, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	return &types.WebhookDoc{
+		ID:        id,
+		Owner:     owner,
+		Url:       target.String(),
+		Secret:    secret,
+		Event:     request.Event,
+		Account:   account,
+		Node:      node,
+		CreatedAt: time.Now().UnixMilli(),
+	}, nil
+}
+
+func WebhookResponse(doc *types.WebhookDoc) *types.Webhook {
+	return &types.Webhook{
+		ID:        doc.ID,
+		Url:       doc.Url,
+		Event:     doc.Event,
+		Account:   doc.Account,
+		Node:      doc.Node,
+		CreatedAt: doc.CreatedAt,
+	}
+}
+
+func DeliveryResponse(doc *types.WebhookDeliveryDoc) *types.WebhookDelivery {
+	return &types.WebhookDelivery{
+		ID:          doc.ID,
+		WebhookID:   doc.WebhookID,
+		Event:       doc.Event,
+		Status:      doc.Status,
+		Attempts:    doc.Attempts,
+		NextAttempt: doc.NextAttempt,
+		StatusCode:  doc.StatusCode,
+		LastError:   doc.LastError,
+		CreatedAt:   doc.CreatedAt,
+		DeliveredAt: doc.DeliveredAt,
+	}
+}
+
+// Sign returns the signature header of a delivery, the hex hmac-sha256 of "<timestamp>.<body>" with
+// the webhook secret. Receivers should also reject old timestamps to avoid replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliveryID is the same for an event delivered again to a webhook, the second one is not saved.
+func deliveryID(webhookID string, key string) string {
+	hash := sha256.Sum256([]byte(webhookID + "|" + key))
+	return hex.EncodeToString(hash[:16])
+}
+
+func randomHex(size int) (string, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(value), nil
+}
@@ -0,0 +1,20 @@
+package webhook
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	// computed with: printf '%s' '<timestamp>.<body>' | openssl dgst -sha256 -hmac '<secret>'
+	tests := []struct {
+		timestamp int64
+		body      string
+		signature string
+	}{
+		{1700000000, `{"event":"reward","id":"d1"}`, "sha256=c41a10533b7ee2efc23bbc11e31e6d47523c6af9ebb1b5c473cfe8f5530af4e5"},
+		{0, "", "sha256=2852ef72b5be70ea4bcd2762af295042abb372233d0fb219a7ae18fd7f2852b9"},
+	}
+	for _, test := range tests {
+		if signature := Sign("whsec_0123456789abcdef", test.timestamp, []byte(test.body)); signature != test.signature {
+			t.Errorf("signature of %d.%s %s, want %s", test.timestamp, test.body, signature, test.signature)
+		}
+	}
+}